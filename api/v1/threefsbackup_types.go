@@ -0,0 +1,106 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// BackupDestination is an S3-compatible object storage for FDB backups
+type BackupDestination struct {
+	// Endpoint is host:port of the object storage, e.g. minio.default.svc:9000
+	Endpoint string `json:"endpoint"`
+	Bucket   string `json:"bucket"`
+	// SecretName is the secret with accessKey and secretKey in the namespace of the backup
+	SecretName string `json:"secretName"`
+	Region     string `json:"region,omitempty"`
+	// Secure connects to the endpoint by https
+	Secure bool `json:"secure,omitempty"`
+}
+
+// BackupSchedule runs backups periodically
+type BackupSchedule struct {
+	// IntervalMinutes is the interval between starts of two backups
+	IntervalMinutes int `json:"intervalMinutes"`
+	// Suspend stops starting new backups
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// ThreeFsBackupSpec defines the desired state of ThreeFsBackup
+type ThreeFsBackupSpec struct {
+	ThreeFsClusterName      string            `json:"threeFsClusterName"`
+	ThreeFsClusterNamespace string            `json:"threeFsClusterNamespace"`
+	Destination             BackupDestination `json:"destination"`
+	// Schedule runs the backup periodically, only once if not set
+	Schedule *BackupSchedule `json:"schedule,omitempty"`
+	// AgentReplica is the number of backup agents, 1 by default
+	AgentReplica int `json:"agentReplica,omitempty"`
+	// HistoryLimit is the number of backup records kept in status, 10 by default
+	HistoryLimit int `json:"historyLimit,omitempty"`
+}
+
+// BackupRecord is a backup written to the destination
+type BackupRecord struct {
+	// Name is the backup name in the bucket, used by ThreeFsRestore
+	Name      string `json:"name"`
+	Phase     string `json:"phase"`
+	StartTime string `json:"startTime,omitempty"`
+	EndTime   string `json:"endTime,omitempty"`
+	// Size is the total bytes of snapshots
+	Size                 int64  `json:"size,omitempty"`
+	MinRestorableVersion int64  `json:"minRestorableVersion,omitempty"`
+	MaxRestorableVersion int64  `json:"maxRestorableVersion,omitempty"`
+	Message              string `json:"message,omitempty"`
+}
+
+// ThreeFsBackupStatus defines the observed state of ThreeFsBackup
+type ThreeFsBackupStatus struct {
+	Phase            string `json:"phase,omitempty"`
+	LastScheduleTime string `json:"lastScheduleTime,omitempty"`
+	// History records backups from the newest
+	History []BackupRecord `json:"history,omitempty"`
+	Message string         `json:"message,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=threefsbackups,shortName=tfsbk
+// +kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.spec.threeFsClusterName`,description="ThreeFs cluster name"
+// +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.phase`,description="ThreeFs backup status"
+// +kubebuilder:printcolumn:name="LastSchedule",type=string,JSONPath=`.status.lastScheduleTime`,description="ThreeFs backup last schedule time"
+
+// ThreeFsBackup is the Schema for the Threefsbackups API
+type ThreeFsBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ThreeFsBackupSpec   `json:"spec,omitempty"`
+	Status ThreeFsBackupStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ThreeFsBackupList contains a list of ThreeFsBackup
+type ThreeFsBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ThreeFsBackup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ThreeFsBackup{}, &ThreeFsBackupList{})
+}
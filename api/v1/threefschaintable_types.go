@@ -34,6 +34,20 @@ type ThreeFsChainTableSpec struct {
 	OldNode                 []string `json:"oldNode,omitempty"`
 	Type                    string   `json:"type"`
 	Force                   bool     `json:"force,omitempty"`
+	// Priority of the job in the queue, jobs with higher priority are processed first
+	Priority int `json:"priority,omitempty"`
+	// Abort stops the job and rolls back chains which are not replaced yet
+	Abort bool `json:"abort,omitempty"`
+	// Snapshot is the chain table snapshot name to re-upload, only for ChainTableRestore
+	Snapshot string `json:"snapshot,omitempty"`
+	// ChainTableId is the chain table the job works on, all chain tables of the new or old nodes if empty
+	ChainTableId string `json:"chainTableId,omitempty"`
+}
+
+// ChainTableSnapshotRef references chain table snapshots taken around a mutation
+type ChainTableSnapshotRef struct {
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
 }
 
 // ThreeFsChainTableStatus defines the observed state of ThreeFsChainTable
@@ -44,6 +58,12 @@ type ThreeFsChainTableStatus struct {
 	Process         string   `json:"process,omitempty"`
 	ProcessChainIds []string `json:"processChainIds,omitempty"`
 	Executed        bool     `json:"executed,omitempty"`
+	// QueuePosition is the position of the job in the pending queue, starts from 1
+	QueuePosition int `json:"queuePosition,omitempty"`
+	// Message records the result of the job when it is aborted
+	Message string `json:"message,omitempty"`
+	// Snapshots are chain table snapshots taken before and after the job
+	Snapshots ChainTableSnapshotRef `json:"snapshots,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -51,6 +71,7 @@ type ThreeFsChainTableStatus struct {
 // +kubebuilder:resource:path=threefschaintables,shortName=tfsct
 // +kubebuilder:printcolumn:name="Process",type=string,JSONPath=`.status.process`,description="ThreeFs chain table process"
 // +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.phase`,description="ThreeFs chain table status"
+// +kubebuilder:printcolumn:name="Queue",type=integer,JSONPath=`.status.queuePosition`,description="ThreeFs chain table queue position"
 
 // ThreeFsChainTable is the Schema for the Threefschaintables API
 type ThreeFsChainTable struct {
@@ -104,6 +125,21 @@ func (v *ThreeFsChainTable) WithForce(force bool) *ThreeFsChainTable {
 	return v
 }
 
+func (v *ThreeFsChainTable) WithPriority(priority int) *ThreeFsChainTable {
+	v.Spec.Priority = priority
+	return v
+}
+
+func (v *ThreeFsChainTable) WithAbort(abort bool) *ThreeFsChainTable {
+	v.Spec.Abort = abort
+	return v
+}
+
+func (v *ThreeFsChainTable) WithSnapshot(snapshot string) *ThreeFsChainTable {
+	v.Spec.Snapshot = snapshot
+	return v
+}
+
 func (v *ThreeFsChainTable) WithThreeFsCluster(name, namespace string) *ThreeFsChainTable {
 	v.Spec.ThreeFsClusterName = name
 	v.Spec.ThreeFsClusterNamespace = namespace
@@ -114,3 +150,8 @@ func (v *ThreeFsChainTable) WithLabels(labels map[string]string) *ThreeFsChainTa
 	v.Labels = labels
 	return v
 }
+
+func (v *ThreeFsChainTable) WithAnnotations(annotations map[string]string) *ThreeFsChainTable {
+	v.Annotations = annotations
+	return v
+}
@@ -25,6 +25,9 @@ import (
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
 type FdbSpec struct {
+	// Mode is builtin to run fdb on fdb nodes, external-operator to create a FoundationDBCluster for the
+	// FoundationDB kubernetes operator, or external to use an existing fdb cluster, builtin by default
+	Mode            string                      `json:"mode,omitempty"`
 	Nodes           []string                    `json:"nodes,omitempty"`
 	ConfigureNew    bool                        `json:"configureNew,omitempty"`
 	ClusterSize     int                         `json:"clusterSize,omitempty"`
@@ -32,6 +35,56 @@ type FdbSpec struct {
 	CoordinatorNum  int                         `json:"coordinatorNum,omitempty"`
 	Port            int                         `json:"port,omitempty"`
 	Resources       corev1.ResourceRequirements `json:"resources,omitempty"`
+	// StorageEngine is the storage engine of a new database, e.g. ssd-redwood-1, ssd by default
+	StorageEngine string `json:"storageEngine,omitempty"`
+	// Processes are fdb processes on each fdb node by process class (storage, log, transaction, stateless, unset),
+	// one unset process with Resources by default
+	Processes map[string]FdbProcessSpec `json:"processes,omitempty"`
+	// RoleCounts are recruited roles configured by fdbcli, fdb default if empty
+	RoleCounts FdbRoleCounts `json:"roleCounts,omitempty"`
+	// TopologyKey is the node label of failure domain that coordinators are spread across,
+	// e.g. topology.kubernetes.io/zone, each node is a failure domain if empty
+	TopologyKey string `json:"topologyKey,omitempty"`
+	// External is the existing fdb cluster of external mode
+	External *FdbExternalSpec `json:"external,omitempty"`
+	// Version is the fdb version, e.g. 7.3.63, changing it upgrades fdb processes together, 7.3.63 by default
+	Version string `json:"version,omitempty"`
+	// TLS runs fdb with mutual tls between fdb processes and clients
+	TLS *FdbTLSSpec `json:"tls,omitempty"`
+}
+
+// FdbTLSSpec is the tls of fdb, certificates are generated by the operator if CertificateRef is not set
+type FdbTLSSpec struct {
+	// Enabled can not be changed after creation
+	Enabled bool `json:"enabled,omitempty"`
+	// CertificateRef is a cert-manager Certificate in the namespace of the cluster, its secret has tls.crt,
+	// tls.key and ca.crt
+	CertificateRef string `json:"certificateRef,omitempty"`
+	// VerifyPeers is the fdb tls peer verification, Check.Valid=1 by default
+	VerifyPeers string `json:"verifyPeers,omitempty"`
+}
+
+// FdbExternalSpec is an existing fdb cluster managed outside of the operator
+type FdbExternalSpec struct {
+	// ClusterFileSecretRef is the secret key in the namespace of the cluster with content of the fdb cluster file
+	ClusterFileSecretRef *corev1.SecretKeySelector `json:"clusterFileSecretRef,omitempty"`
+	// ConnectionString is the content of the fdb cluster file, used if ClusterFileSecretRef is not set
+	ConnectionString string `json:"connectionString,omitempty"`
+}
+
+// FdbProcessSpec is the fdb processes of a process class on each node
+type FdbProcessSpec struct {
+	// Count is the number of processes on each node
+	Count     int                         `json:"count"`
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+	// DataPaths are host paths for data of the processes, processes are spread over them, /opt/3fs/fdb/data by default
+	DataPaths []string `json:"dataPaths,omitempty"`
+}
+
+type FdbRoleCounts struct {
+	Logs      int `json:"logs,omitempty"`
+	Proxies   int `json:"proxies,omitempty"`
+	Resolvers int `json:"resolvers,omitempty"`
 }
 
 type ClickhouseSpec struct {
@@ -51,15 +104,20 @@ type MonitorSpec struct {
 }
 
 type MgmtdSpec struct {
-	Nodes     []string                    `json:"nodes,omitempty"`
-	Replica   int                         `json:"replica"`
-	RdmaPort  int                         `json:"rdmaPort"`
-	TcpPort   int                         `json:"tcpPort"`
-	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+	Nodes []string `json:"nodes,omitempty"`
+	// NodeSelector selects dedicated nodes to run mgmtd, storage nodes are used if empty
+	NodeSelector map[string]string           `json:"nodeSelector,omitempty"`
+	Replica      int                         `json:"replica"`
+	RdmaPort     int                         `json:"rdmaPort"`
+	TcpPort      int                         `json:"tcpPort"`
+	Resources    corev1.ResourceRequirements `json:"resources,omitempty"`
 }
 
 type MetaSpec struct {
-	Nodes     []string                    `json:"nodes,omitempty"`
+	Nodes []string `json:"nodes,omitempty"`
+	// NodeSelector selects dedicated nodes to run meta, storage nodes are used if empty
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// Replica can be increased or decreased on a running cluster
 	Replica   int                         `json:"replica"`
 	RdmaPort  int                         `json:"rdmaPort"`
 	TcpPort   int                         `json:"tcpPort"`
@@ -75,6 +133,61 @@ type StorageSpec struct {
 	Replica       int                         `json:"replica"`
 	TargetPerDisk int                         `json:"targetPerDisk"`
 	Resources     corev1.ResourceRequirements `json:"resources,omitempty"`
+	// NodeGroups overrides disk layout of storage nodes matching the selector, nodes matching no group
+	// use TargetPaths and TargetPerDisk above
+	NodeGroups []StorageNodeGroup `json:"nodeGroups,omitempty"`
+	// Redundancy selects chain replication or erasure coding of chains, default is chain replication with Replica
+	Redundancy StorageRedundancy `json:"redundancy,omitempty"`
+	// TopologyKey is the node label of failure domain, e.g. topology.kubernetes.io/zone, targets of a chain
+	// or an EC group are spread across failure domains if set
+	TopologyKey string `json:"topologyKey,omitempty"`
+	// BackupNodePolicy selects the backup node which replaces a faulty storage node automatically
+	BackupNodePolicy BackupNodePolicy `json:"backupNodePolicy,omitempty"`
+}
+
+// BackupNodePolicy ranks backup nodes passing the pre-flight check (node ready, rdma device and target paths)
+type BackupNodePolicy struct {
+	// Strategies are applied in order to rank backup nodes: SameZone, MostFreeDisk or LeastRecentlyFailed,
+	// default SameZone
+	Strategies []string `json:"strategies,omitempty"`
+	// FailureCooldownMinutes excludes nodes which failed within the duration with LeastRecentlyFailed, default 1440
+	FailureCooldownMinutes int `json:"failureCooldownMinutes,omitempty"`
+}
+
+// StorageRedundancy is the data redundancy of chains
+type StorageRedundancy struct {
+	// Type is CR (chain replication) or EC (erasure coding), default CR
+	Type string `json:"type,omitempty"`
+	// DataChunks and ParityChunks are the number of data and parity chunks of an EC group, only used by EC
+	DataChunks   int `json:"dataChunks,omitempty"`
+	ParityChunks int `json:"parityChunks,omitempty"`
+}
+
+// StorageNodeGroup is the disk layout of a group of storage nodes
+type StorageNodeGroup struct {
+	Name string `json:"name"`
+	// NodeSelector selects storage nodes by labels, a node can only match one group
+	NodeSelector map[string]string `json:"nodeSelector"`
+	TargetPaths  []string          `json:"targetPaths"`
+	// TargetPerDisk defaults to TargetPerDisk of storage multiplied by Weight
+	TargetPerDisk int `json:"targetPerDisk,omitempty"`
+	// Weight is the capacity of one disk in percent of the default disk, default 100
+	Weight int `json:"weight,omitempty"`
+}
+
+// ChainTableSpec is a chain table built on the targets of a storage node group
+type ChainTableSpec struct {
+	// Id of the chain table, ChainTableId of cluster must be one of the chain tables
+	Id string `json:"id"`
+	// NodeGroup is the storage node group placed into the table, empty for nodes matching no group
+	NodeGroup string `json:"nodeGroup,omitempty"`
+	// Redundancy defaults to redundancy of storage
+	Redundancy StorageRedundancy `json:"redundancy,omitempty"`
+	// StripeSize and ChunkSize default to StripeSize and ChunkSize of cluster
+	StripeSize int `json:"stripeSize,omitempty"`
+	ChunkSize  int `json:"chunkSize,omitempty"`
+	// Path is created with the layout of the table if set, files under it are placed into the table
+	Path string `json:"path,omitempty"`
 }
 
 // ThreeFsClusterSpec defines the desired state of ThreeFsCluster
@@ -92,6 +205,45 @@ type ThreeFsClusterSpec struct {
 	Mgmtd        MgmtdSpec      `json:"mgmtd"`
 	Meta         MetaSpec       `json:"meta"`
 	Storage      StorageSpec    `json:"storage"`
+	// ChainTables splits storage node groups into different chain tables, all storage nodes are placed into
+	// chain table ChainTableId if empty
+	ChainTables []ChainTableSpec `json:"chainTables,omitempty"`
+	// AutoRepair configures automatic replacement of faulty storage nodes and failover of meta and mgmtd
+	AutoRepair AutoRepairSpec `json:"autoRepair,omitempty"`
+}
+
+// AutoRepairSpec configures automatic repair of faulty components, thresholds default to FAULT_DURATION env
+// of the operator in minutes
+type AutoRepairSpec struct {
+	// Enabled turns on automatic replacement of faulty storage nodes
+	Enabled bool `json:"enabled,omitempty"`
+	// NodeHeartbeatTimeoutMinutes is how long a storage node can lose heartbeat before it is replaced
+	NodeHeartbeatTimeoutMinutes int `json:"nodeHeartbeatTimeoutMinutes,omitempty"`
+	// TargetOfflineMinutes is how long a target can be OFFLINE before its storage node is replaced
+	TargetOfflineMinutes int `json:"targetOfflineMinutes,omitempty"`
+	// FailoverTimeoutMinutes is how long meta or mgmtd can lose heartbeat before it is moved to another node
+	FailoverTimeoutMinutes int `json:"failoverTimeoutMinutes,omitempty"`
+	// MaxConcurrentRepairs limits unfinished automatic replacements, default 1
+	MaxConcurrentRepairs int `json:"maxConcurrentRepairs,omitempty"`
+	// RateLimit limits automatic replacements started within a time window
+	RateLimit AutoRepairRateLimit `json:"rateLimit,omitempty"`
+	// MaintenanceWindows restrict automatic replacements to the windows if set
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty"`
+}
+
+// AutoRepairRateLimit allows at most MaxRepairs automatic replacements within WindowMinutes
+type AutoRepairRateLimit struct {
+	MaxRepairs    int `json:"maxRepairs,omitempty"`
+	WindowMinutes int `json:"windowMinutes,omitempty"`
+}
+
+// MaintenanceWindow is a daily time range in the time zone of the operator
+type MaintenanceWindow struct {
+	// Start and End are in HH:MM format, the window spans midnight if End is not after Start
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// Days are the weekdays the window starts on, e.g. Sat, every day if empty
+	Days []string `json:"days,omitempty"`
 }
 
 type ClusterStatus struct {
@@ -109,6 +261,10 @@ type FdbClusterStatus struct {
 	Status        string `json:"status"`
 	Msg           string `json:"msg,omitempty"`
 	FaultTime     string `json:"faultTime,omitempty"`
+	// Removal is the phase of removing fdb from the node, one of Excluding, Removing
+	Removal string `json:"removal,omitempty"`
+	// Address is the node ip excluded from fdb
+	Address string `json:"address,omitempty"`
 }
 
 type TargetStatus struct {
@@ -123,10 +279,32 @@ type UpgradeInfo struct {
 	Finished       bool              `json:"finished,omitempty"`
 }
 
+// DiskExpansion records the progress of adding target paths to existing storage nodes
+type DiskExpansion struct {
+	// TargetPaths are target paths which already have targets and chains
+	TargetPaths []string `json:"targetPaths,omitempty"`
+	// Phase is one of "", RollingVolumes, PlacingData
+	Phase string `json:"phase,omitempty"`
+	// Process is the number of storage deployments with new volumes, format: x/n
+	Process   string                `json:"process,omitempty"`
+	Snapshots ChainTableSnapshotRef `json:"snapshots,omitempty"`
+}
+
+// ChainTableStatus is the observed state of a chain table
+type ChainTableStatus struct {
+	Id string `json:"id"`
+	// Phase is Placed once chains of the table are uploaded
+	Phase    string `json:"phase,omitempty"`
+	ChainNum int    `json:"chainNum,omitempty"`
+}
+
 type NodesInfo struct {
 	StorageNodes       []string `json:"storageNodes,omitempty"`
 	StorageBackupNodes []string `json:"storageBackupNodes,omitempty"`
 	FdbNodes           []string `json:"fdbNodes,omitempty"`
+	// MgmtdNodes and MetaNodes are nodes chosen to run mgmtd and meta
+	MgmtdNodes []string `json:"mgmtdNodes,omitempty"`
+	MetaNodes  []string `json:"metaNodes,omitempty"`
 }
 
 // ThreeFsClusterStatus defines the observed state of ThreeFsCluster
@@ -142,6 +320,77 @@ type ThreeFsClusterStatus struct {
 	UnhealthyTargetStatus map[string][]TargetStatus           `json:"unhealthyTargetStatus,omitempty"`
 	NodesInfo             NodesInfo                           `json:"nodesInfo,omitempty"`
 	UpgradeInfo           UpgradeInfo                         `json:"upgradeInfo,omitempty"`
+	// DataPlacementSnapshots are chain table snapshots taken around the initial data placement
+	DataPlacementSnapshots ChainTableSnapshotRef `json:"dataPlacementSnapshots,omitempty"`
+	DiskExpansion          DiskExpansion         `json:"diskExpansion,omitempty"`
+	ChainTables            []ChainTableStatus    `json:"chainTables,omitempty"`
+	// StorageMaintenance records maintenance of storage nodes by node name
+	StorageMaintenance map[string]StorageMaintenance `json:"storageMaintenance,omitempty"`
+	// PrimaryMgmtd is the PRIMARY_MGMTD node in list-nodes
+	PrimaryMgmtd PrimaryMgmtd `json:"primaryMgmtd,omitempty"`
+	// FdbReconfiguration records the latest change of fdb storageReplicas or clusterSize
+	FdbReconfiguration FdbReconfiguration `json:"fdbReconfiguration,omitempty"`
+	// FdbCoordinators are addresses of the selected fdb coordinators
+	FdbCoordinators []string `json:"fdbCoordinators,omitempty"`
+	// FdbUpgrade records the running fdb version and the latest fdb version upgrade
+	FdbUpgrade FdbUpgrade `json:"fdbUpgrade,omitempty"`
+	// FdbTLS records the secret of fdb tls certificates
+	FdbTLS FdbTLSStatus `json:"fdbTLS,omitempty"`
+}
+
+// FdbTLSStatus is the fdb tls certificate in use
+type FdbTLSStatus struct {
+	SecretName string `json:"secretName,omitempty"`
+	// NotAfter is the expiration time of the certificate
+	NotAfter string `json:"notAfter,omitempty"`
+}
+
+// FdbUpgrade records an upgrade of fdb version
+type FdbUpgrade struct {
+	// Version is the running fdb version
+	Version       string `json:"version,omitempty"`
+	TargetVersion string `json:"targetVersion,omitempty"`
+	// Phase is one of StagingClients, Bouncing, Completed
+	Phase     string `json:"phase,omitempty"`
+	StartTime string `json:"startTime,omitempty"`
+	EndTime   string `json:"endTime,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+// FdbReconfiguration records a change of fdb redundancy mode or cluster size
+type FdbReconfiguration struct {
+	// RedundancyMode and ClusterSize are the target of the change
+	RedundancyMode string `json:"redundancyMode,omitempty"`
+	ClusterSize    int    `json:"clusterSize,omitempty"`
+	// Phase is one of AddingProcesses, Redistributing, Completed
+	Phase     string `json:"phase,omitempty"`
+	StartTime string `json:"startTime,omitempty"`
+	EndTime   string `json:"endTime,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+// PrimaryMgmtd records the primary mgmtd and the move of it
+type PrimaryMgmtd struct {
+	// NodeName is the kubernetes node running the primary mgmtd
+	NodeName string `json:"nodeName,omitempty"`
+	NodeId   string `json:"nodeId,omitempty"`
+	// Since is the time the primary is observed
+	Since string `json:"since,omitempty"`
+	// MovingFrom is the node the primary is moving away from by the mgmtd-move-primary annotation
+	MovingFrom string `json:"movingFrom,omitempty"`
+}
+
+// StorageMaintenance records a planned maintenance of a storage node
+type StorageMaintenance struct {
+	// Phase is one of Offlining, InMaintenance, Recovering, Completed
+	Phase string `json:"phase"`
+	// Reason is Annotation if the node has the storage-maintenance annotation, or Cordon if it is unschedulable
+	Reason string `json:"reason,omitempty"`
+	// OfflineTargets are targets offlined before maintenance
+	OfflineTargets []string `json:"offlineTargets,omitempty"`
+	StartTime      string   `json:"startTime,omitempty"`
+	EndTime        string   `json:"endTime,omitempty"`
+	Message        string   `json:"message,omitempty"`
 }
 
 // +kubebuilder:object:root=true
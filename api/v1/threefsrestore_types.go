@@ -0,0 +1,70 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ThreeFsRestoreSpec defines the desired state of ThreeFsRestore
+type ThreeFsRestoreSpec struct {
+	// ThreeFsClusterName is a new cluster to restore into, mgmtd waits until the restore is completed
+	ThreeFsClusterName      string            `json:"threeFsClusterName"`
+	ThreeFsClusterNamespace string            `json:"threeFsClusterNamespace"`
+	Source                  BackupDestination `json:"source"`
+	// BackupName is the backup name in the bucket, as recorded in the history of ThreeFsBackup
+	BackupName string `json:"backupName"`
+	// Version restores to the version, the max restorable version if not set
+	Version int64 `json:"version,omitempty"`
+}
+
+// ThreeFsRestoreStatus defines the observed state of ThreeFsRestore
+type ThreeFsRestoreStatus struct {
+	Phase     string `json:"phase,omitempty"`
+	StartTime string `json:"startTime,omitempty"`
+	EndTime   string `json:"endTime,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=threefsrestores,shortName=tfsrs
+// +kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.spec.threeFsClusterName`,description="ThreeFs cluster name"
+// +kubebuilder:printcolumn:name="Backup",type=string,JSONPath=`.spec.backupName`,description="ThreeFs backup name"
+// +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.phase`,description="ThreeFs restore status"
+
+// ThreeFsRestore is the Schema for the Threefsrestores API
+type ThreeFsRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ThreeFsRestoreSpec   `json:"spec,omitempty"`
+	Status ThreeFsRestoreStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ThreeFsRestoreList contains a list of ThreeFsRestore
+type ThreeFsRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ThreeFsRestore `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ThreeFsRestore{}, &ThreeFsRestoreList{})
+}
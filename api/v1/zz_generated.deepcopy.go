@@ -21,9 +21,159 @@ limitations under the License.
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AutoRepairRateLimit) DeepCopyInto(out *AutoRepairRateLimit) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoRepairRateLimit.
+func (in *AutoRepairRateLimit) DeepCopy() *AutoRepairRateLimit {
+	if in == nil {
+		return nil
+	}
+	out := new(AutoRepairRateLimit)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AutoRepairSpec) DeepCopyInto(out *AutoRepairSpec) {
+	*out = *in
+	out.RateLimit = in.RateLimit
+	if in.MaintenanceWindows != nil {
+		in, out := &in.MaintenanceWindows, &out.MaintenanceWindows
+		*out = make([]MaintenanceWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoRepairSpec.
+func (in *AutoRepairSpec) DeepCopy() *AutoRepairSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AutoRepairSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupDestination) DeepCopyInto(out *BackupDestination) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupDestination.
+func (in *BackupDestination) DeepCopy() *BackupDestination {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupDestination)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupNodePolicy) DeepCopyInto(out *BackupNodePolicy) {
+	*out = *in
+	if in.Strategies != nil {
+		in, out := &in.Strategies, &out.Strategies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupNodePolicy.
+func (in *BackupNodePolicy) DeepCopy() *BackupNodePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupNodePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupRecord) DeepCopyInto(out *BackupRecord) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupRecord.
+func (in *BackupRecord) DeepCopy() *BackupRecord {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupRecord)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupSchedule) DeepCopyInto(out *BackupSchedule) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupSchedule.
+func (in *BackupSchedule) DeepCopy() *BackupSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChainTableSpec) DeepCopyInto(out *ChainTableSpec) {
+	*out = *in
+	out.Redundancy = in.Redundancy
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChainTableSpec.
+func (in *ChainTableSpec) DeepCopy() *ChainTableSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ChainTableSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChainTableStatus) DeepCopyInto(out *ChainTableStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChainTableStatus.
+func (in *ChainTableStatus) DeepCopy() *ChainTableStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ChainTableStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChainTableSnapshotRef) DeepCopyInto(out *ChainTableSnapshotRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChainTableSnapshotRef.
+func (in *ChainTableSnapshotRef) DeepCopy() *ChainTableSnapshotRef {
+	if in == nil {
+		return nil
+	}
+	out := new(ChainTableSnapshotRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClickhouseSpec) DeepCopyInto(out *ClickhouseSpec) {
 	*out = *in
@@ -60,6 +210,27 @@ func (in *ClusterStatus) DeepCopy() *ClusterStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DiskExpansion) DeepCopyInto(out *DiskExpansion) {
+	*out = *in
+	if in.TargetPaths != nil {
+		in, out := &in.TargetPaths, &out.TargetPaths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	out.Snapshots = in.Snapshots
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiskExpansion.
+func (in *DiskExpansion) DeepCopy() *DiskExpansion {
+	if in == nil {
+		return nil
+	}
+	out := new(DiskExpansion)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FdbClusterStatus) DeepCopyInto(out *FdbClusterStatus) {
 	*out = *in
@@ -75,6 +246,77 @@ func (in *FdbClusterStatus) DeepCopy() *FdbClusterStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FdbExternalSpec) DeepCopyInto(out *FdbExternalSpec) {
+	*out = *in
+	if in.ClusterFileSecretRef != nil {
+		in, out := &in.ClusterFileSecretRef, &out.ClusterFileSecretRef
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FdbExternalSpec.
+func (in *FdbExternalSpec) DeepCopy() *FdbExternalSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FdbExternalSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FdbProcessSpec) DeepCopyInto(out *FdbProcessSpec) {
+	*out = *in
+	in.Resources.DeepCopyInto(&out.Resources)
+	if in.DataPaths != nil {
+		in, out := &in.DataPaths, &out.DataPaths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FdbProcessSpec.
+func (in *FdbProcessSpec) DeepCopy() *FdbProcessSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FdbProcessSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FdbReconfiguration) DeepCopyInto(out *FdbReconfiguration) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FdbReconfiguration.
+func (in *FdbReconfiguration) DeepCopy() *FdbReconfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(FdbReconfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FdbRoleCounts) DeepCopyInto(out *FdbRoleCounts) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FdbRoleCounts.
+func (in *FdbRoleCounts) DeepCopy() *FdbRoleCounts {
+	if in == nil {
+		return nil
+	}
+	out := new(FdbRoleCounts)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FdbSpec) DeepCopyInto(out *FdbSpec) {
 	*out = *in
@@ -84,6 +326,24 @@ func (in *FdbSpec) DeepCopyInto(out *FdbSpec) {
 		copy(*out, *in)
 	}
 	in.Resources.DeepCopyInto(&out.Resources)
+	if in.Processes != nil {
+		in, out := &in.Processes, &out.Processes
+		*out = make(map[string]FdbProcessSpec, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	out.RoleCounts = in.RoleCounts
+	if in.External != nil {
+		in, out := &in.External, &out.External
+		*out = new(FdbExternalSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(FdbTLSSpec)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FdbSpec.
@@ -96,6 +356,71 @@ func (in *FdbSpec) DeepCopy() *FdbSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FdbTLSSpec) DeepCopyInto(out *FdbTLSSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FdbTLSSpec.
+func (in *FdbTLSSpec) DeepCopy() *FdbTLSSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FdbTLSSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FdbTLSStatus) DeepCopyInto(out *FdbTLSStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FdbTLSStatus.
+func (in *FdbTLSStatus) DeepCopy() *FdbTLSStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(FdbTLSStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FdbUpgrade) DeepCopyInto(out *FdbUpgrade) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FdbUpgrade.
+func (in *FdbUpgrade) DeepCopy() *FdbUpgrade {
+	if in == nil {
+		return nil
+	}
+	out := new(FdbUpgrade)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindow) DeepCopyInto(out *MaintenanceWindow) {
+	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindow.
+func (in *MaintenanceWindow) DeepCopy() *MaintenanceWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MetaSpec) DeepCopyInto(out *MetaSpec) {
 	*out = *in
@@ -104,6 +429,13 @@ func (in *MetaSpec) DeepCopyInto(out *MetaSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	in.Resources.DeepCopyInto(&out.Resources)
 }
 
@@ -125,6 +457,13 @@ func (in *MgmtdSpec) DeepCopyInto(out *MgmtdSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	in.Resources.DeepCopyInto(&out.Resources)
 }
 
@@ -172,6 +511,16 @@ func (in *NodesInfo) DeepCopyInto(out *NodesInfo) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.MgmtdNodes != nil {
+		in, out := &in.MgmtdNodes, &out.MgmtdNodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MetaNodes != nil {
+		in, out := &in.MetaNodes, &out.MetaNodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodesInfo.
@@ -184,6 +533,83 @@ func (in *NodesInfo) DeepCopy() *NodesInfo {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrimaryMgmtd) DeepCopyInto(out *PrimaryMgmtd) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrimaryMgmtd.
+func (in *PrimaryMgmtd) DeepCopy() *PrimaryMgmtd {
+	if in == nil {
+		return nil
+	}
+	out := new(PrimaryMgmtd)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageMaintenance) DeepCopyInto(out *StorageMaintenance) {
+	*out = *in
+	if in.OfflineTargets != nil {
+		in, out := &in.OfflineTargets, &out.OfflineTargets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageMaintenance.
+func (in *StorageMaintenance) DeepCopy() *StorageMaintenance {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageMaintenance)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageNodeGroup) DeepCopyInto(out *StorageNodeGroup) {
+	*out = *in
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.TargetPaths != nil {
+		in, out := &in.TargetPaths, &out.TargetPaths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageNodeGroup.
+func (in *StorageNodeGroup) DeepCopy() *StorageNodeGroup {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageNodeGroup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageRedundancy) DeepCopyInto(out *StorageRedundancy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageRedundancy.
+func (in *StorageRedundancy) DeepCopy() *StorageRedundancy {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageRedundancy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StorageSpec) DeepCopyInto(out *StorageSpec) {
 	*out = *in
@@ -203,6 +629,15 @@ func (in *StorageSpec) DeepCopyInto(out *StorageSpec) {
 		copy(*out, *in)
 	}
 	in.Resources.DeepCopyInto(&out.Resources)
+	if in.NodeGroups != nil {
+		in, out := &in.NodeGroups, &out.NodeGroups
+		*out = make([]StorageNodeGroup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	out.Redundancy = in.Redundancy
+	in.BackupNodePolicy.DeepCopyInto(&out.BackupNodePolicy)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageSpec.
@@ -259,6 +694,106 @@ func (in *UpgradeInfo) DeepCopy() *UpgradeInfo {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThreeFsBackup) DeepCopyInto(out *ThreeFsBackup) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThreeFsBackup.
+func (in *ThreeFsBackup) DeepCopy() *ThreeFsBackup {
+	if in == nil {
+		return nil
+	}
+	out := new(ThreeFsBackup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ThreeFsBackup) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThreeFsBackupList) DeepCopyInto(out *ThreeFsBackupList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ThreeFsBackup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThreeFsBackupList.
+func (in *ThreeFsBackupList) DeepCopy() *ThreeFsBackupList {
+	if in == nil {
+		return nil
+	}
+	out := new(ThreeFsBackupList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ThreeFsBackupList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThreeFsBackupSpec) DeepCopyInto(out *ThreeFsBackupSpec) {
+	*out = *in
+	out.Destination = in.Destination
+	if in.Schedule != nil {
+		in, out := &in.Schedule, &out.Schedule
+		*out = new(BackupSchedule)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThreeFsBackupSpec.
+func (in *ThreeFsBackupSpec) DeepCopy() *ThreeFsBackupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ThreeFsBackupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThreeFsBackupStatus) DeepCopyInto(out *ThreeFsBackupStatus) {
+	*out = *in
+	if in.History != nil {
+		in, out := &in.History, &out.History
+		*out = make([]BackupRecord, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThreeFsBackupStatus.
+func (in *ThreeFsBackupStatus) DeepCopy() *ThreeFsBackupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ThreeFsBackupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ThreeFsChainTable) DeepCopyInto(out *ThreeFsChainTable) {
 	*out = *in
@@ -351,6 +886,7 @@ func (in *ThreeFsChainTableStatus) DeepCopyInto(out *ThreeFsChainTableStatus) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	out.Snapshots = in.Snapshots
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThreeFsChainTableStatus.
@@ -431,6 +967,12 @@ func (in *ThreeFsClusterSpec) DeepCopyInto(out *ThreeFsClusterSpec) {
 	in.Mgmtd.DeepCopyInto(&out.Mgmtd)
 	in.Meta.DeepCopyInto(&out.Meta)
 	in.Storage.DeepCopyInto(&out.Storage)
+	if in.ChainTables != nil {
+		in, out := &in.ChainTables, &out.ChainTables
+		*out = make([]ChainTableSpec, len(*in))
+		copy(*out, *in)
+	}
+	in.AutoRepair.DeepCopyInto(&out.AutoRepair)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThreeFsClusterSpec.
@@ -496,6 +1038,29 @@ func (in *ThreeFsClusterStatus) DeepCopyInto(out *ThreeFsClusterStatus) {
 	}
 	in.NodesInfo.DeepCopyInto(&out.NodesInfo)
 	in.UpgradeInfo.DeepCopyInto(&out.UpgradeInfo)
+	out.DataPlacementSnapshots = in.DataPlacementSnapshots
+	in.DiskExpansion.DeepCopyInto(&out.DiskExpansion)
+	if in.ChainTables != nil {
+		in, out := &in.ChainTables, &out.ChainTables
+		*out = make([]ChainTableStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.StorageMaintenance != nil {
+		in, out := &in.StorageMaintenance, &out.StorageMaintenance
+		*out = make(map[string]StorageMaintenance, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	out.PrimaryMgmtd = in.PrimaryMgmtd
+	out.FdbReconfiguration = in.FdbReconfiguration
+	if in.FdbCoordinators != nil {
+		in, out := &in.FdbCoordinators, &out.FdbCoordinators
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	out.FdbUpgrade = in.FdbUpgrade
+	out.FdbTLS = in.FdbTLS
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThreeFsClusterStatus.
@@ -507,3 +1072,93 @@ func (in *ThreeFsClusterStatus) DeepCopy() *ThreeFsClusterStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThreeFsRestore) DeepCopyInto(out *ThreeFsRestore) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThreeFsRestore.
+func (in *ThreeFsRestore) DeepCopy() *ThreeFsRestore {
+	if in == nil {
+		return nil
+	}
+	out := new(ThreeFsRestore)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ThreeFsRestore) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThreeFsRestoreList) DeepCopyInto(out *ThreeFsRestoreList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ThreeFsRestore, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThreeFsRestoreList.
+func (in *ThreeFsRestoreList) DeepCopy() *ThreeFsRestoreList {
+	if in == nil {
+		return nil
+	}
+	out := new(ThreeFsRestoreList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ThreeFsRestoreList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThreeFsRestoreSpec) DeepCopyInto(out *ThreeFsRestoreSpec) {
+	*out = *in
+	out.Source = in.Source
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThreeFsRestoreSpec.
+func (in *ThreeFsRestoreSpec) DeepCopy() *ThreeFsRestoreSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ThreeFsRestoreSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThreeFsRestoreStatus) DeepCopyInto(out *ThreeFsRestoreStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThreeFsRestoreStatus.
+func (in *ThreeFsRestoreStatus) DeepCopy() *ThreeFsRestoreStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ThreeFsRestoreStatus)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -52,6 +52,7 @@ import (
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
+	fdbv1beta2 "github.com/FoundationDB/fdb-kubernetes-operator/api/v1beta2"
 	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
 	"github.com/aliyun/kvc-3fs-operator/internal/controller"
 	// +kubebuilder:scaffold:imports
@@ -66,6 +67,7 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(threefsv1.AddToScheme(scheme))
+	utilruntime.Must(fdbv1beta2.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -199,6 +201,22 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "ThreeFsCluster")
 		os.Exit(1)
 	}
+	if err = (&controller.ThreeFsBackupReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("vfsc-operator"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ThreeFsBackup")
+		os.Exit(1)
+	}
+	if err = (&controller.ThreeFsRestoreReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("vfsc-operator"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ThreeFsRestore")
+		os.Exit(1)
+	}
 
 	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
 		setupLog.Info("starting webhook")
@@ -210,6 +228,10 @@ func main() {
 			setupLog.Error(err, "unable to create pod webhook", "webhook", "ThreeFsCluster")
 			os.Exit(1)
 		}
+		if err = webhook2.SetupPodEvictionWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create pod eviction webhook", "webhook", "Eviction")
+			os.Exit(1)
+		}
 	}
 	// +kubebuilder:scaffold:builder
 
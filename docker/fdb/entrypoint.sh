@@ -67,7 +67,17 @@ create_server_environment
 source /var/lib/foundationdb/.fdbenv
 echo "Starting FDB server on $PUBLIC_IP:$FDB_PORT"
 
+# processes rendered by operator
+if [[ -n "$FDB_MONITOR_CONF" ]]; then
+    echo "$FDB_MONITOR_CONF" > /etc/foundationdb/foundationdb.conf
+fi
+
 sed -i "s|\${PUBLIC_IP}|${PUBLIC_IP}|g" /etc/foundationdb/foundationdb.conf
 sed -i "s|\${MACHINE_ID}|$(hostname)|g" /etc/foundationdb/foundationdb.conf
 
+# fdb processes listen on tls addresses if certificates are mounted by operator
+if [[ -n "$FDB_TLS_CERTIFICATE_FILE" ]]; then
+    sed -i -E 's/^(public-address|listen-address)( *= *[^ ]+:\$ID)$/\1\2:tls/' /etc/foundationdb/foundationdb.conf
+fi
+
 /usr/lib/foundationdb/fdbmonitor --conffile /etc/foundationdb/foundationdb.conf --lockfile /var/run/fdbmonitor.pid
\ No newline at end of file
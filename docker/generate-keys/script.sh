@@ -58,6 +58,7 @@ export $THREE_FS_OPERATOR_NAMESPACE="${THREE_FS_OPERATOR_NAMESPACE:-dlf}"
 export MUTATE_PATH="${MUTATE_PATH:-/mutate--v1-pod}"
 export VALIDATE_PATH="${VALIDATE_PATH:-/validate-threefs-aliyun-com-v1-threefscluster}"
 export VALIDATE_PATH2="${VALIDATE_PATH2:-/validate-threefs-aliyun-com-v1-threefschaintable}"
+export EVICTION_PATH="${EVICTION_PATH:-/validate--v1-pod-eviction}"
 
 kubectl -n $THREE_FS_OPERATOR_NAMESPACE create secret tls $WEBHOOK_SECRET_NAME \
             --cert "/tmp/dlf-keys/webhook-server-tls.crt" \
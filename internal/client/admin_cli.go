@@ -107,7 +107,7 @@ func (ac *AdminCliConfig) CreateTarget(token, filePath string) error {
 	return err
 }
 
-func (ac *AdminCliConfig) DumpChainTable(token, chaintablePath string) error {
+func (ac *AdminCliConfig) DumpChainTable(token, chainTableId, chaintablePath string) error {
 	os.MkdirAll(filepath.Dir(chaintablePath), 0755)
 	if _, err := os.Stat(chaintablePath); err == nil {
 		if err := os.Remove(chaintablePath); err != nil {
@@ -129,7 +129,7 @@ func (ac *AdminCliConfig) DumpChainTable(token, chaintablePath string) error {
 			"--config.mgmtd_client.mgmtd_server_addresses", fmt.Sprintf("%s", ac.MgmtdServerAddresses),
 			"--config.user_info.token", token,
 			"--",
-			fmt.Sprintf("dump-chain-table 1 %s", chaintablePath),
+			fmt.Sprintf("dump-chain-table %s %s", chainTableId, chaintablePath),
 		},
 		Timeout: 10 * time.Second,
 	}
@@ -170,7 +170,7 @@ func (ac *AdminCliConfig) DumpChains(token, chainPath string) error {
 	return err
 }
 
-func (ac *AdminCliConfig) UploadChainTable(token, chaintablePath string) error {
+func (ac *AdminCliConfig) UploadChainTable(token, chainTableId, desc, chaintablePath string) error {
 	command := CommandRunner{
 		Command: "/admin_cli",
 		Args: []string{
@@ -178,7 +178,7 @@ func (ac *AdminCliConfig) UploadChainTable(token, chaintablePath string) error {
 			"--config.mgmtd_client.mgmtd_server_addresses", fmt.Sprintf("%s", ac.MgmtdServerAddresses),
 			"--config.user_info.token", token,
 			"--",
-			fmt.Sprintf("upload-chain-table --desc stage 1 %s", chaintablePath),
+			fmt.Sprintf("upload-chain-table --desc %s %s %s", desc, chainTableId, chaintablePath),
 		},
 		Timeout: 10 * time.Second,
 	}
@@ -186,6 +186,27 @@ func (ac *AdminCliConfig) UploadChainTable(token, chaintablePath string) error {
 	return err
 }
 
+// MkdirWithLayout creates the directory with the chain table layout, files created under it use the chain table
+func (ac *AdminCliConfig) MkdirWithLayout(token, path, chainTableId string, stripeSize, chunkSize int) error {
+	command := CommandRunner{
+		Command: "/admin_cli",
+		Args: []string{
+			"-cfg", ac.ConfigPath,
+			"--config.mgmtd_client.mgmtd_server_addresses", fmt.Sprintf("%s", ac.MgmtdServerAddresses),
+			"--config.user_info.token", token,
+			"--",
+			fmt.Sprintf("mkdir --recursive --chain-table-id %s --chunk-size %d --stripe-size %d %s", chainTableId, chunkSize, stripeSize, path),
+		},
+		Timeout: 10 * time.Second,
+	}
+	output, errStr, err := command.Exec(context.Background())
+	klog.Infof("mkdir output: %s", output)
+	if strings.Contains(output, "Exists") || strings.Contains(errStr, "Exists") {
+		return nil
+	}
+	return err
+}
+
 func (ac *AdminCliConfig) ListNodes() (string, error) {
 	command := CommandRunner{
 		Command: "/admin_cli",
@@ -0,0 +1,191 @@
+package clientcomm
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	fdbv1beta2 "github.com/FoundationDB/fdb-kubernetes-operator/api/v1beta2"
+	"k8s.io/apimachinery/pkg/util/json"
+	"k8s.io/klog/v2"
+)
+
+type FdbBackupConfig struct {
+	ClusterFile     string         `json:"cluster_file"`
+	CredentialsFile string         `json:"credentials_file"`
+	TLS             *FdbTLSOptions `json:"tls"`
+}
+
+// FdbBackupPoint is a restorable point in the output of fdbbackup describe
+type FdbBackupPoint struct {
+	Version   int64  `json:"Version"`
+	Timestamp string `json:"Timestamp,omitempty"`
+}
+
+// FdbBackupSnapshot is a snapshot in the output of fdbbackup describe
+type FdbBackupSnapshot struct {
+	Complete   bool  `json:"Complete"`
+	Restorable bool  `json:"Restorable"`
+	TotalBytes int64 `json:"TotalBytes"`
+}
+
+// FdbBackupDescription is the output of fdbbackup describe --json
+type FdbBackupDescription struct {
+	URL                string              `json:"URL"`
+	Restorable         bool                `json:"Restorable"`
+	MinRestorablePoint FdbBackupPoint      `json:"MinRestorablePoint"`
+	MaxRestorablePoint FdbBackupPoint      `json:"MaxRestorablePoint"`
+	Snapshots          []FdbBackupSnapshot `json:"Snapshots"`
+}
+
+// SnapshotBytes returns total bytes of all snapshots
+func (d *FdbBackupDescription) SnapshotBytes() int64 {
+	var size int64
+	for _, snapshot := range d.Snapshots {
+		size += snapshot.TotalBytes
+	}
+	return size
+}
+
+var restoreStateRegexp = regexp.MustCompile(`State:\s*(\S+)`)
+
+func NewFdbBackupConfig(clusterFile, credentialsFile string) *FdbBackupConfig {
+	return &FdbBackupConfig{
+		ClusterFile:     clusterFile,
+		CredentialsFile: credentialsFile,
+	}
+}
+
+func (fb *FdbBackupConfig) WithTLS(options *FdbTLSOptions) *FdbBackupConfig {
+	fb.TLS = options
+	return fb
+}
+
+// withTLSArgs returns args of the action followed by tls options and flags, fdbbackup and fdbrestore read the
+// action from the first argument
+func (fb *FdbBackupConfig) withTLSArgs(action []string, flags ...string) []string {
+	args := append(action, fb.TLS.Args()...)
+	return append(args, flags...)
+}
+
+func (fb *FdbBackupConfig) getStartBackupArgs(url, tag string) []string {
+	return fb.withTLSArgs([]string{"start"},
+		"-C", fb.ClusterFile,
+		"-d", url,
+		"-t", tag,
+		"--blob-credentials", fb.CredentialsFile,
+	)
+}
+
+func (fb *FdbBackupConfig) getStartRestoreArgs(url, tag string, version int64) []string {
+	args := fb.withTLSArgs([]string{"start"},
+		"-r", url,
+		"--dest-cluster-file", fb.ClusterFile,
+		"-t", tag,
+		"--blob-credentials", fb.CredentialsFile,
+	)
+	if version > 0 {
+		args = append(args, "-v", strconv.FormatInt(version, 10))
+	}
+	return args
+}
+
+// StartBackup submits a backup to url, it stops when the backup is restorable
+func (fb *FdbBackupConfig) StartBackup(url, tag string) error {
+	command := CommandRunner{
+		Command: "fdbbackup",
+		Args:    fb.getStartBackupArgs(url, tag),
+		Timeout: 30 * time.Second,
+	}
+	_, _, err := command.Exec(context.Background())
+	return err
+}
+
+// GetBackupStatus returns status of the backup with tag
+func (fb *FdbBackupConfig) GetBackupStatus(tag string) (*fdbv1beta2.FoundationDBLiveBackupStatus, error) {
+	command := CommandRunner{
+		Command: "fdbbackup",
+		Args: fb.withTLSArgs([]string{"status"},
+			"-C", fb.ClusterFile,
+			"-t", tag,
+			"--json",
+		),
+		Timeout: 30 * time.Second,
+	}
+	output, _, err := command.Exec(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	status := &fdbv1beta2.FoundationDBLiveBackupStatus{}
+	if err := json.Unmarshal([]byte(output), status); err != nil {
+		klog.Errorf("parse backup status %s failed: %v", output, err)
+		return nil, err
+	}
+	return status, nil
+}
+
+// DescribeBackup returns size and restorable versions of the backup in url
+func (fb *FdbBackupConfig) DescribeBackup(url string) (*FdbBackupDescription, error) {
+	command := CommandRunner{
+		Command: "fdbbackup",
+		Args: fb.withTLSArgs([]string{"describe"},
+			"-C", fb.ClusterFile,
+			"-d", url,
+			"--blob-credentials", fb.CredentialsFile,
+			"--json",
+		),
+		Timeout: 60 * time.Second,
+	}
+	output, _, err := command.Exec(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return ParseBackupDescription(output)
+}
+
+// StartRestore restores the backup in url into the cluster, to the max restorable version if version is 0
+func (fb *FdbBackupConfig) StartRestore(url, tag string, version int64) error {
+	command := CommandRunner{
+		Command: "fdbrestore",
+		Args:    fb.getStartRestoreArgs(url, tag, version),
+		Timeout: 60 * time.Second,
+	}
+	_, _, err := command.Exec(context.Background())
+	return err
+}
+
+// GetRestoreState returns state of the restore with tag, e.g. running or completed
+func (fb *FdbBackupConfig) GetRestoreState(tag string) (string, error) {
+	command := CommandRunner{
+		Command: "fdbrestore",
+		Args: fb.withTLSArgs([]string{"status"},
+			"--dest-cluster-file", fb.ClusterFile,
+			"-t", tag,
+		),
+		Timeout: 30 * time.Second,
+	}
+	output, _, err := command.Exec(context.Background())
+	if err != nil {
+		return "", err
+	}
+	return ParseRestoreState(output)
+}
+
+func ParseBackupDescription(output string) (*FdbBackupDescription, error) {
+	description := &FdbBackupDescription{}
+	if err := json.Unmarshal([]byte(output), description); err != nil {
+		klog.Errorf("parse backup description %s failed: %v", output, err)
+		return nil, err
+	}
+	return description, nil
+}
+
+func ParseRestoreState(output string) (string, error) {
+	matches := restoreStateRegexp.FindStringSubmatch(output)
+	if len(matches) < 2 {
+		return "", fmt.Errorf("restore state not found in %q", output)
+	}
+	return matches[1], nil
+}
@@ -0,0 +1,33 @@
+package clientcomm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFdbBackupArgs(t *testing.T) {
+	tls := &FdbTLSOptions{CertificateFile: "/tls/tls.crt", KeyFile: "/tls/tls.key", CAFile: "/tls/ca.crt", VerifyPeers: "Check.Valid=1"}
+	tlsArgs := []string{"--tls_certificate_file", "/tls/tls.crt", "--tls_key_file", "/tls/tls.key", "--tls_ca_file", "/tls/ca.crt", "--tls_verify_peers", "Check.Valid=1"}
+
+	fb := NewFdbBackupConfig("/fdb.cluster", "/credentials.json")
+	assert.Equal(t, []string{"start", "-C", "/fdb.cluster", "-d", "blobstore://s3/backup", "-t", "tag", "--blob-credentials", "/credentials.json"},
+		fb.getStartBackupArgs("blobstore://s3/backup", "tag"))
+	assert.Equal(t, []string{"start", "-r", "blobstore://s3/backup", "--dest-cluster-file", "/fdb.cluster", "-t", "tag", "--blob-credentials", "/credentials.json"},
+		fb.getStartRestoreArgs("blobstore://s3/backup", "tag", 0))
+
+	// the action stays the first argument with tls
+	fb.WithTLS(tls)
+	args := fb.getStartBackupArgs("blobstore://s3/backup", "tag")
+	assert.Equal(t, "start", args[0])
+	assert.Equal(t, tlsArgs, args[1:9])
+	assert.Equal(t, []string{"-C", "/fdb.cluster", "-d", "blobstore://s3/backup", "-t", "tag", "--blob-credentials", "/credentials.json"}, args[9:])
+
+	args = fb.getStartRestoreArgs("blobstore://s3/backup", "tag", 100)
+	assert.Equal(t, "start", args[0])
+	assert.Equal(t, tlsArgs, args[1:9])
+	assert.Equal(t, []string{"-v", "100"}, args[len(args)-2:])
+
+	args = fb.withTLSArgs([]string{"status"}, "-t", "tag")
+	assert.Equal(t, append(append([]string{"status"}, tlsArgs...), "-t", "tag"), args)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	fdbv1beta2 "github.com/FoundationDB/fdb-kubernetes-operator/api/v1beta2"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
 	"k8s.io/apimachinery/pkg/util/json"
 	"k8s.io/client-go/rest"
 	"k8s.io/klog/v2"
@@ -16,6 +17,29 @@ type FdbcliConfig struct {
 	ReplicaNum     int            `json:"replica_num"`
 	CoordinatorNum int            `json:"coordinator_num"`
 	RestClient     rest.Interface `json:"rest_client"`
+	StorageEngine  string         `json:"storage_engine"`
+	TLS            *FdbTLSOptions `json:"tls"`
+}
+
+// FdbTLSOptions are tls files passed to fdbcli, fdbbackup and fdbrestore
+type FdbTLSOptions struct {
+	CertificateFile string `json:"certificate_file"`
+	KeyFile         string `json:"key_file"`
+	CAFile          string `json:"ca_file"`
+	VerifyPeers     string `json:"verify_peers"`
+}
+
+// Args returns tls options of fdb tools, nil if tls is disabled
+func (o *FdbTLSOptions) Args() []string {
+	if o == nil {
+		return nil
+	}
+	return []string{
+		"--tls_certificate_file", o.CertificateFile,
+		"--tls_key_file", o.KeyFile,
+		"--tls_ca_file", o.CAFile,
+		"--tls_verify_peers", o.VerifyPeers,
+	}
 }
 
 func NewFdbCliConfig(configPath string, replicaNum, coordinatorNum int, restClient rest.Interface) *FdbcliConfig {
@@ -27,46 +51,255 @@ func NewFdbCliConfig(configPath string, replicaNum, coordinatorNum int, restClie
 	}
 }
 
-func (fc *FdbcliConfig) CreateNewDb() (string, string, error) {
+// GetRedundancyMode returns the fdb redundancy mode of storage replicas
+func GetRedundancyMode(replicaNum int) string {
 	maps := map[int]string{
 		1: "single",
 		2: "double",
 		3: "triple",
 	}
+	return maps[replicaNum]
+}
+
+func (fc *FdbcliConfig) WithStorageEngine(engine string) *FdbcliConfig {
+	fc.StorageEngine = engine
+	return fc
+}
 
+func (fc *FdbcliConfig) WithTLS(options *FdbTLSOptions) *FdbcliConfig {
+	fc.TLS = options
+	return fc
+}
+
+func (fc *FdbcliConfig) CreateNewDb() (string, string, error) {
+	engine := fc.StorageEngine
+	if engine == "" {
+		engine = constant.DefaultFdbStorageEngine
+	}
 	checkCommand := CommandRunner{
 		Command: "fdbcli",
-		Args: []string{
+		Args: append(fc.TLS.Args(),
 			"-C", fc.ConfigPath,
 			"--exec",
-			fmt.Sprintf("configure new %s ssd", maps[fc.ReplicaNum]),
-		},
+			fmt.Sprintf("configure new %s %s", GetRedundancyMode(fc.ReplicaNum), engine),
+		),
 		Timeout: 30 * time.Second,
 	}
 	return checkCommand.Exec(context.Background())
 }
 
-func (fc *FdbcliConfig) ConfigureCoordinator() (string, string, error) {
-	autoCommand := CommandRunner{
+// ConfigureRedundancy changes redundancy mode of an existing database
+func (fc *FdbcliConfig) ConfigureRedundancy(mode string) (string, string, error) {
+	configureCommand := CommandRunner{
+		Command: "fdbcli",
+		Args: append(fc.TLS.Args(),
+			"-C", fc.ConfigPath,
+			"--exec",
+			fmt.Sprintf("configure %s", mode),
+		),
+		Timeout: 30 * time.Second,
+	}
+	return configureCommand.Exec(context.Background())
+}
+
+// ConfigureRoles changes counts of recruited roles, roles with 0 are not changed
+func (fc *FdbcliConfig) ConfigureRoles(logs, proxies, resolvers int) (string, string, error) {
+	args := []string{"configure"}
+	if logs > 0 {
+		args = append(args, fmt.Sprintf("logs=%d", logs))
+	}
+	if proxies > 0 {
+		args = append(args, fmt.Sprintf("proxies=%d", proxies))
+	}
+	if resolvers > 0 {
+		args = append(args, fmt.Sprintf("resolvers=%d", resolvers))
+	}
+	configureCommand := CommandRunner{
+		Command: "fdbcli",
+		Args: append(fc.TLS.Args(),
+			"-C", fc.ConfigPath,
+			"--exec",
+			strings.Join(args, " "),
+		),
+		Timeout: 30 * time.Second,
+	}
+	return configureCommand.Exec(context.Background())
+}
+
+// ParseGetOutput returns the value of fdbcli get output "`key' is `value'", false if the key is not found
+func ParseGetOutput(output string) (string, bool) {
+	_, value, ok := parseKeyValue(output)
+	return value, ok
+}
+
+// parseKeyValue parses a line "`key' is `value'" of fdbcli get and getrange output
+func parseKeyValue(line string) (string, string, bool) {
+	start := strings.Index(line, "`")
+	idx := strings.Index(line, "' is `")
+	if start < 0 || idx < start {
+		return "", "", false
+	}
+	value := line[idx+len("' is `"):]
+	end := strings.LastIndex(value, "'")
+	if end < 0 {
+		return "", "", false
+	}
+	return line[start+1 : idx], value[:end], true
+}
+
+// ParseGetRangeOutput returns keys and values of fdbcli getrange output
+func ParseGetRangeOutput(output string) map[string]string {
+	values := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		if key, value, ok := parseKeyValue(line); ok {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+// getRangeEnd returns the first key after all keys with the prefix
+func getRangeEnd(prefix string) string {
+	return prefix[:len(prefix)-1] + string(prefix[len(prefix)-1]+1)
+}
+
+// GetKey returns the value of a printable key
+func (fc *FdbcliConfig) GetKey(key string) (string, bool, error) {
+	getCommand := CommandRunner{
+		Command: "fdbcli",
+		Args: append(fc.TLS.Args(),
+			"-C", fc.ConfigPath,
+			"--exec",
+			fmt.Sprintf("get %s", key),
+		),
+		Timeout: 30 * time.Second,
+	}
+	output, _, err := getCommand.Exec(context.Background())
+	if err != nil {
+		klog.Errorf("get key %s failed, output: %s, err: %+v", key, output, err)
+		return "", false, err
+	}
+	value, ok := ParseGetOutput(output)
+	return value, ok, nil
+}
+
+// ListKeys returns printable keys with the prefix and their values
+func (fc *FdbcliConfig) ListKeys(prefix string) (map[string]string, error) {
+	getRangeCommand := CommandRunner{
+		Command: "fdbcli",
+		Args: append(fc.TLS.Args(),
+			"-C", fc.ConfigPath,
+			"--exec",
+			fmt.Sprintf("getrange %s %s", prefix, getRangeEnd(prefix)),
+		),
+		Timeout: 30 * time.Second,
+	}
+	output, _, err := getRangeCommand.Exec(context.Background())
+	if err != nil {
+		klog.Errorf("get keys with prefix %s failed, output: %s, err: %+v", prefix, output, err)
+		return nil, err
+	}
+	return ParseGetRangeOutput(output), nil
+}
+
+// ClaimKey sets a printable key with the prefix in a transaction reading all keys with the prefix, so concurrent
+// claims conflict and only one of them is committed. Returns keys with the prefix before the claim
+func (fc *FdbcliConfig) ClaimKey(prefix, key, value string) (map[string]string, error) {
+	claimCommand := CommandRunner{
+		Command: "fdbcli",
+		Args: append(fc.TLS.Args(),
+			"-C", fc.ConfigPath,
+			"--exec",
+			fmt.Sprintf("writemode on; begin; getrange %s %s; set %s %s; commit", prefix, getRangeEnd(prefix), key, value),
+		),
+		Timeout: 30 * time.Second,
+	}
+	output, _, err := claimCommand.Exec(context.Background())
+	if err != nil {
+		klog.Errorf("claim key %s failed, output: %s, err: %+v", key, output, err)
+		return nil, err
+	}
+	if !strings.Contains(output, "Committed") {
+		return nil, fmt.Errorf("claim key %s not committed: %s", key, output)
+	}
+	return ParseGetRangeOutput(output), nil
+}
+
+// ClearKey clears a printable key
+func (fc *FdbcliConfig) ClearKey(key string) (string, string, error) {
+	clearCommand := CommandRunner{
 		Command: "fdbcli",
-		Args: []string{
+		Args: append(fc.TLS.Args(),
 			"-C", fc.ConfigPath,
 			"--exec",
-			"coordinators auto",
-		},
-		Timeout: 10 * time.Second,
+			fmt.Sprintf("writemode on; clear %s", key),
+		),
+		Timeout: 30 * time.Second,
 	}
-	return autoCommand.Exec(context.Background())
+	return clearCommand.Exec(context.Background())
+}
+
+// ExcludeServers excludes addresses without waiting, processes on them are drained in background
+func (fc *FdbcliConfig) ExcludeServers(addresses []string) (string, string, error) {
+	excludeCommand := CommandRunner{
+		Command: "fdbcli",
+		Args: append(fc.TLS.Args(),
+			"-C", fc.ConfigPath,
+			"--exec",
+			fmt.Sprintf("exclude no_wait %s", strings.Join(addresses, " ")),
+		),
+		Timeout: 30 * time.Second,
+	}
+	return excludeCommand.Exec(context.Background())
+}
+
+func (fc *FdbcliConfig) IncludeServers(addresses []string) (string, string, error) {
+	includeCommand := CommandRunner{
+		Command: "fdbcli",
+		Args: append(fc.TLS.Args(),
+			"-C", fc.ConfigPath,
+			"--exec",
+			fmt.Sprintf("include %s", strings.Join(addresses, " ")),
+		),
+		Timeout: 30 * time.Second,
+	}
+	return includeCommand.Exec(context.Background())
+}
+
+// GetCoordinatorNum returns CoordinatorNum, or the fdb recommended number of the redundancy mode if not set
+func (fc *FdbcliConfig) GetCoordinatorNum() int {
+	if fc.CoordinatorNum > 0 {
+		return fc.CoordinatorNum
+	}
+	maps := map[int]int{
+		1: 1,
+		2: 3,
+		3: 5,
+	}
+	return maps[fc.ReplicaNum]
+}
+
+func (fc *FdbcliConfig) SetCoordinators(addrs []string) (string, string, error) {
+	setCommand := CommandRunner{
+		Command: "fdbcli",
+		Args: append(fc.TLS.Args(),
+			"-C", fc.ConfigPath,
+			"--exec",
+			fmt.Sprintf("coordinators %s", strings.Join(addrs, " ")),
+		),
+		Timeout: 30 * time.Second,
+	}
+	return setCommand.Exec(context.Background())
 }
 
 func (fc *FdbcliConfig) CheckFdbCluster() (string, string, error) {
 	checkCommand := CommandRunner{
 		Command: "fdbcli",
-		Args: []string{
+		Args: append(fc.TLS.Args(),
 			"-C", fc.ConfigPath,
 			"--exec",
 			"status minimal",
-		},
+		),
 		Timeout: 30 * time.Second,
 	}
 	return checkCommand.Exec(context.Background())
@@ -79,11 +312,11 @@ func (fc *FdbcliConfig) GetFdbDetails() (string, string, error) {
 	}
 	checkCommand := CommandRunner{
 		Command: "fdbcli",
-		Args: []string{
+		Args: append(fc.TLS.Args(),
 			"-C", fc.ConfigPath,
 			"--exec",
 			"status details",
-		},
+		),
 		Timeout: 30 * time.Second,
 	}
 	return checkCommand.Exec(context.Background())
@@ -96,11 +329,11 @@ func (fc *FdbcliConfig) GetFdbJson() (string, string, error) {
 	}
 	checkCommand := CommandRunner{
 		Command: "fdbcli",
-		Args: []string{
+		Args: append(fc.TLS.Args(),
 			"-C", fc.ConfigPath,
 			"--exec",
 			"status json",
-		},
+		),
 		Timeout: 30 * time.Second,
 	}
 	return checkCommand.Exec(context.Background())
@@ -136,7 +369,7 @@ func (fc *FdbcliConfig) ParseStatusOutput() (*fdbv1beta2.FoundationDBStatus, err
 	err = json.Unmarshal([]byte(rawStatus), status)
 
 	if err != nil {
-		klog.Errorf("could not parse result of status json %w (unparseable JSON: %s)    ", err, rawStatus)
+		klog.Errorf("could not parse result of status json %v (unparseable JSON: %s)    ", err, rawStatus)
 		return nil, fmt.Errorf(
 			"could not parse result of status json %w (unparseable JSON: %s)	",
 			err,
@@ -148,7 +381,7 @@ func (fc *FdbcliConfig) ParseStatusOutput() (*fdbv1beta2.FoundationDBStatus, err
 }
 
 func (fc *FdbcliConfig) InitFdbCluster() error {
-	checkOutput, _, err := fc.CheckFdbCluster()
+	checkOutput, _, _ := fc.CheckFdbCluster()
 	if !strings.Contains(checkOutput, "The database is available") {
 		if output, _, err := fc.CreateNewDb(); err != nil {
 			if !strings.Contains(output, "Database already exists") {
@@ -157,11 +390,6 @@ func (fc *FdbcliConfig) InitFdbCluster() error {
 			}
 		}
 	}
-
-	time.Sleep(5 * time.Second)
-	if _, _, err = fc.ConfigureCoordinator(); err != nil {
-		klog.Errorf("auto coordinator failed, err: %+v", err)
-		return err
-	}
+	// coordinators are selected by the controller
 	return nil
 }
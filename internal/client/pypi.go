@@ -5,6 +5,7 @@ import (
 	"fmt"
 	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
 	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/aliyun/kvc-3fs-operator/internal/storage"
 	"github.com/aliyun/kvc-3fs-operator/internal/utils"
 	"os"
 	"strconv"
@@ -12,15 +13,23 @@ import (
 )
 
 func CreateDataPlacementRule(nodes []string, threefsCluster *threefsv1.ThreeFsCluster, nodeidStart int) error {
+	return CreateDataPlacementRuleWithLayout(nodes, threefsCluster.Spec.Storage, nodeidStart, len(threefsCluster.Spec.Storage.TargetPaths), threefsCluster.Spec.Storage.TargetPerDisk)
+}
+
+// CreateDataPlacementRuleWithLayout generates targets and chains for numDisks disks of each node with targetPerDisk
+// targets on each disk, disk index of the generated files always starts from 0. Chain replication or erasure coding
+// follows redundancy of the storage spec
+func CreateDataPlacementRuleWithLayout(nodes []string, storageSpec threefsv1.StorageSpec, nodeidStart, numDisks, targetPerDisk int) error {
 	os.RemoveAll("/output")
+	chainTableType := storage.GetRedundancyType(storageSpec)
 	dataCommand := &CommandRunner{
 		Command: "python3",
 		Args: []string{
 			"/opt/3fs/data_placement/data_placement.py",
-			"-ql", "-relax", "-type", "CR",
+			"-ql", "-relax", "-type", chainTableType,
 			"--num_nodes", strconv.Itoa(len(nodes)),
-			"--replication_factor", strconv.Itoa(threefsCluster.Spec.Storage.Replica),
-			"--min_targets_per_disk", strconv.Itoa(threefsCluster.Spec.Storage.TargetPerDisk),
+			"--replication_factor", strconv.Itoa(storage.GetGroupSize(storageSpec)),
+			"--min_targets_per_disk", strconv.Itoa(targetPerDisk),
 		},
 		Timeout: 10 * time.Minute,
 	}
@@ -39,11 +48,11 @@ func CreateDataPlacementRule(nodes []string, threefsCluster *threefsv1.ThreeFsCl
 		Command: "python3",
 		Args: []string{
 			"/opt/3fs/data_placement/gen_chain_table.py",
-			"--chain_table_type", "CR",
+			"--chain_table_type", chainTableType,
 			"--node_id_begin", strconv.Itoa(nodeidStart),
 			"--node_id_end", strconv.Itoa(nodeidStart - 1 + len(nodes)),
-			"--num_disks_per_node", strconv.Itoa(len(threefsCluster.Spec.Storage.TargetPaths)),
-			"--num_targets_per_disk", strconv.Itoa(threefsCluster.Spec.Storage.TargetPerDisk),
+			"--num_disks_per_node", strconv.Itoa(numDisks),
+			"--num_targets_per_disk", strconv.Itoa(targetPerDisk),
 			"--target_id_prefix", strconv.Itoa(constant.ThreeFSTargetIDPrefix),
 			"--chain_id_prefix", strconv.Itoa(constant.ThreeFSChainIDPrefix),
 			"--incidence_matrix_path", fmt.Sprintf("%s/incidence_matrix.pickle", dataPlacementDir),
@@ -5,6 +5,14 @@ import "time"
 const (
 	// ResyncInterval is sync interval of the watcher
 	ResyncInterval = 30 * time.Second
+	// ChainTableQueueInterval is requeue interval of pending chain table jobs
+	ChainTableQueueInterval = 10 * time.Second
+	// DiskExpansionInterval is requeue interval while adding target paths
+	DiskExpansionInterval = 20 * time.Second
+	// StorageMaintenanceInterval is requeue interval while storage nodes are in maintenance
+	StorageMaintenanceInterval = 30 * time.Second
+	// FdbBackupInterval is requeue interval while fdb backups or restores are running
+	FdbBackupInterval = 30 * time.Second
 )
 
 const (
@@ -16,15 +24,24 @@ const (
 
 	ThreeComponentReadyStatus = "Processed"
 
+	ThreeFSChainTablePendingStatus    = "Pending"
 	ThreeFSChainTableProcessingStatus = "Processing"
 	ThreeFSChainTableProcessedStatus  = "Processed"
 	ThreeFSChainTableFinishedStatus   = "Finished"
+	ThreeFSChainTableAbortedStatus    = "Aborted"
+
+	// ChainTablePlacedStatus is the phase of chain tables in cluster status whose chains are uploaded
+	ChainTablePlacedStatus = "Placed"
+
+	DiskExpansionRollingVolumesStatus = "RollingVolumes"
+	DiskExpansionPlacingDataStatus    = "PlacingData"
 )
 
 const (
 	ThreeFSChainTableTypeCreate  = "NodeCreate"
 	ThreeFSChainTableTypeDelete  = "NodeDelete"
 	ThreeFSChainTableTypeReplace = "NodeReplace"
+	ThreeFSChainTableTypeRestore = "ChainTableRestore"
 )
 
 const (
@@ -34,6 +51,90 @@ const (
 
 	ThreeFSTargetIDPrefix = 1
 	ThreeFSChainIDPrefix  = 9
+
+	// ThreeFSStorageDiskSlotPath is the mount path prefix of disks beyond storage.targetPaths in storage pods
+	ThreeFSStorageDiskSlotPath = "/3fs/disk"
+	// DefaultStorageNodeGroupWeight is the weight of disks in a node group if not set
+	DefaultStorageNodeGroupWeight = 100
+	// DefaultPlacementOutputPath keeps merged placement files of all node groups, data placement of each
+	// group cleans /output
+	DefaultPlacementOutputPath = "/tmp/placement"
+
+	ThreeFSRedundancyCR = "CR"
+	ThreeFSRedundancyEC = "EC"
+	// DefaultChainTableDesc is the description of chain tables with chain replication
+	DefaultChainTableDesc = "stage"
+)
+
+const (
+	BackupNodeStrategySameZone            = "SameZone"
+	BackupNodeStrategyMostFreeDisk        = "MostFreeDisk"
+	BackupNodeStrategyLeastRecentlyFailed = "LeastRecentlyFailed"
+
+	DefaultBackupNodeFailureCooldownMinutes = 1440
+
+	DefaultMaxConcurrentRepairs = 1
+
+	StorageMaintenanceOffline          = "offline"
+	StorageMaintenanceReasonAnnotation = "Annotation"
+	StorageMaintenanceReasonCordon     = "Cordon"
+
+	StorageMaintenanceOffliningStatus     = "Offlining"
+	StorageMaintenanceInMaintenanceStatus = "InMaintenance"
+	StorageMaintenanceRecoveringStatus    = "Recovering"
+	StorageMaintenanceCompletedStatus     = "Completed"
+)
+
+const (
+	FdbBackupPendingStatus   = "Pending"
+	FdbBackupScheduledStatus = "Scheduled"
+	FdbBackupRunningStatus   = "Running"
+	FdbBackupCompletedStatus = "Completed"
+	FdbBackupFailedStatus    = "Failed"
+
+	DefaultFdbBackupHistoryLimit = 10
+	DefaultFdbBackupAgentReplica = 1
+
+	FdbReconfigureAddingProcessesStatus = "AddingProcesses"
+	FdbReconfigureRedistributingStatus  = "Redistributing"
+	FdbReconfigureCompletedStatus       = "Completed"
+
+	FdbRemovalExcludingStatus = "Excluding"
+	FdbRemovalRemovingStatus  = "Removing"
+
+	DefaultFdbStorageEngine = "ssd"
+	DefaultFdbDataPath      = "/opt/3fs/fdb/data"
+	// FdbProcessPortStep is the port range of each process class on a node, so ports of a class are kept when counts of others change
+	FdbProcessPortStep = 10
+
+	// FdbModeBuiltin runs fdb deployments on fdb nodes, FdbModeExternalOperator creates a FoundationDBCluster
+	// for the FoundationDB kubernetes operator
+	FdbModeBuiltin          = "builtin"
+	FdbModeExternalOperator = "external-operator"
+	// FdbModeExternal uses an existing fdb cluster
+	FdbModeExternal = "external"
+	// FdbOwnerKeyPrefix is the prefix of keys claiming an external fdb cluster by namespace/name of the
+	// ThreeFsCluster, 3fs keys have no prefix or tenant so an fdb cluster can not be shared by ThreeFsClusters
+	FdbOwnerKeyPrefix = "threefs-operator/owner/"
+	// DefaultFdbVersion is the fdb version of the fdb image
+	DefaultFdbVersion = "7.3.63"
+	// MinimumFdbVersion is the oldest fdb version 3fs clients can use by the multi-version client
+	MinimumFdbVersion = "7.1.0"
+	// FdbClientVersion is the version of libfdb_c bundled in 3fs images and fdbcli of the operator image
+	FdbClientVersion = "7.3.63"
+
+	FdbUpgradeStagingClientsStatus = "StagingClients"
+	FdbUpgradeBouncingStatus       = "Bouncing"
+	FdbUpgradeCompletedStatus      = "Completed"
+
+	DefaultFdbTLSVerifyPeers = "Check.Valid=1"
+	// DefaultFdbTLSPath is the mount path of fdb tls certificates in pods
+	DefaultFdbTLSPath = "/var/fdb/tls"
+	// FdbTLSCertDuration is the validity of certificates generated by the operator, renewed FdbTLSRenewBefore
+	// expiration with the same ca
+	FdbTLSCertDuration = 365 * 24 * time.Hour
+	FdbTLSRenewBefore  = 30 * 24 * time.Hour
+	FdbTLSCADuration   = 10 * 365 * 24 * time.Hour
 )
 
 const (
@@ -54,8 +155,14 @@ const (
 	ThreeFSFdbFaultNodeKey = "threefs.aliyun.com/fdb-fault-node"
 	ThreeFSFdbDaemonsetKey = "threefs.aliyun.com/fdb-daemonset"
 	ThreeFSFdbDeployKey    = "threefs.aliyun.com/fdb-deploy"
+	// ThreeFSFdbBackupAgentKey labels backup agents of a ThreeFsBackup or ThreeFsRestore
+	ThreeFSFdbBackupAgentKey = "threefs.aliyun.com/fdb-backup-agent"
+	// ThreeFSFdbClientVersionsKey annotates pods of mgmtd and meta with versions of staged fdb client libraries
+	ThreeFSFdbClientVersionsKey = "threefs.aliyun.com/fdb-client-versions"
 
 	ThreeFSMgmtdPrimaryNodeKey = "threefs.aliyun.com/mgmtd-primary-node"
+	// ThreeFSMgmtdMovePrimaryKey is the cluster annotation to move the primary mgmtd to another mgmtd
+	ThreeFSMgmtdMovePrimaryKey = "threefs.aliyun.com/mgmtd-move-primary"
 	ThreeFSMgmtdNodeKey        = "threefs.aliyun.com/mgmtd-node"
 	ThreeFSMgmtdDaemonsetKey   = "threefs.aliyun.com/mgmtd-daemonset"
 	ThreeFSMgmtdDeployKey      = "threefs.aliyun.com/mgmtd-deploy"
@@ -78,9 +185,28 @@ const (
 
 	ThreeFSPodLabel       = "threefs.aliyun.com/threefs"
 	ThreeFSComponentLabel = "threefs.aliyun.com/component"
-
-	ThreeFSAutoReplaceLabel   = "threefs.aliyun.com/storage-auto-replace"
-	ThreeFSRollingUpdateLabel = "threefs.aliyun.com/rolling-update"
+	// ThreeFSEvictionNamespaceLabel is the namespace label selecting namespaces of 3fs clusters for the eviction webhook
+	ThreeFSEvictionNamespaceLabel = "threefs.aliyun.com/eviction-webhook"
+
+	ThreeFSAutoReplaceLabel = "threefs.aliyun.com/storage-auto-replace"
+	// ThreeFSStorageMountedPathsKey is the node annotation listing mounted target paths, separated by comma
+	ThreeFSStorageMountedPathsKey = "threefs.aliyun.com/storage-mounted-paths"
+	// ThreeFSStorageFreeDiskKey is the node annotation of free disk capacity, e.g. 20Ti
+	ThreeFSStorageFreeDiskKey      = "threefs.aliyun.com/storage-free-disk"
+	ThreeFSStorageLastFaultTimeKey = "threefs.aliyun.com/storage-last-fault-time"
+	// ThreeFSStorageMaintenanceKey is the node annotation of storage maintenance, true to suppress auto repair of
+	// the node, or offline to offline its targets before maintenance as well
+	ThreeFSStorageMaintenanceKey = "threefs.aliyun.com/storage-maintenance"
+	ThreeFSBackupNodeStrategyKey = "threefs.aliyun.com/backup-node-strategy"
+	ThreeFSBackupNodeReasonKey   = "threefs.aliyun.com/backup-node-reason"
+	ThreeFSBackupNodeRejectedKey = "threefs.aliyun.com/backup-node-rejected"
+	ThreeFSRollingUpdateLabel    = "threefs.aliyun.com/rolling-update"
+
+	ThreeFSChainTableSnapshotKey        = "threefs.aliyun.com/chaintable-snapshot"
+	ThreeFSChainTableSnapshotVersionKey = "threefs.aliyun.com/chaintable-snapshot-version"
+	ThreeFSChainTableSnapshotStageKey   = "threefs.aliyun.com/chaintable-snapshot-stage"
+	ThreeFSChainTableSnapshotSourceKey  = "threefs.aliyun.com/chaintable-snapshot-source"
+	ThreeFSChainTableSnapshotTimeKey    = "threefs.aliyun.com/chaintable-snapshot-time"
 )
 
 const (
@@ -117,13 +243,23 @@ const (
 
 	DefaultThreeFSFdbConfigName = "threefs-fdb-config"
 	DefaultThreeFSFdbConfigPath = "/opt/3fs/etc/fdb.cluster"
+	// DefaultThreeFSFdbTLSPath is the directory of fdb tls certificates used by fdbcli of the operator
+	DefaultThreeFSFdbTLSPath = "/opt/3fs/etc/fdb-tls"
 
 	DefaultTokenConfigName = "threefs-token-config"
 
+	DefaultChainTableSnapshotPrefix    = "ct-snapshot"
+	DefaultChainTableSnapshotRetention = 64
+	DefaultChainTableSnapshotChains    = "chains.csv"
+	DefaultChainTableSnapshotTable     = "chain_table.csv"
+	ChainTableSnapshotStageBefore      = "before"
+	ChainTableSnapshotStageAfter       = "after"
+
 	DefaultSidecarPrefix = "threefs-sidecar"
 
 	DefaultThreeFSMutateWebhookName   = "threefs-mutating-webhook"
 	DefaultThreeFSValidateWebhookName = "threefs-validation-webhook"
+	DefaultEvictionValidatePath       = "/validate--v1-pod-eviction"
 
 	ErdmaResourceKey = "aliyun/erdma"
 )
@@ -141,6 +277,15 @@ const (
 	ENVCoordinatorPort       = "FDB_COORDINATOR_PORT"
 	ENVProcessClass          = "FDB_PROCESS_CLASS"
 	ENVFdbNetworkMode        = "FDB_NETWORKING_MODE"
+	// ENVFdbMonitorConf replaces foundationdb.conf of the fdb image if set
+	ENVFdbMonitorConf = "FDB_MONITOR_CONF"
+	// ENVFdbExternalClientDirectory is the directory of fdb client libraries loaded by the multi-version client
+	ENVFdbExternalClientDirectory = "FDB_NETWORK_OPTION_EXTERNAL_CLIENT_DIRECTORY"
+	// fdb tls options read by fdbserver and fdb clients
+	ENVFdbTLSCertificateFile = "FDB_TLS_CERTIFICATE_FILE"
+	ENVFdbTLSKeyFile         = "FDB_TLS_KEY_FILE"
+	ENVFdbTLSCAFile          = "FDB_TLS_CA_FILE"
+	ENVFdbTLSVerifyPeers     = "FDB_TLS_VERIFY_PEERS"
 
 	ENVUseHostnetwork = "USE_HOSTNETWORK"
 	ENVFaultDuration  = "FAULT_DURATION"
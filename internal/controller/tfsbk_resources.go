@@ -0,0 +1,345 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	clientcomm "github.com/aliyun/kvc-3fs-operator/internal/client"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/aliyun/kvc-3fs-operator/internal/fdb"
+	"github.com/aliyun/kvc-3fs-operator/internal/native_resources"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	backupAgentClusterFileEnv  = "FDB_CLUSTER_FILE_CONTENT"
+	blobCredentialsKey         = "credentials.json"
+	backupAgentCredentialsPath = "/var/fdb-credentials"
+)
+
+// GetBackupUrl returns the blobstore url of the backup name in the destination
+func GetBackupUrl(dest threefsv1.BackupDestination, accessKey, name string) string {
+	params := []string{fmt.Sprintf("bucket=%s", dest.Bucket)}
+	if dest.Secure {
+		params = append(params, "secure_connection=1")
+	} else {
+		params = append(params, "secure_connection=0")
+	}
+	if dest.Region != "" {
+		params = append(params, fmt.Sprintf("region=%s", dest.Region))
+	}
+	return fmt.Sprintf("blobstore://%s@%s/%s?%s", accessKey, dest.Endpoint, name, strings.Join(params, "&"))
+}
+
+// GetBlobCredentials returns the blob credentials file of fdbbackup, accounts are keyed by accessKey@host
+func GetBlobCredentials(dest threefsv1.BackupDestination, accessKey, secretKey string) (string, error) {
+	host := dest.Endpoint
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	content, err := json.Marshal(map[string]interface{}{
+		"accounts": map[string]interface{}{
+			fmt.Sprintf("%s@%s", accessKey, host): map[string]string{"secret": secretKey},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// GetBackupRunName returns the backup name in the bucket of a backup started at t
+func GetBackupRunName(name string, t time.Time) string {
+	return fmt.Sprintf("%s-%s", name, t.Format("20060102-150405"))
+}
+
+// GetNextBackupTime returns when the next scheduled backup starts, zero if it is not scheduled
+func GetNextBackupTime(schedule *threefsv1.BackupSchedule, lastScheduleTime string) time.Time {
+	if schedule == nil || schedule.Suspend || schedule.IntervalMinutes <= 0 {
+		return time.Time{}
+	}
+	last, err := time.ParseInLocation(constant.TimeLayout, lastScheduleTime, time.Local)
+	if err != nil {
+		return time.Unix(0, 0)
+	}
+	return last.Add(time.Duration(schedule.IntervalMinutes) * time.Minute)
+}
+
+// IsBackupDue returns true if a new backup should start now, a backup without schedule only runs once
+func IsBackupDue(schedule *threefsv1.BackupSchedule, history []threefsv1.BackupRecord, lastScheduleTime string, now time.Time) bool {
+	if schedule == nil {
+		return len(history) == 0
+	}
+	next := GetNextBackupTime(schedule, lastScheduleTime)
+	return !next.IsZero() && !now.Before(next)
+}
+
+// TrimBackupHistory keeps the newest limit records
+func TrimBackupHistory(history []threefsv1.BackupRecord, limit int) []threefsv1.BackupRecord {
+	if limit <= 0 {
+		limit = constant.DefaultFdbBackupHistoryLimit
+	}
+	if len(history) <= limit {
+		return history
+	}
+	return history[:limit]
+}
+
+// GetBackupPhase returns Running if the newest backup is running, Scheduled between scheduled backups, or the phase
+// of the only backup
+func GetBackupPhase(schedule *threefsv1.BackupSchedule, history []threefsv1.BackupRecord) string {
+	if len(history) > 0 && history[0].Phase == constant.FdbBackupRunningStatus {
+		return constant.FdbBackupRunningStatus
+	}
+	if schedule != nil {
+		return constant.FdbBackupScheduledStatus
+	}
+	if len(history) == 0 {
+		return constant.FdbBackupPendingStatus
+	}
+	return history[0].Phase
+}
+
+// FdbBackupAgent runs fdbbackup and fdbrestore of a ThreeFsBackup or ThreeFsRestore
+type FdbBackupAgent struct {
+	*clientcomm.FdbBackupConfig
+	AccessKey string
+	// Ready is true if backup agents are available
+	Ready bool
+}
+
+func getBackupAgentName(owner metav1.Object, kind string) string {
+	return fmt.Sprintf("%s-%s-agent", owner.GetName(), kind)
+}
+
+func writeFdbBackupFile(path, content string) error {
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		klog.Errorf("write file %s failed: %v", path, err)
+		return err
+	}
+	return nil
+}
+
+// createOrUpdateAgentSecret keeps blob credentials and fdb tls certificates of backup agents in one secret of the
+// owner namespace, renewed certificates are updated in place
+func createOrUpdateAgentSecret(rclient client.Client, scheme *runtime.Scheme, owner metav1.Object, name string, data map[string][]byte) error {
+	secret := &corev1.Secret{}
+	err := rclient.Get(context.Background(), client.ObjectKey{Name: name, Namespace: owner.GetNamespace()}, secret)
+	if err == nil {
+		if reflect.DeepEqual(secret.Data, data) {
+			return nil
+		}
+		secret.Data = data
+		if err := rclient.Update(context.Background(), secret); err != nil {
+			klog.Errorf("update secret %s failed: %v", name, err)
+			return err
+		}
+		return nil
+	} else if !k8serror.IsNotFound(err) {
+		klog.Errorf("get secret %s failed: %v", name, err)
+		return err
+	}
+
+	secret = &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: owner.GetNamespace(),
+		},
+		Data: data,
+	}
+	if err := controllerutil.SetControllerReference(owner, secret, scheme); err != nil {
+		return err
+	}
+	if err := rclient.Create(context.Background(), secret); err != nil {
+		klog.Errorf("create secret %s failed: %v", name, err)
+		return err
+	}
+	return nil
+}
+
+// newBackupAgentDeploy returns backup agents reading credentials of the secret, fdb tls is enabled if verifyPeers is set
+func newBackupAgentDeploy(owner metav1.Object, name, image, secretName, clusterFile, verifyPeers string, replica int) *appsv1.Deployment {
+	labels := map[string]string{
+		constant.ThreeFSFdbBackupAgentKey: name,
+	}
+	envs := []corev1.EnvVar{
+		{
+			Name:  backupAgentClusterFileEnv,
+			Value: clusterFile,
+		},
+	}
+	if verifyPeers != "" {
+		envs = append(envs, fdb.GetTLSEnvs(backupAgentCredentialsPath, verifyPeers)...)
+	}
+	volumes := []corev1.Volume{
+		{
+			Name: "fdb",
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
+			},
+		},
+		{
+			Name: "credentials",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{SecretName: secretName},
+			},
+		},
+	}
+	volumeMounts := []corev1.VolumeMount{
+		{
+			Name:      "fdb",
+			MountPath: "/var/fdb",
+		},
+		{
+			Name:      "credentials",
+			MountPath: backupAgentCredentialsPath,
+			ReadOnly:  true,
+		},
+	}
+	resources := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("100m"),
+			corev1.ResourceMemory: resource.MustParse("256Mi"),
+		},
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("1"),
+			corev1.ResourceMemory: resource.MustParse("1Gi"),
+		},
+	}
+	command := []string{
+		"bash", "-c",
+		fmt.Sprintf("echo \"$%s\" > /var/fdb/fdb.cluster && exec /usr/lib/foundationdb/backup_agent/backup_agent -C /var/fdb/fdb.cluster --blob-credentials %s/%s",
+			backupAgentClusterFileEnv, backupAgentCredentialsPath, blobCredentialsKey),
+	}
+	return native_resources.NewDeployConfig().
+		WithDeployMeta(name, owner.GetNamespace(), labels).
+		WithDeploySpec(labels, labels, int32(replica), false, nil, appsv1.RollingUpdateDeploymentStrategyType).
+		WithVolumes(volumes).
+		WithContainer("backup-agent", image, envs, nil, nil, resources, volumeMounts, command).
+		Deployment
+}
+
+// PrepareFdbBackupAgent creates backup agents of the owner with credentials of the destination, and writes the cluster
+// file and credentials for fdbbackup and fdbrestore run by the operator. kind is backup or restore
+func PrepareFdbBackupAgent(rclient client.Client, scheme *runtime.Scheme, owner metav1.Object, kind string, tfsc *threefsv1.ThreeFsCluster, dest threefsv1.BackupDestination, replica int) (*FdbBackupAgent, error) {
+	secret := &corev1.Secret{}
+	if err := rclient.Get(context.Background(), client.ObjectKey{Name: dest.SecretName, Namespace: owner.GetNamespace()}, secret); err != nil {
+		klog.Errorf("get secret %s failed: %v", dest.SecretName, err)
+		return nil, err
+	}
+	accessKey, secretKey := string(secret.Data["accessKey"]), string(secret.Data["secretKey"])
+	if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("secret %s has no accessKey or secretKey", dest.SecretName)
+	}
+	credentials, err := GetBlobCredentials(dest, accessKey, secretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	fdbConfigMap := &corev1.ConfigMap{}
+	if err := rclient.Get(context.Background(), client.ObjectKey{Name: fdb.GetFdbDeployName(tfsc.Name), Namespace: tfsc.Namespace}, fdbConfigMap); err != nil {
+		klog.Errorf("get configmap %s failed: %v", fdb.GetFdbDeployName(tfsc.Name), err)
+		return nil, err
+	}
+	clusterFile := fdbConfigMap.Data["fdb.cluster"]
+	if clusterFile == "" {
+		return nil, fmt.Errorf("fdb cluster file of threeFsCluster %s is empty", tfsc.Name)
+	}
+
+	name := getBackupAgentName(owner, kind)
+	data := map[string][]byte{blobCredentialsKey: []byte(credentials)}
+	verifyPeers := ""
+	if fdb.IsTLSEnabled(tfsc.Spec.Fdb) {
+		// backup agents may run in another namespace, certificates are copied to the secret of agents
+		if tfsc.Status.FdbTLS.SecretName == "" {
+			return nil, fmt.Errorf("fdb tls certificate of threeFsCluster %s is not ready", tfsc.Name)
+		}
+		tlsSecret := &corev1.Secret{}
+		if err := rclient.Get(context.Background(), client.ObjectKey{Name: tfsc.Status.FdbTLS.SecretName, Namespace: tfsc.Namespace}, tlsSecret); err != nil {
+			klog.Errorf("get secret %s failed: %v", tfsc.Status.FdbTLS.SecretName, err)
+			return nil, err
+		}
+		for key, value := range fdb.GetTLSData(tlsSecret.Data) {
+			data[key] = value
+		}
+		verifyPeers = fdb.GetTLSVerifyPeers(tfsc.Spec.Fdb)
+	}
+	if err := createOrUpdateAgentSecret(rclient, scheme, owner, name, data); err != nil {
+		return nil, err
+	}
+	if replica <= 0 {
+		replica = constant.DefaultFdbBackupAgentReplica
+	}
+	deploy := &appsv1.Deployment{}
+	if err := rclient.Get(context.Background(), client.ObjectKey{Name: name, Namespace: owner.GetNamespace()}, deploy); err != nil {
+		if !k8serror.IsNotFound(err) {
+			klog.Errorf("get deployment %s failed: %v", name, err)
+			return nil, err
+		}
+		deploy = newBackupAgentDeploy(owner, name, fdb.GetFdbImage(GetFdbDeployVersion(tfsc)), name, clusterFile, verifyPeers, replica)
+		if err := controllerutil.SetControllerReference(owner, deploy, scheme); err != nil {
+			return nil, err
+		}
+		if err := rclient.Create(context.Background(), deploy); err != nil {
+			klog.Errorf("create deployment %s failed: %v", name, err)
+			return nil, err
+		}
+		klog.Infof("create backup agent deployment %s success", name)
+	} else if content, _ := GetContainerEnv(deploy.Spec.Template.Spec, backupAgentClusterFileEnv); content != clusterFile || *deploy.Spec.Replicas != int32(replica) {
+		// coordinators changed
+		setContainerEnv(&deploy.Spec.Template.Spec, backupAgentClusterFileEnv, clusterFile)
+		replicas := int32(replica)
+		deploy.Spec.Replicas = &replicas
+		if err := rclient.Update(context.Background(), deploy); err != nil {
+			klog.Errorf("update deployment %s failed: %v", name, err)
+			return nil, err
+		}
+	}
+
+	prefix := filepath.Join(constant.DefaultConfigPath, fmt.Sprintf("%s-%s-%s", kind, owner.GetNamespace(), owner.GetName()))
+	if err := writeFdbBackupFile(prefix+".cluster", clusterFile); err != nil {
+		return nil, err
+	}
+	if err := writeFdbBackupFile(prefix+".json", credentials); err != nil {
+		return nil, err
+	}
+	return &FdbBackupAgent{
+		FdbBackupConfig: clientcomm.NewFdbBackupConfig(prefix+".cluster", prefix+".json").WithTLS(GetFdbTLSOptions(tfsc)),
+		AccessKey:       accessKey,
+		Ready:           deploy.Status.AvailableReplicas > 0,
+	}, nil
+}
+
+// DeleteFdbBackupAgent deletes backup agents of the owner
+func DeleteFdbBackupAgent(rclient client.Client, owner metav1.Object, kind string) error {
+	deploy := &appsv1.Deployment{}
+	name := getBackupAgentName(owner, kind)
+	if err := rclient.Get(context.Background(), client.ObjectKey{Name: name, Namespace: owner.GetNamespace()}, deploy); err != nil {
+		if k8serror.IsNotFound(err) {
+			return nil
+		}
+		klog.Errorf("get deployment %s failed: %v", name, err)
+		return err
+	}
+	if err := rclient.Delete(context.Background(), deploy); err != nil && !k8serror.IsNotFound(err) {
+		klog.Errorf("delete deployment %s failed: %v", name, err)
+		return err
+	}
+	return nil
+}
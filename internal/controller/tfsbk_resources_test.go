@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetBackupUrl(t *testing.T) {
+	dest := threefsv1.BackupDestination{Endpoint: "minio.default.svc:9000", Bucket: "fdb-backup"}
+	assert.Equal(t, "blobstore://ak@minio.default.svc:9000/tfsbk-20250101-000000?bucket=fdb-backup&secure_connection=0",
+		GetBackupUrl(dest, "ak", "tfsbk-20250101-000000"))
+
+	dest.Secure = true
+	dest.Region = "cn-hangzhou"
+	assert.Equal(t, "blobstore://ak@minio.default.svc:9000/tfsbk?bucket=fdb-backup&secure_connection=1&region=cn-hangzhou",
+		GetBackupUrl(dest, "ak", "tfsbk"))
+
+	// accounts are keyed without port
+	credentials, err := GetBlobCredentials(dest, "ak", "sk")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"accounts":{"ak@minio.default.svc":{"secret":"sk"}}}`, credentials)
+}
+
+func TestIsBackupDue(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.Local)
+	history := []threefsv1.BackupRecord{{Name: "tfsbk-1", Phase: constant.FdbBackupCompletedStatus}}
+
+	// without schedule the backup runs only once
+	assert.True(t, IsBackupDue(nil, nil, "", now))
+	assert.False(t, IsBackupDue(nil, history, "2025-01-01 00:00:00", now))
+
+	schedule := &threefsv1.BackupSchedule{IntervalMinutes: 60}
+	assert.True(t, IsBackupDue(schedule, nil, "", now))
+	assert.True(t, IsBackupDue(schedule, history, "2025-01-01 11:00:00", now))
+	assert.False(t, IsBackupDue(schedule, history, "2025-01-01 11:30:00", now))
+	assert.Equal(t, time.Date(2025, 1, 1, 12, 30, 0, 0, time.Local), GetNextBackupTime(schedule, "2025-01-01 11:30:00"))
+
+	schedule.Suspend = true
+	assert.False(t, IsBackupDue(schedule, history, "2025-01-01 00:00:00", now))
+	assert.True(t, GetNextBackupTime(schedule, "2025-01-01 00:00:00").IsZero())
+}
+
+func TestBackupHistory(t *testing.T) {
+	history := []threefsv1.BackupRecord{
+		{Name: "tfsbk-3", Phase: constant.FdbBackupRunningStatus},
+		{Name: "tfsbk-2", Phase: constant.FdbBackupFailedStatus},
+		{Name: "tfsbk-1", Phase: constant.FdbBackupCompletedStatus},
+	}
+	assert.Equal(t, history[:2], TrimBackupHistory(history, 2))
+	assert.Equal(t, history, TrimBackupHistory(history, 0))
+
+	assert.Equal(t, constant.FdbBackupRunningStatus, GetBackupPhase(nil, history))
+	assert.Equal(t, constant.FdbBackupFailedStatus, GetBackupPhase(nil, history[1:]))
+	assert.Equal(t, constant.FdbBackupScheduledStatus, GetBackupPhase(&threefsv1.BackupSchedule{IntervalMinutes: 60}, history[1:]))
+	assert.Equal(t, constant.FdbBackupPendingStatus, GetBackupPhase(nil, nil))
+}
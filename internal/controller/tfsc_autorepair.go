@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/aliyun/kvc-3fs-operator/internal/utils"
+	"github.com/aliyun/kvc-3fs-operator/internal/validation"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// IsAutoRepairEnabled returns true if faulty storage nodes are replaced automatically, the storage-auto-replace
+// label of the cluster is still honored
+func IsAutoRepairEnabled(tfsc *threefsv1.ThreeFsCluster) bool {
+	if tfsc.Spec.AutoRepair.Enabled {
+		return true
+	}
+	return tfsc.Labels != nil && tfsc.Labels[constant.ThreeFSAutoReplaceLabel] == "true"
+}
+
+func autoRepairThreshold(minutes int) time.Duration {
+	if minutes <= 0 {
+		minutes = utils.GetFaultDurationEnv()
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// GetNodeHeartbeatTimeout returns how long a storage node can lose heartbeat before it is replaced
+func GetNodeHeartbeatTimeout(spec threefsv1.AutoRepairSpec) time.Duration {
+	return autoRepairThreshold(spec.NodeHeartbeatTimeoutMinutes)
+}
+
+// GetTargetOfflineTimeout returns how long a target can be OFFLINE before its storage node is replaced
+func GetTargetOfflineTimeout(spec threefsv1.AutoRepairSpec) time.Duration {
+	return autoRepairThreshold(spec.TargetOfflineMinutes)
+}
+
+// GetFailoverTimeout returns how long meta or mgmtd can lose heartbeat before it is moved to another node
+func GetFailoverTimeout(spec threefsv1.AutoRepairSpec) time.Duration {
+	return autoRepairThreshold(spec.FailoverTimeoutMinutes)
+}
+
+// GetMaxConcurrentRepairs returns the max number of unfinished automatic replacements
+func GetMaxConcurrentRepairs(spec threefsv1.AutoRepairSpec) int {
+	if spec.MaxConcurrentRepairs <= 0 {
+		return constant.DefaultMaxConcurrentRepairs
+	}
+	return spec.MaxConcurrentRepairs
+}
+
+// InMaintenanceWindow returns true if no window is set or now is in one of the windows
+func InMaintenanceWindow(windows []threefsv1.MaintenanceWindow, now time.Time) bool {
+	if len(windows) == 0 {
+		return true
+	}
+	minutes := now.Hour()*60 + now.Minute()
+	for _, window := range windows {
+		start, err := validation.ParseClock(window.Start)
+		if err != nil {
+			continue
+		}
+		end, err := validation.ParseClock(window.End)
+		if err != nil {
+			continue
+		}
+		startDay := now
+		switch {
+		case end > start:
+			if minutes < start || minutes >= end {
+				continue
+			}
+		case minutes >= start:
+			// the window spans midnight and started today
+		case minutes < end:
+			// the window spans midnight and started yesterday
+			startDay = now.AddDate(0, 0, -1)
+		default:
+			continue
+		}
+		if len(window.Days) == 0 || utils.StrListContains(window.Days, startDay.Format("Mon")) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRollingUpgradeInProgress returns true if rolling update is enabled and any component still runs an image
+// other than the recorded version, or is not available yet
+func IsRollingUpgradeInProgress(rclient client.Client, tfsc *threefsv1.ThreeFsCluster) (bool, error) {
+	if tfsc.Labels == nil || tfsc.Labels[constant.ThreeFSRollingUpdateLabel] != "true" {
+		return false, nil
+	}
+	componentsMaps := map[string]string{
+		"monitor": constant.ThreeFSMonitorDeploymentKey,
+		"mgmtd":   constant.ThreeFSMgmtdDeployKey,
+		"meta":    constant.ThreeFSMetaDeployKey,
+		"storage": constant.ThreeFSStorageDeployKey,
+	}
+	for component, labelKey := range componentsMaps {
+		image := tfsc.Status.UpgradeInfo.ImageVersion[component]
+		if image == "" {
+			continue
+		}
+		deployList := &appsv1.DeploymentList{}
+		if err := rclient.List(context.Background(), deployList, client.InNamespace(tfsc.Namespace), client.MatchingLabels{labelKey: tfsc.Name}); err != nil {
+			klog.Errorf("list deployment failed: %v", err)
+			return false, err
+		}
+		for _, deploy := range deployList.Items {
+			containers := deploy.Spec.Template.Spec.Containers
+			if len(containers) == 0 || containers[0].Image != image || deploy.Status.AvailableReplicas != deploy.Status.Replicas {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// CheckAutoRepairSuppressed returns why automatic replacement of storage nodes can not start now, empty if it can.
+// It is suppressed during rolling upgrade, outside maintenance windows, or when concurrent repairs or the rate
+// limit are reached
+func CheckAutoRepairSuppressed(rclient client.Client, tfsc *threefsv1.ThreeFsCluster) (string, error) {
+	upgrading, err := IsRollingUpgradeInProgress(rclient, tfsc)
+	if err != nil {
+		return "", err
+	}
+	if upgrading {
+		return "rolling upgrade is in progress", nil
+	}
+	spec := tfsc.Spec.AutoRepair
+	if !InMaintenanceWindow(spec.MaintenanceWindows, time.Now()) {
+		return "out of maintenance windows", nil
+	}
+
+	tfsctList := &threefsv1.ThreeFsChainTableList{}
+	if err := rclient.List(context.Background(), tfsctList); err != nil {
+		klog.Errorf("list tfsct failed: %v", err)
+		return "", err
+	}
+	running, recent := 0, 0
+	window := time.Duration(spec.RateLimit.WindowMinutes) * time.Minute
+	for _, tfsct := range tfsctList.Items {
+		if tfsct.Spec.ThreeFsClusterName != tfsc.Name || tfsct.Spec.ThreeFsClusterNamespace != tfsc.Namespace || !IsAutoStorageReplace(&tfsct) {
+			continue
+		}
+		if !validation.IsTfsctDone(&tfsct) {
+			running++
+		}
+		if time.Since(tfsct.CreationTimestamp.Time) < window {
+			recent++
+		}
+	}
+	if maxRepairs := GetMaxConcurrentRepairs(spec); running >= maxRepairs {
+		return fmt.Sprintf("%d automatic replacements are running, max %d", running, maxRepairs), nil
+	}
+	if spec.RateLimit.MaxRepairs > 0 && window > 0 && recent >= spec.RateLimit.MaxRepairs {
+		return fmt.Sprintf("%d automatic replacements started within %s, max %d", recent, window, spec.RateLimit.MaxRepairs), nil
+	}
+	return "", nil
+}
+
+// isAutoRepairSuppressed records an event if automatic replacement of the faulty storage node can not start now
+func (r *ThreeFsClusterReconciler) isAutoRepairSuppressed(tfsc *threefsv1.ThreeFsCluster, plainOldName string) (bool, error) {
+	if IsStorageNodeInMaintenance(tfsc, plainOldName) {
+		klog.Infof("storage %s is in maintenance, skip auto repair", plainOldName)
+		return true, nil
+	}
+	reason, err := CheckAutoRepairSuppressed(r.Client, tfsc)
+	if err != nil {
+		klog.Errorf("check auto repair of storage %s failed: %v", plainOldName, err)
+		return false, err
+	}
+	if reason == "" {
+		return false, nil
+	}
+	klog.Infof("storage %s is faulty, but auto repair is suppressed: %s", plainOldName, reason)
+	r.Recorder.Event(tfsc, corev1.EventTypeNormal, "AutoRepairSuppressed", fmt.Sprintf("storage %s is faulty, but auto repair is suppressed: %s", plainOldName, reason))
+	return true, nil
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestInMaintenanceWindow(t *testing.T) {
+	// 2025-03-01 is a Saturday
+	saturday := func(hour, minute int) time.Time {
+		return time.Date(2025, 3, 1, hour, minute, 0, 0, time.Local)
+	}
+	assert.True(t, InMaintenanceWindow(nil, saturday(12, 0)))
+
+	windows := []threefsv1.MaintenanceWindow{{Start: "02:00", End: "06:00"}}
+	assert.True(t, InMaintenanceWindow(windows, saturday(2, 0)))
+	assert.False(t, InMaintenanceWindow(windows, saturday(6, 0)))
+
+	// the window starts on Friday night and spans midnight
+	windows = []threefsv1.MaintenanceWindow{{Start: "22:00", End: "04:00", Days: []string{"Fri"}}}
+	assert.True(t, InMaintenanceWindow(windows, saturday(3, 59)))
+	assert.False(t, InMaintenanceWindow(windows, saturday(23, 0)))
+	assert.True(t, InMaintenanceWindow(windows, time.Date(2025, 2, 28, 22, 0, 0, 0, time.Local)))
+}
+
+func TestIsRollingUpgradeInProgress(t *testing.T) {
+	newDeploy := func(namespace, image string) *appsv1.Deployment {
+		deploy := &appsv1.Deployment{}
+		deploy.Name, deploy.Namespace = "test-meta-node-a", namespace
+		deploy.Labels = map[string]string{constant.ThreeFSMetaDeployKey: "test"}
+		deploy.Spec.Template.Spec.Containers = []corev1.Container{{Name: "meta", Image: image}}
+		return deploy
+	}
+	tfsc := &threefsv1.ThreeFsCluster{}
+	tfsc.Name, tfsc.Namespace = "test", "default"
+	tfsc.Labels = map[string]string{constant.ThreeFSRollingUpdateLabel: "true"}
+	tfsc.Status.UpgradeInfo.ImageVersion = map[string]string{"meta": "3fs:v2"}
+
+	// a cluster with the same name in another namespace is still upgrading
+	rclient := newFakeClient(newDeploy("default", "3fs:v2"), newDeploy("other", "3fs:v1"))
+	upgrading, err := IsRollingUpgradeInProgress(rclient, tfsc)
+	assert.NoError(t, err)
+	assert.False(t, upgrading)
+
+	rclient = newFakeClient(newDeploy("default", "3fs:v1"))
+	upgrading, err = IsRollingUpgradeInProgress(rclient, tfsc)
+	assert.NoError(t, err)
+	assert.True(t, upgrading)
+	// deployment without containers is not upgraded yet
+	deploy := newDeploy("default", "")
+	deploy.Spec.Template.Spec.Containers = nil
+	rclient = newFakeClient(deploy)
+	upgrading, err = IsRollingUpgradeInProgress(rclient, tfsc)
+	assert.NoError(t, err)
+	assert.True(t, upgrading)
+}
@@ -0,0 +1,285 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/aliyun/kvc-3fs-operator/internal/storage"
+	"github.com/aliyun/kvc-3fs-operator/internal/utils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// BackupNodeCandidate is a storage backup node passing the pre-flight check
+type BackupNodeCandidate struct {
+	Name   string
+	Domain string
+	// FreeDisk is free disk capacity in bytes, -1 if the node does not report it
+	FreeDisk int64
+	// MountsUnknown is true if the node does not report mounted paths, such nodes are ranked last
+	MountsUnknown bool
+	// LastFaultTime is zero if the node never failed
+	LastFaultTime time.Time
+}
+
+// BackupNodeDecision is the backup node selected to replace a faulty storage node and the reasons
+type BackupNodeDecision struct {
+	Node       string
+	Strategies []string
+	Reason     string
+	// Rejected maps backup nodes which can not be selected to the reasons
+	Rejected map[string]string
+}
+
+// GetBackupNodeStrategies returns strategies of the backup node policy, SameZone by default
+func GetBackupNodeStrategies(policy threefsv1.BackupNodePolicy) []string {
+	if len(policy.Strategies) == 0 {
+		return []string{constant.BackupNodeStrategySameZone}
+	}
+	return policy.Strategies
+}
+
+// GetBackupNodeFailureCooldown returns how long a failed node is excluded with LeastRecentlyFailed
+func GetBackupNodeFailureCooldown(policy threefsv1.BackupNodePolicy) time.Duration {
+	if policy.FailureCooldownMinutes <= 0 {
+		return constant.DefaultBackupNodeFailureCooldownMinutes * time.Minute
+	}
+	return time.Duration(policy.FailureCooldownMinutes) * time.Minute
+}
+
+// PreflightBackupNode checks the backup node is ready and schedulable, has rdma device if storage pods request it,
+// and has all target paths mounted if the node reports mounted paths by annotation
+func PreflightBackupNode(node *corev1.Node, targetPaths []string, needRdma bool) error {
+	if node.Spec.Unschedulable {
+		return fmt.Errorf("node is unschedulable")
+	}
+	ready := false
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady && cond.Status == corev1.ConditionTrue {
+			ready = true
+		}
+	}
+	if !ready {
+		return fmt.Errorf("node is not ready")
+	}
+	if needRdma {
+		if quantity, ok := node.Status.Allocatable[constant.ErdmaResourceKey]; !ok || quantity.IsZero() {
+			return fmt.Errorf("node has no rdma device %s", constant.ErdmaResourceKey)
+		}
+	}
+	if mounted, ok := node.Annotations[constant.ThreeFSStorageMountedPathsKey]; ok {
+		mountedPaths := make([]string, 0)
+		for _, path := range strings.Split(mounted, ",") {
+			mountedPaths = append(mountedPaths, strings.TrimSpace(path))
+		}
+		for _, path := range targetPaths {
+			if !utils.StrListContains(mountedPaths, path) {
+				return fmt.Errorf("target path %s is not mounted", path)
+			}
+		}
+	}
+	return nil
+}
+
+// GetNodeFreeDisk returns free disk capacity in bytes reported by annotation of the node
+func GetNodeFreeDisk(node *corev1.Node) (int64, error) {
+	val, ok := node.Annotations[constant.ThreeFSStorageFreeDiskKey]
+	if !ok {
+		return -1, fmt.Errorf("node does not report free disk by annotation %s", constant.ThreeFSStorageFreeDiskKey)
+	}
+	quantity, err := resource.ParseQuantity(val)
+	if err != nil {
+		return -1, fmt.Errorf("free disk %s is invalid: %v", val, err)
+	}
+	return quantity.Value(), nil
+}
+
+// RankBackupNodes sorts candidates by strategies in order, a later strategy only breaks ties of the former ones.
+// Nodes with unknown mounted paths always come after nodes verified to have all target paths mounted
+func RankBackupNodes(candidates []BackupNodeCandidate, strategies []string, oldDomain string) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.MountsUnknown != b.MountsUnknown {
+			return !a.MountsUnknown
+		}
+		for _, strategy := range strategies {
+			switch strategy {
+			case constant.BackupNodeStrategySameZone:
+				if oldDomain != "" && (a.Domain == oldDomain) != (b.Domain == oldDomain) {
+					return a.Domain == oldDomain
+				}
+			case constant.BackupNodeStrategyMostFreeDisk:
+				if a.FreeDisk != b.FreeDisk {
+					return a.FreeDisk > b.FreeDisk
+				}
+			case constant.BackupNodeStrategyLeastRecentlyFailed:
+				// nodes never failed have zero fault time and come first
+				if !a.LastFaultTime.Equal(b.LastFaultTime) {
+					return a.LastFaultTime.Before(b.LastFaultTime)
+				}
+			}
+		}
+		return false
+	})
+}
+
+func describeBackupNode(candidate BackupNodeCandidate, oldDomain string) string {
+	reasons := make([]string, 0)
+	if candidate.Domain != "" {
+		if candidate.Domain == oldDomain {
+			reasons = append(reasons, fmt.Sprintf("same failure domain %s", candidate.Domain))
+		} else {
+			reasons = append(reasons, fmt.Sprintf("failure domain %s differs from %s", candidate.Domain, oldDomain))
+		}
+	}
+	if candidate.MountsUnknown {
+		reasons = append(reasons, "mounted paths unknown")
+	}
+	if candidate.FreeDisk >= 0 {
+		reasons = append(reasons, fmt.Sprintf("free disk %s", resource.NewQuantity(candidate.FreeDisk, resource.BinarySI).String()))
+	} else {
+		reasons = append(reasons, "free disk unknown")
+	}
+	if candidate.LastFaultTime.IsZero() {
+		reasons = append(reasons, "never failed")
+	} else {
+		reasons = append(reasons, fmt.Sprintf("last failed at %s", candidate.LastFaultTime.Format(constant.TimeLayout)))
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// SelectBackupNode selects the storage backup node to replace oldNode. Backup nodes used by unfinished tfsct, with
+// a different disk layout from the old node or failing the pre-flight check are rejected, the others are ranked by
+// strategies of the backup node policy
+func SelectBackupNode(rclient client.Client, threeFsCluster *threefsv1.ThreeFsCluster, jobs []threefsv1.ThreeFsChainTable, oldNode string) BackupNodeDecision {
+	policy := threeFsCluster.Spec.Storage.BackupNodePolicy
+	decision := BackupNodeDecision{
+		Strategies: GetBackupNodeStrategies(policy),
+		Rejected:   make(map[string]string),
+	}
+	if !CheckStorageBackup(threeFsCluster) {
+		decision.Reason = "no storage backup node"
+		return decision
+	}
+	blacklist := utils.StrListContains(decision.Strategies, constant.BackupNodeStrategyLeastRecentlyFailed)
+	cooldown := GetBackupNodeFailureCooldown(policy)
+	needRdma := !utils.GetUseHostNetworkEnv()
+
+	// old node may be removed from the cluster already, then its disk layout and failure domain are unknown
+	oldLayout, oldErr := storage.GetNodeLayout(rclient, threeFsCluster.Spec.Storage, oldNode)
+	oldDomain, _ := storage.GetNodeDomain(rclient, threeFsCluster.Spec.Storage, oldNode)
+
+	candidates := make([]BackupNodeCandidate, 0)
+	for _, name := range threeFsCluster.Status.NodesInfo.StorageBackupNodes {
+		if IsNodeClaimedByTfsct(jobs, name) {
+			decision.Rejected[name] = "used by unfinished ThreeFsChainTable"
+			continue
+		}
+		node := &corev1.Node{}
+		if err := rclient.Get(context.Background(), client.ObjectKey{Name: name}, node); err != nil {
+			klog.Errorf("get node %s failed: %v", name, err)
+			decision.Rejected[name] = fmt.Sprintf("get node failed: %v", err)
+			continue
+		}
+		layout, err := storage.GetNodeLayout(rclient, threeFsCluster.Spec.Storage, name)
+		if err != nil {
+			decision.Rejected[name] = fmt.Sprintf("get disk layout failed: %v", err)
+			continue
+		}
+		if oldErr == nil && layout.Group != oldLayout.Group {
+			decision.Rejected[name] = fmt.Sprintf("node group %q differs from %q of old node", layout.Group, oldLayout.Group)
+			continue
+		}
+		if err := PreflightBackupNode(node, layout.TargetPaths, needRdma); err != nil {
+			decision.Rejected[name] = err.Error()
+			continue
+		}
+
+		candidate := BackupNodeCandidate{Name: name, FreeDisk: -1}
+		if _, ok := node.Annotations[constant.ThreeFSStorageMountedPathsKey]; !ok && len(layout.TargetPaths) > 0 {
+			candidate.MountsUnknown = true
+		}
+		if key := threeFsCluster.Spec.Storage.TopologyKey; key != "" {
+			candidate.Domain = node.Labels[key]
+		}
+		if freeDisk, err := GetNodeFreeDisk(node); err == nil {
+			candidate.FreeDisk = freeDisk
+		} else if _, ok := node.Annotations[constant.ThreeFSStorageFreeDiskKey]; ok {
+			klog.Warningf("node %s: %v", name, err)
+		}
+		if val, ok := node.Annotations[constant.ThreeFSStorageLastFaultTimeKey]; ok {
+			if faultTime, err := time.ParseInLocation(constant.TimeLayout, val, time.Local); err == nil {
+				candidate.LastFaultTime = faultTime
+			}
+		}
+		if blacklist && !candidate.LastFaultTime.IsZero() && time.Since(candidate.LastFaultTime) < cooldown {
+			decision.Rejected[name] = fmt.Sprintf("failed at %s, within cooldown %s", candidate.LastFaultTime.Format(constant.TimeLayout), cooldown)
+			continue
+		}
+		candidates = append(candidates, candidate)
+	}
+	if len(candidates) == 0 {
+		decision.Reason = "no backup node passes the pre-flight check"
+		return decision
+	}
+
+	RankBackupNodes(candidates, decision.Strategies, oldDomain)
+	decision.Node = candidates[0].Name
+	decision.Reason = describeBackupNode(candidates[0], oldDomain)
+	return decision
+}
+
+// FormatRejectedBackupNodes returns rejected backup nodes and reasons sorted by node name
+func FormatRejectedBackupNodes(rejected map[string]string) string {
+	nodes := make([]string, 0, len(rejected))
+	for node := range rejected {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	items := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		items = append(items, fmt.Sprintf("%s: %s", node, rejected[node]))
+	}
+	return strings.Join(items, "; ")
+}
+
+// CreateAutoReplaceTfsct selects a backup node for the faulty storage node and creates a replace tfsct, the decision
+// is recorded as an event and annotations of the tfsct. False is returned if no backup node is available
+func (r *ThreeFsClusterReconciler) CreateAutoReplaceTfsct(threeFsCluster *threefsv1.ThreeFsCluster, jobs []threefsv1.ThreeFsChainTable, plainOldName string) (bool, error) {
+	decision := SelectBackupNode(r.Client, threeFsCluster, jobs, plainOldName)
+	rejected := FormatRejectedBackupNodes(decision.Rejected)
+	if decision.Node == "" {
+		klog.Errorf("storage %s status is not healthy, but no available storage backup node: %s, rejected: %s", plainOldName, decision.Reason, rejected)
+		r.Recorder.Event(threeFsCluster, corev1.EventTypeWarning, "StorageNotHealthy",
+			fmt.Sprintf("storage %s not healthy, %s, rejected backup nodes: %s", plainOldName, decision.Reason, rejected))
+		return false, nil
+	}
+
+	tfsctName := fmt.Sprintf("tfsct-replace-%s", utils.GenerateUuidWithLen(6))
+	klog.Infof("create tfsct %s for replace(newNode: %s, oldName:%s), %s", tfsctName, decision.Node, plainOldName, decision.Reason)
+	tfsctLabels := map[string]string{
+		constant.ThreeFSAutoReplaceLabel: "true",
+	}
+	tfsctAnnotations := map[string]string{
+		constant.ThreeFSBackupNodeStrategyKey: strings.Join(decision.Strategies, ","),
+		constant.ThreeFSBackupNodeReasonKey:   decision.Reason,
+	}
+	if rejected != "" {
+		tfsctAnnotations[constant.ThreeFSBackupNodeRejectedKey] = rejected
+	}
+	if err := r.CreateTfsct(tfsctName, threeFsCluster.Name, threeFsCluster.Namespace, decision.Node, plainOldName, tfsctLabels, tfsctAnnotations); err != nil {
+		klog.Errorf("create tfsct %s failed: %v", tfsctName, err)
+		return false, err
+	}
+	r.Recorder.Event(threeFsCluster, corev1.EventTypeNormal, "BackupNodeSelected",
+		fmt.Sprintf("select backup node %s to replace storage %s by tfsct %s with strategies %s: %s",
+			decision.Node, plainOldName, tfsctName, strings.Join(decision.Strategies, ","), decision.Reason))
+	return true, nil
+}
@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newBackupNode(name string, annotations map[string]string) *corev1.Node {
+	node := &corev1.Node{}
+	node.Name = name
+	node.Annotations = annotations
+	node.Status.Conditions = []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}}
+	node.Status.Allocatable = corev1.ResourceList{constant.ErdmaResourceKey: resource.MustParse("1")}
+	return node
+}
+
+func TestRankBackupNodes(t *testing.T) {
+	failedAt := time.Now().Add(-48 * time.Hour)
+	candidates := []BackupNodeCandidate{
+		{Name: "node-a", Domain: "zone-b", FreeDisk: 300},
+		{Name: "node-b", Domain: "zone-a", FreeDisk: 100, LastFaultTime: failedAt},
+		{Name: "node-c", Domain: "zone-a", FreeDisk: 200},
+	}
+
+	RankBackupNodes(candidates, []string{constant.BackupNodeStrategySameZone, constant.BackupNodeStrategyMostFreeDisk}, "zone-a")
+	assert.Equal(t, "node-c", candidates[0].Name)
+	assert.Equal(t, "node-b", candidates[1].Name)
+
+	RankBackupNodes(candidates, []string{constant.BackupNodeStrategyMostFreeDisk}, "zone-a")
+	assert.Equal(t, "node-a", candidates[0].Name)
+
+	// nodes with unknown mounted paths come last whatever the strategies
+	candidates[0].MountsUnknown = true
+	RankBackupNodes(candidates, []string{constant.BackupNodeStrategyMostFreeDisk}, "zone-a")
+	assert.Equal(t, "node-a", candidates[2].Name)
+	candidates[2].MountsUnknown = false
+
+	// nodes never failed come first
+	RankBackupNodes(candidates, []string{constant.BackupNodeStrategyLeastRecentlyFailed}, "")
+	assert.Equal(t, "node-b", candidates[2].Name)
+}
+
+func TestPreflightBackupNode(t *testing.T) {
+	node := &corev1.Node{}
+	node.Status.Conditions = []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}}
+	assert.NoError(t, PreflightBackupNode(node, nil, false))
+	assert.Error(t, PreflightBackupNode(node, nil, true))
+	// mounted paths are unknown without the annotation, the node is ranked last instead
+	assert.NoError(t, PreflightBackupNode(node, []string{"/storage/data0/3fs"}, false))
+
+	node.Status.Allocatable = corev1.ResourceList{constant.ErdmaResourceKey: resource.MustParse("1")}
+	assert.NoError(t, PreflightBackupNode(node, nil, true))
+
+	node.Annotations = map[string]string{constant.ThreeFSStorageMountedPathsKey: "/storage/data0/3fs, /storage/data1/3fs"}
+	assert.NoError(t, PreflightBackupNode(node, []string{"/storage/data0/3fs", "/storage/data1/3fs"}, true))
+	assert.Error(t, PreflightBackupNode(node, []string{"/storage/data2/3fs"}, true))
+
+	node.Status.Conditions[0].Status = corev1.ConditionFalse
+	assert.Error(t, PreflightBackupNode(node, nil, false))
+}
+
+func TestGetNodeFreeDisk(t *testing.T) {
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		freeDisk    int64
+		err         bool
+	}{
+		{name: "absent", annotations: nil, freeDisk: -1, err: true},
+		{name: "invalid", annotations: map[string]string{constant.ThreeFSStorageFreeDiskKey: "lots"}, freeDisk: -1, err: true},
+		{name: "valid", annotations: map[string]string{constant.ThreeFSStorageFreeDiskKey: "2Ki"}, freeDisk: 2048},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			freeDisk, err := GetNodeFreeDisk(newBackupNode("node", c.annotations))
+			assert.Equal(t, c.freeDisk, freeDisk)
+			assert.Equal(t, c.err, err != nil)
+		})
+	}
+}
+
+func TestSelectBackupNode(t *testing.T) {
+	paths := "/storage/data0/3fs"
+	nodes := []client.Object{
+		newBackupNode("no-annotation", nil),
+		newBackupNode("no-free-disk", map[string]string{constant.ThreeFSStorageMountedPathsKey: paths}),
+		newBackupNode("small", map[string]string{constant.ThreeFSStorageMountedPathsKey: paths, constant.ThreeFSStorageFreeDiskKey: "1Ti"}),
+		newBackupNode("large", map[string]string{constant.ThreeFSStorageMountedPathsKey: paths, constant.ThreeFSStorageFreeDiskKey: "2Ti"}),
+	}
+	tfsc := &threefsv1.ThreeFsCluster{}
+	tfsc.Spec.Storage.TargetPaths = []string{paths}
+	tfsc.Status.NodesInfo.StorageBackupNodes = []string{"no-annotation", "no-free-disk", "small", "large"}
+
+	// nodes with unknown mounted paths are ranked last
+	decision := SelectBackupNode(newFakeClient(nodes...), tfsc, nil, "old")
+	assert.Equal(t, "no-free-disk", decision.Node)
+	assert.Empty(t, decision.Rejected)
+
+	// nodes with unknown free disk are ranked last by MostFreeDisk
+	tfsc.Spec.Storage.BackupNodePolicy.Strategies = []string{constant.BackupNodeStrategyMostFreeDisk}
+	decision = SelectBackupNode(newFakeClient(nodes...), tfsc, nil, "old")
+	assert.Equal(t, "large", decision.Node)
+
+	// unannotated nodes are still selected if no other node is available
+	tfsc.Status.NodesInfo.StorageBackupNodes = []string{"no-annotation"}
+	decision = SelectBackupNode(newFakeClient(nodes...), tfsc, nil, "old")
+	assert.Equal(t, "no-annotation", decision.Node)
+	assert.Contains(t, decision.Reason, "mounted paths unknown")
+}
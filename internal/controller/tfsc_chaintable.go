@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"fmt"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	clientcomm "github.com/aliyun/kvc-3fs-operator/internal/client"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/aliyun/kvc-3fs-operator/internal/storage"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// placeChainTable creates targets and chains on the storage nodes of the chain table and uploads the table
+func (r *ThreeFsClusterReconciler) placeChainTable(adminCliConfig *clientcomm.AdminCliConfig, tfsc *threefsv1.ThreeFsCluster, token string, groups []storage.PlacementGroup) error {
+	maps, err := ParseMaxChainIdForEachDisk(adminCliConfig)
+	if err != nil {
+		return err
+	}
+	targetPath, placed, err := PlaceStorageGroups(r.Client, tfsc, groups, maps)
+	if err != nil {
+		return err
+	}
+	if err := adminCliConfig.CreateTarget(token, targetPath); err != nil {
+		return err
+	}
+	if err := UploadPlacedChainTables(adminCliConfig, token, tfsc, placed); err != nil {
+		return err
+	}
+	return UpdateChainTablesStatus(r.Client, tfsc, placed)
+}
+
+// HandlePendingChainTables places chain tables which are not placed yet, e.g. tables appended to spec for node
+// groups whose nodes have no targets. Each table is placed independently. It returns true if a table is waiting
+func (r *ThreeFsClusterReconciler) HandlePendingChainTables(adminCliConfig *clientcomm.AdminCliConfig, tfsc *threefsv1.ThreeFsCluster) (bool, error) {
+	if len(tfsc.Status.ChainTables) == 0 && len(tfsc.Spec.ChainTables) == 0 {
+		// cluster placed before multiple chain tables are supported has the default table only
+		return false, UpdateChainTablesStatus(r.Client, tfsc, []PlacedChainTable{{Table: storage.GetDefaultChainTable(tfsc.Spec)}})
+	}
+
+	for _, table := range storage.GetChainTables(tfsc.Spec) {
+		if IsChainTablePlaced(tfsc, table.Id) {
+			continue
+		}
+		groups, err := storage.GroupStorageNodes(r.Client, tfsc.Spec.Storage, tfsc.Status.NodesInfo.StorageNodes)
+		if err != nil {
+			return false, err
+		}
+		tableGroups := make([]storage.PlacementGroup, 0)
+		for _, group := range groups {
+			if table.AllGroups || group.Group == table.NodeGroup {
+				tableGroups = append(tableGroups, group)
+			}
+		}
+		if len(tableGroups) == 0 {
+			klog.Infof("chain table %s of threeFsCluster %s has no storage node yet, skip", table.Id, tfsc.Name)
+			continue
+		}
+
+		jobs, err := ListUnfinishedTfsct(r.Client, tfsc.Name, tfsc.Namespace)
+		if err != nil {
+			return false, err
+		}
+		if len(jobs) > 0 || tfsc.Status.DiskExpansion.Phase != "" {
+			klog.Infof("threeFsCluster %s has unfinished chain table job or disk expansion, wait to place chain table %s", tfsc.Name, table.Id)
+			return true, nil
+		}
+
+		token, err := r.UserAdd(tfsc, adminCliConfig)
+		if err != nil {
+			return false, err
+		}
+		if _, err := TakeChainTableSnapshot(r.Client, r.Scheme, adminCliConfig, token, tfsc, "chain-table-"+table.Id, constant.ChainTableSnapshotStageBefore); err != nil {
+			klog.Warningf("take chain table snapshot before placing chain table %s failed: %v", table.Id, err)
+		}
+		if err := r.placeChainTable(adminCliConfig, tfsc, token, tableGroups); err != nil {
+			r.Recorder.Event(tfsc, corev1.EventTypeWarning, "ChainTablePlaceFailed", fmt.Sprintf("place chain table %s failed: %v", table.Id, err))
+			return false, err
+		}
+		if _, err := TakeChainTableSnapshot(r.Client, r.Scheme, adminCliConfig, token, tfsc, "chain-table-"+table.Id, constant.ChainTableSnapshotStageAfter); err != nil {
+			klog.Warningf("take chain table snapshot after placing chain table %s failed: %v", table.Id, err)
+		}
+		klog.Infof("chain table %s of threeFsCluster %s placed", table.Id, tfsc.Name)
+		r.Recorder.Event(tfsc, corev1.EventTypeNormal, "ChainTablePlaced", fmt.Sprintf("chain table %s placed on node group %q", table.Id, table.NodeGroup))
+	}
+	return false, nil
+}
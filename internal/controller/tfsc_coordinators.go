@@ -0,0 +1,194 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	fdbv1beta2 "github.com/FoundationDB/fdb-kubernetes-operator/api/v1beta2"
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	clientcomm "github.com/aliyun/kvc-3fs-operator/internal/client"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// FdbCoordinatorCandidate is an fdb process which can be a coordinator
+type FdbCoordinatorCandidate struct {
+	Address string
+	Node    string
+	Domain  string
+}
+
+// GetFdbCoordinatorCandidates returns the process with the lowest port on each node ordered by node, excluded
+// processes are skipped. domains maps node name to failure domain, the node itself is the domain if not found
+func GetFdbCoordinatorCandidates(details *fdbv1beta2.FoundationDBStatus, domains map[string]string) []FdbCoordinatorCandidate {
+	candidates := make(map[string]FdbCoordinatorCandidate)
+	ports := make(map[string]int)
+	for _, process := range details.Cluster.Processes {
+		node := process.Locality["machineid"]
+		if process.Excluded || node == "" {
+			continue
+		}
+		if port, ok := ports[node]; ok && port <= process.Address.Port {
+			continue
+		}
+		domain := domains[node]
+		if domain == "" {
+			domain = node
+		}
+		ports[node] = process.Address.Port
+		candidates[node] = FdbCoordinatorCandidate{
+			Address: process.Address.String(),
+			Node:    node,
+			Domain:  domain,
+		}
+	}
+	result := make([]FdbCoordinatorCandidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		result = append(result, candidate)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Node < result[j].Node
+	})
+	return result
+}
+
+// SelectFdbCoordinators selects num coordinators on different nodes spread across failure domains. Current
+// coordinators on candidate nodes are kept unless their domain has more than its share of coordinators
+func SelectFdbCoordinators(current []string, candidates []FdbCoordinatorCandidate, num int) []string {
+	if num > len(candidates) {
+		num = len(candidates)
+	}
+	if num <= 0 {
+		return []string{}
+	}
+	domains := make(map[string]bool)
+	ipCandidates := make(map[string]FdbCoordinatorCandidate)
+	for _, candidate := range candidates {
+		domains[candidate.Domain] = true
+		ipCandidates[strings.Split(candidate.Address, ":")[0]] = candidate
+	}
+	maxPerDomain := (num + len(domains) - 1) / len(domains)
+
+	selected := make([]string, 0, num)
+	usedNodes := make(map[string]bool)
+	domainCount := make(map[string]int)
+	sortedCurrent := append([]string{}, current...)
+	sort.Strings(sortedCurrent)
+	for _, addr := range sortedCurrent {
+		candidate, ok := ipCandidates[strings.Split(addr, ":")[0]]
+		if !ok || usedNodes[candidate.Node] || domainCount[candidate.Domain] >= maxPerDomain || len(selected) >= num {
+			continue
+		}
+		selected = append(selected, addr)
+		usedNodes[candidate.Node] = true
+		domainCount[candidate.Domain]++
+	}
+	for len(selected) < num {
+		best := -1
+		for idx, candidate := range candidates {
+			if usedNodes[candidate.Node] {
+				continue
+			}
+			if best < 0 || domainCount[candidate.Domain] < domainCount[candidates[best].Domain] {
+				best = idx
+			}
+		}
+		selected = append(selected, candidates[best].Address)
+		usedNodes[candidates[best].Node] = true
+		domainCount[candidates[best].Domain]++
+	}
+	sort.Strings(selected)
+	return selected
+}
+
+// IsFdbCoordinatorsChangeRequired returns true if any coordinator is unreachable or not on a candidate node,
+// or the number of coordinators is not num
+func IsFdbCoordinatorsChangeRequired(details *fdbv1beta2.FoundationDBStatus, candidates []FdbCoordinatorCandidate, num int) bool {
+	coordinators := details.Client.Coordinators.Coordinators
+	if len(coordinators) != num || len(GetUnreachableFdbCoordinators(details)) > 0 {
+		return true
+	}
+	ips := make(map[string]bool)
+	for _, candidate := range candidates {
+		ips[strings.Split(candidate.Address, ":")[0]] = true
+	}
+	for _, coordinator := range coordinators {
+		if !ips[getProcessIp(coordinator.Address)] {
+			return true
+		}
+	}
+	return false
+}
+
+// getFdbNodeDomains returns failure domains of nodes by fdb topologyKey, empty if topologyKey is not set
+func (r *ThreeFsClusterReconciler) getFdbNodeDomains(tfsc *threefsv1.ThreeFsCluster) (map[string]string, error) {
+	domains := make(map[string]string)
+	if tfsc.Spec.Fdb.TopologyKey == "" {
+		return domains, nil
+	}
+	nodeList := &corev1.NodeList{}
+	if err := r.List(context.Background(), nodeList); err != nil {
+		klog.Errorf("list node failed: %v", err)
+		return nil, err
+	}
+	for _, node := range nodeList.Items {
+		domains[node.Name] = node.Labels[tfsc.Spec.Fdb.TopologyKey]
+	}
+	return domains, nil
+}
+
+func (r *ThreeFsClusterReconciler) updateFdbCoordinators(tfsc *threefsv1.ThreeFsCluster, coordinators []string) error {
+	localCache := threefsv1.ThreeFsCluster{}
+	if err := r.Get(context.Background(), client.ObjectKey{Name: tfsc.Name, Namespace: tfsc.Namespace}, &localCache); err != nil {
+		return err
+	}
+	newObj := localCache.DeepCopy()
+	newObj.Status.FdbCoordinators = coordinators
+	if err := r.Status().Patch(context.Background(), newObj, client.MergeFrom(&localCache)); err != nil {
+		klog.Errorf("update fdb coordinators of threeFsCluster %s failed: %v", tfsc.Name, err)
+		return err
+	}
+	tfsc.Status.FdbCoordinators = coordinators
+	return nil
+}
+
+// ReconcileFdbCoordinators keeps coordinators in status, and selects new coordinators spread across failure domains
+// only if any of them is unreachable or excluded, or the number of them is not coordinatorNum
+func (r *ThreeFsClusterReconciler) ReconcileFdbCoordinators(fdbcliConfig *clientcomm.FdbcliConfig, tfsc *threefsv1.ThreeFsCluster, details *fdbv1beta2.FoundationDBStatus) error {
+	current := make([]string, 0)
+	for _, coordinator := range details.Client.Coordinators.Coordinators {
+		current = append(current, coordinator.Address.String())
+	}
+	sort.Strings(current)
+	domains, err := r.getFdbNodeDomains(tfsc)
+	if err != nil {
+		return err
+	}
+	candidates := GetFdbCoordinatorCandidates(details, domains)
+	selected := SelectFdbCoordinators(current, candidates, fdbcliConfig.GetCoordinatorNum())
+	if len(selected) == 0 || reflect.DeepEqual(selected, current) || !IsFdbCoordinatorsChangeRequired(details, candidates, len(selected)) {
+		if len(current) > 0 && !reflect.DeepEqual(tfsc.Status.FdbCoordinators, current) {
+			return r.updateFdbCoordinators(tfsc, current)
+		}
+		return nil
+	}
+
+	unreachable := GetUnreachableFdbCoordinators(details)
+	klog.Infof("change fdb coordinators of threeFsCluster %s from %v to %v, unreachable: %v", tfsc.Name, current, selected, unreachable)
+	if output, _, err := fdbcliConfig.SetCoordinators(selected); err != nil {
+		klog.Errorf("set fdb coordinators %v failed, output: %s, err: %+v", selected, output, err)
+		return err
+	}
+	if err := r.updateFdbCoordinators(tfsc, selected); err != nil {
+		return err
+	}
+	r.Recorder.Event(tfsc, corev1.EventTypeNormal, "FdbCoordinatorsChanged", fmt.Sprintf("change fdb coordinators from %s to %s", strings.Join(current, ","), strings.Join(selected, ",")))
+	if len(unreachable) > 0 {
+		recordFdbFaultStep(tfsc, FdbFaultStepCoordinatorsChanged)
+	}
+	return nil
+}
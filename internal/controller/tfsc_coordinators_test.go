@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	fdbv1beta2 "github.com/FoundationDB/fdb-kubernetes-operator/api/v1beta2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFdbCoordinatorCandidates(t *testing.T) {
+	address := func(ip string, port int) fdbv1beta2.ProcessAddress {
+		return fdbv1beta2.ProcessAddress{IPAddress: net.ParseIP(ip), Port: port}
+	}
+	details := &fdbv1beta2.FoundationDBStatus{}
+	details.Cluster.Processes = map[fdbv1beta2.ProcessGroupID]fdbv1beta2.FoundationDBStatusProcessInfo{
+		"p1": {Address: address("10.0.0.1", 4510), Locality: map[string]string{"machineid": "node1"}},
+		"p2": {Address: address("10.0.0.1", 4500), Locality: map[string]string{"machineid": "node1"}},
+		"p3": {Address: address("10.0.0.2", 4500), Locality: map[string]string{"machineid": "node2"}},
+		"p4": {Address: address("10.0.0.3", 4500), Locality: map[string]string{"machineid": "node3"}, Excluded: true},
+	}
+	candidates := GetFdbCoordinatorCandidates(details, map[string]string{"node1": "zone-a"})
+	assert.Equal(t, []FdbCoordinatorCandidate{
+		{Address: "10.0.0.1:4500", Node: "node1", Domain: "zone-a"},
+		{Address: "10.0.0.2:4500", Node: "node2", Domain: "node2"},
+	}, candidates)
+
+	details.Client.Coordinators.Coordinators = []fdbv1beta2.FoundationDBStatusCoordinator{
+		{Address: address("10.0.0.1", 4500), Reachable: true},
+		{Address: address("10.0.0.2", 4500), Reachable: true},
+	}
+	assert.False(t, IsFdbCoordinatorsChangeRequired(details, candidates, 2))
+	assert.True(t, IsFdbCoordinatorsChangeRequired(details, candidates, 1))
+	// coordinator on an excluded node
+	details.Client.Coordinators.Coordinators[1].Address = address("10.0.0.3", 4500)
+	assert.True(t, IsFdbCoordinatorsChangeRequired(details, candidates, 2))
+	details.Client.Coordinators.Coordinators[1] = fdbv1beta2.FoundationDBStatusCoordinator{Address: address("10.0.0.2", 4500)}
+	assert.True(t, IsFdbCoordinatorsChangeRequired(details, candidates, 2))
+}
+
+func TestSelectFdbCoordinators(t *testing.T) {
+	candidates := make([]FdbCoordinatorCandidate, 0)
+	for i := 1; i <= 6; i++ {
+		candidates = append(candidates, FdbCoordinatorCandidate{
+			Address: fmt.Sprintf("10.0.0.%d:4500", i),
+			Node:    fmt.Sprintf("node%d", i),
+			Domain:  fmt.Sprintf("zone-%d", (i-1)/2),
+		})
+	}
+	// spread across zones
+	assert.Equal(t, []string{"10.0.0.1:4500", "10.0.0.3:4500", "10.0.0.5:4500"},
+		SelectFdbCoordinators(nil, candidates, 3))
+	assert.Equal(t, []string{"10.0.0.1:4500", "10.0.0.2:4500", "10.0.0.3:4500", "10.0.0.4:4500", "10.0.0.5:4500"},
+		SelectFdbCoordinators(nil, candidates, 5))
+	// current coordinators are kept, the unreachable 10.0.0.9 is replaced in its zone share
+	assert.Equal(t, []string{"10.0.0.2:4500", "10.0.0.4:4500", "10.0.0.5:4500"},
+		SelectFdbCoordinators([]string{"10.0.0.2:4500", "10.0.0.4:4500", "10.0.0.9:4500"}, candidates, 3))
+	// coordinators in the same zone are moved out
+	assert.Equal(t, []string{"10.0.0.1:4500", "10.0.0.3:4500", "10.0.0.5:4500"},
+		SelectFdbCoordinators([]string{"10.0.0.1:4500", "10.0.0.2:4500", "10.0.0.3:4500"}, candidates, 3))
+	// limited by candidates
+	assert.Len(t, SelectFdbCoordinators(nil, candidates[:2], 5), 2)
+	assert.Empty(t, SelectFdbCoordinators(nil, nil, 3))
+}
@@ -0,0 +1,32 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// TagEvictionNamespaceLabel labels the namespace of the cluster, the eviction webhook only selects labeled namespaces
+func TagEvictionNamespaceLabel(rclient client.Client, namespace string) error {
+	ns := &corev1.Namespace{}
+	if err := rclient.Get(context.Background(), client.ObjectKey{Name: namespace}, ns); err != nil {
+		klog.Errorf("get namespace %s failed: %v", namespace, err)
+		return err
+	}
+	if ns.Labels[constant.ThreeFSEvictionNamespaceLabel] == "enabled" {
+		return nil
+	}
+	if ns.Labels == nil {
+		ns.Labels = make(map[string]string)
+	}
+	ns.Labels[constant.ThreeFSEvictionNamespaceLabel] = "enabled"
+	if err := rclient.Update(context.Background(), ns); err != nil {
+		klog.Errorf("tag namespace %s with eviction webhook label failed: %v", namespace, err)
+		return err
+	}
+	klog.Infof("tag namespace %s with eviction webhook label success", namespace)
+	return nil
+}
@@ -0,0 +1,22 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestTagEvictionNamespaceLabel(t *testing.T) {
+	ns := &corev1.Namespace{}
+	ns.Name = "default"
+	rclient := newFakeClient(ns)
+	assert.NoError(t, TagEvictionNamespaceLabel(rclient, "default"))
+	assert.NoError(t, rclient.Get(context.Background(), client.ObjectKey{Name: "default"}, ns))
+	assert.Equal(t, "enabled", ns.Labels[constant.ThreeFSEvictionNamespaceLabel])
+
+	assert.Error(t, TagEvictionNamespaceLabel(rclient, "other"))
+}
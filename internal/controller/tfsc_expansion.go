@@ -0,0 +1,222 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	clientcomm "github.com/aliyun/kvc-3fs-operator/internal/client"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/aliyun/kvc-3fs-operator/internal/storage"
+	"github.com/aliyun/kvc-3fs-operator/internal/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GetNewTargetPaths returns target paths in spec which have no targets and chains yet
+func GetNewTargetPaths(tfsc *threefsv1.ThreeFsCluster) []string {
+	placed := tfsc.Status.DiskExpansion.TargetPaths
+	if len(placed) == 0 || len(tfsc.Spec.Storage.TargetPaths) <= len(placed) {
+		return nil
+	}
+	return tfsc.Spec.Storage.TargetPaths[len(placed):]
+}
+
+func (r *ThreeFsClusterReconciler) updateDiskExpansionStatus(threeFsCluster *threefsv1.ThreeFsCluster, expansion threefsv1.DiskExpansion) error {
+	localCache := threefsv1.ThreeFsCluster{}
+	_ = r.Get(context.Background(), client.ObjectKey{Name: threeFsCluster.Name, Namespace: threeFsCluster.Namespace}, &localCache)
+
+	modifiedObj := localCache.DeepCopy()
+	modifiedObj.Status.DiskExpansion = expansion
+	threeFsCluster.Status.DiskExpansion = expansion
+	return r.Client.Status().Patch(context.Background(), modifiedObj, client.MergeFrom(&localCache))
+}
+
+// rollStorageTargetPaths mounts all target paths into storage deployments one by one. A deployment is
+// updated only when all updated deployments are available and their targets are UPTODATE
+func (r *ThreeFsClusterReconciler) rollStorageTargetPaths(adminCliConfig *clientcomm.AdminCliConfig, tfsc *threefsv1.ThreeFsCluster, storageConfig *storage.StorageConfig) (bool, string, error) {
+	deployList := &appsv1.DeploymentList{}
+	if err := r.Client.List(context.Background(), deployList, client.InNamespace(tfsc.Namespace), client.MatchingLabels{constant.ThreeFSStorageDeployKey: tfsc.Name}); err != nil {
+		klog.Errorf("list deployment failed: %v", err)
+		return false, "", err
+	}
+
+	updated := 0
+	for _, deploy := range deployList.Items {
+		if !storageConfig.IsDeployTargetPathsUpToDate(&deploy) {
+			continue
+		}
+		updated++
+	}
+	process := fmt.Sprintf("%d/%d", updated, len(deployList.Items))
+
+	for _, deploy := range deployList.Items {
+		if !storageConfig.IsDeployTargetPathsUpToDate(&deploy) {
+			continue
+		}
+		deployNodeName := deploy.Spec.Template.Spec.NodeSelector[constant.KubernetesHostnameKey]
+		if deploy.Status.ObservedGeneration < deploy.Generation || deploy.Status.UpdatedReplicas != deploy.Status.Replicas ||
+			deploy.Status.AvailableReplicas != deploy.Status.Replicas {
+			klog.Infof("storage deploy on node %s is not available yet, wait", deployNodeName)
+			return false, process, nil
+		}
+		if !CheckComponentStatus(adminCliConfig, "STORAGE", utils.TranslatePlainNodeName3fs(deployNodeName), false, r.Client) || !CheckTargetStatus(adminCliConfig, deployNodeName) {
+			klog.Infof("storage deploy on node %s is not ready yet, wait", deployNodeName)
+			return false, process, nil
+		}
+	}
+
+	for _, deploy := range deployList.Items {
+		if storageConfig.IsDeployTargetPathsUpToDate(&deploy) {
+			continue
+		}
+		// one by one
+		if err := storageConfig.UpdateDeployTargetPaths(&deploy); err != nil {
+			return false, process, err
+		}
+		return false, process, nil
+	}
+	return true, process, nil
+}
+
+// placeDataOnNewTargetPaths creates targets and chains on the new disks of storage nodes without node group,
+// then appends the new chains to the chain table
+func (r *ThreeFsClusterReconciler) placeDataOnNewTargetPaths(adminCliConfig *clientcomm.AdminCliConfig, tfsc *threefsv1.ThreeFsCluster, token string, newPaths []string) error {
+	groups, err := storage.GroupStorageNodes(r.Client, tfsc.Spec.Storage, tfsc.Status.NodesInfo.StorageNodes)
+	if err != nil {
+		return err
+	}
+	if len(groups) == 0 || groups[0].Group != "" {
+		klog.Infof("threeFsCluster %s has no storage node without node group, no target to create", tfsc.Name)
+		return nil
+	}
+	group := groups[0]
+	group.NumDisks = len(newPaths)
+	group.DiskOffset = len(tfsc.Status.DiskExpansion.TargetPaths)
+
+	maps, err := ParseMaxChainIdForEachDisk(adminCliConfig)
+	if err != nil {
+		return err
+	}
+	targetPath, placed, err := PlaceStorageGroups(r.Client, tfsc, []storage.PlacementGroup{group}, maps)
+	if err != nil {
+		return err
+	}
+	if len(placed) == 0 {
+		klog.Infof("threeFsCluster %s storage nodes without node group are not placed into any chain table", tfsc.Name)
+		return nil
+	}
+
+	if err := adminCliConfig.CreateTarget(token, targetPath); err != nil {
+		return err
+	}
+	if err := UploadPlacedChainTables(adminCliConfig, token, tfsc, placed); err != nil {
+		return err
+	}
+	return UpdateChainTablesStatus(r.Client, tfsc, placed)
+}
+
+// takeDiskExpansionSnapshot takes the chain table snapshot around disk expansion once
+func (r *ThreeFsClusterReconciler) takeDiskExpansionSnapshot(adminCliConfig *clientcomm.AdminCliConfig, tfsc *threefsv1.ThreeFsCluster, token, stage string) error {
+	expansion := tfsc.Status.DiskExpansion
+	if (stage == constant.ChainTableSnapshotStageBefore && expansion.Snapshots.Before != "") ||
+		(stage == constant.ChainTableSnapshotStageAfter && expansion.Snapshots.After != "") {
+		return nil
+	}
+	name, err := TakeChainTableSnapshot(r.Client, r.Scheme, adminCliConfig, token, tfsc, "disk-expansion", stage)
+	if err != nil {
+		klog.Errorf("take chain table snapshot %s disk expansion failed: %v", stage, err)
+		return err
+	}
+	if stage == constant.ChainTableSnapshotStageBefore {
+		expansion.Snapshots.Before = name
+	} else {
+		expansion.Snapshots.After = name
+	}
+	return r.updateDiskExpansionStatus(tfsc, expansion)
+}
+
+// HandleTargetPathsExpansion adds target paths appended to spec to all storage nodes: the storage main config
+// is uploaded again, storage deployments are updated one by one with the new hostPath volumes, then targets
+// and chains are created on the new disks. It returns true if the expansion is in progress
+func (r *ThreeFsClusterReconciler) HandleTargetPathsExpansion(adminCliConfig *clientcomm.AdminCliConfig, tfsc *threefsv1.ThreeFsCluster, storageConfig *storage.StorageConfig) (bool, error) {
+	expansion := *tfsc.Status.DiskExpansion.DeepCopy()
+	if len(expansion.TargetPaths) == 0 {
+		// cluster created before disk expansion is supported, all paths in spec are placed
+		expansion.TargetPaths = tfsc.Spec.Storage.TargetPaths
+		return false, r.updateDiskExpansionStatus(tfsc, expansion)
+	}
+	newPaths := GetNewTargetPaths(tfsc)
+	if len(newPaths) == 0 {
+		return false, nil
+	}
+
+	switch expansion.Phase {
+	case "":
+		jobs, err := ListUnfinishedTfsct(r.Client, tfsc.Name, tfsc.Namespace)
+		if err != nil {
+			return false, err
+		}
+		if len(jobs) > 0 {
+			klog.Infof("threeFsCluster %s has unfinished chain table job %s, wait to add target paths", tfsc.Name, jobs[0].Name)
+			return true, nil
+		}
+		if err := adminCliConfig.UploadMainConfig("STORAGE", filepath.Join(constant.DefaultConfigPath, constant.ThreeFSStorageMain)); err != nil {
+			return false, err
+		}
+		expansion.Phase = constant.DiskExpansionRollingVolumesStatus
+		expansion.Process = ""
+		expansion.Snapshots = threefsv1.ChainTableSnapshotRef{}
+		if err := r.updateDiskExpansionStatus(tfsc, expansion); err != nil {
+			return false, err
+		}
+		r.Recorder.Event(tfsc, corev1.EventTypeNormal, "DiskExpansionStarted", fmt.Sprintf("add target paths %v to storage nodes", newPaths))
+		return true, nil
+	case constant.DiskExpansionRollingVolumesStatus:
+		done, process, err := r.rollStorageTargetPaths(adminCliConfig, tfsc, storageConfig)
+		if err != nil {
+			return false, err
+		}
+		expansion.Process = process
+		if done {
+			expansion.Phase = constant.DiskExpansionPlacingDataStatus
+		}
+		if expansion.Process != tfsc.Status.DiskExpansion.Process || expansion.Phase != tfsc.Status.DiskExpansion.Phase {
+			if err := r.updateDiskExpansionStatus(tfsc, expansion); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	case constant.DiskExpansionPlacingDataStatus:
+		token, err := r.UserAdd(tfsc, adminCliConfig)
+		if err != nil {
+			return false, err
+		}
+		if err := r.takeDiskExpansionSnapshot(adminCliConfig, tfsc, token, constant.ChainTableSnapshotStageBefore); err != nil {
+			r.Recorder.Event(tfsc, corev1.EventTypeWarning, "SnapshotFailed", fmt.Sprintf("take before disk expansion snapshot failed: %v", err))
+			return false, err
+		}
+		if err := r.placeDataOnNewTargetPaths(adminCliConfig, tfsc, token, newPaths); err != nil {
+			r.Recorder.Event(tfsc, corev1.EventTypeWarning, "DiskExpansionFailed", err.Error())
+			return false, err
+		}
+		if err := r.takeDiskExpansionSnapshot(adminCliConfig, tfsc, token, constant.ChainTableSnapshotStageAfter); err != nil {
+			// chains are already uploaded, do not place data again
+			r.Recorder.Event(tfsc, corev1.EventTypeWarning, "SnapshotFailed", fmt.Sprintf("take after disk expansion snapshot failed: %v", err))
+		}
+
+		expansion = *tfsc.Status.DiskExpansion.DeepCopy()
+		expansion.TargetPaths = tfsc.Spec.Storage.TargetPaths
+		expansion.Phase = ""
+		expansion.Process = ""
+		if err := r.updateDiskExpansionStatus(tfsc, expansion); err != nil {
+			return false, err
+		}
+		klog.Infof("threeFsCluster %s target paths %v added", tfsc.Name, newPaths)
+		r.Recorder.Event(tfsc, corev1.EventTypeNormal, "DiskExpansionFinished", fmt.Sprintf("target paths %v added", newPaths))
+	}
+	return false, nil
+}
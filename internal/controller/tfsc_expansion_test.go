@@ -0,0 +1,29 @@
+package controller
+
+import (
+	"testing"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetNewTargetPaths(t *testing.T) {
+	testCases := []struct {
+		name        string
+		targetPaths []string
+		placed      []string
+		expect      []string
+	}{
+		{name: "not recorded yet", targetPaths: []string{"/data0", "/data1"}, placed: nil, expect: nil},
+		{name: "no new paths", targetPaths: []string{"/data0"}, placed: []string{"/data0"}, expect: nil},
+		{name: "new paths appended", targetPaths: []string{"/data0", "/data1", "/data2"}, placed: []string{"/data0"}, expect: []string{"/data1", "/data2"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tfsc := &threefsv1.ThreeFsCluster{}
+			tfsc.Spec.Storage.TargetPaths = tc.targetPaths
+			tfsc.Status.DiskExpansion.TargetPaths = tc.placed
+			assert.Equal(t, tc.expect, GetNewTargetPaths(tfsc))
+		})
+	}
+}
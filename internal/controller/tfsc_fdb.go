@@ -0,0 +1,458 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	fdbv1beta2 "github.com/FoundationDB/fdb-kubernetes-operator/api/v1beta2"
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	clientcomm "github.com/aliyun/kvc-3fs-operator/internal/client"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/aliyun/kvc-3fs-operator/internal/fdb"
+	"github.com/aliyun/kvc-3fs-operator/internal/native_resources"
+	"github.com/aliyun/kvc-3fs-operator/internal/utils"
+	"github.com/aliyun/kvc-3fs-operator/internal/validation"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GetFdbMachineNum returns number of fdb nodes with processes which are not excluded
+func GetFdbMachineNum(details *fdbv1beta2.FoundationDBStatus) int {
+	machines := make(map[string]bool)
+	for _, process := range details.Cluster.Processes {
+		if !process.Excluded {
+			machines[process.Locality["machineid"]] = true
+		}
+	}
+	return len(machines)
+}
+
+// GetFdbRoleCounts returns role counts of fdb configuration, proxies are commit proxies and grv proxies
+func GetFdbRoleCounts(details *fdbv1beta2.FoundationDBStatus) threefsv1.FdbRoleCounts {
+	configuration := details.Cluster.DatabaseConfiguration
+	proxies := configuration.Proxies
+	if configuration.CommitProxies+configuration.GrvProxies > 0 {
+		proxies = configuration.CommitProxies + configuration.GrvProxies
+	}
+	return threefsv1.FdbRoleCounts{
+		Logs:      configuration.Logs,
+		Proxies:   proxies,
+		Resolvers: configuration.Resolvers,
+	}
+}
+
+// IsFdbRoleCountsSatisfied returns true if roles set in desired are configured
+func IsFdbRoleCountsSatisfied(desired, current threefsv1.FdbRoleCounts) bool {
+	return (desired.Logs == 0 || desired.Logs == current.Logs) &&
+		(desired.Proxies == 0 || desired.Proxies == current.Proxies) &&
+		(desired.Resolvers == 0 || desired.Resolvers == current.Resolvers)
+}
+
+// ConfigureFdbRoles configures counts of logs, proxies and resolvers in spec.fdb.roleCounts
+func (r *ThreeFsClusterReconciler) ConfigureFdbRoles(fdbcliConfig *clientcomm.FdbcliConfig, tfsc *threefsv1.ThreeFsCluster, details *fdbv1beta2.FoundationDBStatus) error {
+	roles := tfsc.Spec.Fdb.RoleCounts
+	current := GetFdbRoleCounts(details)
+	if IsFdbRoleCountsSatisfied(roles, current) {
+		return nil
+	}
+	if output, _, err := fdbcliConfig.ConfigureRoles(roles.Logs, roles.Proxies, roles.Resolvers); err != nil {
+		klog.Errorf("configure fdb roles %+v failed, output: %s", roles, output)
+		return err
+	}
+	klog.Infof("configure fdb roles of threeFsCluster %s from %+v to %+v", tfsc.Name, current, roles)
+	r.Recorder.Event(tfsc, corev1.EventTypeNormal, "FdbRolesConfigured", fmt.Sprintf("configure fdb logs=%d proxies=%d resolvers=%d", roles.Logs, roles.Proxies, roles.Resolvers))
+	return nil
+}
+
+// RollFdbProcesses updates fdb deployments one by one after spec.fdb.processes changed
+func (r *ThreeFsClusterReconciler) RollFdbProcesses(fdbConfig *fdb.FdbConfig, tfsc *threefsv1.ThreeFsCluster) (bool, error) {
+	content, err := fdbConfig.GetConfigContent()
+	if err != nil {
+		return false, err
+	}
+	deployName, rolling, err := fdbConfig.UpdateDeployProcesses(content)
+	if err != nil {
+		return false, err
+	}
+	if deployName != "" {
+		r.Recorder.Event(tfsc, corev1.EventTypeNormal, "FdbProcessesUpdated", fmt.Sprintf("update fdb processes of deployment %s", deployName))
+	}
+	return rolling, nil
+}
+
+// IsFdbDataDistributed returns true if data is fully replicated and no data is moving
+func IsFdbDataDistributed(details *fdbv1beta2.FoundationDBStatus) bool {
+	data := details.Cluster.Data
+	return data.State.Healthy && data.MovingData.InFlightBytes == 0 && data.MovingData.InQueueBytes == 0
+}
+
+func (r *ThreeFsClusterReconciler) updateFdbReconfiguration(tfsc *threefsv1.ThreeFsCluster, reconfiguration threefsv1.FdbReconfiguration) error {
+	localCache := threefsv1.ThreeFsCluster{}
+	if err := r.Get(context.Background(), client.ObjectKey{Name: tfsc.Name, Namespace: tfsc.Namespace}, &localCache); err != nil {
+		return err
+	}
+	newObj := localCache.DeepCopy()
+	newObj.Status.FdbReconfiguration = reconfiguration
+	if err := r.Status().Patch(context.Background(), newObj, client.MergeFrom(&localCache)); err != nil {
+		return err
+	}
+	tfsc.Status.FdbReconfiguration = reconfiguration
+	return nil
+}
+
+// ReconfigureFdb applies changes of fdb storageReplicas and clusterSize to a running fdb cluster,
+// returns true until new processes joined, redundancy mode changed and data redistributed
+func (r *ThreeFsClusterReconciler) ReconfigureFdb(fdbcliConfig *clientcomm.FdbcliConfig, tfsc *threefsv1.ThreeFsCluster, details *fdbv1beta2.FoundationDBStatus) (bool, error) {
+	reconfiguration := tfsc.Status.FdbReconfiguration
+	mode := clientcomm.GetRedundancyMode(tfsc.Spec.Fdb.StorageReplicas)
+	clusterSize := tfsc.Spec.Fdb.ClusterSize
+	currentMode := string(details.Cluster.DatabaseConfiguration.RedundancyMode)
+	machineNum := GetFdbMachineNum(details)
+
+	if reconfiguration.RedundancyMode != mode || reconfiguration.ClusterSize != clusterSize {
+		// record the configuration of the created fdb cluster
+		if reconfiguration.RedundancyMode == "" && currentMode == mode && machineNum >= clusterSize {
+			return false, r.updateFdbReconfiguration(tfsc, threefsv1.FdbReconfiguration{
+				RedundancyMode: mode,
+				ClusterSize:    clusterSize,
+				Phase:          constant.FdbReconfigureCompletedStatus,
+			})
+		}
+		if err := validation.ValidateFdbReconfiguration(len(tfsc.Status.NodesInfo.FdbNodes), tfsc.Spec.Fdb.StorageReplicas, clusterSize); err != nil {
+			if reconfiguration.Message != err.Error() {
+				klog.Errorf("reconfigure fdb of threeFsCluster %s rejected: %v", tfsc.Name, err)
+				r.Recorder.Event(tfsc, corev1.EventTypeWarning, "FdbReconfigureRejected", err.Error())
+				reconfiguration.Message = err.Error()
+				return false, r.updateFdbReconfiguration(tfsc, reconfiguration)
+			}
+			return false, nil
+		}
+		klog.Infof("reconfigure fdb of threeFsCluster %s from %s with %d nodes to %s with cluster size %d", tfsc.Name, currentMode, machineNum, mode, clusterSize)
+		r.Recorder.Event(tfsc, corev1.EventTypeNormal, "FdbReconfiguring", fmt.Sprintf("reconfigure fdb from %s with %d nodes to %s with cluster size %d", currentMode, machineNum, mode, clusterSize))
+		return true, r.updateFdbReconfiguration(tfsc, threefsv1.FdbReconfiguration{
+			RedundancyMode: mode,
+			ClusterSize:    clusterSize,
+			Phase:          constant.FdbReconfigureAddingProcessesStatus,
+			StartTime:      time.Now().Format(constant.TimeLayout),
+		})
+	}
+
+	switch reconfiguration.Phase {
+	case constant.FdbReconfigureAddingProcessesStatus:
+		// new fdb deployments are created with cluster size, wait them join the cluster
+		if machineNum < clusterSize || !details.Cluster.Data.State.Healthy {
+			klog.Infof("wait fdb processes of threeFsCluster %s join, %d/%d nodes", tfsc.Name, machineNum, clusterSize)
+			return true, nil
+		}
+		if currentMode != mode {
+			if output, _, err := fdbcliConfig.ConfigureRedundancy(mode); err != nil {
+				klog.Errorf("configure fdb redundancy mode %s failed, output: %s, err: %+v", mode, output, err)
+				return true, err
+			}
+		}
+		reconfiguration.Phase = constant.FdbReconfigureRedistributingStatus
+		return true, r.updateFdbReconfiguration(tfsc, reconfiguration)
+	case constant.FdbReconfigureRedistributingStatus:
+		if currentMode != mode || !IsFdbDataDistributed(details) {
+			klog.Infof("wait fdb data of threeFsCluster %s redistributed: %s", tfsc.Name, details.Cluster.Data.State.Description)
+			return true, nil
+		}
+		reconfiguration.Phase = constant.FdbReconfigureCompletedStatus
+		reconfiguration.EndTime = time.Now().Format(constant.TimeLayout)
+		if err := r.updateFdbReconfiguration(tfsc, reconfiguration); err != nil {
+			return false, err
+		}
+		r.Recorder.Event(tfsc, corev1.EventTypeNormal, "FdbReconfigured", fmt.Sprintf("fdb is %s with %d nodes and data redistributed", mode, machineNum))
+	}
+	return false, nil
+}
+
+func getProcessIp(address fdbv1beta2.ProcessAddress) string {
+	return strings.Split(address.String(), ":")[0]
+}
+
+// IsFdbCoordinatorIp returns true if a coordinator is on the ip
+func IsFdbCoordinatorIp(details *fdbv1beta2.FoundationDBStatus, ip string) bool {
+	for _, coordinator := range details.Client.Coordinators.Coordinators {
+		if getProcessIp(coordinator.Address) == ip {
+			return true
+		}
+	}
+	return false
+}
+
+// GetFdbProcessesOnIp returns fdb processes on the ip
+func GetFdbProcessesOnIp(details *fdbv1beta2.FoundationDBStatus, ip string) []fdbv1beta2.FoundationDBStatusProcessInfo {
+	processes := make([]fdbv1beta2.FoundationDBStatusProcessInfo, 0)
+	for _, process := range details.Cluster.Processes {
+		if getProcessIp(process.Address) == ip {
+			processes = append(processes, process)
+		}
+	}
+	return processes
+}
+
+// IsFdbIpExcluded returns true if the ip or an address on it is in excluded_servers of the database configuration
+func IsFdbIpExcluded(details *fdbv1beta2.FoundationDBStatus, ip string) bool {
+	for _, server := range details.Cluster.DatabaseConfiguration.ExcludedServers {
+		if server.Address == ip || strings.HasPrefix(server.Address, ip+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFdbExclusionDone returns true if the ip is in excluded_servers, and processes on it are excluded and have no
+// storage or log data. Processes of a dead node are absent from status, so the exclusion itself must be recorded
+func IsFdbExclusionDone(details *fdbv1beta2.FoundationDBStatus, ip string) bool {
+	if !IsFdbIpExcluded(details, ip) {
+		return false
+	}
+	for _, process := range GetFdbProcessesOnIp(details, ip) {
+		if !process.Excluded {
+			return false
+		}
+		for _, role := range process.Roles {
+			if role.Role == "storage" || role.Role == "log" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (r *ThreeFsClusterReconciler) updateFdbNodeStatus(tfsc *threefsv1.ThreeFsCluster, nodeName string, status *threefsv1.FdbClusterStatus) error {
+	localCache := threefsv1.ThreeFsCluster{}
+	if err := r.Get(context.Background(), client.ObjectKey{Name: tfsc.Name, Namespace: tfsc.Namespace}, &localCache); err != nil {
+		return err
+	}
+	newObj := localCache.DeepCopy()
+	if newObj.Status.FdbStatus == nil {
+		newObj.Status.FdbStatus = make(map[string]threefsv1.FdbClusterStatus)
+	}
+	if status == nil {
+		delete(newObj.Status.FdbStatus, nodeName)
+	} else {
+		newObj.Status.FdbStatus[nodeName] = *status
+	}
+	if err := r.Status().Patch(context.Background(), newObj, client.MergeFrom(&localCache)); err != nil {
+		klog.Errorf("update fdb status of node %s failed: %v", nodeName, err)
+		return err
+	}
+	tfsc.Status.FdbStatus = newObj.Status.FdbStatus
+	return nil
+}
+
+// RemoveFdbNodes removes fdb from nodes leaving fdb nodes or beyond cluster size one by one: exclude processes,
+// move coordinators away, delete the deployment and include the node again, returns true while removing
+func (r *ThreeFsClusterReconciler) RemoveFdbNodes(fdbConfig *fdb.FdbConfig, fdbcliConfig *clientcomm.FdbcliConfig, tfsc *threefsv1.ThreeFsCluster, details *fdbv1beta2.FoundationDBStatus) (bool, error) {
+	nodes, err := fdbConfig.GetSurplusNodes()
+	if err != nil {
+		return false, err
+	}
+	// removals in progress are always finished
+	for nodeName, status := range tfsc.Status.FdbStatus {
+		if status.Removal != "" && !utils.StrListContains(nodes, nodeName) {
+			nodes = append(nodes, nodeName)
+		}
+	}
+	if len(nodes) == 0 {
+		return false, nil
+	}
+	sort.Strings(nodes)
+	nodeName := nodes[0]
+	for _, name := range nodes {
+		if tfsc.Status.FdbStatus[name].Removal != "" {
+			nodeName = name
+			break
+		}
+	}
+	status := tfsc.Status.FdbStatus[nodeName]
+	switch status.Removal {
+	case "":
+		ip, err := native_resources.NewNodeConfig(r.Client).ParseNodeIp(nodeName)
+		if err != nil || ip == "" {
+			klog.Errorf("get ip of fdb node %s failed: %v", nodeName, err)
+			return false, fmt.Errorf("get ip of fdb node %s failed", nodeName)
+		}
+		if output, _, err := fdbcliConfig.ExcludeServers([]string{ip}); err != nil {
+			klog.Errorf("exclude fdb node %s(%s) failed, output: %s, err: %+v", nodeName, ip, output, err)
+			return true, err
+		}
+		status.Name = nodeName
+		status.Removal = constant.FdbRemovalExcludingStatus
+		status.Address = ip
+		if err := r.updateFdbNodeStatus(tfsc, nodeName, &status); err != nil {
+			return true, err
+		}
+		klog.Infof("exclude fdb node %s(%s)", nodeName, ip)
+		r.Recorder.Event(tfsc, corev1.EventTypeNormal, "FdbNodeRemoving", fmt.Sprintf("exclude fdb processes on node %s(%s)", nodeName, ip))
+		recordFdbFaultStep(tfsc, FdbFaultStepRemoving)
+	case constant.FdbRemovalExcludingStatus:
+		if IsFdbCoordinatorIp(details, status.Address) {
+			klog.Infof("fdb node %s(%s) is coordinator, move coordinators", nodeName, status.Address)
+			return true, r.ReconcileFdbCoordinators(fdbcliConfig, tfsc, details)
+		}
+		if !IsFdbExclusionDone(details, status.Address) {
+			klog.Infof("wait exclusion of fdb node %s(%s) done", nodeName, status.Address)
+			return true, nil
+		}
+		if err := fdbConfig.DeleteNodeDeployIfExist(nodeName); err != nil {
+			return true, err
+		}
+		status.Removal = constant.FdbRemovalRemovingStatus
+		if err := r.updateFdbNodeStatus(tfsc, nodeName, &status); err != nil {
+			return true, err
+		}
+		klog.Infof("fdb node %s(%s) is excluded, delete fdb deployment", nodeName, status.Address)
+	case constant.FdbRemovalRemovingStatus:
+		if len(GetFdbProcessesOnIp(details, status.Address)) > 0 {
+			klog.Infof("wait fdb processes on node %s(%s) stopped", nodeName, status.Address)
+			return true, nil
+		}
+		if output, _, err := fdbcliConfig.IncludeServers([]string{status.Address}); err != nil {
+			klog.Errorf("include fdb node %s(%s) failed, output: %s, err: %+v", nodeName, status.Address, output, err)
+			return true, err
+		}
+		if err := r.updateFdbNodeStatus(tfsc, nodeName, nil); err != nil {
+			return true, err
+		}
+		klog.Infof("fdb node %s(%s) is removed", nodeName, status.Address)
+		r.Recorder.Event(tfsc, corev1.EventTypeNormal, "FdbNodeRemoved", fmt.Sprintf("fdb is removed from node %s(%s)", nodeName, status.Address))
+		recordFdbFaultStep(tfsc, FdbFaultStepRemoved)
+		return false, nil
+	}
+	return true, nil
+}
+
+// GetUnreachableFdbCoordinators returns addresses of unreachable coordinators
+func GetUnreachableFdbCoordinators(details *fdbv1beta2.FoundationDBStatus) []string {
+	addrs := make([]string, 0)
+	for _, coordinator := range details.Client.Coordinators.Coordinators {
+		if !coordinator.Reachable {
+			addrs = append(addrs, coordinator.Address.String())
+		}
+	}
+	return addrs
+}
+
+// recordFdbDeployCreated records fdb deployments created on nodes, they replace faulty nodes once fdb is ready
+func (r *ThreeFsClusterReconciler) recordFdbDeployCreated(tfsc *threefsv1.ThreeFsCluster, nodes []string) {
+	if len(nodes) == 0 {
+		return
+	}
+	faultyNodes := make([]string, 0)
+	for nodeName, status := range tfsc.Status.FdbStatus {
+		if status.IsDeleted {
+			faultyNodes = append(faultyNodes, nodeName)
+		}
+	}
+	sort.Strings(faultyNodes)
+	if tfsc.Status.ConfigStatus["fdb"] != constant.ThreeComponentReadyStatus || len(faultyNodes) == 0 {
+		klog.Infof("fdb deployments created on nodes %s", strings.Join(nodes, ","))
+		return
+	}
+	klog.Infof("fdb deployments created on nodes %s to replace faulty nodes %s", strings.Join(nodes, ","), strings.Join(faultyNodes, ","))
+	r.Recorder.Event(tfsc, corev1.EventTypeNormal, "FdbReplacementCreated", fmt.Sprintf("create fdb on nodes %s to replace faulty nodes %s", strings.Join(nodes, ","), strings.Join(faultyNodes, ",")))
+	recordFdbFaultStep(tfsc, FdbFaultStepReplacementCreated)
+}
+
+// ReconcileFdbOperatorCluster creates or updates the FoundationDBCluster of external-operator mode, and writes its
+// connection string into the fdb configmap once reconciled. Only the initial fdb configmap needs a reconciled
+// FoundationDBCluster, so false is returned until the configmap is created. While a created FoundationDBCluster
+// is updated, other components keep running with the existing cluster file
+func (r *ThreeFsClusterReconciler) ReconcileFdbOperatorCluster(fdbConfig *fdb.FdbConfig, tfsc *threefsv1.ThreeFsCluster) (bool, error) {
+	content, _ := fdbConfig.GetConfigContent()
+	configured := content != ""
+
+	// clients support the new version before the FoundationDB kubernetes operator upgrades fdb
+	staging, err := r.StageFdbClientLibraries(fdbConfig, tfsc)
+	if err != nil {
+		return false, err
+	}
+	if staging {
+		return configured, nil
+	}
+	cluster, changed, err := fdbConfig.CreateOrUpdateOperatorCluster(tfsc)
+	if err != nil {
+		return false, err
+	}
+	if changed {
+		r.Recorder.Event(tfsc, corev1.EventTypeNormal, "FdbOperatorClusterApplied", fmt.Sprintf("apply FoundationDBCluster %s", cluster.Name))
+		return configured, nil
+	}
+	if !fdb.IsOperatorClusterReconciled(cluster) {
+		klog.Infof("wait FoundationDBCluster %s reconciled, generation %d, reconciled %d, available %v",
+			cluster.Name, cluster.Generation, cluster.Status.Generations.Reconciled, cluster.Status.Health.Available)
+		return configured, nil
+	}
+	if err := fdbConfig.CreateFdbConfigIfNotExist(cluster.Status.ConnectionString); err != nil {
+		return false, err
+	}
+	if err := r.RecordFdbOperatorVersion(tfsc, cluster); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// InitExternalFdb checks the external fdb cluster of external mode and claims it by the owner key, an external fdb
+// cluster can not be shared by ThreeFsClusters
+func (r *ThreeFsClusterReconciler) InitExternalFdb(fdbcliConfig *clientcomm.FdbcliConfig, tfsc *threefsv1.ThreeFsCluster) error {
+	details, err := fdbcliConfig.ParseStatusOutput()
+	if err != nil {
+		r.Recorder.Event(tfsc, corev1.EventTypeWarning, "FdbExternalUnavailable", fmt.Sprintf("get status of external fdb cluster failed: %v", err))
+		return err
+	}
+	if err := fdb.ValidateExternalStatus(details, tfsc.Spec.Fdb.StorageReplicas); err != nil {
+		r.Recorder.Event(tfsc, corev1.EventTypeWarning, "FdbExternalUnavailable", err.Error())
+		return err
+	}
+	owner := fmt.Sprintf("%s/%s", tfsc.Namespace, tfsc.Name)
+	ownerKey := constant.FdbOwnerKeyPrefix + owner
+	owners, err := fdbcliConfig.ListKeys(constant.FdbOwnerKeyPrefix)
+	if err != nil {
+		return err
+	}
+	if _, ok := owners[ownerKey]; ok {
+		return nil
+	}
+	if len(owners) == 0 {
+		// owners are read again in the claim transaction, a concurrent claim of another cluster fails one of them
+		if owners, err = fdbcliConfig.ClaimKey(constant.FdbOwnerKeyPrefix, ownerKey, owner); err != nil {
+			return err
+		}
+		if len(owners) == 0 {
+			r.Recorder.Event(tfsc, corev1.EventTypeNormal, "FdbExternalClaimed", fmt.Sprintf("external fdb cluster is claimed by threeFsCluster %s", owner))
+			return nil
+		}
+		// claimed by another cluster before the transaction, the owner keeps it since its own key exists
+		if output, _, err := fdbcliConfig.ClearKey(ownerKey); err != nil {
+			klog.Errorf("clear owner key %s failed, output: %s, err: %+v", ownerKey, output, err)
+			return err
+		}
+	}
+	others := make([]string, 0, len(owners))
+	for _, value := range owners {
+		others = append(others, value)
+	}
+	sort.Strings(others)
+	r.Recorder.Event(tfsc, corev1.EventTypeWarning, "FdbExternalClaimed", fmt.Sprintf("external fdb cluster is used by threeFsCluster %s", strings.Join(others, ",")))
+	return fmt.Errorf("external fdb cluster is used by threeFsCluster %s", strings.Join(others, ","))
+}
+
+// ReleaseExternalFdb clears the owner key of the ThreeFsCluster in the external fdb cluster, so that the fdb
+// cluster can be used by another ThreeFsCluster. Data of 3fs in fdb is kept
+func (r *ThreeFsClusterReconciler) ReleaseExternalFdb(fdbcliConfig *clientcomm.FdbcliConfig, tfsc *threefsv1.ThreeFsCluster) error {
+	ownerKey := constant.FdbOwnerKeyPrefix + fmt.Sprintf("%s/%s", tfsc.Namespace, tfsc.Name)
+	if output, _, err := fdbcliConfig.ClearKey(ownerKey); err != nil {
+		klog.Errorf("clear owner key %s failed, output: %s, err: %+v", ownerKey, output, err)
+		r.Recorder.Event(tfsc, corev1.EventTypeWarning, "FdbExternalReleaseFailed", fmt.Sprintf("clear owner key %s of external fdb cluster failed: %v", ownerKey, err))
+		return err
+	}
+	klog.Infof("external fdb cluster is released by threeFsCluster %s/%s", tfsc.Namespace, tfsc.Name)
+	return nil
+}
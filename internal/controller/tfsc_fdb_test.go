@@ -0,0 +1,260 @@
+package controller
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	fdbv1beta2 "github.com/FoundationDB/fdb-kubernetes-operator/api/v1beta2"
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	clientcomm "github.com/aliyun/kvc-3fs-operator/internal/client"
+	"github.com/aliyun/kvc-3fs-operator/internal/fdb"
+	"github.com/aliyun/kvc-3fs-operator/internal/validation"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestFdbDataDistributed(t *testing.T) {
+	details := &fdbv1beta2.FoundationDBStatus{}
+	details.Cluster.Processes = map[fdbv1beta2.ProcessGroupID]fdbv1beta2.FoundationDBStatusProcessInfo{
+		"p1": {Locality: map[string]string{"machineid": "node1"}},
+		"p2": {Locality: map[string]string{"machineid": "node1"}},
+		"p3": {Locality: map[string]string{"machineid": "node2"}},
+		"p4": {Locality: map[string]string{"machineid": "node3"}, Excluded: true},
+	}
+	assert.Equal(t, 2, GetFdbMachineNum(details))
+
+	details.Cluster.Data.State.Healthy = true
+	details.Cluster.Data.MovingData.InQueueBytes = 1024
+	assert.False(t, IsFdbDataDistributed(details))
+	details.Cluster.Data.MovingData.InQueueBytes = 0
+	assert.True(t, IsFdbDataDistributed(details))
+	details.Cluster.Data.State.Healthy = false
+	assert.False(t, IsFdbDataDistributed(details))
+}
+
+func TestFdbProcesses(t *testing.T) {
+	processes := map[string]threefsv1.FdbProcessSpec{
+		"storage": {
+			Count:     2,
+			DataPaths: []string{"/nvme0/fdb", "/nvme1/fdb"},
+			Resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("8Gi")},
+			},
+		},
+		"log":       {Count: 1},
+		"stateless": {Count: 1},
+	}
+	assert.NoError(t, fdb.ValidateProcesses(processes))
+	assert.Error(t, fdb.ValidateProcesses(map[string]threefsv1.FdbProcessSpec{"log": {Count: 1}}))
+	assert.Error(t, fdb.ValidateProcesses(map[string]threefsv1.FdbProcessSpec{"proxy": {Count: 1}}))
+	assert.Error(t, fdb.ValidateProcesses(map[string]threefsv1.FdbProcessSpec{"storage": {Count: 11}}))
+
+	assert.Equal(t, []string{"/opt/3fs/fdb/data", "/nvme0/fdb", "/nvme1/fdb"}, fdb.GetDataPaths(processes))
+	// ports of a class are kept when counts of other classes change
+	result := fdb.GetProcesses(processes, 4500)
+	assert.Equal(t, []fdb.FdbProcess{
+		{Class: "storage", Port: 4510, MountPath: "/var/lib/foundationdb/data-1", Memory: "8192MiB"},
+		{Class: "storage", Port: 4511, MountPath: "/var/lib/foundationdb/data-2", Memory: "8192MiB"},
+		{Class: "log", Port: 4520, MountPath: "/var/lib/foundationdb/data"},
+		{Class: "stateless", Port: 4540, MountPath: "/var/lib/foundationdb/data"},
+	}, result)
+	conf := fdb.GetMonitorConf(result)
+	assert.True(t, strings.Contains(conf, "[fdbserver.4511]\nclass = storage\ndatadir = /var/lib/foundationdb/data-2/$ID\nmemory = 8192MiB\n"))
+	assert.True(t, strings.Contains(conf, "[fdbserver.4540]\nclass = stateless\n"))
+
+	defaultResources := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+	}
+	resources := fdb.GetProcessResources(processes, defaultResources)
+	assert.Equal(t, int64(16), resources.Limits.Memory().Value()>>30)
+	assert.Equal(t, int64(2), resources.Requests.Cpu().Value())
+}
+
+func TestFdbRoleCounts(t *testing.T) {
+	details := &fdbv1beta2.FoundationDBStatus{}
+	details.Cluster.DatabaseConfiguration.Logs = 3
+	details.Cluster.DatabaseConfiguration.CommitProxies = 3
+	details.Cluster.DatabaseConfiguration.GrvProxies = 1
+	current := GetFdbRoleCounts(details)
+	assert.Equal(t, threefsv1.FdbRoleCounts{Logs: 3, Proxies: 4}, current)
+
+	assert.True(t, IsFdbRoleCountsSatisfied(threefsv1.FdbRoleCounts{}, current))
+	assert.True(t, IsFdbRoleCountsSatisfied(threefsv1.FdbRoleCounts{Logs: 3, Proxies: 4}, current))
+	assert.False(t, IsFdbRoleCountsSatisfied(threefsv1.FdbRoleCounts{Logs: 3, Resolvers: 2}, current))
+
+	assert.NoError(t, validation.ValidateFdbSpec(threefsv1.FdbSpec{StorageEngine: "ssd-redwood-1", RoleCounts: threefsv1.FdbRoleCounts{Proxies: 4}}))
+	assert.Error(t, validation.ValidateFdbSpec(threefsv1.FdbSpec{StorageEngine: "btree"}))
+	assert.Error(t, validation.ValidateFdbSpec(threefsv1.FdbSpec{RoleCounts: threefsv1.FdbRoleCounts{Proxies: 1}}))
+}
+
+func TestFdbExclusion(t *testing.T) {
+	address := func(ip string, port int) fdbv1beta2.ProcessAddress {
+		return fdbv1beta2.ProcessAddress{IPAddress: net.ParseIP(ip), Port: port}
+	}
+	details := &fdbv1beta2.FoundationDBStatus{}
+	details.Client.Coordinators.Coordinators = []fdbv1beta2.FoundationDBStatusCoordinator{
+		{Address: address("10.0.0.1", 4500), Reachable: true},
+	}
+	details.Cluster.Processes = map[fdbv1beta2.ProcessGroupID]fdbv1beta2.FoundationDBStatusProcessInfo{
+		"p1": {Address: address("10.0.0.1", 4500)},
+		"p2": {Address: address("10.0.0.2", 4500), Excluded: true, Roles: []fdbv1beta2.FoundationDBStatusProcessRoleInfo{{Role: "storage"}}},
+		"p3": {Address: address("10.0.0.2", 4510), Excluded: true},
+	}
+	assert.True(t, IsFdbCoordinatorIp(details, "10.0.0.1"))
+	assert.False(t, IsFdbCoordinatorIp(details, "10.0.0.2"))
+	assert.Len(t, GetFdbProcessesOnIp(details, "10.0.0.2"), 2)
+
+	assert.False(t, IsFdbExclusionDone(details, "10.0.0.1"))
+	details.Cluster.DatabaseConfiguration.ExcludedServers = []fdbv1beta2.ExcludedServers{{Address: "10.0.0.2"}, {Address: "10.0.0.30:4500:tls"}}
+	assert.True(t, IsFdbIpExcluded(details, "10.0.0.2"))
+	assert.True(t, IsFdbIpExcluded(details, "10.0.0.30"))
+	assert.False(t, IsFdbIpExcluded(details, "10.0.0.3"))
+	// storage data is still on the excluded process
+	assert.False(t, IsFdbExclusionDone(details, "10.0.0.2"))
+	details.Cluster.Processes["p2"] = fdbv1beta2.FoundationDBStatusProcessInfo{Address: address("10.0.0.2", 4500), Excluded: true}
+	assert.True(t, IsFdbExclusionDone(details, "10.0.0.2"))
+	// processes of a down node are not in status, the exclusion must be in excluded_servers
+	assert.False(t, IsFdbExclusionDone(details, "10.0.0.3"))
+	assert.True(t, IsFdbExclusionDone(details, "10.0.0.30"))
+}
+
+func TestUnreachableFdbCoordinators(t *testing.T) {
+	details := &fdbv1beta2.FoundationDBStatus{}
+	assert.Empty(t, GetUnreachableFdbCoordinators(details))
+	details.Client.Coordinators.Coordinators = []fdbv1beta2.FoundationDBStatusCoordinator{
+		{Address: fdbv1beta2.ProcessAddress{IPAddress: net.ParseIP("10.0.0.1"), Port: 4500}, Reachable: true},
+		{Address: fdbv1beta2.ProcessAddress{IPAddress: net.ParseIP("10.0.0.2"), Port: 4500}, Reachable: false},
+	}
+	assert.Equal(t, []string{"10.0.0.2:4500"}, GetUnreachableFdbCoordinators(details))
+}
+
+func TestFdbOperatorCluster(t *testing.T) {
+	spec := threefsv1.FdbSpec{
+		Mode:            "external-operator",
+		ClusterSize:     3,
+		StorageReplicas: 2,
+		Processes: map[string]threefsv1.FdbProcessSpec{
+			"storage": {Count: 2},
+			"log":     {Count: 1},
+		},
+		RoleCounts:  threefsv1.FdbRoleCounts{Logs: 3, Proxies: 4},
+		TopologyKey: "topology.kubernetes.io/zone",
+	}
+	assert.True(t, fdb.IsExternalOperatorMode(spec))
+	assert.NoError(t, validation.ValidateFdbSpec(spec))
+	assert.Error(t, validation.ValidateFdbSpec(threefsv1.FdbSpec{Mode: "external"}))
+
+	clusterSpec := fdb.GetOperatorClusterSpec(spec)
+	assert.Equal(t, fdbv1beta2.RedundancyMode("double"), clusterSpec.DatabaseConfiguration.RedundancyMode)
+	assert.Equal(t, fdbv1beta2.StorageEngine("ssd"), clusterSpec.DatabaseConfiguration.StorageEngine)
+	assert.Equal(t, fdbv1beta2.RoleCounts{Logs: 3, GrvProxies: 1, CommitProxies: 3}, clusterSpec.DatabaseConfiguration.RoleCounts)
+	assert.Equal(t, fdbv1beta2.ProcessCounts{Storage: 6, Log: 3}, clusterSpec.ProcessCounts)
+	assert.Equal(t, "topology.kubernetes.io/zone", clusterSpec.FaultDomain.Key)
+	assert.Equal(t, fdbv1beta2.ProcessCounts{Storage: 3}, fdb.GetOperatorClusterSpec(threefsv1.FdbSpec{ClusterSize: 3}).ProcessCounts)
+
+	cluster := &fdbv1beta2.FoundationDBCluster{Spec: clusterSpec}
+	cluster.Generation = 2
+	cluster.Status.Generations.Reconciled = 1
+	cluster.Status.ConnectionString = "test:abc@10.0.0.1:4501"
+	cluster.Status.Health.Available = true
+	assert.False(t, fdb.IsOperatorClusterReconciled(cluster))
+	cluster.Status.Generations.Reconciled = 2
+	assert.True(t, fdb.IsOperatorClusterReconciled(cluster))
+}
+
+func TestFdbExternal(t *testing.T) {
+	spec := threefsv1.FdbSpec{
+		Mode:     "external",
+		External: &threefsv1.FdbExternalSpec{ConnectionString: "test:abc@10.0.0.1:4500,10.0.0.2:4500\n"},
+	}
+	assert.True(t, fdb.IsExternalMode(spec))
+	assert.False(t, fdb.IsBuiltinMode(spec))
+	assert.NoError(t, validation.ValidateFdbSpec(spec))
+	assert.Error(t, validation.ValidateFdbSpec(threefsv1.FdbSpec{Mode: "external"}))
+	assert.Error(t, validation.ValidateFdbSpec(threefsv1.FdbSpec{Mode: "external", External: &threefsv1.FdbExternalSpec{ConnectionString: "10.0.0.1:4500"}}))
+	assert.Error(t, validation.ValidateFdbSpec(threefsv1.FdbSpec{External: spec.External}))
+	assert.True(t, fdb.IsBuiltinMode(threefsv1.FdbSpec{}))
+
+	details := &fdbv1beta2.FoundationDBStatus{}
+	assert.Error(t, fdb.ValidateExternalStatus(details, 2))
+	details.Client.DatabaseStatus.Available = true
+	details.Cluster.DatabaseConfiguration.RedundancyMode = "single"
+	assert.Error(t, fdb.ValidateExternalStatus(details, 2))
+	details.Cluster.DatabaseConfiguration.RedundancyMode = "triple"
+	assert.NoError(t, fdb.ValidateExternalStatus(details, 2))
+
+	value, ok := clientcomm.ParseGetOutput("`threefs-operator/owner/default/tfsc' is `default/tfsc'\n")
+	assert.True(t, ok)
+	assert.Equal(t, "default/tfsc", value)
+	_, ok = clientcomm.ParseGetOutput("`threefs-operator/owner/default/tfsc': not found\n")
+	assert.False(t, ok)
+	// truncated output without the closing quote
+	_, ok = clientcomm.ParseGetOutput("`threefs-operator/owner/default/tfsc' is `default/tfsc")
+	assert.False(t, ok)
+
+	owners := clientcomm.ParseGetRangeOutput("\nRange limited to 25 keys\n" +
+		"`threefs-operator/owner/default/a' is `default/a'\n" +
+		"`threefs-operator/owner/test/b' is `test/b'\n" +
+		"Committed (12345)\n")
+	assert.Equal(t, map[string]string{
+		"threefs-operator/owner/default/a": "default/a",
+		"threefs-operator/owner/test/b":    "test/b",
+	}, owners)
+	assert.Empty(t, clientcomm.ParseGetRangeOutput("\nRange limited to 25 keys\n"))
+}
+
+func TestReconcileFdbOperatorCluster(t *testing.T) {
+	tfsc := &threefsv1.ThreeFsCluster{}
+	tfsc.Name, tfsc.Namespace = "test", "default"
+	tfsc.Spec.Fdb = threefsv1.FdbSpec{Mode: "external-operator", ClusterSize: 3, StorageReplicas: 2}
+	rclient := newFakeClient(tfsc)
+	r := &ThreeFsClusterReconciler{Client: rclient, Scheme: rclient.Scheme(), Recorder: record.NewFakeRecorder(10)}
+	fdbConfig := fdb.NewFdbConfig(tfsc.Name, tfsc.Namespace, 2, 3, nil, 4500, corev1.ResourceRequirements{}, rclient, nil, nil, rclient.Scheme())
+
+	// the fdb configmap is created only after the FoundationDBCluster is reconciled
+	ready, err := r.ReconcileFdbOperatorCluster(fdbConfig, tfsc)
+	assert.NoError(t, err)
+	assert.False(t, ready)
+	ready, err = r.ReconcileFdbOperatorCluster(fdbConfig, tfsc)
+	assert.NoError(t, err)
+	assert.False(t, ready)
+
+	cluster := &fdbv1beta2.FoundationDBCluster{}
+	assert.NoError(t, rclient.Get(context.Background(), client.ObjectKey{Name: "test-fdb", Namespace: "default"}, cluster))
+	cluster.Status.ConnectionString = "test:first@10.0.0.1:4501"
+	cluster.Status.Generations.Reconciled = cluster.Generation
+	cluster.Status.Health.Available = true
+	assert.NoError(t, rclient.Update(context.Background(), cluster))
+	ready, err = r.ReconcileFdbOperatorCluster(fdbConfig, tfsc)
+	assert.NoError(t, err)
+	assert.True(t, ready)
+
+	// other components keep running with the existing cluster file while the FoundationDBCluster is updated
+	tfsc.Spec.Fdb.StorageReplicas = 3
+	ready, err = r.ReconcileFdbOperatorCluster(fdbConfig, tfsc)
+	assert.NoError(t, err)
+	assert.True(t, ready)
+	assert.NoError(t, rclient.Get(context.Background(), client.ObjectKey{Name: "test-fdb", Namespace: "default"}, cluster))
+	cluster.Status.ConnectionString = "test:second@10.0.0.2:4501"
+	cluster.Status.Generations.Reconciled = cluster.Generation - 1
+	assert.NoError(t, rclient.Update(context.Background(), cluster))
+	ready, err = r.ReconcileFdbOperatorCluster(fdbConfig, tfsc)
+	assert.NoError(t, err)
+	assert.True(t, ready)
+	content, _ := fdbConfig.GetConfigContent()
+	assert.Equal(t, "test:first@10.0.0.1:4501", content)
+
+	// the connection string is propagated once reconciled
+	cluster.Status.Generations.Reconciled = cluster.Generation
+	assert.NoError(t, rclient.Update(context.Background(), cluster))
+	ready, err = r.ReconcileFdbOperatorCluster(fdbConfig, tfsc)
+	assert.NoError(t, err)
+	assert.True(t, ready)
+	content, _ = fdbConfig.GetConfigContent()
+	assert.Equal(t, "test:second@10.0.0.2:4501", content)
+}
@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	clientcomm "github.com/aliyun/kvc-3fs-operator/internal/client"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/aliyun/kvc-3fs-operator/internal/fdb"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GetFdbTLSOptions returns tls options of fdbcli, fdbbackup and fdbrestore run by the operator, nil if tls is disabled
+func GetFdbTLSOptions(tfsc *threefsv1.ThreeFsCluster) *clientcomm.FdbTLSOptions {
+	if !fdb.IsTLSEnabled(tfsc.Spec.Fdb) {
+		return nil
+	}
+	return fdb.GetTLSOptions(fdb.GetTLSDir(tfsc.Namespace, tfsc.Name), fdb.GetTLSVerifyPeers(tfsc.Spec.Fdb))
+}
+
+func (r *ThreeFsClusterReconciler) updateFdbTLS(tfsc *threefsv1.ThreeFsCluster, status threefsv1.FdbTLSStatus) error {
+	localCache := threefsv1.ThreeFsCluster{}
+	if err := r.Get(context.Background(), client.ObjectKey{Name: tfsc.Name, Namespace: tfsc.Namespace}, &localCache); err != nil {
+		return err
+	}
+	newObj := localCache.DeepCopy()
+	newObj.Status.FdbTLS = status
+	if err := r.Status().Patch(context.Background(), newObj, client.MergeFrom(&localCache)); err != nil {
+		return err
+	}
+	tfsc.Status.FdbTLS = status
+	return nil
+}
+
+// ReconcileFdbTLS prepares fdb tls certificates of the cert-manager Certificate or generated by the operator, and
+// writes them for fdbcli of the operator. Renewed certificates are updated in the same secret with the same ca, so
+// mounted files of fdb, mgmtd and meta pods are updated in place without restarting pods. Returns the secret name
+// and false if the certificate is not ready
+func (r *ThreeFsClusterReconciler) ReconcileFdbTLS(fdbConfig *fdb.FdbConfig, tfsc *threefsv1.ThreeFsCluster) (string, bool, error) {
+	if !fdb.IsTLSEnabled(tfsc.Spec.Fdb) {
+		return "", true, nil
+	}
+
+	var secret *corev1.Secret
+	if ref := tfsc.Spec.Fdb.TLS.CertificateRef; ref != "" {
+		certSecret, ready, err := fdbConfig.GetCertificateSecret(ref)
+		if err != nil {
+			r.Recorder.Event(tfsc, corev1.EventTypeWarning, "FdbTLSCertificateNotReady", fmt.Sprintf("get certificate %s failed: %v", ref, err))
+			return "", false, err
+		}
+		if !ready {
+			klog.Infof("certificate %s of threeFsCluster %s is not ready", ref, tfsc.Name)
+			r.Recorder.Event(tfsc, corev1.EventTypeWarning, "FdbTLSCertificateNotReady", fmt.Sprintf("certificate %s is not ready", ref))
+			return "", false, nil
+		}
+		secret = certSecret
+	} else {
+		generated, rotated, err := fdbConfig.CreateOrRotateTLSSecret(tfsc)
+		if err != nil {
+			return "", false, err
+		}
+		secret = generated
+		if rotated {
+			r.Recorder.Event(tfsc, corev1.EventTypeNormal, "FdbTLSCertificateRotated", fmt.Sprintf("renew fdb tls certificate of secret %s", secret.Name))
+		}
+	}
+
+	if err := fdb.WriteTLSFiles(fdb.GetTLSDir(tfsc.Namespace, tfsc.Name), fdb.GetTLSData(secret.Data)); err != nil {
+		return "", false, err
+	}
+
+	notAfter, err := fdb.GetCertificateNotAfter(secret.Data["tls.crt"])
+	if err != nil {
+		klog.Errorf("parse fdb tls certificate of secret %s failed: %v", secret.Name, err)
+		return "", false, err
+	}
+	status := threefsv1.FdbTLSStatus{SecretName: secret.Name, NotAfter: notAfter.Local().Format(constant.TimeLayout)}
+	if tfsc.Status.FdbTLS != status {
+		// certificates of cert-manager are renewed outside of the operator
+		if tfsc.Spec.Fdb.TLS.CertificateRef != "" && tfsc.Status.FdbTLS.NotAfter != "" && tfsc.Status.FdbTLS.SecretName == secret.Name {
+			r.Recorder.Event(tfsc, corev1.EventTypeNormal, "FdbTLSCertificateRotated", fmt.Sprintf("fdb tls certificate of secret %s is renewed, expires at %s", secret.Name, status.NotAfter))
+		}
+		if err := r.updateFdbTLS(tfsc, status); err != nil {
+			klog.Errorf("update fdb tls status of threeFsCluster %s failed: %v", tfsc.Name, err)
+			return "", false, err
+		}
+	}
+	if time.Until(notAfter) < 0 {
+		r.Recorder.Event(tfsc, corev1.EventTypeWarning, "FdbTLSCertificateExpired", fmt.Sprintf("fdb tls certificate of secret %s expired at %s", secret.Name, status.NotAfter))
+	}
+	return secret.Name, true, nil
+}
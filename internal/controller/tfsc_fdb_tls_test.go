@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	fdbv1beta2 "github.com/FoundationDB/fdb-kubernetes-operator/api/v1beta2"
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/aliyun/kvc-3fs-operator/internal/fdb"
+	"github.com/aliyun/kvc-3fs-operator/internal/validation"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestFdbTLSSpec(t *testing.T) {
+	assert.False(t, fdb.IsTLSEnabled(threefsv1.FdbSpec{}))
+	assert.Equal(t, constant.DefaultFdbTLSVerifyPeers, fdb.GetTLSVerifyPeers(threefsv1.FdbSpec{}))
+	assert.Equal(t, "test-fdb-tls", fdb.GetTLSSecretName("test"))
+
+	assert.Nil(t, validation.ValidateFdbSpec(threefsv1.FdbSpec{TLS: &threefsv1.FdbTLSSpec{Enabled: true}}))
+	assert.NotNil(t, validation.ValidateFdbSpec(threefsv1.FdbSpec{TLS: &threefsv1.FdbTLSSpec{CertificateRef: "fdb-cert"}}))
+	external := &threefsv1.FdbExternalSpec{ConnectionString: "desc:id@10.0.0.1:4500:tls"}
+	assert.NotNil(t, validation.ValidateFdbSpec(threefsv1.FdbSpec{Mode: constant.FdbModeExternal, External: external,
+		TLS: &threefsv1.FdbTLSSpec{Enabled: true}}))
+	assert.Nil(t, validation.ValidateFdbSpec(threefsv1.FdbSpec{Mode: constant.FdbModeExternal, External: external,
+		TLS: &threefsv1.FdbTLSSpec{Enabled: true, CertificateRef: "fdb-cert"}}))
+
+	assert.Equal(t, "desc:id@10.0.0.1:4500:tls,10.0.0.2:4500:tls", fdb.WithTLSAddresses("desc:id@10.0.0.1:4500,10.0.0.2:4500:tls"))
+	_, err := fdbv1beta2.ParseConnectionString(fdb.WithTLSAddresses("desc:id@10.0.0.1:4500"))
+	assert.Nil(t, err)
+
+	tfsc := &threefsv1.ThreeFsCluster{}
+	tfsc.Name, tfsc.Namespace = "test", "default"
+	assert.Nil(t, GetFdbTLSOptions(tfsc))
+	assert.Nil(t, GetFdbTLSOptions(tfsc).Args())
+	tfsc.Spec.Fdb.TLS = &threefsv1.FdbTLSSpec{Enabled: true, VerifyPeers: "Check.Valid=0"}
+	assert.Equal(t, []string{
+		"--tls_certificate_file", "/opt/3fs/etc/fdb-tls/default/test/tls.crt",
+		"--tls_key_file", "/opt/3fs/etc/fdb-tls/default/test/tls.key",
+		"--tls_ca_file", "/opt/3fs/etc/fdb-tls/default/test/ca.crt",
+		"--tls_verify_peers", "Check.Valid=0",
+	}, GetFdbTLSOptions(tfsc).Args())
+}
+
+func TestFdbTLSCertificate(t *testing.T) {
+	now := time.Now()
+	data, err := fdb.GenerateTLSData("test", nil, now)
+	assert.Nil(t, err)
+	notAfter, err := fdb.GetCertificateNotAfter(data["tls.crt"])
+	assert.Nil(t, err)
+	assert.WithinDuration(t, now.Add(constant.FdbTLSCertDuration), notAfter, time.Second)
+	assert.False(t, fdb.NeedsRenewal(data["tls.crt"], now))
+	assert.True(t, fdb.NeedsRenewal(data["tls.crt"], now.Add(constant.FdbTLSCertDuration-constant.FdbTLSRenewBefore+time.Hour)))
+	assert.True(t, fdb.NeedsRenewal(nil, now))
+
+	// renewed certificate is signed by the same ca
+	renewed, err := fdb.GenerateTLSData("test", data, now.Add(constant.FdbTLSCertDuration-constant.FdbTLSRenewBefore))
+	assert.Nil(t, err)
+	assert.Equal(t, data["ca.crt"], renewed["ca.crt"])
+	assert.NotEqual(t, data["tls.crt"], renewed["tls.crt"])
+	assert.Len(t, fdb.GetTLSData(renewed), 3)
+	assert.NotContains(t, fdb.GetTLSData(renewed), "ca.key")
+}
+
+func TestFdbTLSPodTemplate(t *testing.T) {
+	template := &corev1.PodTemplateSpec{}
+	fdb.WithTLS(template, "", constant.DefaultFdbTLSVerifyPeers)
+	assert.Empty(t, template.Spec.Volumes)
+
+	template.Spec.Containers = []corev1.Container{{Name: "mgmtd"}}
+	fdb.WithTLS(template, "test-fdb-tls", constant.DefaultFdbTLSVerifyPeers)
+	assert.Equal(t, "test-fdb-tls", template.Spec.Volumes[0].Secret.SecretName)
+	assert.Len(t, template.Spec.Volumes[0].Secret.Items, 3)
+	assert.Equal(t, constant.DefaultFdbTLSPath, template.Spec.Containers[0].VolumeMounts[0].MountPath)
+	envs := make(map[string]string)
+	for _, env := range template.Spec.Containers[0].Env {
+		envs[env.Name] = env.Value
+	}
+	assert.Equal(t, "/var/fdb/tls/tls.crt", envs[constant.ENVFdbTLSCertificateFile])
+	assert.Equal(t, constant.DefaultFdbTLSVerifyPeers, envs[constant.ENVFdbTLSVerifyPeers])
+
+	spec := fdb.GetOperatorClusterSpec(threefsv1.FdbSpec{ClusterSize: 3, StorageReplicas: 2})
+	fdb.WithOperatorTLS(&spec, "test-fdb-tls", constant.DefaultFdbTLSVerifyPeers)
+	assert.True(t, spec.MainContainer.EnableTLS)
+	assert.True(t, spec.SidecarContainer.EnableTLS)
+	podTemplate := spec.Processes[fdbv1beta2.ProcessClassGeneral].PodTemplate
+	assert.Len(t, podTemplate.Spec.Containers, 2)
+	assert.Equal(t, fdbv1beta2.SidecarContainerName, podTemplate.Spec.Containers[1].Name)
+	assert.Len(t, podTemplate.Spec.Containers[1].Env, 4)
+}
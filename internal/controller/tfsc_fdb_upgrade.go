@@ -0,0 +1,218 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	fdbv1beta2 "github.com/FoundationDB/fdb-kubernetes-operator/api/v1beta2"
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/aliyun/kvc-3fs-operator/internal/fdb"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GetFdbRunningVersion returns the version run by all fdb processes, empty if processes run different versions
+func GetFdbRunningVersion(details *fdbv1beta2.FoundationDBStatus) string {
+	version := ""
+	for _, process := range details.Cluster.Processes {
+		if process.Version == "" {
+			continue
+		}
+		if version != "" && version != process.Version {
+			return ""
+		}
+		version = process.Version
+	}
+	return version
+}
+
+// GetFdbIncompatibleClients returns addresses of connected clients which do not support the protocol of version
+func GetFdbIncompatibleClients(details *fdbv1beta2.FoundationDBStatus, version string) []string {
+	clients := make(map[string]bool)
+	for _, supported := range details.Cluster.Clients.SupportedVersions {
+		compatible := fdb.IsProtocolCompatible(supported.ClientVersion, version)
+		for _, connected := range supported.ConnectedClients {
+			clients[connected.Address] = clients[connected.Address] || compatible
+		}
+	}
+	incompatible := make([]string, 0)
+	for address, compatible := range clients {
+		if !compatible {
+			incompatible = append(incompatible, address)
+		}
+	}
+	sort.Strings(incompatible)
+	return incompatible
+}
+
+// GetFdbDeployVersion returns fdb version of images of fdb deployments, the target version once fdb processes
+// are bounced
+func GetFdbDeployVersion(tfsc *threefsv1.ThreeFsCluster) string {
+	upgrade := tfsc.Status.FdbUpgrade
+	if upgrade.Phase == constant.FdbUpgradeBouncingStatus {
+		return upgrade.TargetVersion
+	}
+	if upgrade.Version != "" {
+		return upgrade.Version
+	}
+	return fdb.GetFdbVersion(tfsc.Spec.Fdb)
+}
+
+// GetFdbClientVersions returns versions of client libraries staged for mgmtd and meta, clients support both the
+// running version and the target version during an upgrade
+func GetFdbClientVersions(tfsc *threefsv1.ThreeFsCluster) []string {
+	return fdb.GetStagedClientVersions(tfsc.Status.FdbUpgrade.Version, fdb.GetFdbVersion(tfsc.Spec.Fdb))
+}
+
+func (r *ThreeFsClusterReconciler) updateFdbUpgrade(tfsc *threefsv1.ThreeFsCluster, upgrade threefsv1.FdbUpgrade) error {
+	localCache := threefsv1.ThreeFsCluster{}
+	if err := r.Get(context.Background(), client.ObjectKey{Name: tfsc.Name, Namespace: tfsc.Namespace}, &localCache); err != nil {
+		return err
+	}
+	newObj := localCache.DeepCopy()
+	newObj.Status.FdbUpgrade = upgrade
+	if err := r.Status().Patch(context.Background(), newObj, client.MergeFrom(&localCache)); err != nil {
+		return err
+	}
+	tfsc.Status.FdbUpgrade = upgrade
+	return nil
+}
+
+// StageFdbClientLibraries stages fdb client libraries to mgmtd and meta deployments one by one,
+// returns true until all deployments are updated and ready
+func (r *ThreeFsClusterReconciler) StageFdbClientLibraries(fdbConfig *fdb.FdbConfig, tfsc *threefsv1.ThreeFsCluster) (bool, error) {
+	for _, labelKey := range []string{constant.ThreeFSMgmtdDeployKey, constant.ThreeFSMetaDeployKey} {
+		deployName, staging, err := fdbConfig.UpdateClientLibraries(labelKey)
+		if err != nil {
+			return false, err
+		}
+		if deployName != "" {
+			r.Recorder.Event(tfsc, corev1.EventTypeNormal, "FdbClientLibrariesStaged", fmt.Sprintf("stage fdb client libraries %v to deployment %s", fdbConfig.ClientVersions, deployName))
+		}
+		if staging {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// rejectFdbUpgrade records why the upgrade can not start or continue, the event is emitted once for a message
+func (r *ThreeFsClusterReconciler) rejectFdbUpgrade(tfsc *threefsv1.ThreeFsCluster, upgrade threefsv1.FdbUpgrade, reason, message string) error {
+	if upgrade.Message == message {
+		return nil
+	}
+	klog.Errorf("upgrade fdb of threeFsCluster %s blocked: %s", tfsc.Name, message)
+	r.Recorder.Event(tfsc, corev1.EventTypeWarning, reason, message)
+	upgrade.Message = message
+	return r.updateFdbUpgrade(tfsc, upgrade)
+}
+
+// UpgradeFdb upgrades fdb of builtin mode to spec.fdb.version, client libraries of the target version are staged
+// for mgmtd and meta first, all fdb processes are bounced together once connected clients support the target
+// version, returns true until fdb processes run the target version and fdb is healthy
+func (r *ThreeFsClusterReconciler) UpgradeFdb(fdbConfig *fdb.FdbConfig, tfsc *threefsv1.ThreeFsCluster, details *fdbv1beta2.FoundationDBStatus) (bool, error) {
+	upgrade := tfsc.Status.FdbUpgrade
+	target := fdb.GetFdbVersion(tfsc.Spec.Fdb)
+
+	// record the version of the created fdb cluster
+	if upgrade.Version == "" {
+		running := GetFdbRunningVersion(details)
+		if running == "" {
+			running = target
+		}
+		upgrade = threefsv1.FdbUpgrade{Version: running, Phase: constant.FdbUpgradeCompletedStatus}
+		if err := r.updateFdbUpgrade(tfsc, upgrade); err != nil {
+			return false, err
+		}
+	}
+
+	switch upgrade.Phase {
+	case constant.FdbUpgradeStagingClientsStatus:
+		staging, err := r.StageFdbClientLibraries(fdbConfig, tfsc)
+		if err != nil {
+			return true, err
+		}
+		if staging {
+			klog.Infof("wait fdb client libraries of threeFsCluster %s staged", tfsc.Name)
+			return true, nil
+		}
+		if clients := GetFdbIncompatibleClients(details, upgrade.TargetVersion); len(clients) > 0 {
+			return true, r.rejectFdbUpgrade(tfsc, upgrade, "FdbUpgradeBlocked",
+				fmt.Sprintf("clients %s do not support fdb %s", strings.Join(clients, ","), upgrade.TargetVersion))
+		}
+		if !details.Client.DatabaseStatus.Available || !details.Cluster.Data.State.Healthy {
+			klog.Infof("wait fdb of threeFsCluster %s healthy before bouncing: %s", tfsc.Name, details.Cluster.Data.State.Description)
+			return true, nil
+		}
+		deploys, err := fdbConfig.WithVersion(upgrade.TargetVersion).UpdateDeployVersion()
+		if err != nil {
+			return true, err
+		}
+		klog.Infof("bounce fdb deployments %s of threeFsCluster %s to %s", strings.Join(deploys, ","), tfsc.Name, upgrade.TargetVersion)
+		r.Recorder.Event(tfsc, corev1.EventTypeNormal, "FdbBouncing", fmt.Sprintf("bounce fdb processes from %s to %s", upgrade.Version, upgrade.TargetVersion))
+		upgrade.Phase = constant.FdbUpgradeBouncingStatus
+		upgrade.Message = ""
+		return true, r.updateFdbUpgrade(tfsc, upgrade)
+	case constant.FdbUpgradeBouncingStatus:
+		ready, err := fdbConfig.WithVersion(upgrade.TargetVersion).IsDeployVersionReady()
+		if err != nil {
+			return true, err
+		}
+		if !ready || GetFdbRunningVersion(details) != upgrade.TargetVersion ||
+			!details.Client.DatabaseStatus.Available || !details.Cluster.Data.State.Healthy {
+			klog.Infof("wait fdb processes of threeFsCluster %s run %s and healthy: %s", tfsc.Name, upgrade.TargetVersion, details.Cluster.Data.State.Description)
+			return true, nil
+		}
+		upgrade.Version = upgrade.TargetVersion
+		upgrade.Phase = constant.FdbUpgradeCompletedStatus
+		upgrade.EndTime = time.Now().Format(constant.TimeLayout)
+		if err := r.updateFdbUpgrade(tfsc, upgrade); err != nil {
+			return false, err
+		}
+		r.Recorder.Event(tfsc, corev1.EventTypeNormal, "FdbUpgraded", fmt.Sprintf("fdb is upgraded to %s and healthy", upgrade.Version))
+		return false, nil
+	}
+
+	if upgrade.Version == target {
+		return false, nil
+	}
+	if err := fdb.ValidateVersionChange(upgrade.Version, target); err != nil {
+		return false, r.rejectFdbUpgrade(tfsc, upgrade, "FdbUpgradeRejected", err.Error())
+	}
+	if !details.Client.DatabaseStatus.Available || !details.Cluster.Data.State.Healthy {
+		klog.Infof("wait fdb of threeFsCluster %s healthy before upgrading: %s", tfsc.Name, details.Cluster.Data.State.Description)
+		return false, nil
+	}
+	klog.Infof("upgrade fdb of threeFsCluster %s from %s to %s", tfsc.Name, upgrade.Version, target)
+	r.Recorder.Event(tfsc, corev1.EventTypeNormal, "FdbUpgrading", fmt.Sprintf("upgrade fdb from %s to %s", upgrade.Version, target))
+	return true, r.updateFdbUpgrade(tfsc, threefsv1.FdbUpgrade{
+		Version:       upgrade.Version,
+		TargetVersion: target,
+		Phase:         constant.FdbUpgradeStagingClientsStatus,
+		StartTime:     time.Now().Format(constant.TimeLayout),
+	})
+}
+
+// RecordFdbOperatorVersion records the running version of the FoundationDBCluster of external-operator mode,
+// which is upgraded by the FoundationDB kubernetes operator
+func (r *ThreeFsClusterReconciler) RecordFdbOperatorVersion(tfsc *threefsv1.ThreeFsCluster, cluster *fdbv1beta2.FoundationDBCluster) error {
+	upgrade := tfsc.Status.FdbUpgrade
+	running := cluster.GetRunningVersion()
+	if running == "" || upgrade.Version == running {
+		return nil
+	}
+	if upgrade.Version != "" {
+		r.Recorder.Event(tfsc, corev1.EventTypeNormal, "FdbUpgraded", fmt.Sprintf("FoundationDBCluster %s is upgraded from %s to %s", cluster.Name, upgrade.Version, running))
+	}
+	return r.updateFdbUpgrade(tfsc, threefsv1.FdbUpgrade{
+		Version:       running,
+		TargetVersion: running,
+		Phase:         constant.FdbUpgradeCompletedStatus,
+		EndTime:       time.Now().Format(constant.TimeLayout),
+	})
+}
@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"testing"
+
+	fdbv1beta2 "github.com/FoundationDB/fdb-kubernetes-operator/api/v1beta2"
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/aliyun/kvc-3fs-operator/internal/fdb"
+	"github.com/aliyun/kvc-3fs-operator/internal/validation"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestFdbVersion(t *testing.T) {
+	assert.Equal(t, constant.DefaultFdbVersion, fdb.GetFdbVersion(threefsv1.FdbSpec{}))
+	assert.Nil(t, fdb.ValidateVersion(""))
+	assert.Nil(t, fdb.ValidateVersion("7.1.61"))
+	assert.NotNil(t, fdb.ValidateVersion("7.3"))
+	assert.NotNil(t, fdb.ValidateVersion("6.3.25"))
+	assert.Nil(t, validation.ValidateFdbSpec(threefsv1.FdbSpec{Version: "7.3.69"}))
+	assert.NotNil(t, validation.ValidateFdbSpec(threefsv1.FdbSpec{Mode: constant.FdbModeExternal, Version: "7.3.69",
+		External: &threefsv1.FdbExternalSpec{ConnectionString: "desc:id@10.0.0.1:4500"}}))
+
+	assert.Nil(t, fdb.ValidateVersionChange("7.3.63", "7.3.69"))
+	assert.Nil(t, fdb.ValidateVersionChange("7.1.61", "7.3.63"))
+	// patch downgrade keeps the protocol
+	assert.Nil(t, fdb.ValidateVersionChange("7.3.69", "7.3.63"))
+	assert.NotNil(t, fdb.ValidateVersionChange("7.3.63", "7.1.61"))
+
+	t.Setenv("FDB_IMAGE", "registry:5000/vcns/fdb:0728")
+	assert.Equal(t, "registry:5000/vcns/fdb:0728", fdb.GetFdbImage(""))
+	assert.Equal(t, "registry:5000/vcns/fdb:0728", fdb.GetFdbImage(constant.DefaultFdbVersion))
+	assert.Equal(t, "registry:5000/vcns/fdb:7.1.61", fdb.GetFdbImage("7.1.61"))
+	t.Setenv("FDB_IMAGE", "registry:5000/vcns/fdb")
+	assert.Equal(t, "registry:5000/vcns/fdb:7.1.61", fdb.GetFdbImage("7.1.61"))
+}
+
+func TestFdbClientLibraries(t *testing.T) {
+	assert.Equal(t, []string{}, fdb.GetStagedClientVersions("7.3.63", "7.3.69", ""))
+	assert.Equal(t, []string{"7.1.61"}, fdb.GetStagedClientVersions("7.1.61", "7.3.63", "7.1.61"))
+
+	tfsc := &threefsv1.ThreeFsCluster{}
+	tfsc.Spec.Fdb.Version = "7.3.63"
+	tfsc.Status.FdbUpgrade = threefsv1.FdbUpgrade{Version: "7.1.61", TargetVersion: "7.3.63", Phase: constant.FdbUpgradeStagingClientsStatus}
+	assert.Equal(t, []string{"7.1.61"}, GetFdbClientVersions(tfsc))
+	assert.Equal(t, "7.1.61", GetFdbDeployVersion(tfsc))
+	tfsc.Status.FdbUpgrade.Phase = constant.FdbUpgradeBouncingStatus
+	assert.Equal(t, "7.3.63", GetFdbDeployVersion(tfsc))
+	tfsc.Status.FdbUpgrade = threefsv1.FdbUpgrade{}
+	assert.Equal(t, "7.3.63", GetFdbDeployVersion(tfsc))
+
+	template := &corev1.PodTemplateSpec{}
+	template.Spec.Containers = []corev1.Container{{Name: "mgmtd"}}
+	fdb.WithClientLibraries(template, nil)
+	assert.True(t, fdb.IsSameClientLibraries(template, []string{}))
+	assert.Empty(t, template.Spec.InitContainers)
+	fdb.WithClientLibraries(template, []string{"7.1.61"})
+	assert.True(t, fdb.IsSameClientLibraries(template, []string{"7.1.61"}))
+	assert.False(t, fdb.IsSameClientLibraries(template, []string{}))
+	assert.Equal(t, "fdb-client-7-1-61", template.Spec.InitContainers[0].Name)
+	assert.Equal(t, constant.ENVFdbExternalClientDirectory, template.Spec.Containers[0].Env[0].Name)
+	assert.Len(t, template.Spec.Volumes, 1)
+}
+
+func TestFdbUpgradeStatus(t *testing.T) {
+	details := &fdbv1beta2.FoundationDBStatus{}
+	details.Cluster.Processes = map[fdbv1beta2.ProcessGroupID]fdbv1beta2.FoundationDBStatusProcessInfo{
+		"p1": {Version: "7.3.63"},
+		"p2": {Version: "7.3.63"},
+	}
+	assert.Equal(t, "7.3.63", GetFdbRunningVersion(details))
+	details.Cluster.Processes["p3"] = fdbv1beta2.FoundationDBStatusProcessInfo{Version: "7.3.69"}
+	assert.Equal(t, "", GetFdbRunningVersion(details))
+
+	connected := func(addresses ...string) []fdbv1beta2.FoundationDBStatusConnectedClient {
+		clients := make([]fdbv1beta2.FoundationDBStatusConnectedClient, 0)
+		for _, address := range addresses {
+			clients = append(clients, fdbv1beta2.FoundationDBStatusConnectedClient{Address: address})
+		}
+		return clients
+	}
+	details.Cluster.Clients.SupportedVersions = []fdbv1beta2.FoundationDBStatusSupportedVersion{
+		{ClientVersion: "7.3.63", ConnectedClients: connected("10.0.0.1:1", "10.0.0.2:1", "10.0.0.3:1")},
+		{ClientVersion: "7.1.61", ConnectedClients: connected("10.0.0.2:1")},
+	}
+	assert.Equal(t, []string{}, GetFdbIncompatibleClients(details, "7.3.69"))
+	assert.Equal(t, []string{"10.0.0.1:1", "10.0.0.3:1"}, GetFdbIncompatibleClients(details, "7.1.62"))
+}
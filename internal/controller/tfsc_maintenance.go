@@ -0,0 +1,225 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	clientcomm "github.com/aliyun/kvc-3fs-operator/internal/client"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/aliyun/kvc-3fs-operator/internal/utils"
+	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GetStorageMaintenanceRequest returns whether the node is requested into maintenance by the storage-maintenance
+// annotation or by cordon, and whether its targets should be offlined first. Only the annotation with value
+// offline offlines targets
+func GetStorageMaintenanceRequest(node *corev1.Node) (bool, bool, string) {
+	if val, ok := node.Annotations[constant.ThreeFSStorageMaintenanceKey]; ok && val != "false" {
+		return true, val == constant.StorageMaintenanceOffline, constant.StorageMaintenanceReasonAnnotation
+	}
+	if node.Spec.Unschedulable {
+		return true, false, constant.StorageMaintenanceReasonCordon
+	}
+	return false, false, ""
+}
+
+// IsStorageNodeInMaintenance returns true if maintenance of the storage node is not completed yet
+func IsStorageNodeInMaintenance(tfsc *threefsv1.ThreeFsCluster, nodeName string) bool {
+	maintenance, ok := tfsc.Status.StorageMaintenance[nodeName]
+	return ok && maintenance.Phase != constant.StorageMaintenanceCompletedStatus
+}
+
+// CheckTargetsSafeToOffline checks every chain with a target on the node keeps another SERVING-UPTODATE target
+// after the target is offlined. Chains with a single target (erasure coding) are rebuilt from other chains of
+// the group, so they are not checked
+func CheckTargetsSafeToOffline(chains []Chain, nodeId string) error {
+	for _, chain := range chains {
+		if len(chain.Targets) < 2 {
+			continue
+		}
+		serving := 0
+		for _, target := range chain.Targets {
+			if target.TargetId[2:7] == nodeId {
+				continue
+			}
+			if target.State == "SERVING-UPTODATE" {
+				serving++
+			}
+		}
+		if serving == 0 {
+			return fmt.Errorf("chain %s has no other SERVING-UPTODATE target", chain.ChainId)
+		}
+	}
+	return nil
+}
+
+func (r *ThreeFsClusterReconciler) offlineMaintenanceTargets(adminCliConfig *clientcomm.AdminCliConfig, tfsc *threefsv1.ThreeFsCluster, nodeName string) ([]string, error) {
+	nodeId, err := ParseNodeIdFromNodeName(adminCliConfig, "STORAGE", nodeName)
+	if err != nil {
+		klog.Errorf("parse node id of %s failed: %v", nodeName, err)
+		return nil, err
+	}
+	chains, err := GetChainTablesWithNode(adminCliConfig, nodeName)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckTargetsSafeToOffline(chains, strconv.Itoa(nodeId)); err != nil {
+		return nil, err
+	}
+	token, err := r.UserAdd(tfsc, adminCliConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]string, 0)
+	for _, chain := range chains {
+		for _, target := range chain.Targets {
+			if target.TargetId[2:7] != strconv.Itoa(nodeId) {
+				continue
+			}
+			if _, err := adminCliConfig.OfflineTarget(token, strconv.Itoa(nodeId), target.TargetId); err != nil {
+				klog.Errorf("offline target: nodeid(%d) target(%s) failed: %v", nodeId, target.TargetId, err)
+				return targets, err
+			}
+			targets = append(targets, target.TargetId)
+		}
+	}
+	return targets, nil
+}
+
+func (r *ThreeFsClusterReconciler) restartStoragePod(tfsc *threefsv1.ThreeFsCluster, nodeName string) error {
+	podList := &corev1.PodList{}
+	if err := r.Client.List(context.Background(), podList, client.InNamespace(tfsc.Namespace), client.MatchingLabels{constant.ThreeFSStorageDeployKey: tfsc.Name}); err != nil {
+		klog.Errorf("list storage pods failed: %v", err)
+		return err
+	}
+	for _, pod := range podList.Items {
+		if pod.Spec.NodeName != nodeName {
+			continue
+		}
+		klog.Infof("restart storage pod %s on node %s", pod.Name, nodeName)
+		if err := r.Client.Delete(context.Background(), &pod); err != nil && !k8serror.IsNotFound(err) {
+			klog.Errorf("delete storage pod %s failed: %v", pod.Name, err)
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ThreeFsClusterReconciler) updateStorageMaintenanceStatus(threeFsCluster *threefsv1.ThreeFsCluster, maintenance map[string]threefsv1.StorageMaintenance) error {
+	localCache := threefsv1.ThreeFsCluster{}
+	_ = r.Get(context.Background(), client.ObjectKey{Name: threeFsCluster.Name, Namespace: threeFsCluster.Namespace}, &localCache)
+
+	modifiedObj := localCache.DeepCopy()
+	modifiedObj.Status.StorageMaintenance = maintenance
+	threeFsCluster.Status.StorageMaintenance = maintenance
+	return r.Client.Status().Patch(context.Background(), modifiedObj, client.MergeFrom(&localCache))
+}
+
+// HandleStorageMaintenance moves storage nodes requested into maintenance through Offlining (optional),
+// InMaintenance, Recovering and Completed. Nodes recover once maintenance is no longer requested and all their
+// targets are UPTODATE again. It returns true while any node is not completed
+func (r *ThreeFsClusterReconciler) HandleStorageMaintenance(adminCliConfig *clientcomm.AdminCliConfig, tfsc *threefsv1.ThreeFsCluster) (bool, error) {
+	maintenance := make(map[string]threefsv1.StorageMaintenance)
+	for name, val := range tfsc.Status.StorageMaintenance {
+		if utils.StrListContains(tfsc.Status.NodesInfo.StorageNodes, name) {
+			maintenance[name] = *val.DeepCopy()
+		}
+	}
+
+	changed := len(maintenance) != len(tfsc.Status.StorageMaintenance)
+	now := time.Now().Format(constant.TimeLayout)
+	for _, nodeName := range tfsc.Status.NodesInfo.StorageNodes {
+		node := &corev1.Node{}
+		if err := r.Client.Get(context.Background(), client.ObjectKey{Name: nodeName}, node); err != nil {
+			if k8serror.IsNotFound(err) {
+				continue
+			}
+			klog.Errorf("get node %s failed: %v", nodeName, err)
+			return false, err
+		}
+		requested, offline, reason := GetStorageMaintenanceRequest(node)
+		current, exists := maintenance[nodeName]
+		inProgress := exists && current.Phase != constant.StorageMaintenanceCompletedStatus
+
+		switch {
+		case requested && !inProgress:
+			current = threefsv1.StorageMaintenance{
+				Phase:     constant.StorageMaintenanceInMaintenanceStatus,
+				Reason:    reason,
+				StartTime: now,
+			}
+			if offline {
+				current.Phase = constant.StorageMaintenanceOffliningStatus
+			}
+			klog.Infof("storage node %s enters maintenance by %s", nodeName, reason)
+			r.Recorder.Event(tfsc, corev1.EventTypeNormal, "StorageMaintenanceStarted", fmt.Sprintf("storage node %s enters maintenance by %s", nodeName, reason))
+		case requested && current.Phase == constant.StorageMaintenanceOffliningStatus:
+			targets, err := r.offlineMaintenanceTargets(adminCliConfig, tfsc, nodeName)
+			for _, target := range targets {
+				if !utils.StrListContains(current.OfflineTargets, target) {
+					current.OfflineTargets = append(current.OfflineTargets, target)
+				}
+			}
+			if err != nil {
+				klog.Errorf("offline targets of storage node %s failed: %v", nodeName, err)
+				current.Message = fmt.Sprintf("offline targets failed: %v", err)
+				break
+			}
+			current.Phase = constant.StorageMaintenanceInMaintenanceStatus
+			current.Message = ""
+			r.Recorder.Event(tfsc, corev1.EventTypeNormal, "StorageMaintenanceOfflined", fmt.Sprintf("%d targets of storage node %s are offlined", len(current.OfflineTargets), nodeName))
+		case requested && current.Phase == constant.StorageMaintenanceRecoveringStatus:
+			// requested again before recovered
+			current.Phase = constant.StorageMaintenanceInMaintenanceStatus
+		case !requested && (current.Phase == constant.StorageMaintenanceOffliningStatus || current.Phase == constant.StorageMaintenanceInMaintenanceStatus):
+			klog.Infof("storage node %s leaves maintenance, wait targets to be UPTODATE", nodeName)
+			if len(current.OfflineTargets) > 0 {
+				// offlined targets are registered again after storage restarts
+				if err := r.restartStoragePod(tfsc, nodeName); err != nil {
+					current.Message = fmt.Sprintf("restart storage failed: %v", err)
+					break
+				}
+			}
+			current.Phase = constant.StorageMaintenanceRecoveringStatus
+			current.Message = ""
+		case !requested && current.Phase == constant.StorageMaintenanceRecoveringStatus:
+			if !CheckComponentStatus(adminCliConfig, "STORAGE", utils.TranslatePlainNodeName3fs(nodeName), false, r.Client) || !CheckTargetStatus(adminCliConfig, nodeName) {
+				current.Message = "wait targets to be UPTODATE"
+				break
+			}
+			current.Phase = constant.StorageMaintenanceCompletedStatus
+			current.EndTime = now
+			current.Message = ""
+			klog.Infof("storage node %s maintenance completed", nodeName)
+			r.Recorder.Event(tfsc, corev1.EventTypeNormal, "StorageMaintenanceCompleted", fmt.Sprintf("storage node %s maintenance from %s to %s completed", nodeName, current.StartTime, current.EndTime))
+		default:
+			continue
+		}
+
+		if !exists || current.Phase != maintenance[nodeName].Phase || current.Message != maintenance[nodeName].Message {
+			changed = true
+		}
+		maintenance[nodeName] = current
+	}
+
+	waiting := false
+	for _, current := range maintenance {
+		if current.Phase != constant.StorageMaintenanceCompletedStatus {
+			waiting = true
+		}
+	}
+	if changed {
+		if err := r.updateStorageMaintenanceStatus(tfsc, maintenance); err != nil {
+			klog.Errorf("update storage maintenance status of threeFsCluster %s failed: %v", tfsc.Name, err)
+			return false, err
+		}
+	}
+	return waiting, nil
+}
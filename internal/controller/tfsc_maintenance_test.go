@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestGetStorageMaintenanceRequest(t *testing.T) {
+	node := &corev1.Node{}
+	requested, _, _ := GetStorageMaintenanceRequest(node)
+	assert.False(t, requested)
+
+	node.Spec.Unschedulable = true
+	requested, offline, reason := GetStorageMaintenanceRequest(node)
+	assert.True(t, requested)
+	assert.False(t, offline)
+	assert.Equal(t, constant.StorageMaintenanceReasonCordon, reason)
+
+	node.Annotations = map[string]string{constant.ThreeFSStorageMaintenanceKey: constant.StorageMaintenanceOffline}
+	requested, offline, reason = GetStorageMaintenanceRequest(node)
+	assert.True(t, requested)
+	assert.True(t, offline)
+	assert.Equal(t, constant.StorageMaintenanceReasonAnnotation, reason)
+
+	node.Spec.Unschedulable = false
+	node.Annotations[constant.ThreeFSStorageMaintenanceKey] = "false"
+	requested, _, _ = GetStorageMaintenanceRequest(node)
+	assert.False(t, requested)
+}
+
+func TestCheckTargetsSafeToOffline(t *testing.T) {
+	// target id is prefix(2)+node(5)+disk(3)+idx
+	chains := []Chain{
+		{ChainId: "900100001", Targets: []Target{
+			{TargetId: "101000100101", State: "SERVING-UPTODATE"},
+			{TargetId: "101000200101", State: "SERVING-UPTODATE"},
+		}},
+		{ChainId: "900100002", Targets: []Target{
+			{TargetId: "101000100102", State: "SERVING-UPTODATE"},
+		}},
+	}
+	assert.NoError(t, CheckTargetsSafeToOffline(chains, "10001"))
+
+	chains[0].Targets[1].State = "OFFLINE"
+	assert.Error(t, CheckTargetsSafeToOffline(chains, "10001"))
+	assert.NoError(t, CheckTargetsSafeToOffline(chains, "10003"))
+}
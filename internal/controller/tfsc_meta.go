@@ -0,0 +1,182 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	clientcomm "github.com/aliyun/kvc-3fs-operator/internal/client"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/aliyun/kvc-3fs-operator/internal/meta"
+	"github.com/aliyun/kvc-3fs-operator/internal/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GetServiceCandidateNodes returns sorted nodes matching the node selector of meta or mgmtd, or the storage nodes
+// if the selector is empty
+func GetServiceCandidateNodes(rclient client.Client, tfsc *threefsv1.ThreeFsCluster, selector map[string]string) ([]string, error) {
+	nodes := make([]string, 0)
+	if len(selector) == 0 {
+		nodes = append(nodes, tfsc.Status.NodesInfo.StorageNodes...)
+	} else {
+		nodeList := &corev1.NodeList{}
+		if err := rclient.List(context.Background(), nodeList, client.MatchingLabels(selector)); err != nil {
+			klog.Errorf("list node with selector %v failed: %v", selector, err)
+			return nil, err
+		}
+		for _, node := range nodeList.Items {
+			nodes = append(nodes, node.Name)
+		}
+	}
+	sort.Strings(nodes)
+	return nodes, nil
+}
+
+// SelectServiceNodes keeps current nodes which are still candidates, removes the latest added ones if replica is
+// decreased, and adds candidates in order if replica is increased
+func SelectServiceNodes(current, candidates []string, replica int) []string {
+	selected := make([]string, 0, replica)
+	for _, node := range current {
+		if len(selected) == replica {
+			break
+		}
+		if utils.StrListContains(candidates, node) && !utils.StrListContains(selected, node) {
+			selected = append(selected, node)
+		}
+	}
+	for _, node := range candidates {
+		if len(selected) == replica {
+			break
+		}
+		if !utils.StrListContains(selected, node) {
+			selected = append(selected, node)
+		}
+	}
+	return selected
+}
+
+// ReconcileMetaNodes selects meta nodes for the replica and records them in status, nodes already running meta are
+// kept so placement does not change across reconciles
+func (r *ThreeFsClusterReconciler) ReconcileMetaNodes(tfsc *threefsv1.ThreeFsCluster) ([]string, error) {
+	candidates, err := GetServiceCandidateNodes(r.Client, tfsc, tfsc.Spec.Meta.NodeSelector)
+	if err != nil {
+		return nil, err
+	}
+	current := tfsc.Status.NodesInfo.MetaNodes
+	if len(current) == 0 {
+		// clusters created before meta nodes are recorded
+		nodeList := &corev1.NodeList{}
+		if err := r.Client.List(context.Background(), nodeList, client.MatchingLabels{constant.ThreeFSMetaNodeKey: "true"}); err != nil {
+			klog.Errorf("list node with meta label failed: %v", err)
+			return nil, err
+		}
+		for _, node := range nodeList.Items {
+			current = append(current, node.Name)
+		}
+		sort.Strings(current)
+	}
+
+	metaNodes := SelectServiceNodes(current, candidates, tfsc.Spec.Meta.Replica)
+	if len(metaNodes) < tfsc.Spec.Meta.Replica {
+		return nil, fmt.Errorf("tag meta node number is not enough, need %d, but got %d", tfsc.Spec.Meta.Replica, len(candidates))
+	}
+	if reflect.DeepEqual(metaNodes, tfsc.Status.NodesInfo.MetaNodes) {
+		return metaNodes, nil
+	}
+
+	klog.Infof("update threeFsCluster %s meta nodes from %v to %v", tfsc.Name, tfsc.Status.NodesInfo.MetaNodes, metaNodes)
+	localCache := threefsv1.ThreeFsCluster{}
+	_ = r.Get(context.Background(), client.ObjectKey{Name: tfsc.Name, Namespace: tfsc.Namespace}, &localCache)
+	modifiedObj := localCache.DeepCopy()
+	modifiedObj.Status.NodesInfo.MetaNodes = metaNodes
+	if err := r.Client.Status().Patch(context.Background(), modifiedObj, client.MergeFrom(&localCache)); err != nil {
+		klog.Errorf("update threeFsCluster %s meta nodes failed: %v", tfsc.Name, err)
+		return nil, err
+	}
+	tfsc.Status.NodesInfo.MetaNodes = metaNodes
+	return metaNodes, nil
+}
+
+// DeleteRemovedMetaDeploys deletes meta deployments on nodes which are not meta nodes any more. Like storage
+// nodes drained before removal, a running meta is deleted only after meta on all meta nodes are connected, one
+// deployment at a time, so scaling in never leaves clients without meta. Meta without available pods, e.g. on
+// failed nodes, is deleted at once
+func (r *ThreeFsClusterReconciler) DeleteRemovedMetaDeploys(adminCliConfig *clientcomm.AdminCliConfig, tfsc *threefsv1.ThreeFsCluster, metaConfig *meta.MetaConfig, metaNodes []string) error {
+	deploys, err := metaConfig.ListRemovedDeploys()
+	if err != nil {
+		return err
+	}
+	running := make([]*appsv1.Deployment, 0)
+	for idx := range deploys {
+		deploy := &deploys[idx]
+		if deploy.Status.AvailableReplicas > 0 {
+			running = append(running, deploy)
+			continue
+		}
+		if err := r.Client.Delete(context.Background(), deploy); err != nil && !k8serror.IsNotFound(err) {
+			klog.Errorf("delete deployment %s failed: %v", deploy.Name, err)
+			return err
+		}
+		klog.Infof("delete unavailable meta deployment %s", deploy.Name)
+	}
+	if len(running) == 0 {
+		return nil
+	}
+	for _, node := range metaNodes {
+		if !CheckComponentStatus(adminCliConfig, "META", utils.TranslatePlainNodeName3fs(node), false, r.Client) {
+			klog.Infof("meta on %s is not connected yet, wait to delete meta deployment %s", node, running[0].Name)
+			return nil
+		}
+	}
+
+	// one by one
+	deploy := running[0]
+	if err := r.Client.Delete(context.Background(), deploy); err != nil && !k8serror.IsNotFound(err) {
+		klog.Errorf("delete deployment %s failed: %v", deploy.Name, err)
+		return err
+	}
+	klog.Infof("delete meta deployment %s, %d meta deployments left to delete", deploy.Name, len(running)-1)
+	r.Recorder.Event(tfsc, corev1.EventTypeNormal, "MetaScaledIn", fmt.Sprintf("meta deployment %s is deleted", deploy.Name))
+	return nil
+}
+
+// UnregisterRemovedMetaNodes unregisters meta services which are not on meta nodes any more, a meta service is
+// unregistered only after it loses heartbeat, i.e. its deployment is deleted
+func (r *ThreeFsClusterReconciler) UnregisterRemovedMetaNodes(adminCliConfig *clientcomm.AdminCliConfig, tfsc *threefsv1.ThreeFsCluster, metaNodes []string) error {
+	output, err := adminCliConfig.ListNodes()
+	if err != nil {
+		klog.Errorf("list nodes failed: %v", err)
+		return err
+	}
+	nodes, err := ParseNodeTable(output)
+	if err != nil {
+		klog.Errorf("parse node table failed: %v", err)
+		return err
+	}
+	hostnames := make([]string, 0, len(metaNodes))
+	for _, node := range metaNodes {
+		hostnames = append(hostnames, utils.TranslatePlainNodeName3fs(node))
+	}
+	for _, node := range nodes {
+		if node.Type != "META" || utils.StrListContains(hostnames, node.Hostname) {
+			continue
+		}
+		if node.Status == "HEARTBEAT_CONNECTED" || node.Status == "HEARTBEAT_CONNECTING" {
+			klog.Infof("meta %s on %s is removed but still connected, wait", node.Id, node.Hostname)
+			continue
+		}
+		if err := adminCliConfig.UnregisterNode(node.Id, node.Type); err != nil {
+			klog.Errorf("unregister meta %s on %s failed: %v", node.Id, node.Hostname, err)
+			return err
+		}
+		klog.Infof("unregister meta %s on %s success", node.Id, node.Hostname)
+		r.Recorder.Event(tfsc, corev1.EventTypeNormal, "MetaUnregistered", fmt.Sprintf("meta %s on %s is scaled in and unregistered", node.Id, node.Hostname))
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	clientcomm "github.com/aliyun/kvc-3fs-operator/internal/client"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/aliyun/kvc-3fs-operator/internal/meta"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestSelectServiceNodes(t *testing.T) {
+	candidates := []string{"node-a", "node-b", "node-c", "node-d"}
+	assert.Equal(t, []string{"node-a", "node-b"}, SelectServiceNodes(nil, candidates, 2))
+
+	// current nodes are kept across reconciles
+	assert.Equal(t, []string{"node-c", "node-a"}, SelectServiceNodes([]string{"node-c", "node-a"}, candidates, 2))
+
+	// scale out appends candidates in order, scale in removes the latest added nodes
+	assert.Equal(t, []string{"node-c", "node-a", "node-b"}, SelectServiceNodes([]string{"node-c", "node-a"}, candidates, 3))
+	assert.Equal(t, []string{"node-c"}, SelectServiceNodes([]string{"node-c", "node-a", "node-b"}, candidates, 1))
+
+	// nodes not matching the selector any more are replaced
+	assert.Equal(t, []string{"node-a", "node-b"}, SelectServiceNodes([]string{"node-x", "node-a"}, candidates, 2))
+	assert.Equal(t, []string{"node-a"}, SelectServiceNodes(nil, []string{"node-a"}, 2))
+}
+
+func newMetaDeploy(nodeName string, available int32) *appsv1.Deployment {
+	deploy := &appsv1.Deployment{}
+	deploy.Name, deploy.Namespace = "test-meta-"+nodeName, "default"
+	deploy.Labels = map[string]string{constant.ThreeFSMetaDeployKey: "test"}
+	deploy.Spec.Template.Spec.NodeSelector = map[string]string{constant.KubernetesHostnameKey: nodeName}
+	deploy.Status.Replicas, deploy.Status.AvailableReplicas = 1, available
+	return deploy
+}
+
+func TestDeleteRemovedMetaDeploys(t *testing.T) {
+	metaNode, removedNode := &corev1.Node{}, &corev1.Node{}
+	metaNode.Name, metaNode.Labels = "node-a", map[string]string{constant.ThreeFSMetaNodeKey: "true"}
+	removedNode.Name = "node-b"
+	tfsc := &threefsv1.ThreeFsCluster{}
+	tfsc.Name, tfsc.Namespace = "test", "default"
+	// meta on node-c is unavailable and node-c is removed from the cluster
+	rclient := newFakeClient(metaNode, removedNode, newMetaDeploy("node-a", 1), newMetaDeploy("node-b", 1), newMetaDeploy("node-c", 0))
+	r := &ThreeFsClusterReconciler{Client: rclient, Scheme: rclient.Scheme(), Recorder: record.NewFakeRecorder(10)}
+	metaConfig := meta.NewMetaConfig("test", "default", nil, "", 0, 0, 1, corev1.ResourceRequirements{}, nil, rclient)
+
+	deploys, err := metaConfig.ListRemovedDeploys()
+	assert.NoError(t, err)
+	assert.Len(t, deploys, 2)
+
+	// running meta waits until meta on meta nodes are connected, admin_cli is not available here
+	assert.NoError(t, r.DeleteRemovedMetaDeploys(&clientcomm.AdminCliConfig{}, tfsc, metaConfig, []string{"node-a"}))
+	deployList := &appsv1.DeploymentList{}
+	assert.NoError(t, rclient.List(context.Background(), deployList, client.InNamespace("default")))
+	names := make([]string, 0)
+	for _, deploy := range deployList.Items {
+		names = append(names, deploy.Name)
+	}
+	assert.ElementsMatch(t, []string{"test-meta-node-a", "test-meta-node-b"}, names)
+
+	assert.NoError(t, r.DeleteRemovedMetaDeploys(&clientcomm.AdminCliConfig{}, tfsc, metaConfig, nil))
+	assert.NoError(t, rclient.List(context.Background(), deployList, client.InNamespace("default")))
+	assert.Len(t, deployList.Items, 1)
+	assert.Equal(t, "test-meta-node-a", deployList.Items[0].Name)
+}
@@ -0,0 +1,41 @@
+package controller
+
+import (
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// steps of fdb fault remediation
+const (
+	FdbFaultStepDetected            = "detected"
+	FdbFaultStepRecovered           = "recovered"
+	FdbFaultStepLabeled             = "labeled"
+	FdbFaultStepReplacementCreated  = "replacement_created"
+	FdbFaultStepRemoving            = "removing"
+	FdbFaultStepRemoved             = "removed"
+	FdbFaultStepCoordinatorsChanged = "coordinators_changed"
+)
+
+var (
+	fdbFaultSteps = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "threefs_fdb_fault_remediation_total",
+		Help: "Number of fdb fault remediation steps",
+	}, []string{"namespace", "cluster", "step"})
+	fdbUnhealthyNodes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "threefs_fdb_unhealthy_nodes",
+		Help: "Number of fdb nodes with unreachable processes or coordinators",
+	}, []string{"namespace", "cluster"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(fdbFaultSteps, fdbUnhealthyNodes)
+}
+
+func recordFdbFaultStep(tfsc *threefsv1.ThreeFsCluster, step string) {
+	fdbFaultSteps.WithLabelValues(tfsc.Namespace, tfsc.Name, step).Inc()
+}
+
+func recordFdbUnhealthyNodes(tfsc *threefsv1.ThreeFsCluster, num int) {
+	fdbUnhealthyNodes.WithLabelValues(tfsc.Namespace, tfsc.Name).Set(float64(num))
+}
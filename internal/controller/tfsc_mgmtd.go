@@ -0,0 +1,318 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	clientcomm "github.com/aliyun/kvc-3fs-operator/internal/client"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/aliyun/kvc-3fs-operator/internal/utils"
+	"github.com/aliyun/kvc-3fs-operator/internal/validation"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const mgmtdAddressEnv = "MGMTD_SERVER_ADDRESS"
+
+// GetMgmtdNodes returns sorted nodes with mgmtd label, for clusters created before mgmtd nodes are recorded
+func GetMgmtdNodes(rclient client.Client) ([]string, error) {
+	nodeList := &corev1.NodeList{}
+	if err := rclient.List(context.Background(), nodeList, client.MatchingLabels{constant.ThreeFSMgmtdNodeKey: "true"}); err != nil {
+		klog.Errorf("list node with mgmtd label failed: %v", err)
+		return nil, err
+	}
+	nodes := make([]string, 0, len(nodeList.Items))
+	for _, node := range nodeList.Items {
+		nodes = append(nodes, node.Name)
+	}
+	sort.Strings(nodes)
+	return nodes, nil
+}
+
+// GetContainerEnv returns value of the env in the first container with it
+func GetContainerEnv(spec corev1.PodSpec, name string) (string, bool) {
+	for _, container := range spec.Containers {
+		for _, env := range container.Env {
+			if env.Name == name {
+				return env.Value, true
+			}
+		}
+	}
+	return "", false
+}
+
+func setContainerEnv(spec *corev1.PodSpec, name, value string) {
+	for i := range spec.Containers {
+		for j := range spec.Containers[i].Env {
+			if spec.Containers[i].Env[j].Name == name {
+				spec.Containers[i].Env[j].Value = value
+			}
+		}
+	}
+}
+
+func isRolledDeployReady(adminCliConfig *clientcomm.AdminCliConfig, component string, deploy *appsv1.Deployment, rclient client.Client) bool {
+	if deploy.Status.AvailableReplicas != deploy.Status.Replicas || deploy.Status.ObservedGeneration < deploy.Generation {
+		return false
+	}
+	deployNodeName := deploy.Spec.Template.Spec.NodeSelector[constant.KubernetesHostnameKey]
+	if !CheckComponentStatus(adminCliConfig, strings.ToUpper(component), utils.TranslatePlainNodeName3fs(deployNodeName), false, rclient) {
+		return false
+	}
+	if component == "storage" {
+		return CheckTargetStatus(adminCliConfig, deployNodeName)
+	}
+	return true
+}
+
+// RollMgmtdAddresses updates mgmtd addresses of mgmtd, meta and storage deployments one by one after mgmtd is
+// replaced, the next deployment is updated only after the former ones are connected and their targets are UPTODATE.
+// It returns true until all deployments and fuse clients use the current mgmtd addresses.
+func (r *ThreeFsClusterReconciler) RollMgmtdAddresses(adminCliConfig *clientcomm.AdminCliConfig, tfsc *threefsv1.ThreeFsCluster) (bool, error) {
+	addresses := tfsc.Status.MgmtdAddresses
+	if !strings.Contains(addresses, "RDMA") {
+		return false, nil
+	}
+	labelKeys := map[string]string{
+		"mgmtd":   constant.ThreeFSMgmtdDeployKey,
+		"meta":    constant.ThreeFSMetaDeployKey,
+		"storage": constant.ThreeFSStorageDeployKey,
+	}
+	// surviving mgmtd know the new one first, then meta and storage
+	for _, component := range []string{"mgmtd", "meta", "storage"} {
+		labelKey := labelKeys[component]
+		deployList := &appsv1.DeploymentList{}
+		if err := r.Client.List(context.Background(), deployList, client.InNamespace(tfsc.Namespace), client.MatchingLabels{labelKey: tfsc.Name}); err != nil {
+			klog.Errorf("list %s deployment failed: %v", component, err)
+			return false, err
+		}
+		stale := make([]*appsv1.Deployment, 0)
+		for idx := range deployList.Items {
+			deploy := &deployList.Items[idx]
+			if address, ok := GetContainerEnv(deploy.Spec.Template.Spec, mgmtdAddressEnv); !ok || address == addresses {
+				continue
+			}
+			stale = append(stale, deploy)
+		}
+		if len(stale) == 0 {
+			continue
+		}
+		for idx := range deployList.Items {
+			deploy := &deployList.Items[idx]
+			if address, _ := GetContainerEnv(deploy.Spec.Template.Spec, mgmtdAddressEnv); address == addresses && !isRolledDeployReady(adminCliConfig, component, deploy, r.Client) {
+				klog.Infof("%s deploy %s with new mgmtd addresses is not ready yet, wait", component, deploy.Name)
+				return true, nil
+			}
+		}
+
+		// one by one
+		deploy := stale[0]
+		setContainerEnv(&deploy.Spec.Template.Spec, mgmtdAddressEnv, addresses)
+		if err := r.Client.Update(context.Background(), deploy); err != nil {
+			klog.Errorf("update deployment %s mgmtd addresses failed: %v", deploy.Name, err)
+			return false, err
+		}
+		klog.Infof("update deployment %s with mgmtd addresses %s, %d %s deployments left", deploy.Name, addresses, len(stale)-1, component)
+		r.Recorder.Event(tfsc, corev1.EventTypeNormal, "MgmtdAddressesRolled", fmt.Sprintf("%s deployment %s uses new mgmtd addresses", component, deploy.Name))
+		return true, nil
+	}
+	return r.RollFuseClients(tfsc, addresses)
+}
+
+// RollFuseClients evicts pods whose fuse sidecar uses old mgmtd addresses one by one, the pods recreated by
+// their controllers are injected with the current addresses. The next pod is evicted only after all fuse client
+// pods are ready. Pods without controller are not recreated after eviction, they are only reported by
+// FuseClientsStale events
+func (r *ThreeFsClusterReconciler) RollFuseClients(tfsc *threefsv1.ThreeFsCluster, addresses string) (bool, error) {
+	podList := &corev1.PodList{}
+	if err := r.Client.List(context.Background(), podList, client.MatchingLabels{constant.ThreeFSCrdLabel: tfsc.Name, constant.ThreeFSCrdNsLabel: tfsc.Namespace}); err != nil {
+		klog.Errorf("list fuse client pods failed: %v", err)
+		return false, err
+	}
+	var stale *corev1.Pod
+	unmanaged := make([]string, 0)
+	for idx := range podList.Items {
+		pod := &podList.Items[idx]
+		address, ok := GetContainerEnv(pod.Spec, mgmtdAddressEnv)
+		if !ok {
+			continue
+		}
+		if pod.DeletionTimestamp != nil || !validation.IsPodReady(pod) {
+			klog.Infof("fuse client pod %s/%s is not ready yet, wait", pod.Namespace, pod.Name)
+			return true, nil
+		}
+		if address == addresses {
+			continue
+		}
+		if metav1.GetControllerOf(pod) == nil {
+			unmanaged = append(unmanaged, fmt.Sprintf("%s/%s", pod.Namespace, pod.Name))
+			continue
+		}
+		if stale == nil {
+			stale = pod
+		}
+	}
+	if stale == nil {
+		if len(unmanaged) > 0 {
+			r.Recorder.Event(tfsc, corev1.EventTypeWarning, "FuseClientsStale", fmt.Sprintf("fuse clients of %d pods without controller use old mgmtd addresses, restart them to use new addresses: %s", len(unmanaged), strings.Join(unmanaged, ", ")))
+		}
+		return false, nil
+	}
+
+	// eviction respects PodDisruptionBudget of the pod, retry later if it is not allowed now
+	if err := r.Client.SubResource("eviction").Create(context.Background(), stale, &policyv1.Eviction{}); err != nil {
+		if k8serror.IsTooManyRequests(err) {
+			klog.Infof("evict fuse client pod %s/%s is not allowed now: %v", stale.Namespace, stale.Name, err)
+			return true, nil
+		}
+		klog.Errorf("evict fuse client pod %s/%s failed: %v", stale.Namespace, stale.Name, err)
+		return false, err
+	}
+	klog.Infof("evict fuse client pod %s/%s to use mgmtd addresses %s", stale.Namespace, stale.Name, addresses)
+	r.Recorder.Event(tfsc, corev1.EventTypeNormal, "FuseClientRolled", fmt.Sprintf("fuse client pod %s/%s is evicted to use new mgmtd addresses", stale.Namespace, stale.Name))
+	return true, nil
+}
+
+// GetPrimaryMgmtd returns the PRIMARY_MGMTD node in list-nodes
+func GetPrimaryMgmtd(nodes []NodeInfo) (NodeInfo, bool) {
+	for _, node := range nodes {
+		if node.Type == "MGMTD" && node.Status == "PRIMARY_MGMTD" {
+			return node, true
+		}
+	}
+	return NodeInfo{}, false
+}
+
+// NextPrimaryMgmtd returns the primary mgmtd to record and whether the primary changed
+func NextPrimaryMgmtd(current threefsv1.PrimaryMgmtd, nodeName, nodeId, now string) (threefsv1.PrimaryMgmtd, bool) {
+	if current.NodeId == nodeId && current.NodeName == nodeName {
+		return current, false
+	}
+	return threefsv1.PrimaryMgmtd{
+		NodeName:   nodeName,
+		NodeId:     nodeId,
+		Since:      now,
+		MovingFrom: current.MovingFrom,
+	}, true
+}
+
+// SyncMgmtdPrimaryLabel tags the primary mgmtd node and removes the label from other nodes
+func SyncMgmtdPrimaryLabel(nodeName string, rclient client.Client) error {
+	nodeList := &corev1.NodeList{}
+	if err := rclient.List(context.Background(), nodeList, client.HasLabels{constant.ThreeFSMgmtdPrimaryNodeKey}); err != nil {
+		klog.Errorf("list node with mgmtd primary label failed: %v", err)
+		return err
+	}
+	tagged := false
+	for _, node := range nodeList.Items {
+		if node.Name == nodeName {
+			tagged = true
+			continue
+		}
+		delete(node.Labels, constant.ThreeFSMgmtdPrimaryNodeKey)
+		if err := rclient.Update(context.Background(), &node); err != nil {
+			klog.Errorf("remove node %s with mgmtd primary label failed: %v", node.Name, err)
+			return err
+		}
+		klog.Infof("remove node %s with mgmtd primary label success", node.Name)
+	}
+	if tagged {
+		return nil
+	}
+	return TagMgmtdPrimaryLabel(nodeName, rclient)
+}
+
+func (r *ThreeFsClusterReconciler) updatePrimaryMgmtdStatus(tfsc *threefsv1.ThreeFsCluster, primary threefsv1.PrimaryMgmtd) error {
+	localCache := threefsv1.ThreeFsCluster{}
+	_ = r.Get(context.Background(), client.ObjectKey{Name: tfsc.Name, Namespace: tfsc.Namespace}, &localCache)
+
+	modifiedObj := localCache.DeepCopy()
+	modifiedObj.Status.PrimaryMgmtd = primary
+	tfsc.Status.PrimaryMgmtd = primary
+	return r.Client.Status().Patch(context.Background(), modifiedObj, client.MergeFrom(&localCache))
+}
+
+func (r *ThreeFsClusterReconciler) removeMovePrimaryAnnotation(tfsc *threefsv1.ThreeFsCluster) error {
+	localCache := threefsv1.ThreeFsCluster{}
+	if err := r.Get(context.Background(), client.ObjectKey{Name: tfsc.Name, Namespace: tfsc.Namespace}, &localCache); err != nil {
+		klog.Errorf("get threeFsCluster %s failed: %v", tfsc.Name, err)
+		return err
+	}
+	if _, ok := localCache.Annotations[constant.ThreeFSMgmtdMovePrimaryKey]; !ok {
+		return nil
+	}
+	modifiedObj := localCache.DeepCopy()
+	delete(modifiedObj.Annotations, constant.ThreeFSMgmtdMovePrimaryKey)
+	return r.Client.Patch(context.Background(), modifiedObj, client.MergeFrom(&localCache))
+}
+
+// HandleMgmtdMovePrimary moves the primary mgmtd away from its node when the cluster has the mgmtd-move-primary
+// annotation. The primary mgmtd pod is restarted only if all other mgmtd are healthy, so another mgmtd takes over
+// the primary lease. It returns true until the new primary is elected and all mgmtd are healthy again
+func (r *ThreeFsClusterReconciler) HandleMgmtdMovePrimary(adminCliConfig *clientcomm.AdminCliConfig, tfsc *threefsv1.ThreeFsCluster) (bool, error) {
+	primary := tfsc.Status.PrimaryMgmtd
+	if primary.MovingFrom != "" {
+		if primary.NodeName == "" || primary.NodeName == primary.MovingFrom || !CheckComponentStatus(adminCliConfig, "MGMTD", "", true, r.Client) {
+			klog.Infof("primary mgmtd is moving from %s, wait new primary elected and mgmtd healthy", primary.MovingFrom)
+			return true, nil
+		}
+		if err := r.removeMovePrimaryAnnotation(tfsc); err != nil {
+			klog.Errorf("remove mgmtd move primary annotation of threeFsCluster %s failed: %v", tfsc.Name, err)
+			return false, err
+		}
+		movingFrom := primary.MovingFrom
+		primary.MovingFrom = ""
+		if err := r.updatePrimaryMgmtdStatus(tfsc, primary); err != nil {
+			klog.Errorf("update primary mgmtd status of threeFsCluster %s failed: %v", tfsc.Name, err)
+			return false, err
+		}
+		klog.Infof("primary mgmtd moved from %s to %s", movingFrom, primary.NodeName)
+		r.Recorder.Event(tfsc, corev1.EventTypeNormal, "MgmtdPrimaryMoved", fmt.Sprintf("primary mgmtd moved from %s to %s", movingFrom, primary.NodeName))
+		return false, nil
+	}
+
+	if tfsc.Annotations == nil || tfsc.Annotations[constant.ThreeFSMgmtdMovePrimaryKey] != "true" {
+		return false, nil
+	}
+	if tfsc.Spec.Mgmtd.Replica < 2 {
+		klog.Errorf("move primary mgmtd of threeFsCluster %s rejected, mgmtd replica is %d", tfsc.Name, tfsc.Spec.Mgmtd.Replica)
+		r.Recorder.Event(tfsc, corev1.EventTypeWarning, "MgmtdMovePrimaryRejected", fmt.Sprintf("move primary mgmtd needs at least 2 mgmtd, replica is %d", tfsc.Spec.Mgmtd.Replica))
+		return false, r.removeMovePrimaryAnnotation(tfsc)
+	}
+	if primary.NodeName == "" || !CheckComponentStatus(adminCliConfig, "MGMTD", "", true, r.Client) {
+		klog.Infof("primary mgmtd is unknown or mgmtd is not healthy, wait to move primary")
+		return true, nil
+	}
+
+	// record first, so the primary is restarted only once
+	primary.MovingFrom = primary.NodeName
+	if err := r.updatePrimaryMgmtdStatus(tfsc, primary); err != nil {
+		klog.Errorf("update primary mgmtd status of threeFsCluster %s failed: %v", tfsc.Name, err)
+		return false, err
+	}
+	podList := &corev1.PodList{}
+	if err := r.Client.List(context.Background(), podList, client.InNamespace(tfsc.Namespace), client.MatchingLabels{constant.ThreeFSMgmtdDeployKey: tfsc.Name}); err != nil {
+		klog.Errorf("list mgmtd pods failed: %v", err)
+		return false, err
+	}
+	for _, pod := range podList.Items {
+		if pod.Spec.NodeName != primary.NodeName {
+			continue
+		}
+		klog.Infof("restart primary mgmtd pod %s on node %s", pod.Name, primary.NodeName)
+		if err := r.Client.Delete(context.Background(), &pod); err != nil && !k8serror.IsNotFound(err) {
+			klog.Errorf("delete mgmtd pod %s failed: %v", pod.Name, err)
+			return false, err
+		}
+	}
+	r.Recorder.Event(tfsc, corev1.EventTypeNormal, "MgmtdPrimaryMoving", fmt.Sprintf("restart primary mgmtd on %s to move primary to another mgmtd", primary.NodeName))
+	return true, nil
+}
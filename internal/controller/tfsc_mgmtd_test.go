@@ -0,0 +1,184 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestMgmtdAddressEnv(t *testing.T) {
+	spec := corev1.PodSpec{
+		Containers: []corev1.Container{
+			{Name: "meta", Env: []corev1.EnvVar{{Name: "NODE_NAME"}, {Name: mgmtdAddressEnv, Value: `["RDMA://10.0.0.1:8000"]`}}},
+		},
+	}
+	address, ok := GetContainerEnv(spec, mgmtdAddressEnv)
+	assert.True(t, ok)
+	assert.Equal(t, `["RDMA://10.0.0.1:8000"]`, address)
+
+	setContainerEnv(&spec, mgmtdAddressEnv, `["RDMA://10.0.0.2:8000"]`)
+	address, _ = GetContainerEnv(spec, mgmtdAddressEnv)
+	assert.Equal(t, `["RDMA://10.0.0.2:8000"]`, address)
+
+	_, ok = GetContainerEnv(spec, "NOT_EXIST")
+	assert.False(t, ok)
+}
+
+func TestReplaceMgmtdNode(t *testing.T) {
+	assert.Equal(t, []string{"node-a", "node-d", "node-c"}, replaceNode([]string{"node-a", "node-b", "node-c"}, "node-b", "node-d"))
+}
+
+func TestPrimaryMgmtd(t *testing.T) {
+	nodes := []NodeInfo{
+		{Id: "1", Type: "MGMTD", Status: "HEARTBEAT_CONNECTED", Hostname: "node_a"},
+		{Id: "2", Type: "MGMTD", Status: "PRIMARY_MGMTD", Hostname: "node_b"},
+		{Id: "100", Type: "META", Status: "HEARTBEAT_CONNECTED", Hostname: "node_b"},
+	}
+	node, ok := GetPrimaryMgmtd(nodes)
+	assert.True(t, ok)
+	assert.Equal(t, "2", node.Id)
+	_, ok = GetPrimaryMgmtd(nodes[:1])
+	assert.False(t, ok)
+
+	current := threefsv1.PrimaryMgmtd{NodeName: "node-b", NodeId: "2", Since: "2025-01-01 00:00:00"}
+	next, changed := NextPrimaryMgmtd(current, "node-b", "2", "2025-01-02 00:00:00")
+	assert.False(t, changed)
+	assert.Equal(t, current, next)
+
+	// moving is kept until the move is completed
+	current.MovingFrom = "node-b"
+	next, changed = NextPrimaryMgmtd(current, "node-a", "1", "2025-01-02 00:00:00")
+	assert.True(t, changed)
+	assert.Equal(t, threefsv1.PrimaryMgmtd{NodeName: "node-a", NodeId: "1", Since: "2025-01-02 00:00:00", MovingFrom: "node-b"}, next)
+}
+
+func newMgmtdAddressDeploy(name, labelKey, addresses string) *appsv1.Deployment {
+	deploy := &appsv1.Deployment{}
+	deploy.Name, deploy.Namespace = name, "default"
+	deploy.Labels = map[string]string{labelKey: "test"}
+	deploy.Spec.Template.Spec.Containers = []corev1.Container{{Name: "main", Env: []corev1.EnvVar{{Name: mgmtdAddressEnv, Value: addresses}}}}
+	// pods of rolled deployments are not available yet
+	deploy.Status.Replicas = 1
+	return deploy
+}
+
+func TestRollMgmtdAddresses(t *testing.T) {
+	oldAddresses, newAddresses := `["RDMA://10.0.0.1:8000","RDMA://10.0.0.2:8000"]`, `["RDMA://10.0.0.1:8000","RDMA://10.0.0.3:8000"]`
+	tfsc := &threefsv1.ThreeFsCluster{}
+	tfsc.Name, tfsc.Namespace = "test", "default"
+	tfsc.Status.MgmtdAddresses = newAddresses
+	getAddresses := func(rclient client.Client, name string) string {
+		deploy := &appsv1.Deployment{}
+		assert.NoError(t, rclient.Get(context.Background(), client.ObjectKey{Name: name, Namespace: "default"}, deploy))
+		address, _ := GetContainerEnv(deploy.Spec.Template.Spec, mgmtdAddressEnv)
+		return address
+	}
+
+	// mgmtd is rolled before meta, and one deployment at a time
+	rclient := newFakeClient(
+		newMgmtdAddressDeploy("test-mgmtd-a", constant.ThreeFSMgmtdDeployKey, oldAddresses),
+		newMgmtdAddressDeploy("test-mgmtd-b", constant.ThreeFSMgmtdDeployKey, oldAddresses),
+		newMgmtdAddressDeploy("test-meta-a", constant.ThreeFSMetaDeployKey, oldAddresses),
+	)
+	r := &ThreeFsClusterReconciler{Client: rclient, Scheme: rclient.Scheme(), Recorder: record.NewFakeRecorder(10)}
+	rolling, err := r.RollMgmtdAddresses(nil, tfsc)
+	assert.NoError(t, err)
+	assert.True(t, rolling)
+	assert.Equal(t, newAddresses, getAddresses(rclient, "test-mgmtd-a"))
+	assert.Equal(t, oldAddresses, getAddresses(rclient, "test-mgmtd-b"))
+	assert.Equal(t, oldAddresses, getAddresses(rclient, "test-meta-a"))
+	// wait for the rolled mgmtd
+	rolling, err = r.RollMgmtdAddresses(nil, tfsc)
+	assert.NoError(t, err)
+	assert.True(t, rolling)
+	assert.Equal(t, oldAddresses, getAddresses(rclient, "test-mgmtd-b"))
+
+	// meta is rolled once all mgmtd use the new addresses
+	rclient = newFakeClient(
+		newMgmtdAddressDeploy("test-mgmtd-a", constant.ThreeFSMgmtdDeployKey, newAddresses),
+		newMgmtdAddressDeploy("test-meta-a", constant.ThreeFSMetaDeployKey, oldAddresses),
+	)
+	r.Client = rclient
+	rolling, err = r.RollMgmtdAddresses(nil, tfsc)
+	assert.NoError(t, err)
+	assert.True(t, rolling)
+	assert.Equal(t, newAddresses, getAddresses(rclient, "test-meta-a"))
+
+	// addresses of pod network are not rolled
+	tfsc.Status.MgmtdAddresses = ""
+	rolling, err = r.RollMgmtdAddresses(nil, tfsc)
+	assert.NoError(t, err)
+	assert.False(t, rolling)
+}
+
+func newFuseClientPod(name, addresses string, ready, managed bool) *corev1.Pod {
+	pod := &corev1.Pod{}
+	pod.Name, pod.Namespace = name, "app"
+	pod.Labels = map[string]string{constant.ThreeFSCrdLabel: "test", constant.ThreeFSCrdNsLabel: "default"}
+	pod.Spec.Containers = []corev1.Container{{Name: "threefs-sidecar", Env: []corev1.EnvVar{{Name: mgmtdAddressEnv, Value: addresses}}}}
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	pod.Status.Phase = corev1.PodRunning
+	pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: status}}
+	if managed {
+		isController := true
+		pod.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "app", UID: "uid", Controller: &isController}}
+	}
+	return pod
+}
+
+func TestRollFuseClients(t *testing.T) {
+	oldAddresses, newAddresses := `["RDMA://10.0.0.1:8000","RDMA://10.0.0.2:8000"]`, `["RDMA://10.0.0.1:8000","RDMA://10.0.0.3:8000"]`
+	tfsc := &threefsv1.ThreeFsCluster{}
+	tfsc.Name, tfsc.Namespace = "test", "default"
+	tfsc.Status.MgmtdAddresses = newAddresses
+	exists := func(rclient client.Client, name string) bool {
+		return rclient.Get(context.Background(), client.ObjectKey{Name: name, Namespace: "app"}, &corev1.Pod{}) == nil
+	}
+
+	// fuse clients are rolled after all deployments use the new addresses, one pod at a time
+	rclient := newFakeClient(
+		newMgmtdAddressDeploy("test-mgmtd-a", constant.ThreeFSMgmtdDeployKey, newAddresses),
+		newFuseClientPod("app-a", oldAddresses, true, true),
+		newFuseClientPod("app-b", oldAddresses, true, true),
+		newFuseClientPod("app-c", newAddresses, true, true),
+	)
+	r := &ThreeFsClusterReconciler{Client: rclient, Scheme: rclient.Scheme(), Recorder: record.NewFakeRecorder(10)}
+	rolling, err := r.RollMgmtdAddresses(nil, tfsc)
+	assert.NoError(t, err)
+	assert.True(t, rolling)
+	assert.False(t, exists(rclient, "app-a"))
+	assert.True(t, exists(rclient, "app-b"))
+	assert.True(t, exists(rclient, "app-c"))
+
+	// wait until the recreated pod is ready
+	assert.NoError(t, rclient.Create(context.Background(), newFuseClientPod("app-d", newAddresses, false, true)))
+	rolling, err = r.RollMgmtdAddresses(nil, tfsc)
+	assert.NoError(t, err)
+	assert.True(t, rolling)
+	assert.True(t, exists(rclient, "app-b"))
+
+	// pods without controller are reported instead of evicted
+	rclient = newFakeClient(
+		newFuseClientPod("app-a", oldAddresses, true, false),
+		newFuseClientPod("app-c", newAddresses, true, true),
+	)
+	recorder := record.NewFakeRecorder(10)
+	r = &ThreeFsClusterReconciler{Client: rclient, Scheme: rclient.Scheme(), Recorder: recorder}
+	rolling, err = r.RollMgmtdAddresses(nil, tfsc)
+	assert.NoError(t, err)
+	assert.False(t, rolling)
+	assert.True(t, exists(rclient, "app-a"))
+	assert.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "FuseClientsStale")
+}
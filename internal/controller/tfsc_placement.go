@@ -0,0 +1,442 @@
+package controller
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	clientcomm "github.com/aliyun/kvc-3fs-operator/internal/client"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/aliyun/kvc-3fs-operator/internal/storage"
+	"github.com/aliyun/kvc-3fs-operator/internal/utils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ParseStorageNodeIdMap maps node ids generated by data placement (start from ThreeFSStorageStartNodeId,
+// in the order of nodes) to node ids which are actually used by the storage nodes
+func ParseStorageNodeIdMap(rclient client.Client, nodes []string, tfsc *threefsv1.ThreeFsCluster) (map[int]int, error) {
+	storageEnvConfig := &corev1.ConfigMap{}
+	if err := rclient.Get(context.Background(), client.ObjectKey{Name: storage.GetStorageDeployName(tfsc.Name), Namespace: tfsc.Namespace}, storageEnvConfig); err != nil {
+		klog.Errorf("get storage env config failed, err: %+v", err)
+		return nil, err
+	}
+
+	nodeIds := make(map[int]int)
+	for idx, node := range nodes {
+		val, ok := storageEnvConfig.Data[utils.TranslatePlainNodeName3fs(node)]
+		if !ok {
+			return nil, fmt.Errorf("node %s not found", node)
+		}
+		nodeId, err := strconv.Atoi(val)
+		if err != nil {
+			return nil, fmt.Errorf("node %s node id %s is not a number", node, val)
+		}
+		nodeIds[constant.ThreeFSStorageStartNodeId+idx] = nodeId
+	}
+	return nodeIds, nil
+}
+
+// ParseTargetNodeId returns the node id of the target, target id is composed of prefix(2) + node id(5) +
+// disk index(3) + target index
+func ParseTargetNodeId(targetId string) (int, error) {
+	if len(targetId) < 10 {
+		return 0, fmt.Errorf("invalid target id %s", targetId)
+	}
+	nodeId, err := strconv.Atoi(targetId[2:7])
+	if err != nil {
+		return 0, fmt.Errorf("invalid target id %s: %v", targetId, err)
+	}
+	return nodeId, nil
+}
+
+// RemapTargetId moves the target to the real node id and shifts its disk index by diskOffset,
+// target id is composed of prefix(2) + node id(5) + disk index(3) + target index
+func RemapTargetId(targetId string, diskOffset int, nodeIds map[int]int) (string, error) {
+	if len(targetId) < 10 {
+		return "", fmt.Errorf("invalid target id %s", targetId)
+	}
+	nodeId, err := ParseTargetNodeId(targetId)
+	if err != nil {
+		return "", err
+	}
+	newNodeId, ok := nodeIds[nodeId]
+	if !ok {
+		return "", fmt.Errorf("node id %d of target %s not found", nodeId, targetId)
+	}
+	diskIdx, err := strconv.Atoi(targetId[7:10])
+	if err != nil {
+		return "", fmt.Errorf("invalid target id %s: %v", targetId, err)
+	}
+	return fmt.Sprintf("%s%05d%03d%s", targetId[:2], newNodeId, diskIdx+diskOffset, targetId[10:]), nil
+}
+
+// RemapChainId shifts the disk index of the chain id by diskOffset
+func RemapChainId(chainId string, diskOffset int) (string, error) {
+	num, err := strconv.Atoi(chainId)
+	if err != nil {
+		return "", fmt.Errorf("invalid chain id %s: %v", chainId, err)
+	}
+	return strconv.Itoa(num + diskOffset*100000), nil
+}
+
+func remapCSVFile(path, pattern string, remap func(col int, val string) (string, error)) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		klog.Errorf("open file %s failed: %+v", path, err)
+		return "", err
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		klog.Errorf("read csv file failed: %+v", err)
+		return "", err
+	}
+	if len(records) < 2 {
+		return "", fmt.Errorf("csv file %s is empty", path)
+	}
+	for i := 1; i < len(records); i++ {
+		for j := range records[i] {
+			if records[i][j] == "" {
+				continue
+			}
+			if records[i][j], err = remap(j, records[i][j]); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	outFile, err := os.CreateTemp(filepath.Dir(path), pattern)
+	if err != nil {
+		klog.Errorf("create file in %s failed: %+v", filepath.Dir(path), err)
+		return "", err
+	}
+	defer outFile.Close()
+	if err := csv.NewWriter(outFile).WriteAll(records); err != nil {
+		klog.Errorf("write csv file failed: %+v", err)
+		return "", err
+	}
+	return outFile.Name(), nil
+}
+
+func remapTargetFile(path string, diskOffset int, nodeIds map[int]int) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		klog.Errorf("open file %s failed: %+v", path, err)
+		return "", err
+	}
+	defer file.Close()
+
+	outFile, err := os.CreateTemp(filepath.Dir(path), "target_remapped_*.txt")
+	if err != nil {
+		klog.Errorf("create file in %s failed: %+v", filepath.Dir(path), err)
+		return "", err
+	}
+	defer outFile.Close()
+
+	remaps := map[*regexp.Regexp]func(string) (string, error){
+		regexp.MustCompile(`(--node-id\s+)(\d+)`): func(val string) (string, error) {
+			nodeId, _ := strconv.Atoi(val)
+			newNodeId, ok := nodeIds[nodeId]
+			if !ok {
+				return "", fmt.Errorf("node id %s not found", val)
+			}
+			return strconv.Itoa(newNodeId), nil
+		},
+		regexp.MustCompile(`(--disk-index\s+)(\d+)`): func(val string) (string, error) {
+			diskIdx, _ := strconv.Atoi(val)
+			return strconv.Itoa(diskIdx + diskOffset), nil
+		},
+		regexp.MustCompile(`(--target-id\s+)(\d+)`): func(val string) (string, error) {
+			return RemapTargetId(val, diskOffset, nodeIds)
+		},
+		regexp.MustCompile(`(--chain-id\s+)(\d+)`): func(val string) (string, error) {
+			return RemapChainId(val, diskOffset)
+		},
+	}
+
+	scanner := bufio.NewScanner(file)
+	writer := bufio.NewWriter(outFile)
+	for scanner.Scan() {
+		line := scanner.Text()
+		for re, remap := range remaps {
+			matches := re.FindStringSubmatch(line)
+			if len(matches) < 3 {
+				continue
+			}
+			newVal, err := remap(matches[2])
+			if err != nil {
+				klog.Errorf("remap line %s failed: %v", line, err)
+				return "", err
+			}
+			line = strings.Replace(line, matches[0], matches[1]+newVal, 1)
+		}
+		_, _ = writer.WriteString(line + "\n")
+	}
+	if err := writer.Flush(); err != nil {
+		klog.Errorf("flush file failed: %+v", err)
+		return "", err
+	}
+	return outFile.Name(), nil
+}
+
+// RemapPlacementFiles rewrites files generated for the new disks only, so that disk index starts from diskOffset
+// and node ids are the ones used by the storage nodes
+func RemapPlacementFiles(targetPath, chainPath, chaintablePath string, diskOffset int, nodeIds map[int]int) (string, string, string, error) {
+	newTargetPath, err := remapTargetFile(targetPath, diskOffset, nodeIds)
+	if err != nil {
+		return "", "", "", err
+	}
+	newChainPath, err := remapCSVFile(chainPath, "chains_remapped_*.csv", func(col int, val string) (string, error) {
+		if col == 0 {
+			return RemapChainId(val, diskOffset)
+		}
+		return RemapTargetId(val, diskOffset, nodeIds)
+	})
+	if err != nil {
+		return "", "", "", err
+	}
+	newChaintablePath, err := remapCSVFile(chaintablePath, "chain_table_remapped_*.csv", func(col int, val string) (string, error) {
+		return RemapChainId(val, diskOffset)
+	})
+	if err != nil {
+		return "", "", "", err
+	}
+	klog.Infof("remap placement files success, target: %s, chains: %s, chain table: %s", newTargetPath, newChainPath, newChaintablePath)
+	return newTargetPath, newChainPath, newChaintablePath, nil
+}
+
+func appendFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.Copy(out, in)
+	return err
+}
+
+func appendCSVFile(src, dst string) error {
+	if _, err := os.Stat(dst); os.IsNotExist(err) {
+		return appendFile(src, dst)
+	}
+	if err := utils.MergeCSVFiles(dst, src, dst+".tmp"); err != nil {
+		return err
+	}
+	return os.Rename(dst+".tmp", dst)
+}
+
+// PlacedChainTable is the data placement output of a chain table
+type PlacedChainTable struct {
+	Table          storage.ChainTable
+	ChainPath      string
+	ChainTablePath string
+	ChainNum       int
+}
+
+// PlaceStorageGroups runs data placement for each group with the redundancy of the chain table which the group is
+// placed into, groups without chain table are skipped. Node ids and disk indexes of generated files are remapped,
+// and chain ids are shifted by the max chain index of each disk in maps, which is updated with every group, so
+// chains of different groups never overlap. It returns the merged create target file and chains and chain table
+// files of each chain table
+func PlaceStorageGroups(rclient client.Client, tfsc *threefsv1.ThreeFsCluster, groups []storage.PlacementGroup, maps map[int]int) (string, []PlacedChainTable, error) {
+	outputDir := constant.DefaultPlacementOutputPath
+	_ = os.RemoveAll(outputDir)
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		klog.Errorf("create dir %s failed: %v", outputDir, err)
+		return "", nil, err
+	}
+	targetOut := filepath.Join(outputDir, "create_target_cmd.txt")
+
+	maxChainIdx := make(map[int]int)
+	for diskIdx, chainIdx := range maps {
+		maxChainIdx[diskIdx] = chainIdx
+	}
+	placed := make([]PlacedChainTable, 0)
+	for _, group := range groups {
+		if len(group.Nodes) == 0 || group.NumDisks == 0 {
+			continue
+		}
+		table, ok := storage.GetNodeGroupChainTable(tfsc.Spec, group.Group)
+		if !ok {
+			klog.Infof("node group %q is not placed into any chain table, skip nodes %v", group.Group, group.Nodes)
+			continue
+		}
+		klog.Infof("place data for node group %q into chain table %s, nodes: %v, disks: %d, targets per disk: %d",
+			group.Group, table.Id, group.Nodes, group.NumDisks, group.TargetPerDisk)
+		if err := clientcomm.CreateDataPlacementRuleWithLayout(group.Nodes, table.Storage, constant.ThreeFSStorageStartNodeId, group.NumDisks, group.TargetPerDisk); err != nil {
+			return "", nil, err
+		}
+		// generated node ids are mapped to nodes in order, reorder nodes so that targets of each chain are
+		// spread across failure domains
+		nodes, err := SpreadNodesAcrossDomains(rclient, table.Storage, "/output/generated_chains.csv", group.Nodes)
+		if err != nil {
+			klog.Errorf("spread nodes of group %q across failure domains failed: %v", group.Group, err)
+			return "", nil, err
+		}
+		nodeIds, err := ParseStorageNodeIdMap(rclient, nodes, tfsc)
+		if err != nil {
+			return "", nil, err
+		}
+		targetPath, chainPath, chainTablePath, err := RemapPlacementFiles("/output/create_target_cmd.txt", "/output/generated_chains.csv",
+			"/output/generated_chain_table.csv", group.DiskOffset, nodeIds)
+		if err != nil {
+			return "", nil, err
+		}
+		targetPath, chainPath, chainTablePath, err = UpdateChainIdWithExistingChain(targetPath, chainPath, chainTablePath, maxChainIdx)
+		if err != nil {
+			return "", nil, err
+		}
+
+		chainIds, err := ParseChainTableFromFile(chainTablePath)
+		if err != nil {
+			return "", nil, err
+		}
+		for _, chainId := range chainIds {
+			chainIdx, diskIdx, err := ParseChainId(chainId)
+			if err != nil {
+				return "", nil, err
+			}
+			if maxChainIdx[diskIdx] < chainIdx {
+				maxChainIdx[diskIdx] = chainIdx
+			}
+		}
+
+		idx := -1
+		for i := range placed {
+			if placed[i].Table.Id == table.Id {
+				idx = i
+			}
+		}
+		if idx < 0 {
+			placed = append(placed, PlacedChainTable{
+				Table:          table,
+				ChainPath:      filepath.Join(outputDir, fmt.Sprintf("generated_chains.%s.csv", table.Id)),
+				ChainTablePath: filepath.Join(outputDir, fmt.Sprintf("generated_chain_table.%s.csv", table.Id)),
+			})
+			idx = len(placed) - 1
+		}
+		placed[idx].ChainNum += len(chainIds)
+
+		if err := appendFile(targetPath, targetOut); err != nil {
+			klog.Errorf("merge target file %s failed: %v", targetPath, err)
+			return "", nil, err
+		}
+		if err := appendCSVFile(chainPath, placed[idx].ChainPath); err != nil {
+			klog.Errorf("merge chains file %s failed: %v", chainPath, err)
+			return "", nil, err
+		}
+		if err := appendCSVFile(chainTablePath, placed[idx].ChainTablePath); err != nil {
+			klog.Errorf("merge chain table file %s failed: %v", chainTablePath, err)
+			return "", nil, err
+		}
+	}
+	return targetOut, placed, nil
+}
+
+// IsChainTablePlaced returns true if chains of the table are already uploaded
+func IsChainTablePlaced(tfsc *threefsv1.ThreeFsCluster, id string) bool {
+	for _, table := range tfsc.Status.ChainTables {
+		if table.Id == id && table.Phase == constant.ChainTablePlacedStatus {
+			return true
+		}
+	}
+	return false
+}
+
+// UploadPlacedChainTables uploads chains and chain tables generated by PlaceStorageGroups. New chains are merged
+// with dumped chains with the same number of targets, and new chain table is merged with the dumped chain table
+// if the table is placed already, otherwise the table is created and its directory is made
+func UploadPlacedChainTables(adminCli *clientcomm.AdminCliConfig, token string, tfsc *threefsv1.ThreeFsCluster, placed []PlacedChainTable) error {
+	for _, table := range placed {
+		dumpChainsPath := "output/dump_chains.csv"
+		if matched, err := filepath.Glob(dumpChainsPath + ".*"); err == nil {
+			for _, file := range matched {
+				_ = os.Remove(file)
+			}
+		}
+		if err := adminCli.DumpChains(token, dumpChainsPath); err != nil {
+			return err
+		}
+		chainPath := table.ChainPath
+		dumpedChains := fmt.Sprintf("%s.%d", dumpChainsPath, storage.GetChainTargetNum(table.Table.Storage))
+		if _, err := os.Stat(dumpedChains); err == nil {
+			chainPath = fmt.Sprintf("output/new_chains.%s.csv", table.Table.Id)
+			if err := utils.MergeCSVFiles(table.ChainPath, dumpedChains, chainPath); err != nil {
+				return err
+			}
+		}
+		if err := adminCli.UploadChains(token, chainPath); err != nil {
+			return err
+		}
+
+		placedBefore := IsChainTablePlaced(tfsc, table.Table.Id)
+		chainTablePath := table.ChainTablePath
+		if placedBefore {
+			dumpTablePath := fmt.Sprintf("output/dump_chain_table.%s.csv", table.Table.Id)
+			if err := adminCli.DumpChainTable(token, table.Table.Id, dumpTablePath); err != nil {
+				return err
+			}
+			chainTablePath = fmt.Sprintf("output/new_chaintables.%s.csv", table.Table.Id)
+			if err := utils.MergeCSVFiles(table.ChainTablePath, dumpTablePath, chainTablePath); err != nil {
+				return err
+			}
+		}
+		if err := adminCli.UploadChainTable(token, table.Table.Id, storage.GetChainTableDesc(table.Table.Storage), chainTablePath); err != nil {
+			return err
+		}
+		klog.Infof("chain table %s uploaded with %d new chains", table.Table.Id, table.ChainNum)
+
+		if !placedBefore && table.Table.Path != "" {
+			if err := adminCli.MkdirWithLayout(token, table.Table.Path, table.Table.Id, table.Table.StripeSize, table.Table.ChunkSize); err != nil {
+				klog.Errorf("create directory %s of chain table %s failed: %v", table.Table.Path, table.Table.Id, err)
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// UpdateChainTablesStatus records the placed chain tables and adds up their chain numbers
+func UpdateChainTablesStatus(rclient client.Client, tfsc *threefsv1.ThreeFsCluster, placed []PlacedChainTable) error {
+	localCache := threefsv1.ThreeFsCluster{}
+	if err := rclient.Get(context.Background(), client.ObjectKey{Name: tfsc.Name, Namespace: tfsc.Namespace}, &localCache); err != nil {
+		return err
+	}
+
+	modifiedObj := localCache.DeepCopy()
+	for _, table := range placed {
+		found := false
+		for idx := range modifiedObj.Status.ChainTables {
+			if modifiedObj.Status.ChainTables[idx].Id == table.Table.Id {
+				modifiedObj.Status.ChainTables[idx].Phase = constant.ChainTablePlacedStatus
+				modifiedObj.Status.ChainTables[idx].ChainNum += table.ChainNum
+				found = true
+			}
+		}
+		if !found {
+			modifiedObj.Status.ChainTables = append(modifiedObj.Status.ChainTables, threefsv1.ChainTableStatus{
+				Id:       table.Table.Id,
+				Phase:    constant.ChainTablePlacedStatus,
+				ChainNum: table.ChainNum,
+			})
+		}
+	}
+	tfsc.Status.ChainTables = modifiedObj.Status.ChainTables
+	return rclient.Status().Patch(context.Background(), modifiedObj, client.MergeFrom(&localCache))
+}
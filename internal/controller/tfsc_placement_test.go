@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemapTargetId(t *testing.T) {
+	nodeIds := map[int]int{10001: 10005, 10002: 10012}
+
+	// node 10001, disk 0, target 1
+	targetId, err := RemapTargetId("101000100101", 2, nodeIds)
+	assert.NoError(t, err)
+	assert.Equal(t, "101000500301", targetId)
+
+	targetId, err = RemapTargetId("101000200102", 0, nodeIds)
+	assert.NoError(t, err)
+	assert.Equal(t, "101001200102", targetId)
+
+	_, err = RemapTargetId("101000300101", 0, nodeIds)
+	assert.Error(t, err)
+}
+
+func TestRemapChainId(t *testing.T) {
+	// chain 1 on disk 0 is moved to disk 2
+	chainId, err := RemapChainId("900100001", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "900300001", chainId)
+
+	chainIdx, diskIdx, err := ParseChainId(chainId)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, chainIdx)
+	assert.Equal(t, 3, diskIdx)
+}
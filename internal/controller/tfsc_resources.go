@@ -55,6 +55,38 @@ func (r *ThreeFsClusterReconciler) updateConfigtStatus(threeFsCluster *threefsv1
 	return r.Client.Status().Patch(context.Background(), modifiedObj, client.MergeFrom(&localCache))
 }
 
+func (r *ThreeFsClusterReconciler) updateDataPlacementSnapshot(threeFsCluster *threefsv1.ThreeFsCluster, stage, name string) error {
+	localCache := threefsv1.ThreeFsCluster{}
+	_ = r.Get(context.Background(), client.ObjectKey{Name: threeFsCluster.Name, Namespace: threeFsCluster.Namespace}, &localCache)
+
+	modifiedObj := localCache.DeepCopy()
+	if stage == constant.ChainTableSnapshotStageBefore {
+		modifiedObj.Status.DataPlacementSnapshots.Before = name
+	} else {
+		modifiedObj.Status.DataPlacementSnapshots.After = name
+	}
+	threeFsCluster.Status.DataPlacementSnapshots = modifiedObj.Status.DataPlacementSnapshots
+	return r.Client.Status().Patch(context.Background(), modifiedObj, client.MergeFrom(&localCache))
+}
+
+// takeDataPlacementSnapshot takes the chain table snapshot around data placement once, chain table may not
+// exist before the first data placement, so failure is only recorded as event
+func (r *ThreeFsClusterReconciler) takeDataPlacementSnapshot(adminCliConfig *clientcomm.AdminCliConfig, threeFsCluster *threefsv1.ThreeFsCluster, token, stage string) {
+	if (stage == constant.ChainTableSnapshotStageBefore && threeFsCluster.Status.DataPlacementSnapshots.Before != "") ||
+		(stage == constant.ChainTableSnapshotStageAfter && threeFsCluster.Status.DataPlacementSnapshots.After != "") {
+		return
+	}
+	name, err := TakeChainTableSnapshot(r.Client, r.Scheme, adminCliConfig, token, threeFsCluster, "data-placement", stage)
+	if err != nil {
+		klog.Warningf("take chain table snapshot %s data placement failed: %v", stage, err)
+		r.Recorder.Event(threeFsCluster, corev1.EventTypeWarning, "SnapshotFailed", fmt.Sprintf("take %s data placement snapshot failed: %v", stage, err))
+		return
+	}
+	if err := r.updateDataPlacementSnapshot(threeFsCluster, stage, name); err != nil {
+		klog.Warningf("update ThreeFsCluster %s data placement snapshot failed: %v", threeFsCluster.Name, err)
+	}
+}
+
 func (r *ThreeFsClusterReconciler) addLabels(threeFsCluster *threefsv1.ThreeFsCluster, labels map[string]string) error {
 	for k, v := range labels {
 		threeFsCluster.Labels[k] = v
@@ -79,7 +111,7 @@ func (r *ThreeFsClusterReconciler) ParseMgmtdAddressesBak(name, ns string) strin
 	for _, node := range nodeList.Items {
 		ip, err := native_resources.NewNodeConfig(r.Client).ParseNodeIp(node.Name)
 		if err != nil {
-			klog.Errorf("get node %s ip failed: %v", node, err)
+			klog.Errorf("get node %s ip failed: %v", node.Name, err)
 		}
 		nodesList = append(nodesList, fmt.Sprintf(`"RDMA://%s:%d"`, ip, tfsc.Spec.Mgmtd.RdmaPort))
 	}
@@ -123,7 +155,7 @@ func (r *ThreeFsClusterReconciler) ParseMgmtdAddressesInPodNet(name, ns string)
 	nodesList := make([]string, 0)
 	for _, pod := range podList.Items {
 		if pod.Status.PodIP == "" {
-			return "", fmt.Errorf("mgmtd pod ip is empty yet", pod.Name)
+			return "", fmt.Errorf("mgmtd pod %s ip is empty yet", pod.Name)
 		}
 		nodesList = append(nodesList, fmt.Sprintf(`"RDMA://%s:%d"`, pod.Status.PodIP, tfsc.Spec.Mgmtd.RdmaPort))
 	}
@@ -133,7 +165,7 @@ func (r *ThreeFsClusterReconciler) ParseMgmtdAddressesInPodNet(name, ns string)
 
 func (r *ThreeFsClusterReconciler) ParseTargetPaths(storage *storage.StorageConfig) string {
 	targetPathList := make([]string, 0)
-	for _, targetpath := range storage.TargetPaths {
+	for _, targetpath := range storage.GetContainerTargetPaths() {
 		targetPathList = append(targetPathList, fmt.Sprintf(`"%s"`, targetpath))
 	}
 	return fmt.Sprintf("[%s]", strings.Join(targetPathList, ", "))
@@ -411,6 +443,9 @@ func TagMgmtdPrimaryLabel(nodeName string, rclient client.Client) error {
 		klog.Errorf("get node %s failed: %v", nodeName, err)
 		return err
 	}
+	if node.Labels == nil {
+		node.Labels = make(map[string]string)
+	}
 	node.Labels[constant.ThreeFSMgmtdPrimaryNodeKey] = "true"
 	if err := rclient.Update(context.Background(), node); err != nil {
 		klog.Errorf("update node %s failed: %v", nodeName, err)
@@ -491,6 +526,11 @@ func (r *ThreeFsClusterReconciler) UpdateClusterFdbStatus(tfsc *threefsv1.ThreeF
 			Msg:           "",
 			FaultTime:     faultTime,
 		}
+		// keep removal progress of the node
+		if oldStatus, ok := newObj.Status.FdbStatus[node.Name]; ok {
+			tmpStatus.Removal = oldStatus.Removal
+			tmpStatus.Address = oldStatus.Address
+		}
 
 		if _, ok := newObj.Status.FdbStatus[node.Name]; !ok {
 			// not exist before, not exist now, skip
@@ -540,6 +580,21 @@ func (r *ThreeFsClusterReconciler) UpdateClusterFdbStatus(tfsc *threefsv1.ThreeF
 	}
 
 	klog.Infof("try to update ThreeFsCluster %s fdb status to %+v", tfsc.Name, newObj.Status.FdbStatus)
+	unhealthyNum := 0
+	for k, v := range newObj.Status.FdbStatus {
+		oldFaultTime := tfsc.Status.FdbStatus[k].FaultTime
+		if v.FaultTime != "" {
+			unhealthyNum++
+		}
+		if v.FaultTime != "" && oldFaultTime == "" {
+			r.Recorder.Event(tfsc, corev1.EventTypeWarning, "FdbProcessUnreachable", fmt.Sprintf("fdb on node %s is unreachable, replace it after %d minutes", k, utils.GetFaultDurationEnv()))
+			recordFdbFaultStep(tfsc, FdbFaultStepDetected)
+		} else if v.FaultTime == "" && oldFaultTime != "" {
+			r.Recorder.Event(tfsc, corev1.EventTypeNormal, "FdbProcessRecovered", fmt.Sprintf("fdb on node %s is recovered", k))
+			recordFdbFaultStep(tfsc, FdbFaultStepRecovered)
+		}
+	}
+	recordFdbUnhealthyNodes(tfsc, unhealthyNum)
 	// check fault
 	klog.Infof("try to check fdb fault time duration")
 	for k, v := range newObj.Status.FdbStatus {
@@ -552,7 +607,7 @@ func (r *ThreeFsClusterReconciler) UpdateClusterFdbStatus(tfsc *threefsv1.ThreeF
 			return err
 		}
 		faultTime := utils.GetFaultDurationEnv()
-		if time.Now().Sub(startTime) > time.Duration(int64(faultTime))*time.Minute && !v.IsDeleted {
+		if time.Now().Sub(startTime) > time.Duration(int64(faultTime))*time.Minute && !v.IsDeleted && v.Removal == "" {
 			klog.Infof("node %s fault time duration is %s, try to label node", k, time.Now().Sub(startTime))
 			r.Recorder.Event(tfsc, corev1.EventTypeWarning, "FdbFault", fmt.Sprintf("node %s fault time duration is %s, tag fdb fault label", k, time.Now().Sub(startTime)))
 			nodeObj := &corev1.Node{}
@@ -565,7 +620,7 @@ func (r *ThreeFsClusterReconciler) UpdateClusterFdbStatus(tfsc *threefsv1.ThreeF
 					return err
 				}
 			}
-			// TODO
+			recordFdbFaultStep(tfsc, FdbFaultStepLabeled)
 			v.IsDeleted = true
 			newObj.Status.FdbStatus[k] = v
 		}
@@ -588,23 +643,23 @@ func GetNodeNameFromParsedName(parsedName string, rclient client.Client) string
 	return ""
 }
 
-func SelectOneNodeWithLabelKey(nodeName, labelkey string, rclient client.Client) string {
-	nodeList := &corev1.NodeList{}
-	if err := rclient.List(context.Background(), nodeList, client.MatchingLabels{constant.ThreeFSStorageNodeKey: "true"}); err != nil {
-		klog.Errorf("list node failed: %v", err)
-		return ""
-	}
-	for _, node := range nodeList.Items {
-		if node.Name == nodeName {
+func SelectOneNodeWithLabelKey(nodeName, labelkey string, candidates []string, rclient client.Client) string {
+	for _, candidate := range candidates {
+		if candidate == nodeName {
 			// skip fault node
 			continue
 		}
+		node := &corev1.Node{}
+		if err := rclient.Get(context.Background(), client.ObjectKey{Name: candidate}, node); err != nil {
+			klog.Errorf("get node %s failed: %v", candidate, err)
+			continue
+		}
 		if _, ok := node.Labels[labelkey]; ok {
 			// skip node with label
 			continue
 		}
 		node.Labels[labelkey] = "true"
-		if err := rclient.Update(context.Background(), &node); err != nil {
+		if err := rclient.Update(context.Background(), node); err != nil {
 			klog.Errorf("update node %s with label failed: %v", node.Name, err)
 			return ""
 		}
@@ -613,6 +668,18 @@ func SelectOneNodeWithLabelKey(nodeName, labelkey string, rclient client.Client)
 	return ""
 }
 
+// replaceNode replaces the fault node in the recorded nodes with the new node
+func replaceNode(nodes []string, oldNode, newNode string) []string {
+	replaced := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		if node == oldNode {
+			node = newNode
+		}
+		replaced = append(replaced, node)
+	}
+	return replaced
+}
+
 func (r *ThreeFsClusterReconciler) UpdateClusterStatus(adminCli *clientcomm.AdminCliConfig, tfsc *threefsv1.ThreeFsCluster, rclient client.Client) error {
 	klog.Infof("UpdateClusterStatus: try to update ThreeFsCluster %s status", tfsc.Name)
 	output, err := adminCli.ListNodes()
@@ -626,6 +693,9 @@ func (r *ThreeFsClusterReconciler) UpdateClusterStatus(adminCli *clientcomm.Admi
 		return err
 	}
 	status := make(map[string]map[string]threefsv1.ClusterStatus)
+	mgmtdNodes := tfsc.Status.NodesInfo.MgmtdNodes
+	metaNodes := tfsc.Status.NodesInfo.MetaNodes
+	mgmtdAddresses := tfsc.Status.MgmtdAddresses
 	for _, node := range nodes {
 		if _, ok := status[node.Type]; !ok {
 			status[node.Type] = make(map[string]threefsv1.ClusterStatus)
@@ -660,13 +730,23 @@ func (r *ThreeFsClusterReconciler) UpdateClusterStatus(adminCli *clientcomm.Admi
 					klog.Errorf("parse last heartbeat %s failed: %v", node.LastHeartbeat, err)
 					return err
 				}
-				faultTime := utils.GetFaultDurationEnv()
-				if time.Now().Sub(lastHeartBeat) > time.Duration(int64(faultTime))*time.Minute {
+				if time.Now().Sub(lastHeartBeat) > GetFailoverTimeout(tfsc.Spec.AutoRepair) {
 					tagFault = true
 					klog.Errorf("node %s last heartbeat is %s, according to time duration, tag fault", node.Id, node.LastHeartbeat)
 					r.Recorder.Event(tfsc, corev1.EventTypeWarning, "MetaFault", fmt.Sprintf("node %s last heartbeat is %s, try to move to another node", node.Id, node.LastHeartbeat))
 				}
 			}
+			// components lose heartbeat while restarted by rolling upgrade
+			if tagFault {
+				upgrading, err := IsRollingUpgradeInProgress(rclient, tfsc)
+				if err != nil {
+					return err
+				}
+				if upgrading {
+					klog.Infof("node(%s) %s is fault, but rolling upgrade is in progress, skip failover", node.Type, node.Hostname)
+					tagFault = false
+				}
+			}
 			if tagFault {
 				nodeName := GetNodeNameFromParsedName(node.Hostname, rclient)
 				klog.Infof("node(%s) %s is fault, try to remove node label", node.Type, nodeName)
@@ -676,8 +756,16 @@ func (r *ThreeFsClusterReconciler) UpdateClusterStatus(adminCli *clientcomm.Admi
 					return err
 				}
 
-				if node.Type == "MGMTD" && !utils.GetUseHostNetworkEnv() {
-					// TODO mgmtd fault handle
+				if node.Type == "MGMTD" {
+					candidates, err := GetServiceCandidateNodes(rclient, tfsc, tfsc.Spec.Mgmtd.NodeSelector)
+					if err != nil {
+						return err
+					}
+					if len(mgmtdNodes) == 0 {
+						if mgmtdNodes, err = GetMgmtdNodes(rclient); err != nil {
+							return err
+						}
+					}
 					if _, ok := nodeObj.Labels[constant.ThreeFSMgmtdNodeKey]; ok {
 						delete(nodeObj.Labels, constant.ThreeFSMgmtdNodeKey)
 						if err := rclient.Update(context.Background(), nodeObj); err != nil {
@@ -685,15 +773,26 @@ func (r *ThreeFsClusterReconciler) UpdateClusterStatus(adminCli *clientcomm.Admi
 							return err
 						}
 						klog.Infof("remove node %s with mgmtd node label success", nodeName)
-						newNodeName := SelectOneNodeWithLabelKey(nodeName, constant.ThreeFSMgmtdNodeKey, rclient)
+						newNodeName := SelectOneNodeWithLabelKey(nodeName, constant.ThreeFSMgmtdNodeKey, candidates, rclient)
 						if newNodeName == "" {
 							klog.Errorf("select one node with label %s failed", constant.ThreeFSMgmtdNodeKey)
 							return err
 						}
 						klog.Infof("select one node with label %s success, new node is %s", constant.ThreeFSMgmtdNodeKey, newNodeName)
+						mgmtdNodes = replaceNode(mgmtdNodes, nodeName, newNodeName)
 						adminCli.UnregisterNode(node.Id, node.Type)
+						// in host network clients connect to mgmtd by node ip, pod network uses the headless service
+						if utils.GetUseHostNetworkEnv() {
+							mgmtdAddresses = r.ParseMgmtdAddresses(tfsc.Name, tfsc.Namespace, mgmtdNodes)
+							klog.Infof("mgmtd on %s is replaced by %s, new mgmtd addresses: %s", nodeName, newNodeName, mgmtdAddresses)
+							r.Recorder.Event(tfsc, corev1.EventTypeWarning, "MgmtdReplaced", fmt.Sprintf("mgmtd on %s is replaced by %s, new mgmtd addresses: %s", nodeName, newNodeName, mgmtdAddresses))
+						}
 					}
 				} else if node.Type == "META" {
+					candidates, err := GetServiceCandidateNodes(rclient, tfsc, tfsc.Spec.Meta.NodeSelector)
+					if err != nil {
+						return err
+					}
 					if _, ok := nodeObj.Labels[constant.ThreeFSMetaNodeKey]; ok {
 						delete(nodeObj.Labels, constant.ThreeFSMetaNodeKey)
 						if err := rclient.Update(context.Background(), nodeObj); err != nil {
@@ -701,12 +800,13 @@ func (r *ThreeFsClusterReconciler) UpdateClusterStatus(adminCli *clientcomm.Admi
 							return err
 						}
 						klog.Infof("remove node %s with meta node label success", nodeName)
-						newNodeName := SelectOneNodeWithLabelKey(nodeName, constant.ThreeFSMetaNodeKey, rclient)
+						newNodeName := SelectOneNodeWithLabelKey(nodeName, constant.ThreeFSMetaNodeKey, candidates, rclient)
 						if newNodeName == "" {
 							klog.Errorf("select one node with label %s failed", constant.ThreeFSMetaNodeKey)
 							return err
 						}
 						klog.Infof("select one node with label %s success, new node is %s", constant.ThreeFSMetaNodeKey, newNodeName)
+						metaNodes = replaceNode(metaNodes, nodeName, newNodeName)
 					}
 					adminCli.UnregisterNode(node.Id, node.Type)
 				}
@@ -715,8 +815,43 @@ func (r *ThreeFsClusterReconciler) UpdateClusterStatus(adminCli *clientcomm.Admi
 		status[node.Type][node.Hostname] = tmpStatus
 	}
 
+	// primary mgmtd label follows list-nodes
+	primary := tfsc.Status.PrimaryMgmtd
+	if node, ok := GetPrimaryMgmtd(nodes); ok {
+		if nodeName := GetNodeNameFromParsedName(node.Hostname, rclient); nodeName != "" {
+			next, changed := NextPrimaryMgmtd(primary, nodeName, node.Id, time.Now().Format(constant.TimeLayout))
+			if changed {
+				switch {
+				case primary.NodeName == "":
+					klog.Infof("primary mgmtd is %s(%s)", nodeName, node.Id)
+					r.Recorder.Event(tfsc, corev1.EventTypeNormal, "MgmtdPrimaryElected", fmt.Sprintf("primary mgmtd is %s(%s)", nodeName, node.Id))
+				case primary.MovingFrom != "":
+					klog.Infof("primary mgmtd changed from %s(%s) to %s(%s) by move primary", primary.NodeName, primary.NodeId, nodeName, node.Id)
+					r.Recorder.Event(tfsc, corev1.EventTypeNormal, "MgmtdPrimaryChanged", fmt.Sprintf("primary mgmtd changed from %s(%s) to %s(%s) by move primary", primary.NodeName, primary.NodeId, nodeName, node.Id))
+				default:
+					klog.Infof("primary mgmtd changed from %s(%s) to %s(%s)", primary.NodeName, primary.NodeId, nodeName, node.Id)
+					r.Recorder.Event(tfsc, corev1.EventTypeWarning, "MgmtdPrimaryChanged", fmt.Sprintf("primary mgmtd changed from %s(%s) to %s(%s)", primary.NodeName, primary.NodeId, nodeName, node.Id))
+				}
+				primary = next
+			}
+			if err := SyncMgmtdPrimaryLabel(nodeName, rclient); err != nil {
+				return err
+			}
+		}
+	}
+
 	newObj := tfsc.DeepCopy()
 	newObj.Status.ClusterStatus = status
+	newObj.Status.PrimaryMgmtd = primary
+	newObj.Status.NodesInfo.MgmtdNodes = mgmtdNodes
+	newObj.Status.NodesInfo.MetaNodes = metaNodes
+	if mgmtdAddresses != tfsc.Status.MgmtdAddresses {
+		newObj.Status.MgmtdAddresses = mgmtdAddresses
+		// upload main configs with new mgmtd addresses again
+		for _, component := range []string{"meta", "storage", "fuse"} {
+			delete(newObj.Status.ConfigStatus, component)
+		}
+	}
 	return rclient.Status().Patch(context.Background(), newObj, client.MergeFrom(tfsc))
 }
 
@@ -768,12 +903,6 @@ func CheckComponentStatus(adminCli *clientcomm.AdminCliConfig, component, nodeNa
 			continue
 		}
 		tag = true
-		//if node.Status == "PRIMARY_MGMTD" {
-		//	if err := TagMgmtdPrimaryLabel(GetPlainNodeNameFromAdmincli(node.Hostname, rclient), rclient); err != nil {
-		//		klog.Errorf("tag node %s with mgmgt primary label failed: %v", node.Id, err)
-		//		return false
-		//	}
-		//}
 		if node.Status != "HEARTBEAT_CONNECTED" && node.Status != "PRIMARY_MGMTD" {
 			klog.Errorf("node %s status is %s, ConfigVersion is %s", node.Id, node.Status, node.ConfigVersion)
 			if all || len(parsedNodeName) > 0 {
@@ -917,21 +1046,6 @@ func ParseMgmtdAddressesFromHeadlessSvc(name, ns string, port int) string {
 	return fmt.Sprintf(`["RDMA://%s:%d"]`, GetSvcDnsName(mgmtd.GetMgmtdDeployName(name), ns), port)
 }
 
-func IsProcessingTfsctExisted(rclient client.Client) bool {
-	tfsctList := &threefsv1.ThreeFsChainTableList{}
-	if err := rclient.List(context.Background(), tfsctList); err != nil {
-		klog.Errorf("list tfsct failed: %v", err)
-		return false
-	}
-	for _, tfsct := range tfsctList.Items {
-		if tfsct.Status.Phase == constant.ThreeFSChainTableFinishedStatus {
-			continue
-		}
-		return true
-	}
-	return false
-}
-
 func CheckStorageBackup(threeFsCluster *threefsv1.ThreeFsCluster) bool {
 	if threeFsCluster.Status.NodesInfo.StorageBackupNodes == nil || len(threeFsCluster.Status.NodesInfo.StorageBackupNodes) == 0 {
 		return false
@@ -939,54 +1053,45 @@ func CheckStorageBackup(threeFsCluster *threefsv1.ThreeFsCluster) bool {
 	return true
 }
 
-func (r *ThreeFsClusterReconciler) CreateTfsct(tfsctName, tfscName, namespace, plainNewName, plainOldName string, tfsctLabels map[string]string) error {
+func (r *ThreeFsClusterReconciler) CreateTfsct(tfsctName, tfscName, namespace, plainNewName, plainOldName string, tfsctLabels, tfsctAnnotations map[string]string) error {
 	tfsctObj := threefsv1.NewThreeFsChainTable(tfsctName, namespace).
 		WithThreeFsCluster(tfscName, namespace).
 		WithNewNode([]string{plainNewName}).
 		WithOldNode([]string{plainOldName}).
 		WithType(constant.ThreeFSChainTableTypeReplace).
 		WithForce(true).
-		WithLabels(tfsctLabels)
+		WithLabels(tfsctLabels).
+		WithAnnotations(tfsctAnnotations)
 
 	return r.Client.Create(context.Background(), tfsctObj)
 }
 
 func (r *ThreeFsClusterReconciler) HandleFaultStorage(threeFsCluster *threefsv1.ThreeFsCluster) error {
 	// check storage status, if not heartbeat_connected for xx min, create tfsct crd for replace
-	faultTime := utils.GetFaultDurationEnv()
+	heartbeatTimeout := GetNodeHeartbeatTimeout(threeFsCluster.Spec.AutoRepair)
 	for _, storageNode := range threeFsCluster.Status.ClusterStatus["STORAGE"] {
 		startTime, err := time.Parse(constant.TimeLayout, storageNode.LastHeatBeatTime)
 		if err != nil {
 			klog.Errorf("parse fault time failed: %v", err)
 			return err
 		}
-		if storageNode.Status != "HEARTBEAT_CONNECTED" && time.Now().Sub(startTime) > time.Duration(int64(faultTime))*time.Minute {
-			if !IsProcessingTfsctExisted(r.Client) {
-				if !CheckStorageBackup(threeFsCluster) {
-					klog.Errorf("storage %s status is not healthy, but storage backup nodes is empty", storageNode.Name)
-					r.Recorder.Event(threeFsCluster, corev1.EventTypeWarning, "StorageNotHealthy", "storage not healthy")
-				}
-
-				plainOldName := ParsePlainNameWithNodeId(r.Client, storageNode.Name)
-				plainNewName := threeFsCluster.Status.NodesInfo.StorageBackupNodes[0]
-				tfsctName := fmt.Sprintf("tfsct-replace-%s", utils.GenerateUuidWithLen(6))
-				klog.Infof("create tfsct %s for replace(newNode: %s, oldName:%s)", tfsctName, plainNewName, plainOldName)
-				tfsctLabels := map[string]string{
-					constant.ThreeFSAutoReplaceLabel: "true",
-				}
-				tfsctObj := threefsv1.NewThreeFsChainTable(tfsctName, threeFsCluster.Namespace).
-					WithThreeFsCluster(threeFsCluster.Name, threeFsCluster.Namespace).
-					WithNewNode([]string{plainNewName}).
-					WithOldNode([]string{plainOldName}).
-					WithType(constant.ThreeFSChainTableTypeReplace).
-					WithForce(true).
-					WithLabels(tfsctLabels)
-
-				if err := r.Client.Create(context.Background(), tfsctObj); err != nil {
-					klog.Errorf("create tfsct %s failed: %v", tfsctName, err)
-					return err
-				}
-				return nil
+		if storageNode.Status != "HEARTBEAT_CONNECTED" && time.Now().Sub(startTime) > heartbeatTimeout {
+			plainOldName := ParsePlainNameWithNodeId(r.Client, storageNode.Name)
+			jobs, err := ListUnfinishedTfsct(r.Client, threeFsCluster.Name, threeFsCluster.Namespace)
+			if err != nil {
+				return err
+			}
+			if IsNodeClaimedByTfsct(jobs, plainOldName) {
+				klog.Infof("storage %s is already handled by unfinished tfsct, skip", plainOldName)
+				continue
+			}
+			if suppressed, err := r.isAutoRepairSuppressed(threeFsCluster, plainOldName); err != nil {
+				return err
+			} else if suppressed {
+				continue
+			}
+			if _, err := r.CreateAutoReplaceTfsct(threeFsCluster, jobs, plainOldName); err != nil {
+				return err
 			}
 		}
 	}
@@ -1007,7 +1112,7 @@ func (r *ThreeFsClusterReconciler) HandleFaultStorage(threeFsCluster *threefsv1.
 						klog.Errorf("parse fault time failed: %v", err)
 						return err
 					}
-					if time.Now().Sub(startTime) > time.Duration(int64(faultTime))*time.Minute {
+					if time.Now().Sub(startTime) > GetTargetOfflineTimeout(threeFsCluster.Spec.AutoRepair) {
 						tagFault = true
 					}
 				}
@@ -1024,25 +1129,27 @@ func (r *ThreeFsClusterReconciler) HandleFaultStorage(threeFsCluster *threefsv1.
 			return err
 		}
 		if tagFault {
-			if !CheckStorageBackup(oldTfscObj) {
-				klog.Errorf("storage %s status is not healthy, but storage backup nodes is empty", node)
-				r.Recorder.Event(threeFsCluster, corev1.EventTypeWarning, "StorageNotHealthy", "storage not healthy")
-				return fmt.Errorf("storage %s status is not healthy, but storage backup nodes is empty", node)
-			}
-
 			plainOldName := ParsePlainNameWithNodeId(r.Client, node)
-			plainNewName := threeFsCluster.Status.NodesInfo.StorageBackupNodes[0]
-			tfsctName := fmt.Sprintf("tfsct-replace-%s", utils.GenerateUuidWithLen(6))
-			klog.Infof("create tfsct %s for replace(newNode: %s, oldName:%s)", tfsctName, plainNewName, plainOldName)
-			tfsctLabels := map[string]string{
-				constant.ThreeFSAutoReplaceLabel: "true",
+			jobs, err := ListUnfinishedTfsct(r.Client, threeFsCluster.Name, threeFsCluster.Namespace)
+			if err != nil {
+				return err
 			}
-			if err := r.CreateTfsct(tfsctName, threeFsCluster.Name, threeFsCluster.Namespace, plainNewName, plainOldName, tfsctLabels); err != nil {
-				klog.Errorf("create tfsct %s failed: %v", tfsctName, err)
+			if IsNodeClaimedByTfsct(jobs, plainOldName) {
+				klog.Infof("storage %s is already handled by unfinished tfsct, skip", plainOldName)
+				continue
+			}
+			if suppressed, err := r.isAutoRepairSuppressed(oldTfscObj, plainOldName); err != nil {
 				return err
+			} else if suppressed {
+				continue
+			}
+			created, err := r.CreateAutoReplaceTfsct(oldTfscObj, jobs, plainOldName)
+			if err != nil {
+				return err
+			}
+			if !created {
+				return fmt.Errorf("storage %s status is not healthy, but no available storage backup node", node)
 			}
-			// detect one target, break here
-			break
 		}
 	}
 	return nil
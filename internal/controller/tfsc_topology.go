@@ -0,0 +1,194 @@
+package controller
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/aliyun/kvc-3fs-operator/internal/storage"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// maxDomainAssignSteps limits the backtracking of AssignNodeDomains
+const maxDomainAssignSteps = 1000000
+
+// ParsePlacementGroups reads chains generated by data placement and returns node indexes (starting from 0) of
+// each group of targets which must be spread across failure domains: the targets of a chain for chain replication,
+// or groupSize chains of an EC group for erasure coding
+func ParsePlacementGroups(chainPath string, erasureCoding bool, groupSize int) ([][]int, error) {
+	file, err := os.Open(chainPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	groupMaps := make(map[string][]int)
+	keys := make([]string, 0)
+	for idx, record := range records {
+		if idx == 0 || len(record) < 2 {
+			continue
+		}
+		chainId, err := strconv.Atoi(record[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid chain id %s", record[0])
+		}
+		key := record[0]
+		if erasureCoding && groupSize > 0 {
+			// chain index of an EC group is (group-1)*groupSize+slot, see gen_chain_table.py
+			key = fmt.Sprintf("%d-%d", chainId/100000, (chainId%100000-1)/groupSize)
+		}
+		if _, ok := groupMaps[key]; !ok {
+			keys = append(keys, key)
+		}
+		for _, targetId := range record[1:] {
+			nodeId, err := ParseTargetNodeId(targetId)
+			if err != nil {
+				return nil, err
+			}
+			groupMaps[key] = append(groupMaps[key], nodeId-constant.ThreeFSStorageStartNodeId)
+		}
+	}
+
+	groups := make([][]int, 0, len(keys))
+	for _, key := range keys {
+		groups = append(groups, groupMaps[key])
+	}
+	return groups, nil
+}
+
+// AssignNodeDomains assigns the nodes to node indexes of the placement groups, so that nodes of each group are in
+// different failure domains. It returns the node (index of nodeDomains) assigned to each node index
+func AssignNodeDomains(groups [][]int, nodeDomains []string) ([]int, error) {
+	numNodes := len(nodeDomains)
+	neighbors := make([]map[int]bool, numNodes)
+	for idx := range neighbors {
+		neighbors[idx] = make(map[int]bool)
+	}
+	for _, group := range groups {
+		for _, a := range group {
+			if a < 0 || a >= numNodes {
+				return nil, fmt.Errorf("node index %d of placement group is out of range", a)
+			}
+			for _, b := range group {
+				if a != b {
+					neighbors[a][b] = true
+				}
+			}
+		}
+	}
+
+	domainNodes := make(map[string][]int)
+	domains := make([]string, 0)
+	for idx, domain := range nodeDomains {
+		if _, ok := domainNodes[domain]; !ok {
+			domains = append(domains, domain)
+		}
+		domainNodes[domain] = append(domainNodes[domain], idx)
+	}
+	sort.Strings(domains)
+	capacity := make(map[string]int)
+	for domain, nodes := range domainNodes {
+		capacity[domain] = len(nodes)
+	}
+
+	// node indexes with more neighbors are assigned first
+	order := make([]int, numNodes)
+	for idx := range order {
+		order[idx] = idx
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return len(neighbors[order[i]]) > len(neighbors[order[j]])
+	})
+
+	assigned := make([]string, numNodes)
+	steps := 0
+	var assign func(pos int) bool
+	assign = func(pos int) bool {
+		if pos == numNodes {
+			return true
+		}
+		steps++
+		if steps > maxDomainAssignSteps {
+			return false
+		}
+		idx := order[pos]
+		used := make(map[string]bool)
+		for neighbor := range neighbors[idx] {
+			if assigned[neighbor] != "" {
+				used[assigned[neighbor]] = true
+			}
+		}
+		candidates := make([]string, 0)
+		for _, domain := range domains {
+			if capacity[domain] > 0 && !used[domain] {
+				candidates = append(candidates, domain)
+			}
+		}
+		// domains with more free nodes first
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return capacity[candidates[i]] > capacity[candidates[j]]
+		})
+		for _, domain := range candidates {
+			assigned[idx] = domain
+			capacity[domain]--
+			if assign(pos + 1) {
+				return true
+			}
+			capacity[domain]++
+			assigned[idx] = ""
+		}
+		return false
+	}
+	if !assign(0) {
+		return nil, fmt.Errorf("can not spread placement groups of %d nodes across %d failure domains", numNodes, len(domains))
+	}
+
+	result := make([]int, numNodes)
+	for idx := 0; idx < numNodes; idx++ {
+		domain := assigned[idx]
+		result[idx] = domainNodes[domain][0]
+		domainNodes[domain] = domainNodes[domain][1:]
+	}
+	return result, nil
+}
+
+// SpreadNodesAcrossDomains reorders nodes of the placement group according to generated chains, so that the nodes
+// mapped to the generated node ids of every chain or EC group are in different failure domains
+func SpreadNodesAcrossDomains(rclient client.Client, spec threefsv1.StorageSpec, chainPath string, nodes []string) ([]string, error) {
+	if spec.TopologyKey == "" {
+		return nodes, nil
+	}
+	nodeDomains := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		domain, err := storage.GetNodeDomain(rclient, spec, node)
+		if err != nil {
+			return nil, err
+		}
+		nodeDomains = append(nodeDomains, domain)
+	}
+
+	groups, err := ParsePlacementGroups(chainPath, storage.IsErasureCoding(spec), storage.GetGroupSize(spec))
+	if err != nil {
+		klog.Errorf("parse placement groups from %s failed: %v", chainPath, err)
+		return nil, err
+	}
+	assigned, err := AssignNodeDomains(groups, nodeDomains)
+	if err != nil {
+		return nil, err
+	}
+	spread := make([]string, 0, len(nodes))
+	for _, idx := range assigned {
+		spread = append(spread, nodes[idx])
+	}
+	klog.Infof("nodes %v are spread across failure domains %s as %v", nodes, spec.TopologyKey, spread)
+	return spread, nil
+}
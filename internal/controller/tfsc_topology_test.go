@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePlacementGroups(t *testing.T) {
+	chainPath := filepath.Join(t.TempDir(), "generated_chains.csv")
+	content := "ChainId,TargetId,TargetId\n" +
+		"900100001,101000100101,101000200101\n" +
+		"900100002,101000200102,101000300101\n"
+	assert.NoError(t, os.WriteFile(chainPath, []byte(content), 0644))
+
+	groups, err := ParsePlacementGroups(chainPath, false, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]int{{0, 1}, {1, 2}}, groups)
+
+	// chains of an EC group with 2 chunks have a single target each
+	content = "ChainId,TargetId\n" +
+		"900100001,101000100101\n" +
+		"900100002,101000200101\n" +
+		"900100003,101000300101\n" +
+		"900100004,101000100102\n"
+	assert.NoError(t, os.WriteFile(chainPath, []byte(content), 0644))
+
+	groups, err = ParsePlacementGroups(chainPath, true, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]int{{0, 1}, {2, 0}}, groups)
+
+	// target index of more than 2 digits
+	content = "ChainId,TargetId,TargetId\n" +
+		"900100001,1010001001101,1010002001101\n"
+	assert.NoError(t, os.WriteFile(chainPath, []byte(content), 0644))
+
+	groups, err = ParsePlacementGroups(chainPath, false, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]int{{0, 1}}, groups)
+}
+
+func TestAssignNodeDomains(t *testing.T) {
+	groups := [][]int{{0, 1}, {1, 2}, {2, 3}, {3, 0}}
+	nodeDomains := []string{"zone-a", "zone-a", "zone-b", "zone-b"}
+
+	assigned, err := AssignNodeDomains(groups, nodeDomains)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []int{0, 1, 2, 3}, assigned)
+	for _, group := range groups {
+		assert.NotEqual(t, nodeDomains[assigned[group[0]]], nodeDomains[assigned[group[1]]])
+	}
+
+	// 3 replicas can not be spread across 2 domains
+	_, err = AssignNodeDomains([][]int{{0, 1, 2}}, []string{"zone-a", "zone-a", "zone-b"})
+	assert.Error(t, err)
+}
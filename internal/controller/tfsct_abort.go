@@ -0,0 +1,238 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	clientcomm "github.com/aliyun/kvc-3fs-operator/internal/client"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/aliyun/kvc-3fs-operator/internal/storage"
+	"github.com/aliyun/kvc-3fs-operator/internal/utils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// RollbackResult records how chains of an aborted replace job are handled
+type RollbackResult struct {
+	// RolledBack chains are restored to the old target
+	RolledBack int
+	// Kept chains have the new target in SERVING-UPTODATE, no rollback is needed
+	Kept int
+	// Broken chains lost the old target, they are left with the new target (if any)
+	Broken int
+	// NewTargetInUse is true if any chain still references the new target after rollback
+	NewTargetInUse bool
+}
+
+// IsAbortRequested gets the latest job and checks whether abort is requested,
+// used to stop issuing update-chain operations as early as possible
+func (r *ThreeFsChainTableReconciler) IsAbortRequested(chaintable *threefsv1.ThreeFsChainTable) bool {
+	latest := &threefsv1.ThreeFsChainTable{}
+	if err := r.Client.Get(context.Background(), client.ObjectKeyFromObject(chaintable), latest); err != nil {
+		klog.Errorf("get threefsChanintable %s failed: %v", chaintable.Name, err)
+		return chaintable.Spec.Abort
+	}
+	return latest.Spec.Abort
+}
+
+// RollbackReplaceChains restores the previous membership for chains whose new target is not SERVING-UPTODATE.
+// The old target is added back to the chain when it still exists, then the new target is removed from the chain
+func (r *ThreeFsChainTableReconciler) RollbackReplaceChains(adminCli *clientcomm.AdminCliConfig, chaintable *threefsv1.ThreeFsChainTable, token string) (*RollbackResult, error) {
+	output, err := adminCli.ListTargets()
+	if err != nil {
+		klog.Errorf("list targets failed: %v", err)
+		return nil, err
+	}
+	targets, err := ParseTargets(output)
+	if err != nil {
+		klog.Errorf("parse targets failed: %v", err)
+		return nil, err
+	}
+	existingTargets := make(map[string]bool)
+	for _, target := range targets {
+		existingTargets[target.TargetId] = true
+	}
+
+	chains, err := r.GetChainTablesWithChainIdTargetId(adminCli, chaintable.Status.ProcessChainIds)
+	if err != nil {
+		klog.Errorf("get chain table with chain id failed: %v", err)
+		return nil, err
+	}
+
+	// new node may be not registered yet, which means no new target is added
+	newNodeId := ""
+	if len(chaintable.Spec.NewNode) > 0 {
+		if nodeId, err := ParseNodeIdFromNodeName(adminCli, "STORAGE", chaintable.Spec.NewNode[0]); err == nil {
+			newNodeId = strconv.Itoa(nodeId)
+		}
+	}
+
+	result := &RollbackResult{}
+	for _, chain := range chains {
+		oldTargetId := chain.Key
+		newTargetId := ""
+		if newNodeId != "" {
+			newTargetId = strings.Replace(oldTargetId, oldTargetId[2:7], newNodeId, 1)
+		}
+
+		hasOldTarget := false
+		newTargetState := ""
+		for _, target := range chain.Targets {
+			if target.TargetId == oldTargetId {
+				hasOldTarget = true
+			}
+			if newTargetId != "" && target.TargetId == newTargetId {
+				newTargetState = target.State
+			}
+		}
+
+		if newTargetState == "SERVING-UPTODATE" {
+			klog.Infof("chain %s new target %s is SERVING-UPTODATE, keep it", chain.ChainId, newTargetId)
+			result.Kept++
+			result.NewTargetInUse = true
+			continue
+		}
+		if !hasOldTarget && !existingTargets[oldTargetId] {
+			klog.Warningf("chain %s old target %s not exists, leave chain as is", chain.ChainId, oldTargetId)
+			result.Broken++
+			if newTargetState != "" {
+				result.NewTargetInUse = true
+			}
+			continue
+		}
+
+		if !hasOldTarget {
+			if _, err := adminCli.UpdateChain(token, "add", chain.ChainId, oldTargetId); err != nil {
+				klog.Errorf("add chain %s old target %s failed: %v", chain.ChainId, oldTargetId, err)
+				return nil, err
+			}
+			klog.Infof("add chain %s old target %s success", chain.ChainId, oldTargetId)
+		}
+		if newTargetState != "" {
+			if _, err := adminCli.UpdateChain(token, "remove", chain.ChainId, newTargetId); err != nil {
+				klog.Errorf("remove chain %s new target %s failed: %v", chain.ChainId, newTargetId, err)
+				return nil, err
+			}
+			klog.Infof("remove chain %s new target %s success", chain.ChainId, newTargetId)
+		}
+		result.RolledBack++
+	}
+
+	return result, nil
+}
+
+// RestoreReplaceNodes brings the old node back to storage nodes if any chain is rolled back to it,
+// and returns the new node to the backup pool if no chain references it anymore
+func (r *ThreeFsChainTableReconciler) RestoreReplaceNodes(chaintable *threefsv1.ThreeFsChainTable, result *RollbackResult) error {
+	oldNode := chaintable.Spec.OldNode[0]
+	newNode := chaintable.Spec.NewNode[0]
+
+	if result.RolledBack > 0 {
+		oldNodeObj := &corev1.Node{}
+		if err := r.Client.Get(context.Background(), client.ObjectKey{Name: oldNode}, oldNodeObj); err != nil {
+			klog.Errorf("get node %s err: %+v", oldNode, err)
+			return err
+		}
+		if _, ok := oldNodeObj.Labels[constant.ThreeFSStorageFaultNodeKey]; ok {
+			delete(oldNodeObj.Labels, constant.ThreeFSStorageFaultNodeKey)
+			if err := r.Client.Update(context.Background(), oldNodeObj); err != nil {
+				klog.Errorf("remove storage fault label of node %s err: %+v", oldNode, err)
+				return err
+			}
+		}
+	}
+
+	vfsc := &threefsv1.ThreeFsCluster{}
+	if err := r.Get(context.Background(), client.ObjectKey{Name: chaintable.Spec.ThreeFsClusterName, Namespace: chaintable.Spec.ThreeFsClusterNamespace}, vfsc); err != nil {
+		klog.Errorf("get ThreeFsCluster %s err: %+v", chaintable.Spec.ThreeFsClusterName, err)
+		return err
+	}
+	oldObj := vfsc.DeepCopy()
+	if result.RolledBack > 0 && !utils.StrListContains(vfsc.Status.NodesInfo.StorageNodes, oldNode) {
+		vfsc.Status.NodesInfo.StorageNodes = append(vfsc.Status.NodesInfo.StorageNodes, oldNode)
+	}
+	if !result.NewTargetInUse && utils.StrListContains(vfsc.Status.NodesInfo.StorageNodes, newNode) {
+		vfsc.Status.NodesInfo.StorageNodes = utils.StrListRemove(vfsc.Status.NodesInfo.StorageNodes, newNode)
+	}
+	if err := r.Status().Patch(context.Background(), vfsc, client.MergeFrom(oldObj)); err != nil {
+		klog.Errorf("restore storage nodes in ThreeFsCluster %s err: %+v", chaintable.Spec.ThreeFsClusterName, err)
+		return err
+	}
+
+	if result.RolledBack == 0 || len(chaintable.Status.ProcessChainIds) == 0 {
+		return nil
+	}
+
+	// old targets belong to the old node id, keep it for the restored storage
+	splits := strings.Split(chaintable.Status.ProcessChainIds[0], "@")
+	if len(splits) != 2 || len(splits[1]) < 7 {
+		return fmt.Errorf("invalid process chain id %s", chaintable.Status.ProcessChainIds[0])
+	}
+	oldNodeId := splits[1][2:7]
+	storageEnvConfig := &corev1.ConfigMap{}
+	if err := r.Get(context.Background(), client.ObjectKey{Name: storage.GetStorageDeployName(chaintable.Spec.ThreeFsClusterName), Namespace: chaintable.Spec.ThreeFsClusterNamespace}, storageEnvConfig); err != nil {
+		klog.Errorf("get configmap %s err: %+v", storage.GetStorageDeployName(chaintable.Spec.ThreeFsClusterName), err)
+		return err
+	}
+	if storageEnvConfig.Data == nil {
+		storageEnvConfig.Data = make(map[string]string)
+	}
+	if storageEnvConfig.Data[utils.TranslatePlainNodeName3fs(oldNode)] != oldNodeId {
+		storageEnvConfig.Data[utils.TranslatePlainNodeName3fs(oldNode)] = oldNodeId
+		if err := r.Client.Update(context.Background(), storageEnvConfig); err != nil {
+			klog.Errorf("restore node id of %s in configmap %s err: %+v", oldNode, storageEnvConfig.Name, err)
+			return err
+		}
+	}
+	klog.Infof("restore old node %s with node id %s success", oldNode, oldNodeId)
+
+	return nil
+}
+
+// AbortTfsct stops the job, rolls back chains which are not replaced yet and sets the job to Aborted
+func (r *ThreeFsChainTableReconciler) AbortTfsct(adminCli *clientcomm.AdminCliConfig, chaintable *threefsv1.ThreeFsChainTable, token string) error {
+	msg := "aborted before any chain is updated"
+	if chaintable.Spec.Type == constant.ThreeFSChainTableTypeReplace && len(chaintable.Status.ProcessChainIds) > 0 {
+		result, err := r.RollbackReplaceChains(adminCli, chaintable, token)
+		if err != nil {
+			return err
+		}
+		if err := r.RestoreReplaceNodes(chaintable, result); err != nil {
+			return err
+		}
+		msg = fmt.Sprintf("aborted, %d chains rolled back to old target, %d chains kept new target, %d chains without old target left as is",
+			result.RolledBack, result.Kept, result.Broken)
+	} else if chaintable.Spec.Type == constant.ThreeFSChainTableTypeCreate && chaintable.Status.Executed {
+		msg = "aborted after chain table uploaded, new chains are kept"
+	} else if chaintable.Spec.Type == constant.ThreeFSChainTableTypeCreate && chaintable.Status.ProcessChainIds != nil {
+		msg = "aborted before chain table uploaded, new chains are not referenced by chain table"
+	}
+
+	if err := r.UpdateAbortStatus(msg, chaintable); err != nil {
+		return err
+	}
+	r.TakeFinishedTfsctSnapshot(adminCli, chaintable, token)
+	return nil
+}
+
+// UpdateAbortStatus sets the job to Aborted with the result message
+func (r *ThreeFsChainTableReconciler) UpdateAbortStatus(msg string, chaintable *threefsv1.ThreeFsChainTable) error {
+	originalObj := chaintable.DeepCopy()
+	modififedObj := chaintable.DeepCopy()
+	modififedObj.Status.Phase = constant.ThreeFSChainTableAbortedStatus
+	modififedObj.Status.QueuePosition = 0
+	modififedObj.Status.Message = msg
+	if err := r.Client.Status().Patch(context.Background(), modififedObj, client.MergeFrom(originalObj)); err != nil {
+		klog.Errorf("patch chain table status with aborted failed: %v", err)
+		return err
+	}
+	*chaintable = *modififedObj
+
+	klog.Infof("threefsChanintable job %s %s", chaintable.Name, msg)
+	r.Recorder.Event(chaintable, corev1.EventTypeNormal, "Aborted", msg)
+	return nil
+}
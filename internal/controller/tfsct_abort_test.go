@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newReplaceChainTable(name string) *threefsv1.ThreeFsChainTable {
+	chaintable := &threefsv1.ThreeFsChainTable{}
+	chaintable.Name = name
+	chaintable.Spec.Type = constant.ThreeFSChainTableTypeReplace
+	chaintable.Spec.ThreeFsClusterName = "test"
+	chaintable.Spec.ThreeFsClusterNamespace = "default"
+	chaintable.Spec.OldNode = []string{"node-old"}
+	chaintable.Spec.NewNode = []string{"node-new"}
+	return chaintable
+}
+
+func TestIsAbortRequested(t *testing.T) {
+	chaintable := newReplaceChainTable("job")
+	latest := chaintable.DeepCopy()
+	latest.Spec.Abort = true
+	r := &ThreeFsChainTableReconciler{Client: newFakeClient(latest)}
+	assert.True(t, r.IsAbortRequested(chaintable))
+
+	// falls back to the local spec if the job is gone
+	r = &ThreeFsChainTableReconciler{Client: newFakeClient()}
+	assert.False(t, r.IsAbortRequested(chaintable))
+	chaintable.Spec.Abort = true
+	assert.True(t, r.IsAbortRequested(chaintable))
+}
+
+func TestRestoreReplaceNodes(t *testing.T) {
+	newObjs := func() []client.Object {
+		oldNode := &corev1.Node{}
+		oldNode.Name = "node-old"
+		oldNode.Labels = map[string]string{constant.ThreeFSStorageFaultNodeKey: "true"}
+		tfsc := &threefsv1.ThreeFsCluster{}
+		tfsc.Name = "test"
+		tfsc.Namespace = "default"
+		tfsc.Status.NodesInfo.StorageNodes = []string{"node-a", "node-new"}
+		cm := &corev1.ConfigMap{}
+		cm.Name = "test-storage"
+		cm.Namespace = "default"
+		cm.Data = map[string]string{"node_old": "10099"}
+		return []client.Object{oldNode, tfsc, cm}
+	}
+
+	// chains rolled back, the old node and its node id are restored
+	chaintable := newReplaceChainTable("job")
+	chaintable.Status.ProcessChainIds = []string{"900100001@1000100001001"}
+	r := &ThreeFsChainTableReconciler{Client: newFakeClient(newObjs()...)}
+	assert.NoError(t, r.RestoreReplaceNodes(chaintable, &RollbackResult{RolledBack: 1}))
+
+	node := &corev1.Node{}
+	assert.NoError(t, r.Get(context.Background(), client.ObjectKey{Name: "node-old"}, node))
+	assert.NotContains(t, node.Labels, constant.ThreeFSStorageFaultNodeKey)
+	tfsc := &threefsv1.ThreeFsCluster{}
+	assert.NoError(t, r.Get(context.Background(), client.ObjectKey{Name: "test", Namespace: "default"}, tfsc))
+	assert.Equal(t, []string{"node-a", "node-old"}, tfsc.Status.NodesInfo.StorageNodes)
+	cm := &corev1.ConfigMap{}
+	assert.NoError(t, r.Get(context.Background(), client.ObjectKey{Name: "test-storage", Namespace: "default"}, cm))
+	assert.Equal(t, "00100", cm.Data["node_old"])
+
+	// new target still in use, the new node is kept and the old node is untouched
+	r = &ThreeFsChainTableReconciler{Client: newFakeClient(newObjs()...)}
+	assert.NoError(t, r.RestoreReplaceNodes(chaintable, &RollbackResult{Kept: 1, NewTargetInUse: true}))
+	assert.NoError(t, r.Get(context.Background(), client.ObjectKey{Name: "node-old"}, node))
+	assert.Contains(t, node.Labels, constant.ThreeFSStorageFaultNodeKey)
+	assert.NoError(t, r.Get(context.Background(), client.ObjectKey{Name: "test", Namespace: "default"}, tfsc))
+	assert.Equal(t, []string{"node-a", "node-new"}, tfsc.Status.NodesInfo.StorageNodes)
+
+	// invalid process chain id
+	chaintable.Status.ProcessChainIds = []string{"900100001"}
+	r = &ThreeFsChainTableReconciler{Client: newFakeClient(newObjs()...)}
+	assert.Error(t, r.RestoreReplaceNodes(chaintable, &RollbackResult{RolledBack: 1}))
+}
+
+func TestUpdateAbortStatus(t *testing.T) {
+	chaintable := newReplaceChainTable("job")
+	chaintable.Status.Phase = constant.ThreeFSChainTableProcessingStatus
+	chaintable.Status.QueuePosition = 2
+	r := &ThreeFsChainTableReconciler{Client: newFakeClient(chaintable.DeepCopy()), Recorder: record.NewFakeRecorder(10)}
+	assert.NoError(t, r.UpdateAbortStatus("aborted", chaintable))
+	assert.Equal(t, constant.ThreeFSChainTableAbortedStatus, chaintable.Status.Phase)
+
+	latest := &threefsv1.ThreeFsChainTable{}
+	assert.NoError(t, r.Get(context.Background(), client.ObjectKeyFromObject(chaintable), latest))
+	assert.Equal(t, constant.ThreeFSChainTableAbortedStatus, latest.Status.Phase)
+	assert.Equal(t, 0, latest.Status.QueuePosition)
+	assert.Equal(t, "aborted", latest.Status.Message)
+}
@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"context"
+	"sort"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/aliyun/kvc-3fs-operator/internal/utils"
+	"github.com/aliyun/kvc-3fs-operator/internal/validation"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// IsTfsctRunning returns true if the job has been admitted and is not finished
+func IsTfsctRunning(tfsct *threefsv1.ThreeFsChainTable) bool {
+	return tfsct.Status.Phase == constant.ThreeFSChainTableProcessingStatus ||
+		tfsct.Status.Phase == constant.ThreeFSChainTableProcessedStatus
+}
+
+// ListUnfinishedTfsct lists all unfinished jobs which belong to the threefs cluster
+func ListUnfinishedTfsct(rclient client.Client, tfscName, tfscNamespace string) ([]threefsv1.ThreeFsChainTable, error) {
+	tfsctList := &threefsv1.ThreeFsChainTableList{}
+	if err := rclient.List(context.Background(), tfsctList); err != nil {
+		klog.Errorf("list tfsct failed: %v", err)
+		return nil, err
+	}
+	jobs := make([]threefsv1.ThreeFsChainTable, 0)
+	for _, tfsct := range tfsctList.Items {
+		if tfsct.Spec.ThreeFsClusterName != tfscName || tfsct.Spec.ThreeFsClusterNamespace != tfscNamespace {
+			continue
+		}
+		if validation.IsTfsctDone(&tfsct) {
+			continue
+		}
+		jobs = append(jobs, tfsct)
+	}
+	return jobs, nil
+}
+
+// SortTfsctQueue sorts jobs by priority desc, then by creation time asc
+func SortTfsctQueue(jobs []threefsv1.ThreeFsChainTable) {
+	sort.SliceStable(jobs, func(i, j int) bool {
+		if jobs[i].Spec.Priority != jobs[j].Spec.Priority {
+			return jobs[i].Spec.Priority > jobs[j].Spec.Priority
+		}
+		if !jobs[i].CreationTimestamp.Equal(&jobs[j].CreationTimestamp) {
+			return jobs[i].CreationTimestamp.Before(&jobs[j].CreationTimestamp)
+		}
+		return jobs[i].Namespace+"/"+jobs[i].Name < jobs[j].Namespace+"/"+jobs[j].Name
+	})
+}
+
+// GetTfsctNodes returns all nodes referenced by the job
+func GetTfsctNodes(tfsct *threefsv1.ThreeFsChainTable) []string {
+	nodes := make([]string, 0, len(tfsct.Spec.NewNode)+len(tfsct.Spec.OldNode))
+	nodes = append(nodes, tfsct.Spec.NewNode...)
+	nodes = append(nodes, tfsct.Spec.OldNode...)
+	return nodes
+}
+
+// CanTfsctRunConcurrently checks whether two jobs are allowed to run at the same time.
+// NodeCreate rewrites the whole chain table with shared output files, so it always runs alone,
+// replace jobs can run in parallel only when their node sets do not overlap
+func CanTfsctRunConcurrently(a, b *threefsv1.ThreeFsChainTable) bool {
+	if a.Spec.Type != constant.ThreeFSChainTableTypeReplace || b.Spec.Type != constant.ThreeFSChainTableTypeReplace {
+		return false
+	}
+	for _, node := range GetTfsctNodes(a) {
+		if utils.StrListContains(GetTfsctNodes(b), node) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsNodeClaimedByTfsct checks whether the node is referenced by any of the jobs
+func IsNodeClaimedByTfsct(jobs []threefsv1.ThreeFsChainTable, node string) bool {
+	for idx := range jobs {
+		if utils.StrListContains(GetTfsctNodes(&jobs[idx]), node) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetTfsctQueueState returns whether the job can be admitted now and its position in the pending queue.
+// A pending job is admitted only if it can run together with all running jobs and all pending jobs ahead of it,
+// so that a job never overtakes a conflicting job with higher priority or earlier submission
+func GetTfsctQueueState(jobs []threefsv1.ThreeFsChainTable, tfsct *threefsv1.ThreeFsChainTable) (bool, int) {
+	running := make([]threefsv1.ThreeFsChainTable, 0)
+	pending := make([]threefsv1.ThreeFsChainTable, 0)
+	for _, job := range jobs {
+		if job.Namespace == tfsct.Namespace && job.Name == tfsct.Name {
+			continue
+		}
+		if job.DeletionTimestamp != nil && validation.IsTfsctPending(&job) {
+			continue
+		}
+		if IsTfsctRunning(&job) {
+			running = append(running, job)
+		} else if validation.IsTfsctPending(&job) {
+			pending = append(pending, job)
+		}
+	}
+	pending = append(pending, *tfsct)
+	SortTfsctQueue(pending)
+
+	position := 0
+	blockers := running
+	for idx := range pending {
+		if pending[idx].Namespace == tfsct.Namespace && pending[idx].Name == tfsct.Name {
+			position = idx + 1
+			break
+		}
+		blockers = append(blockers, pending[idx])
+	}
+
+	for idx := range blockers {
+		if !CanTfsctRunConcurrently(&blockers[idx], tfsct) {
+			return false, position
+		}
+	}
+	return true, position
+}
+
+// UpdateQueueStatus patches the phase and queue position of the job
+func (r *ThreeFsChainTableReconciler) UpdateQueueStatus(phase string, position int, chaintable *threefsv1.ThreeFsChainTable) error {
+	if chaintable.Status.Phase == phase && chaintable.Status.QueuePosition == position {
+		return nil
+	}
+
+	originalObj := chaintable.DeepCopy()
+	modififedObj := chaintable.DeepCopy()
+	modififedObj.Status.Phase = phase
+	modififedObj.Status.QueuePosition = position
+	if err := r.Client.Status().Patch(context.Background(), modififedObj, client.MergeFrom(originalObj)); err != nil {
+		klog.Errorf("patch chain table status with queue position failed: %v", err)
+		return err
+	}
+	*chaintable = *modififedObj
+
+	return nil
+}
+
+// AdmitTfsct moves the job from pending to processing if there is no conflicting job, otherwise
+// refreshes its queue position. Admission is serialized so that concurrent reconciles can not
+// admit two conflicting jobs at the same time
+func (r *ThreeFsChainTableReconciler) AdmitTfsct(chaintable *threefsv1.ThreeFsChainTable) (bool, error) {
+	r.queueLock.Lock()
+	defer r.queueLock.Unlock()
+
+	jobs, err := ListUnfinishedTfsct(r.Client, chaintable.Spec.ThreeFsClusterName, chaintable.Spec.ThreeFsClusterNamespace)
+	if err != nil {
+		return false, err
+	}
+	admitted, position := GetTfsctQueueState(jobs, chaintable)
+	if !admitted {
+		if err := r.UpdateQueueStatus(constant.ThreeFSChainTablePendingStatus, position, chaintable); err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+
+	if err := r.UpdateQueueStatus(constant.ThreeFSChainTableProcessingStatus, 0, chaintable); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newQueueJob(name, phase, jobType string, priority int, created time.Time, newNode, oldNode string) threefsv1.ThreeFsChainTable {
+	job := threefsv1.NewThreeFsChainTable(name, "default").
+		WithType(jobType).
+		WithPriority(priority).
+		WithNewNode([]string{newNode}).
+		WithOldNode([]string{oldNode})
+	job.CreationTimestamp = metav1.NewTime(created)
+	job.Status.Phase = phase
+	return *job
+}
+
+func TestGetTfsctQueueState(t *testing.T) {
+	now := time.Now()
+	running := newQueueJob("running", constant.ThreeFSChainTableProcessingStatus, constant.ThreeFSChainTableTypeReplace, 0, now, "backup-1", "node-1")
+	disjoint := newQueueJob("disjoint", constant.ThreeFSChainTablePendingStatus, constant.ThreeFSChainTableTypeReplace, 0, now.Add(time.Second), "backup-2", "node-2")
+	overlap := newQueueJob("overlap", constant.ThreeFSChainTablePendingStatus, constant.ThreeFSChainTableTypeReplace, 0, now.Add(2*time.Second), "backup-1", "node-3")
+	jobs := []threefsv1.ThreeFsChainTable{running, disjoint, overlap}
+
+	admitted, position := GetTfsctQueueState(jobs, &disjoint)
+	assert.True(t, admitted)
+	assert.Equal(t, 1, position)
+
+	admitted, position = GetTfsctQueueState(jobs, &overlap)
+	assert.False(t, admitted)
+	assert.Equal(t, 2, position)
+
+	// create job always runs alone
+	create := newQueueJob("create", "", constant.ThreeFSChainTableTypeCreate, 0, now.Add(3*time.Second), "new-1", "")
+	admitted, _ = GetTfsctQueueState(append(jobs, create), &create)
+	assert.False(t, admitted)
+}
+
+func TestSortTfsctQueue(t *testing.T) {
+	now := time.Now()
+	jobs := []threefsv1.ThreeFsChainTable{
+		newQueueJob("late", "", constant.ThreeFSChainTableTypeReplace, 0, now.Add(time.Second), "b1", "n1"),
+		newQueueJob("early", "", constant.ThreeFSChainTableTypeReplace, 0, now, "b2", "n2"),
+		newQueueJob("urgent", "", constant.ThreeFSChainTableTypeReplace, 10, now.Add(2*time.Second), "b3", "n3"),
+	}
+	SortTfsctQueue(jobs)
+	assert.Equal(t, "urgent", jobs[0].Name)
+	assert.Equal(t, "early", jobs[1].Name)
+	assert.Equal(t, "late", jobs[2].Name)
+}
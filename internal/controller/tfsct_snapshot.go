@@ -0,0 +1,288 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	clientcomm "github.com/aliyun/kvc-3fs-operator/internal/client"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/aliyun/kvc-3fs-operator/internal/native_resources"
+	"github.com/aliyun/kvc-3fs-operator/internal/storage"
+	"github.com/aliyun/kvc-3fs-operator/internal/utils"
+	"github.com/aliyun/kvc-3fs-operator/internal/validation"
+	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func GetChainTableSnapshotName(tfscName string, version int) string {
+	return fmt.Sprintf("%s-%s-%d", tfscName, constant.DefaultChainTableSnapshotPrefix, version)
+}
+
+// ListChainTableSnapshots lists snapshots of the threefs cluster sorted by version asc
+func ListChainTableSnapshots(rclient client.Client, tfscName, namespace string) ([]corev1.ConfigMap, error) {
+	cmList := &corev1.ConfigMapList{}
+	if err := rclient.List(context.Background(), cmList, client.InNamespace(namespace), client.MatchingLabels{constant.ThreeFSChainTableSnapshotKey: tfscName}); err != nil {
+		klog.Errorf("list chain table snapshots of %s failed: %v", tfscName, err)
+		return nil, err
+	}
+	snapshots := cmList.Items
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return GetChainTableSnapshotVersion(&snapshots[i]) < GetChainTableSnapshotVersion(&snapshots[j])
+	})
+	return snapshots, nil
+}
+
+func GetChainTableSnapshotVersion(cm *corev1.ConfigMap) int {
+	version, err := strconv.Atoi(cm.Labels[constant.ThreeFSChainTableSnapshotVersionKey])
+	if err != nil {
+		return 0
+	}
+	return version
+}
+
+// GetChainTableSnapshotFile returns the file name of the chain table in snapshots
+func GetChainTableSnapshotFile(chainTableId string) string {
+	return fmt.Sprintf("%s.%s", constant.DefaultChainTableSnapshotTable, chainTableId)
+}
+
+// TakeChainTableSnapshot dumps chains and all chain tables with admin_cli, and stores the compressed files
+// into a versioned configmap owned by the threefs cluster. Returns the snapshot name
+func TakeChainTableSnapshot(rclient client.Client, scheme *runtime.Scheme, adminCli *clientcomm.AdminCliConfig, token string,
+	tfsc *threefsv1.ThreeFsCluster, source, stage string) (string, error) {
+
+	dir, err := os.MkdirTemp("/tmp", "chaintable_snapshot_*")
+	if err != nil {
+		klog.Errorf("create snapshot tmp dir failed: %v", err)
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := adminCli.DumpChains(token, filepath.Join(dir, constant.DefaultChainTableSnapshotChains)); err != nil {
+		klog.Errorf("dump chains for snapshot failed: %v", err)
+		return "", err
+	}
+	for _, table := range storage.GetChainTables(tfsc.Spec) {
+		// chain tables without nodes are not created yet
+		if err := adminCli.DumpChainTable(token, table.Id, filepath.Join(dir, GetChainTableSnapshotFile(table.Id))); err != nil {
+			klog.Warningf("dump chain table %s for snapshot failed: %v", table.Id, err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	binaryData := make(map[string][]byte)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return "", err
+		}
+		if len(content) == 0 {
+			continue
+		}
+		compressed, err := utils.GzipBytes(content)
+		if err != nil {
+			return "", err
+		}
+		binaryData[entry.Name()+".gz"] = compressed
+	}
+	tableDumped := false
+	for key := range binaryData {
+		if strings.HasPrefix(key, constant.DefaultChainTableSnapshotTable) {
+			tableDumped = true
+		}
+	}
+	if !tableDumped {
+		return "", fmt.Errorf("chain table dumped is empty")
+	}
+
+	snapshots, err := ListChainTableSnapshots(rclient, tfsc.Name, tfsc.Namespace)
+	if err != nil {
+		return "", err
+	}
+	version := 1
+	if len(snapshots) > 0 {
+		version = GetChainTableSnapshotVersion(&snapshots[len(snapshots)-1]) + 1
+	}
+
+	// version may be taken by a concurrent job, try the next one
+	for retry := 0; retry < 3; retry++ {
+		name := GetChainTableSnapshotName(tfsc.Name, version)
+		labels := map[string]string{
+			constant.ThreeFSChainTableSnapshotKey:        tfsc.Name,
+			constant.ThreeFSChainTableSnapshotVersionKey: strconv.Itoa(version),
+		}
+		annotations := map[string]string{
+			constant.ThreeFSChainTableSnapshotStageKey:  stage,
+			constant.ThreeFSChainTableSnapshotSourceKey: source,
+			constant.ThreeFSChainTableSnapshotTimeKey:   time.Now().Format(constant.TimeLayout),
+		}
+		cm := native_resources.NewConfigmapConfig(rclient).
+			WithMeta(name, tfsc.Namespace).
+			WithLabels(labels).
+			WithAnnotations(annotations).
+			WithBinaryData(binaryData).ConfigMap
+		cm.Data = nil
+		if err := controllerutil.SetOwnerReference(tfsc, cm, scheme); err != nil {
+			klog.Errorf("set owner reference of snapshot %s failed: %v", name, err)
+			return "", err
+		}
+		if err := rclient.Create(context.Background(), cm); err != nil {
+			if k8serror.IsAlreadyExists(err) {
+				version++
+				continue
+			}
+			klog.Errorf("create chain table snapshot %s failed: %v", name, err)
+			return "", err
+		}
+		klog.Infof("chain table snapshot %s(%s %s) created", name, stage, source)
+
+		if err := PruneChainTableSnapshots(rclient, tfsc.Name, tfsc.Namespace, constant.DefaultChainTableSnapshotRetention); err != nil {
+			klog.Warningf("prune chain table snapshots of %s failed: %v", tfsc.Name, err)
+		}
+		return name, nil
+	}
+
+	return "", fmt.Errorf("create chain table snapshot failed, version conflict")
+}
+
+// PruneChainTableSnapshots keeps the newest snapshots and deletes the others
+func PruneChainTableSnapshots(rclient client.Client, tfscName, namespace string, retention int) error {
+	snapshots, err := ListChainTableSnapshots(rclient, tfscName, namespace)
+	if err != nil {
+		return err
+	}
+	for idx := 0; idx < len(snapshots)-retention; idx++ {
+		if err := rclient.Delete(context.Background(), &snapshots[idx]); err != nil && !k8serror.IsNotFound(err) {
+			klog.Errorf("delete chain table snapshot %s failed: %v", snapshots[idx].Name, err)
+			return err
+		}
+		klog.Infof("chain table snapshot %s pruned", snapshots[idx].Name)
+	}
+	return nil
+}
+
+// RestoreChainTableSnapshot re-uploads chains and chain tables stored in the snapshot, only chain table chainTableId
+// is uploaded if it is not empty
+func RestoreChainTableSnapshot(rclient client.Client, adminCli *clientcomm.AdminCliConfig, token string, tfsc *threefsv1.ThreeFsCluster, name, chainTableId string) error {
+	cm := &corev1.ConfigMap{}
+	if err := rclient.Get(context.Background(), client.ObjectKey{Name: name, Namespace: tfsc.Namespace}, cm); err != nil {
+		klog.Errorf("get chain table snapshot %s failed: %v", name, err)
+		return err
+	}
+
+	dir, err := os.MkdirTemp("/tmp", "chaintable_restore_*")
+	if err != nil {
+		klog.Errorf("create restore tmp dir failed: %v", err)
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	chainFiles := make([]string, 0)
+	for key, compressed := range cm.BinaryData {
+		content, err := utils.GunzipBytes(compressed)
+		if err != nil {
+			klog.Errorf("decompress %s of snapshot %s failed: %v", key, name, err)
+			return err
+		}
+		fileName := strings.TrimSuffix(key, ".gz")
+		if err := os.WriteFile(filepath.Join(dir, fileName), content, 0644); err != nil {
+			return err
+		}
+		if strings.HasPrefix(fileName, constant.DefaultChainTableSnapshotChains) {
+			chainFiles = append(chainFiles, fileName)
+		}
+	}
+	tableFiles := storage.ParseChainTableSnapshotFiles(cm)
+	if chainTableId != "" {
+		fileName, ok := tableFiles[chainTableId]
+		if !ok {
+			return fmt.Errorf("chain table snapshot %s has no chain table %s", name, chainTableId)
+		}
+		tableFiles = map[string]string{chainTableId: fileName}
+	}
+	if len(tableFiles) == 0 {
+		return fmt.Errorf("chain table snapshot %s has no chain table", name)
+	}
+
+	// chains must be uploaded before chain table which references them
+	sort.Strings(chainFiles)
+	for _, fileName := range chainFiles {
+		if err := adminCli.UploadChains(token, filepath.Join(dir, fileName)); err != nil {
+			klog.Errorf("upload chains %s of snapshot %s failed: %v", fileName, name, err)
+			return err
+		}
+	}
+	for id, fileName := range tableFiles {
+		desc := constant.DefaultChainTableDesc
+		if table, ok := storage.GetChainTable(tfsc.Spec, id); ok {
+			desc = storage.GetChainTableDesc(table.Storage)
+		}
+		if err := adminCli.UploadChainTable(token, id, desc, filepath.Join(dir, fileName)); err != nil {
+			klog.Errorf("upload chain table %s of snapshot %s failed: %v", id, name, err)
+			return err
+		}
+	}
+
+	klog.Infof("chain table snapshot %s restored", name)
+	return nil
+}
+
+// TakeTfsctSnapshot takes the snapshot of the stage for the job once and records it in the job status
+func (r *ThreeFsChainTableReconciler) TakeTfsctSnapshot(adminCli *clientcomm.AdminCliConfig, chaintable *threefsv1.ThreeFsChainTable, token, stage string) error {
+	if (stage == constant.ChainTableSnapshotStageBefore && chaintable.Status.Snapshots.Before != "") ||
+		(stage == constant.ChainTableSnapshotStageAfter && chaintable.Status.Snapshots.After != "") {
+		return nil
+	}
+
+	vfsc := &threefsv1.ThreeFsCluster{}
+	if err := r.Get(context.Background(), client.ObjectKey{Name: chaintable.Spec.ThreeFsClusterName, Namespace: chaintable.Spec.ThreeFsClusterNamespace}, vfsc); err != nil {
+		klog.Errorf("get ThreeFsCluster %s err: %+v", chaintable.Spec.ThreeFsClusterName, err)
+		return err
+	}
+	name, err := TakeChainTableSnapshot(r.Client, r.Scheme, adminCli, token, vfsc, chaintable.Name, stage)
+	if err != nil {
+		r.Recorder.Event(chaintable, corev1.EventTypeWarning, "SnapshotFailed", fmt.Sprintf("take %s snapshot failed: %v", stage, err))
+		return err
+	}
+
+	originalObj := chaintable.DeepCopy()
+	modififedObj := chaintable.DeepCopy()
+	if stage == constant.ChainTableSnapshotStageBefore {
+		modififedObj.Status.Snapshots.Before = name
+	} else {
+		modififedObj.Status.Snapshots.After = name
+	}
+	if err := r.Client.Status().Patch(context.Background(), modififedObj, client.MergeFrom(originalObj)); err != nil {
+		klog.Errorf("patch chain table status with snapshot failed: %v", err)
+		return err
+	}
+	*chaintable = *modififedObj
+
+	return nil
+}
+
+// TakeFinishedTfsctSnapshot takes the after snapshot once the job is done, failure does not block the job
+func (r *ThreeFsChainTableReconciler) TakeFinishedTfsctSnapshot(adminCli *clientcomm.AdminCliConfig, chaintable *threefsv1.ThreeFsChainTable, token string) {
+	if !validation.IsTfsctDone(chaintable) {
+		return
+	}
+	if err := r.TakeTfsctSnapshot(adminCli, chaintable, token, constant.ChainTableSnapshotStageAfter); err != nil {
+		klog.Warningf("take snapshot after threefsChanintable job %s failed: %v", chaintable.Name, err)
+	}
+}
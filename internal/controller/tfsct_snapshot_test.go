@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newChainTableSnapshot(tfscName, namespace string, version int) *corev1.ConfigMap {
+	cm := &corev1.ConfigMap{}
+	cm.Name = GetChainTableSnapshotName(tfscName, version)
+	cm.Namespace = namespace
+	cm.Labels = map[string]string{
+		constant.ThreeFSChainTableSnapshotKey:        tfscName,
+		constant.ThreeFSChainTableSnapshotVersionKey: strconv.Itoa(version),
+	}
+	return cm
+}
+
+func TestGetChainTableSnapshotVersion(t *testing.T) {
+	assert.Equal(t, "test-ct-snapshot-3", GetChainTableSnapshotName("test", 3))
+	assert.Equal(t, 3, GetChainTableSnapshotVersion(newChainTableSnapshot("test", "default", 3)))
+
+	cm := &corev1.ConfigMap{}
+	assert.Equal(t, 0, GetChainTableSnapshotVersion(cm))
+	cm.Labels = map[string]string{constant.ThreeFSChainTableSnapshotVersionKey: "abc"}
+	assert.Equal(t, 0, GetChainTableSnapshotVersion(cm))
+	assert.Equal(t, "chain_table.csv.2", GetChainTableSnapshotFile("2"))
+}
+
+func TestPruneChainTableSnapshots(t *testing.T) {
+	objs := []client.Object{newChainTableSnapshot("other", "default", 1)}
+	for _, version := range []int{10, 2, 1, 3} {
+		objs = append(objs, newChainTableSnapshot("test", "default", version))
+	}
+	rclient := newFakeClient(objs...)
+
+	snapshots, err := ListChainTableSnapshots(rclient, "test", "default")
+	assert.NoError(t, err)
+	versions := []int{}
+	for idx := range snapshots {
+		versions = append(versions, GetChainTableSnapshotVersion(&snapshots[idx]))
+	}
+	assert.Equal(t, []int{1, 2, 3, 10}, versions)
+
+	assert.NoError(t, PruneChainTableSnapshots(rclient, "test", "default", 2))
+	snapshots, err = ListChainTableSnapshots(rclient, "test", "default")
+	assert.NoError(t, err)
+	assert.Len(t, snapshots, 2)
+	assert.Equal(t, GetChainTableSnapshotName("test", 3), snapshots[0].Name)
+	assert.Equal(t, GetChainTableSnapshotName("test", 10), snapshots[1].Name)
+
+	// snapshots of other clusters are kept
+	snapshots, err = ListChainTableSnapshots(rclient, "other", "default")
+	assert.NoError(t, err)
+	assert.Len(t, snapshots, 1)
+}
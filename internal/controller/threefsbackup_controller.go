@@ -0,0 +1,201 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ThreeFsBackupReconciler reconciles a ThreeFsBackup object
+type ThreeFsBackupReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=threefs.aliyun.com,resources=threefsbackups,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=threefs.aliyun.com,resources=threefsbackups/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=threefs.aliyun.com,resources=threefsbackups/finalizers,verbs=update
+
+// Reconcile starts fdb backups of the cluster to the destination once or by schedule, and records results of them
+func (r *ThreeFsBackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	klog.Infof("Reconciling threeFsBackup %s", req.NamespacedName)
+	backup := &threefsv1.ThreeFsBackup{}
+	if err := r.Client.Get(ctx, req.NamespacedName, backup); err != nil {
+		if k8serror.IsNotFound(err) {
+			klog.Infof("threeFsBackup %s has been deleted", req.NamespacedName)
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	if backup.DeletionTimestamp != nil {
+		return ctrl.Result{}, nil
+	}
+
+	status := *backup.Status.DeepCopy()
+	if backup.Spec.Schedule != nil && backup.Spec.Schedule.IntervalMinutes <= 0 {
+		status.Phase = constant.FdbBackupFailedStatus
+		status.Message = "schedule intervalMinutes must be positive"
+		return ctrl.Result{}, r.updateBackupStatus(backup, status)
+	}
+
+	threeFsCluster := &threefsv1.ThreeFsCluster{}
+	if err := r.Get(ctx, client.ObjectKey{Name: backup.Spec.ThreeFsClusterName, Namespace: backup.Spec.ThreeFsClusterNamespace}, threeFsCluster); err != nil {
+		if !k8serror.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		status.Message = fmt.Sprintf("threeFsCluster %s/%s not found", backup.Spec.ThreeFsClusterNamespace, backup.Spec.ThreeFsClusterName)
+	} else if threeFsCluster.Status.Phase != constant.ThreeFSClusterReadyStatus {
+		status.Message = fmt.Sprintf("wait threeFsCluster %s ready", threeFsCluster.Name)
+	}
+	if status.Message != "" {
+		klog.Infof("threeFsBackup %s: %s", backup.Name, status.Message)
+		if len(status.History) == 0 {
+			status.Phase = constant.FdbBackupPendingStatus
+		}
+		if err := r.updateBackupStatus(backup, status); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: constant.FdbBackupInterval}, nil
+	}
+
+	agent, err := PrepareFdbBackupAgent(r.Client, r.Scheme, backup, "backup", threeFsCluster, backup.Spec.Destination, backup.Spec.AgentReplica)
+	if err != nil {
+		status.Message = fmt.Sprintf("prepare backup agent failed: %v", err)
+		_ = r.updateBackupStatus(backup, status)
+		return ctrl.Result{}, err
+	}
+
+	now := time.Now()
+	if len(status.History) > 0 && status.History[0].Phase == constant.FdbBackupRunningStatus {
+		if err := r.checkRunningBackup(backup, agent, &status.History[0], now); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	running := len(status.History) > 0 && status.History[0].Phase == constant.FdbBackupRunningStatus
+	status.Message = ""
+	if !running && IsBackupDue(backup.Spec.Schedule, status.History, status.LastScheduleTime, now) {
+		if !agent.Ready {
+			status.Message = "wait backup agents ready"
+			if err := r.updateBackupStatus(backup, status); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{RequeueAfter: constant.FdbBackupInterval}, nil
+		}
+		name := GetBackupRunName(backup.Name, now)
+		if err := agent.StartBackup(GetBackupUrl(backup.Spec.Destination, agent.AccessKey, name), backup.Name); err != nil {
+			klog.Errorf("start backup %s failed: %v", name, err)
+			r.Recorder.Event(backup, corev1.EventTypeWarning, "BackupFailed", fmt.Sprintf("start backup %s failed: %v", name, err))
+			status.Message = fmt.Sprintf("start backup %s failed: %v", name, err)
+			_ = r.updateBackupStatus(backup, status)
+			return ctrl.Result{}, err
+		}
+		klog.Infof("backup %s of threeFsCluster %s started", name, threeFsCluster.Name)
+		r.Recorder.Event(backup, corev1.EventTypeNormal, "BackupStarted", fmt.Sprintf("backup %s started", name))
+		record := threefsv1.BackupRecord{
+			Name:      name,
+			Phase:     constant.FdbBackupRunningStatus,
+			StartTime: now.Format(constant.TimeLayout),
+		}
+		status.History = append([]threefsv1.BackupRecord{record}, status.History...)
+		status.LastScheduleTime = record.StartTime
+		running = true
+	}
+
+	status.History = TrimBackupHistory(status.History, backup.Spec.HistoryLimit)
+	status.Phase = GetBackupPhase(backup.Spec.Schedule, status.History)
+	if err := r.updateBackupStatus(backup, status); err != nil {
+		return ctrl.Result{}, err
+	}
+	if running {
+		return ctrl.Result{RequeueAfter: constant.FdbBackupInterval}, nil
+	}
+	if next := GetNextBackupTime(backup.Spec.Schedule, status.LastScheduleTime); !next.IsZero() {
+		return ctrl.Result{RequeueAfter: max(time.Until(next), time.Second)}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// checkRunningBackup records size and restorable versions once the running backup stops
+func (r *ThreeFsBackupReconciler) checkRunningBackup(backup *threefsv1.ThreeFsBackup, agent *FdbBackupAgent, record *threefsv1.BackupRecord, now time.Time) error {
+	liveStatus, err := agent.GetBackupStatus(backup.Name)
+	if err != nil {
+		klog.Errorf("get status of backup %s failed: %v", record.Name, err)
+		return err
+	}
+	if liveStatus.Status.Running {
+		return nil
+	}
+
+	record.EndTime = now.Format(constant.TimeLayout)
+	description, err := agent.DescribeBackup(GetBackupUrl(backup.Spec.Destination, agent.AccessKey, record.Name))
+	if err != nil || !description.Restorable {
+		record.Phase = constant.FdbBackupFailedStatus
+		record.Message = "backup stopped but is not restorable"
+		if err != nil {
+			record.Message = fmt.Sprintf("describe backup failed: %v", err)
+		}
+		klog.Errorf("backup %s failed: %s", record.Name, record.Message)
+		r.Recorder.Event(backup, corev1.EventTypeWarning, "BackupFailed", fmt.Sprintf("backup %s failed: %s", record.Name, record.Message))
+		return nil
+	}
+	record.Phase = constant.FdbBackupCompletedStatus
+	record.Size = description.SnapshotBytes()
+	record.MinRestorableVersion = description.MinRestorablePoint.Version
+	record.MaxRestorableVersion = description.MaxRestorablePoint.Version
+	klog.Infof("backup %s completed, size %d, restorable versions %d-%d", record.Name, record.Size, record.MinRestorableVersion, record.MaxRestorableVersion)
+	r.Recorder.Event(backup, corev1.EventTypeNormal, "BackupCompleted", fmt.Sprintf("backup %s completed, size %s, restorable versions %d-%d",
+		record.Name, resource.NewQuantity(record.Size, resource.BinarySI).String(), record.MinRestorableVersion, record.MaxRestorableVersion))
+	return nil
+}
+
+func (r *ThreeFsBackupReconciler) updateBackupStatus(backup *threefsv1.ThreeFsBackup, status threefsv1.ThreeFsBackupStatus) error {
+	localCache := threefsv1.ThreeFsBackup{}
+	_ = r.Get(context.Background(), client.ObjectKey{Name: backup.Name, Namespace: backup.Namespace}, &localCache)
+
+	modifiedObj := localCache.DeepCopy()
+	modifiedObj.Status = status
+	backup.Status = status
+	if err := r.Client.Status().Patch(context.Background(), modifiedObj, client.MergeFrom(&localCache)); err != nil {
+		klog.Errorf("update threeFsBackup %s status failed: %v", backup.Name, err)
+		return err
+	}
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ThreeFsBackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&threefsv1.ThreeFsBackup{}).
+		Owns(&appsv1.Deployment{}).
+		Complete(r)
+}
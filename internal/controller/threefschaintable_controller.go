@@ -24,6 +24,7 @@ import (
 	"github.com/aliyun/kvc-3fs-operator/internal/constant"
 	"github.com/aliyun/kvc-3fs-operator/internal/storage"
 	"github.com/aliyun/kvc-3fs-operator/internal/utils"
+	"github.com/aliyun/kvc-3fs-operator/internal/validation"
 	corev1 "k8s.io/api/core/v1"
 	k8serror "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -35,6 +36,8 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"strings"
+	"sync"
+	"time"
 )
 
 // ThreeFsChainTableReconciler reconciles a ThreeFsChainTable object
@@ -42,6 +45,8 @@ type ThreeFsChainTableReconciler struct {
 	client.Client
 	Scheme   *runtime.Scheme
 	Recorder record.EventRecorder
+
+	queueLock sync.Mutex
 }
 
 // +kubebuilder:rbac:groups=threefs.aliyun.com.code.alibaba-inc.com,resources=threefschaintables,verbs=get;list;watch;create;update;patch;delete
@@ -80,11 +85,33 @@ func (r *ThreeFsChainTableReconciler) Reconcile(ctx context.Context, req ctrl.Re
 		klog.Infof("delete threefsCluster %s related resources success", threefsChanintable.Name)
 	}
 
-	if threefsChanintable.Status.Phase == constant.ThreeFSChainTableFinishedStatus {
-		klog.Infof("threefsChanintable job %s has finished, skip", req.NamespacedName)
+	if validation.IsTfsctDone(threefsChanintable) {
+		klog.Infof("threefsChanintable job %s has %s, skip", req.NamespacedName, strings.ToLower(threefsChanintable.Status.Phase))
 		return ctrl.Result{}, nil
 	}
 
+	if validation.IsTfsctPending(threefsChanintable) {
+		if threefsChanintable.DeletionTimestamp != nil {
+			klog.Infof("threefsChanintable job %s is deleted before processing, skip", req.NamespacedName)
+			return ctrl.Result{}, nil
+		}
+		if threefsChanintable.Spec.Abort {
+			if err := r.UpdateAbortStatus("aborted before processing", threefsChanintable); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, nil
+		}
+		admitted, err := r.AdmitTfsct(threefsChanintable)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !admitted {
+			klog.Infof("threefsChanintable job %s is pending, queue position %d", req.NamespacedName, threefsChanintable.Status.QueuePosition)
+			return ctrl.Result{RequeueAfter: constant.ChainTableQueueInterval}, nil
+		}
+		klog.Infof("threefsChanintable job %s is admitted to process", req.NamespacedName)
+	}
+
 	vfsc := threefsv1.ThreeFsCluster{}
 	if err := r.Get(context.Background(), client.ObjectKey{Name: threefsChanintable.Spec.ThreeFsClusterName, Namespace: threefsChanintable.Spec.ThreeFsClusterNamespace}, &vfsc); err != nil {
 		klog.Errorf("get ThreeFsCluster %s err: %+v", threefsChanintable.Spec.ThreeFsClusterName, err)
@@ -103,6 +130,20 @@ func (r *ThreeFsChainTableReconciler) Reconcile(ctx context.Context, req ctrl.Re
 	adminCliConfig := clientcomm.NewAdminCli(vfsc.Status.MgmtdAddresses,
 		filepath.Join(constant.DefaultConfigPath, constant.ThreeFSAdminCliMain))
 
+	// abort has higher priority than any other operations, new node is not required to be ready
+	if threefsChanintable.Spec.Abort {
+		tokenConfig := corev1.ConfigMap{}
+		if err := r.Client.Get(context.Background(), client.ObjectKey{Name: constant.DefaultTokenConfigName, Namespace: threefsChanintable.Spec.ThreeFsClusterNamespace}, &tokenConfig); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.AbortTfsct(adminCliConfig, threefsChanintable, tokenConfig.Data["token"]); err != nil {
+			klog.Errorf("abort threefsChanintable job %s failed, err: %+v", req.NamespacedName, err)
+			r.Recorder.Event(threefsChanintable, corev1.EventTypeWarning, "AbortFailed", err.Error())
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
 	// for add/replace, need to check storage process status
 	if threefsChanintable.Spec.NewNode != nil {
 		for _, newnode := range threefsChanintable.Spec.NewNode {
@@ -146,12 +187,6 @@ func (r *ThreeFsChainTableReconciler) Reconcile(ctx context.Context, req ctrl.Re
 		}
 	}
 
-	if threefsChanintable.Status.Phase == "" {
-		if err := r.UpdatePhase(constant.ThreeFSChainTableProcessingStatus, threefsChanintable); err != nil {
-			return ctrl.Result{}, err
-		}
-	}
-
 	if !utils.StrListContains(threefsChanintable.GetFinalizers(), constant.ThreeFSFinalizer) {
 		controllerutil.AddFinalizer(threefsChanintable, constant.ThreeFSFinalizer)
 		if err := r.Update(context.Background(), threefsChanintable); err != nil {
@@ -182,6 +217,11 @@ func (r *ThreeFsChainTableReconciler) Reconcile(ctx context.Context, req ctrl.Re
 
 		if threefsChanintable.Spec.Type == constant.ThreeFSChainTableTypeReplace {
 			if !threefsChanintable.Status.Executed {
+				if err := r.TakeTfsctSnapshot(adminCliConfig, threefsChanintable, tokenConfig.Data["token"], constant.ChainTableSnapshotStageBefore); err != nil {
+					klog.Errorf("take snapshot before threefsChanintable job %s failed, err: %+v", req.NamespacedName, err)
+					return ctrl.Result{}, err
+				}
+
 				oldNodeObj := &corev1.Node{}
 				if err := r.Client.Get(context.Background(), client.ObjectKey{Name: threefsChanintable.Spec.OldNode[0]}, oldNodeObj); err != nil {
 					klog.Errorf("get node %s err: %+v", threefsChanintable.Spec.OldNode[0], err)
@@ -189,6 +229,11 @@ func (r *ThreeFsChainTableReconciler) Reconcile(ctx context.Context, req ctrl.Re
 				} else {
 					if _, ok := oldNodeObj.Labels[constant.ThreeFSStorageFaultNodeKey]; !ok {
 						oldNodeObj.Labels[constant.ThreeFSStorageFaultNodeKey] = "true"
+						// fault time ranks backup nodes with LeastRecentlyFailed after the node is repaired
+						if oldNodeObj.Annotations == nil {
+							oldNodeObj.Annotations = make(map[string]string)
+						}
+						oldNodeObj.Annotations[constant.ThreeFSStorageLastFaultTimeKey] = time.Now().Format(constant.TimeLayout)
 						if err = r.Client.Update(context.Background(), oldNodeObj); err != nil {
 							klog.Errorf("update node %s with storage fault label err: %+v", threefsChanintable.Spec.OldNode[0], err)
 							return ctrl.Result{}, err
@@ -246,12 +291,21 @@ func (r *ThreeFsChainTableReconciler) Reconcile(ctx context.Context, req ctrl.Re
 				}
 				klog.Infof("check chain table related to old node success, all chain table related to old node is not SERVING-UPTODATE")
 
-				// delete target related to old node
-				if err = r.DeleteTargetRelatedNode(adminCliConfig, chains, threefsChanintable.Spec.OldNode[0], tokenConfig.Data["token"]); err != nil {
-					klog.Errorf("delete target related to old node failed")
-					return ctrl.Result{}, err
+				if r.IsAbortRequested(threefsChanintable) {
+					klog.Infof("threefsChanintable job %s abort requested, stop updating chains", req.NamespacedName)
+					return ctrl.Result{Requeue: true}, nil
+				}
+
+				// chains of erasure coding have only one target, which is removed after the new target is added
+				erasureCoding := storage.IsErasureCoding(vfsc.Spec.Storage)
+				if !erasureCoding {
+					// delete target related to old node
+					if err = r.DeleteTargetRelatedNode(adminCliConfig, chains, threefsChanintable.Spec.OldNode[0], tokenConfig.Data["token"]); err != nil {
+						klog.Errorf("delete target related to old node failed")
+						return ctrl.Result{}, err
+					}
+					klog.Infof("delete target related to old node %s success", threefsChanintable.Spec.OldNode)
 				}
-				klog.Infof("delete target related to old node %s success", threefsChanintable.Spec.OldNode)
 
 				// create target related to new node
 				tmpFilePath, err := r.CreateTargetTmpFile(adminCliConfig, chainids, threefsChanintable.Spec.OldNode[0], threefsChanintable.Spec.NewNode[0])
@@ -267,6 +321,11 @@ func (r *ThreeFsChainTableReconciler) Reconcile(ctx context.Context, req ctrl.Re
 				}
 				klog.Infof("create target related to new node %s success", threefsChanintable.Spec.NewNode)
 
+				if r.IsAbortRequested(threefsChanintable) {
+					klog.Infof("threefsChanintable job %s abort requested, stop updating chains", req.NamespacedName)
+					return ctrl.Result{Requeue: true}, nil
+				}
+
 				// add target related to new node
 				if err = r.AddTargetRelatedNode(adminCliConfig, chainids, threefsChanintable.Spec.OldNode[0], threefsChanintable.Spec.NewNode[0], tokenConfig.Data["token"]); err != nil {
 					klog.Errorf("add target related to new node failed")
@@ -274,6 +333,14 @@ func (r *ThreeFsChainTableReconciler) Reconcile(ctx context.Context, req ctrl.Re
 				}
 				klog.Infof("add target related to new node %s success", threefsChanintable.Spec.NewNode)
 
+				if erasureCoding {
+					if err = r.DeleteTargetRelatedNode(adminCliConfig, chains, threefsChanintable.Spec.OldNode[0], tokenConfig.Data["token"]); err != nil {
+						klog.Errorf("delete target related to old node failed")
+						return ctrl.Result{}, err
+					}
+					klog.Infof("delete target related to old node %s success", threefsChanintable.Spec.OldNode)
+				}
+
 				// tag crd
 				if err := r.UpdateExecTag(true, threefsChanintable); err != nil {
 					klog.Errorf("update threefsChanintable %s executed failed, err: %+v", threefsChanintable.Name, err)
@@ -332,15 +399,17 @@ func (r *ThreeFsChainTableReconciler) Reconcile(ctx context.Context, req ctrl.Re
 				klog.Errorf("update threefsChanintable %s status failed, err: %+v", threefsChanintable.Name, err)
 				return ctrl.Result{}, err
 			}
+			r.TakeFinishedTfsctSnapshot(adminCliConfig, threefsChanintable, tokenConfig.Data["token"])
 		} else if threefsChanintable.Spec.Type == constant.ThreeFSChainTableTypeCreate {
 			if !threefsChanintable.Status.Executed {
-				startIdx, err := ParseStartNodeId(r.Client, threefsChanintable.Spec.NewNode, vfsc)
-				if err != nil {
-					klog.Errorf("parse start node id failed, err: %+v", err)
+				if err := r.TakeTfsctSnapshot(adminCliConfig, threefsChanintable, tokenConfig.Data["token"], constant.ChainTableSnapshotStageBefore); err != nil {
+					klog.Errorf("take snapshot before threefsChanintable job %s failed, err: %+v", req.NamespacedName, err)
 					return ctrl.Result{}, err
 				}
-				if err := clientcomm.CreateDataPlacementRule(threefsChanintable.Spec.NewNode, &vfsc, startIdx); err != nil {
-					r.Recorder.Event(threefsChanintable, "Warning", "CreateDataPlacementRuleFailed", err.Error())
+
+				groups, err := storage.GroupStorageNodes(r.Client, vfsc.Spec.Storage, threefsChanintable.Spec.NewNode)
+				if err != nil {
+					klog.Errorf("group new nodes by disk layout failed, err: %+v", err)
 					return ctrl.Result{}, err
 				}
 
@@ -349,16 +418,25 @@ func (r *ThreeFsChainTableReconciler) Reconcile(ctx context.Context, req ctrl.Re
 				if err != nil {
 					return ctrl.Result{}, err
 				}
-				newTargetPath, newChainPath, newChainTablePath, err := UpdateChainIdWithExistingChain("/output/create_target_cmd.txt", "/output/generated_chains.csv", "/output/generated_chain_table.csv", maps)
+				newTargetPath, placed, err := PlaceStorageGroups(r.Client, &vfsc, groups, maps)
 				if err != nil {
+					r.Recorder.Event(threefsChanintable, "Warning", "CreateDataPlacementRuleFailed", err.Error())
 					return ctrl.Result{}, err
 				}
 
 				if threefsChanintable.Status.ProcessChainIds == nil {
-					chainsIdList, err := ParseChainTableFromFile(newChainPath)
-					if err != nil || chainsIdList == nil || len(chainsIdList) == 0 {
-						klog.Errorf("parse chain table from file failed, err: %+v", err)
-						return ctrl.Result{}, err
+					chainsIdList := make([]string, 0)
+					for _, table := range placed {
+						chainIds, err := ParseChainTableFromFile(table.ChainTablePath)
+						if err != nil {
+							klog.Errorf("parse chain table from file failed, err: %+v", err)
+							return ctrl.Result{}, err
+						}
+						chainsIdList = append(chainsIdList, chainIds...)
+					}
+					if len(chainsIdList) == 0 {
+						klog.Errorf("threefsChanintable job %s has no chain to create", req.NamespacedName)
+						return ctrl.Result{}, fmt.Errorf("threefsChanintable job %s has no chain to create", req.NamespacedName)
 					}
 					if err := r.UpdateProcessChains(chainsIdList, threefsChanintable); err != nil {
 						klog.Errorf("update process chains failed: %v", err)
@@ -374,28 +452,14 @@ func (r *ThreeFsChainTableReconciler) Reconcile(ctx context.Context, req ctrl.Re
 				}
 				klog.Infof("create target related to new node %+v success", threefsChanintable.Spec.NewNode)
 
-				if err := adminCliConfig.DumpChains(tokenConfig.Data["token"], "output/dump_chains.csv"); err != nil {
+				if err := UploadPlacedChainTables(adminCliConfig, tokenConfig.Data["token"], &vfsc, placed); err != nil {
 					return ctrl.Result{}, err
 				}
-
-				if err := utils.MergeCSVFiles(newChainPath, fmt.Sprintf("output/dump_chains.csv.%d", vfsc.Spec.Storage.Replica), "output/new_chains.csv"); err != nil {
+				if err := UpdateChainTablesStatus(r.Client, &vfsc, placed); err != nil {
+					klog.Errorf("update ThreeFsCluster %s chain tables status failed, err: %+v", vfsc.Name, err)
 					return ctrl.Result{}, err
 				}
-				if err := adminCliConfig.UploadChains(tokenConfig.Data["token"], "output/new_chains.csv"); err != nil {
-					return ctrl.Result{}, err
-				}
-				klog.Infof("upload chains related to new node %+v success", threefsChanintable.Spec.NewNode)
-
-				if err := adminCliConfig.DumpChainTable(tokenConfig.Data["token"], "output/dump_chain_table.csv"); err != nil {
-					return ctrl.Result{}, err
-				}
-				if err := utils.MergeCSVFiles(newChainTablePath, "output/dump_chain_table.csv", "output/new_chaintables.csv"); err != nil {
-					return ctrl.Result{}, err
-				}
-				if err := adminCliConfig.UploadChainTable(tokenConfig.Data["token"], "output/new_chaintables.csv"); err != nil {
-					return ctrl.Result{}, err
-				}
-				klog.Infof("upload chain table related to new node %+v success", threefsChanintable.Spec.NewNode)
+				klog.Infof("upload chains and chain table related to new node %+v success", threefsChanintable.Spec.NewNode)
 
 				// tag crd
 				if err := r.UpdateExecTag(true, threefsChanintable); err != nil {
@@ -421,6 +485,30 @@ func (r *ThreeFsChainTableReconciler) Reconcile(ctx context.Context, req ctrl.Re
 				klog.Errorf("update threefsChanintable %s status failed, err: %+v", threefsChanintable.Name, err)
 				return ctrl.Result{}, err
 			}
+			r.TakeFinishedTfsctSnapshot(adminCliConfig, threefsChanintable, tokenConfig.Data["token"])
+		} else if threefsChanintable.Spec.Type == constant.ThreeFSChainTableTypeRestore {
+			if !threefsChanintable.Status.Executed {
+				if err := r.TakeTfsctSnapshot(adminCliConfig, threefsChanintable, tokenConfig.Data["token"], constant.ChainTableSnapshotStageBefore); err != nil {
+					klog.Errorf("take snapshot before threefsChanintable job %s failed, err: %+v", req.NamespacedName, err)
+					return ctrl.Result{}, err
+				}
+
+				if err := RestoreChainTableSnapshot(r.Client, adminCliConfig, tokenConfig.Data["token"], &vfsc, threefsChanintable.Spec.Snapshot, threefsChanintable.Spec.ChainTableId); err != nil {
+					r.Recorder.Event(threefsChanintable, corev1.EventTypeWarning, "RestoreSnapshotFailed", err.Error())
+					return ctrl.Result{}, err
+				}
+				r.Recorder.Event(threefsChanintable, corev1.EventTypeNormal, "RestoreSnapshot", fmt.Sprintf("chain table snapshot %s restored", threefsChanintable.Spec.Snapshot))
+				klog.Infof("threefsChanintable job %s restore snapshot %s success", threefsChanintable.GetName(), threefsChanintable.Spec.Snapshot)
+			}
+
+			threefsChanintable.Status.Executed = true
+			threefsChanintable.Status.Process = "1/1"
+			threefsChanintable.Status.Phase = constant.ThreeFSChainTableFinishedStatus
+			if err := r.Client.Status().Update(context.Background(), threefsChanintable); err != nil {
+				klog.Errorf("update threefsChanintable %s status failed, err: %+v", threefsChanintable.Name, err)
+				return ctrl.Result{}, err
+			}
+			r.TakeFinishedTfsctSnapshot(adminCliConfig, threefsChanintable, tokenConfig.Data["token"])
 		}
 
 	}
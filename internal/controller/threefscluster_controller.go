@@ -60,6 +60,8 @@ type ThreeFsClusterReconciler struct {
 // +kubebuilder:rbac:groups=threefs.aliyun.com,resources=threefsclusters,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=threefs.aliyun.com,resources=threefsclusters/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=threefs.aliyun.com,resources=threefsclusters/finalizers,verbs=update
+// +kubebuilder:rbac:groups=apps.foundationdb.org,resources=foundationdbclusters,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -111,20 +113,30 @@ func (r *ThreeFsClusterReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 	fdbConfig := fdb.NewFdbConfig(threeFsCluster.Name, threeFsCluster.Namespace,
 		threeFsCluster.Spec.Fdb.StorageReplicas, threeFsCluster.Spec.Fdb.ClusterSize,
 		threeFsCluster.Status.NodesInfo.FdbNodes, threeFsCluster.Spec.Fdb.Port, threeFsCluster.Spec.Fdb.Resources, r.Client,
-		r.RESTClient, r.RESTConfig, r.Scheme)
+		r.RESTClient, r.RESTConfig, r.Scheme).
+		WithProcesses(threeFsCluster.Spec.Fdb.Processes).
+		WithVersion(GetFdbDeployVersion(threeFsCluster)).
+		WithClientVersions(GetFdbClientVersions(threeFsCluster))
 
 	storageConfig := storage.NewStorageConfig(threeFsCluster.Name, threeFsCluster.Namespace,
 		threeFsCluster.Status.NodesInfo.StorageNodes, "", threeFsCluster.Spec.Storage.RdmaPort,
 		threeFsCluster.Spec.Storage.TcpPort,
-		threeFsCluster.Spec.Storage.TargetPaths, threeFsCluster.Spec.Storage.Resources, r.Client)
+		threeFsCluster.Spec.Storage.TargetPaths, threeFsCluster.Spec.Storage.Resources, r.Client).
+		WithNodeGroups(threeFsCluster.Spec.Storage.TargetPerDisk, threeFsCluster.Spec.Storage.NodeGroups)
 
+	// fdb of external-operator mode is scheduled by the FoundationDB kubernetes operator, and fdb of external mode
+	// is an existing fdb cluster, only fdb of builtin mode is run on fdb nodes
+	builtinFdb := fdb.IsBuiltinMode(threeFsCluster.Spec.Fdb)
+	externalFdbOperator := fdb.IsExternalOperatorMode(threeFsCluster.Spec.Fdb)
 	if threeFsCluster.DeletionTimestamp == nil {
 		// check fdb node label and change fdb nodes
-		if err := fdbConfig.TagNodeLabel(threeFsCluster); err != nil {
-			if strings.Contains(err.Error(), "fdb node is not enough") {
-				r.Recorder.Event(threeFsCluster, "Warning", "TagNodeLabelFailed", "tag fdb node number is not enough")
+		if builtinFdb {
+			if err := fdbConfig.TagNodeLabel(threeFsCluster); err != nil {
+				if strings.Contains(err.Error(), "fdb node is not enough") {
+					r.Recorder.Event(threeFsCluster, "Warning", "TagNodeLabelFailed", "tag fdb node number is not enough")
+				}
+				return ctrl.Result{}, err
 			}
-			return ctrl.Result{}, err
 		}
 
 		// check storage node label and change storage nodes
@@ -135,17 +147,26 @@ func (r *ThreeFsClusterReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 			return ctrl.Result{}, err
 		}
 
-		if len(threeFsCluster.Status.NodesInfo.StorageNodes) < threeFsCluster.Spec.Mgmtd.Replica || len(threeFsCluster.Status.NodesInfo.StorageNodes) < threeFsCluster.Spec.Meta.Replica {
-			return ctrl.Result{}, fmt.Errorf("storage node is not enough for mgmtd/meta replica")
+		if err := TagEvictionNamespaceLabel(r.Client, threeFsCluster.Namespace); err != nil {
+			return ctrl.Result{}, err
 		}
 	}
 
+	mgmtdCandidates, err := GetServiceCandidateNodes(r.Client, threeFsCluster, threeFsCluster.Spec.Mgmtd.NodeSelector)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if threeFsCluster.DeletionTimestamp == nil && len(mgmtdCandidates) < threeFsCluster.Spec.Mgmtd.Replica {
+		return ctrl.Result{}, fmt.Errorf("node is not enough for mgmtd replica")
+	}
+
 	mgmtdConfig := mgmtd.NewMgmtdConfig(threeFsCluster.Name, threeFsCluster.Namespace,
-		threeFsCluster.Status.NodesInfo.StorageNodes, threeFsCluster.Spec.Mgmtd.RdmaPort, threeFsCluster.Spec.Mgmtd.TcpPort,
+		mgmtdCandidates, threeFsCluster.Spec.Mgmtd.RdmaPort, threeFsCluster.Spec.Mgmtd.TcpPort,
 		threeFsCluster.Spec.Mgmtd.Replica, threeFsCluster.Spec.Fdb.Resources, fdbConfig, r.Client)
 	// client related config
 	fdbcliConfig := clientcomm.NewFdbCliConfig(constant.DefaultThreeFSFdbConfigPath,
-		threeFsCluster.Spec.Fdb.StorageReplicas, threeFsCluster.Spec.Fdb.CoordinatorNum, r.RESTClient)
+		threeFsCluster.Spec.Fdb.StorageReplicas, threeFsCluster.Spec.Fdb.CoordinatorNum, r.RESTClient).
+		WithStorageEngine(threeFsCluster.Spec.Fdb.StorageEngine)
 
 	var mgmtdAddresses string
 	if threeFsCluster.DeletionTimestamp == nil {
@@ -164,6 +185,7 @@ func (r *ThreeFsClusterReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 			newObj := threeFsCluster.DeepCopy()
 			newObj.Status.TagMgmtd = true
 			newObj.Status.MgmtdAddresses = mgmtdAddresses
+			newObj.Status.NodesInfo.MgmtdNodes = mgmtdNodes
 			if err := r.Client.Status().Patch(context.Background(), newObj, client.MergeFrom(threeFsCluster)); err != nil {
 				klog.Errorf("update ThreeFsCluster %s TagMgmtd status failed, err: %+v", threeFsCluster.Name, err)
 				return ctrl.Result{}, err
@@ -180,7 +202,7 @@ func (r *ThreeFsClusterReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 	}
 
 	metaConfig := meta.NewMetaConfig(threeFsCluster.Name, threeFsCluster.Namespace,
-		threeFsCluster.Status.NodesInfo.StorageNodes, mgmtdAddresses,
+		threeFsCluster.Status.NodesInfo.MetaNodes, mgmtdAddresses,
 		threeFsCluster.Spec.Meta.RdmaPort, threeFsCluster.Spec.Meta.TcpPort, threeFsCluster.Spec.Meta.Replica,
 		threeFsCluster.Spec.Meta.Resources, fdbConfig, r.Client)
 	storageConfig.MgmtdAddresses = mgmtdAddresses
@@ -223,6 +245,21 @@ func (r *ThreeFsClusterReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 		if err := fdbConfig.DeleteDeployIfExist(); err != nil {
 			return ctrl.Result{}, err
 		}
+		if externalFdbOperator {
+			if err := fdbConfig.DeleteOperatorClusterIfExist(); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		if fdb.IsExternalMode(threeFsCluster.Spec.Fdb) && threeFsCluster.Status.ConfigStatus["fdb"] == constant.ThreeComponentReadyStatus {
+			// cluster file of fdbcli is rendered from the fdb configmap, release the external fdb before deleting it
+			if err := r.RenderFdbConfig(fdbConfig); err != nil {
+				return ctrl.Result{}, err
+			}
+			fdbcliConfig.WithTLS(GetFdbTLSOptions(threeFsCluster))
+			if err := r.ReleaseExternalFdb(fdbcliConfig, threeFsCluster); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
 		if err := fdbConfig.DeleteFdbConfigIfExist(); err != nil {
 			return ctrl.Result{}, err
 		}
@@ -268,6 +305,18 @@ func (r *ThreeFsClusterReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 			return ctrl.Result{}, err
 		}
 
+		// check fdb tls certificates before fdb, mgmtd and meta pods mount them
+		tlsSecretName, tlsReady, err := r.ReconcileFdbTLS(fdbConfig, threeFsCluster)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !tlsReady {
+			klog.Infof("fdb tls certificate is not ready, requeue after 10s")
+			return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+		}
+		fdbConfig.WithTLS(tlsSecretName, fdb.GetTLSVerifyPeers(threeFsCluster.Spec.Fdb))
+		fdbcliConfig.WithTLS(GetFdbTLSOptions(threeFsCluster))
+
 		if !threeFsCluster.Spec.Clickhouse.UseEcsClickhouse {
 			// check clickhouse deploy & service
 			if err := chCongig.CreateDeployIfNotExist(); err != nil {
@@ -321,30 +370,62 @@ func (r *ThreeFsClusterReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 		}
 		klog.Infof("monitor deploy & service created")
 
-		// check fdb configmap & deploy
-		content, _ := fdbcliConfig.GetRemoteConfigContent()
-		if err := fdbConfig.CreateFdbConfigIfNotExist(content); err != nil {
-			return ctrl.Result{}, err
-		}
+		if externalFdbOperator {
+			// check FoundationDBCluster & fdb configmap
+			ready, err := r.ReconcileFdbOperatorCluster(fdbConfig, threeFsCluster)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if !ready {
+				klog.Infof("fdb configmap of FoundationDBCluster is not created, requeue after 10s")
+				return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+			}
+			klog.Infof("fdb configmap of FoundationDBCluster ready")
+		} else if fdb.IsExternalMode(threeFsCluster.Spec.Fdb) {
+			// check fdb configmap of the external fdb cluster
+			content, err := fdbConfig.GetExternalConnectionString(threeFsCluster.Spec.Fdb.External)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if err := fdbConfig.CreateFdbConfigIfNotExist(content); err != nil {
+				return ctrl.Result{}, err
+			}
+			klog.Infof("fdb configmap of external fdb cluster created")
+		} else {
+			// check fdb configmap & deploy
+			content, _ := fdbcliConfig.GetRemoteConfigContent()
+			if err := fdbConfig.CreateFdbConfigIfNotExist(content); err != nil {
+				return ctrl.Result{}, err
+			}
 
-		if err := fdbConfig.CreateDeployIfNotExist(); err != nil {
-			return ctrl.Result{}, err
-		}
+			if err := fdbConfig.CreateDeployIfNotExist(); err != nil {
+				return ctrl.Result{}, err
+			}
+			r.recordFdbDeployCreated(threeFsCluster, fdbConfig.CreatedNodes)
 
-		klog.Infof("fdb configmap & deploy created")
+			klog.Infof("fdb configmap & deploy created")
+		}
 
 		if err := r.RenderMainConfigPhase1(monConfig, fdbConfig, mgmtdConfig); err != nil {
 			return ctrl.Result{}, err
 		}
 
 		// check fdb cluster status
-		if threeFsCluster.Status.ConfigStatus["fdb"] == constant.ThreeComponentReadyStatus {
-			// for fault tolerance, no return
-			if _, _, err := fdbcliConfig.ConfigureCoordinator(); err != nil {
-				klog.Errorf("auto coordinator failed, err: %+v", err)
-			}
-		} else {
-			if err := fdbcliConfig.InitFdbCluster(); err != nil {
+		if threeFsCluster.Status.ConfigStatus["fdb"] != constant.ThreeComponentReadyStatus {
+			// database of external-operator mode is configured by the FoundationDB kubernetes operator,
+			// and database of external mode is checked and claimed by the ThreeFsCluster
+			if !builtinFdb {
+				if fdb.IsExternalMode(threeFsCluster.Spec.Fdb) {
+					if err := r.InitExternalFdb(fdbcliConfig, threeFsCluster); err != nil {
+						klog.Errorf("check external fdb cluster failed, err: %+v", err)
+						return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+					}
+				}
+				if err := r.updateConfigtStatus(threeFsCluster, "fdb", constant.ThreeComponentReadyStatus); err != nil {
+					klog.Errorf("update ThreeFsCluster %s status failed, err: %+v", threeFsCluster.Name, err)
+					return ctrl.Result{}, err
+				}
+			} else if err := fdbcliConfig.InitFdbCluster(); err != nil {
 				klog.Errorf("init/check fdb cluster failed, err: %+v", err)
 				return ctrl.Result{}, err
 			} else {
@@ -361,18 +442,74 @@ func (r *ThreeFsClusterReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 			return ctrl.Result{}, err
 		}
 
+		// coordinators and configuration of external-operator and external mode are not managed by the operator
+		if builtinFdb {
+			// update fdb crd status, faulty fdb nodes are tracked and labeled while fdb is unhealthy
+			if err := r.UpdateClusterFdbStatus(threeFsCluster, details, r.Client); err != nil {
+				klog.Errorf("update ThreeFsCluster %s status failed, err: %+v", threeFsCluster.Name, err)
+				return ctrl.Result{}, err
+			}
+			_ = r.Get(context.Background(), client.ObjectKey{Name: threeFsCluster.Name, Namespace: threeFsCluster.Namespace}, threeFsCluster)
+			klog.Infof("update fdb cluster status success")
+
+			// for fault tolerance, no return
+			if err := r.ReconcileFdbCoordinators(fdbcliConfig, threeFsCluster, details); err != nil {
+				klog.Errorf("reconcile fdb coordinators failed, err: %+v", err)
+			}
+
+			// apply changes of storageReplicas and clusterSize
+			reconfiguring, err := r.ReconfigureFdb(fdbcliConfig, threeFsCluster, details)
+			if err != nil {
+				klog.Errorf("reconfigure fdb of threeFsCluster %s failed, err: %+v", threeFsCluster.Name, err)
+				return ctrl.Result{}, err
+			}
+			if reconfiguring {
+				klog.Infof("fdb is reconfiguring, requeue after 10s")
+				return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+			}
+
+			// upgrade fdb to spec.fdb.version
+			upgrading, err := r.UpgradeFdb(fdbConfig, threeFsCluster, details)
+			if err != nil {
+				klog.Errorf("upgrade fdb of threeFsCluster %s failed, err: %+v", threeFsCluster.Name, err)
+				return ctrl.Result{}, err
+			}
+			if upgrading {
+				klog.Infof("fdb is upgrading, requeue after 10s")
+				return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+			}
+		}
+
+		// removing and rolling fdb nodes need fully replicated data
 		if details.Cluster.Data.State.Name != "healthy" {
 			klog.Infof("fdb cluster not fully replicated healthy(%s), requeue after 10s", details.Cluster.Data.State.Description)
 			return ctrl.Result{RequeueAfter: time.Second * 10}, nil
 		}
 
-		// update fdb crd status
-		if err := r.UpdateClusterFdbStatus(threeFsCluster, details, r.Client); err != nil {
-			klog.Errorf("update ThreeFsCluster %s status failed, err: %+v", threeFsCluster.Name, err)
-			return ctrl.Result{}, err
+		if builtinFdb {
+			// remove fdb from nodes leaving fdb nodes or beyond cluster size
+			removingFdb, err := r.RemoveFdbNodes(fdbConfig, fdbcliConfig, threeFsCluster, details)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if removingFdb {
+				klog.Infof("fdb node is removing, requeue after 10s")
+				return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+			}
+			// apply changes of fdb processes node by node
+			rollingFdb, err := r.RollFdbProcesses(fdbConfig, threeFsCluster)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if rollingFdb {
+				klog.Infof("fdb processes are updating, requeue after 10s")
+				return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+			}
+			// for fault tolerance, no return
+			if err := r.ConfigureFdbRoles(fdbcliConfig, threeFsCluster, details); err != nil {
+				klog.Errorf("configure fdb roles failed, err: %+v", err)
+			}
 		}
-		_ = r.Get(context.Background(), client.ObjectKey{Name: threeFsCluster.Name, Namespace: threeFsCluster.Namespace}, threeFsCluster)
-		klog.Infof("update fdb cluster status success")
 
 		// update meta/mgmtd/storage crd status
 		if strings.Contains(mgmtdAddresses, "RDMA") {
@@ -382,9 +519,22 @@ func (r *ThreeFsClusterReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 		}
 		_ = r.Get(context.Background(), client.ObjectKey{Name: threeFsCluster.Name, Namespace: threeFsCluster.Namespace}, threeFsCluster)
 
+		// fdb of a new cluster is restored from backup before mgmtd is initialized
+		if threeFsCluster.Status.ConfigStatus["mgmtd"] != constant.ThreeComponentReadyStatus {
+			restoring, err := IsRestorePending(r.Client, threeFsCluster)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if restoring {
+				klog.Infof("threeFsCluster %s fdb is restoring, wait to init mgmtd", threeFsCluster.Name)
+				return ctrl.Result{RequeueAfter: constant.FdbBackupInterval}, nil
+			}
+		}
+
 		// check mgmtd configmap & deploy
 		if threeFsCluster.Status.ConfigStatus["mgmtd"] != constant.ThreeComponentReadyStatus {
-			if err := adminCliConfig.InitCluster(threeFsCluster.Spec.ChainTableId, threeFsCluster.Spec.StripeSize, threeFsCluster.Spec.ChunkSize); err != nil {
+			defaultTable := storage.GetDefaultChainTable(threeFsCluster.Spec)
+			if err := adminCliConfig.InitCluster(defaultTable.Id, defaultTable.StripeSize, defaultTable.ChunkSize); err != nil {
 				return ctrl.Result{}, err
 			}
 			if err := r.updateConfigtStatus(threeFsCluster, "mgmtd", constant.ThreeComponentReadyStatus); err != nil {
@@ -448,7 +598,11 @@ func (r *ThreeFsClusterReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 			}
 			klog.Infof("threeFsCluster %s meta config uploaded", threeFsCluster.Name)
 		}
-		if err := metaConfig.TagNodeLabel(); err != nil {
+		metaNodes, err := r.ReconcileMetaNodes(threeFsCluster)
+		if err == nil {
+			err = metaConfig.TagNodeLabel(metaNodes)
+		}
+		if err != nil {
 			if strings.Contains(err.Error(), "tag meta node number is not enough") {
 				r.Recorder.Event(threeFsCluster, "Warning", "TagNodeLabelFailed", err.Error())
 			}
@@ -469,6 +623,12 @@ func (r *ThreeFsClusterReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 		if err := metaConfig.CreateDeployIfNotExist(); err != nil {
 			return ctrl.Result{}, err
 		}
+		if err := r.DeleteRemovedMetaDeploys(adminCliConfig, threeFsCluster, metaConfig, metaNodes); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.UnregisterRemovedMetaNodes(adminCliConfig, threeFsCluster, metaNodes); err != nil {
+			return ctrl.Result{}, err
+		}
 		if !CheckComponentStatus(adminCliConfig, "META", "", false, r.Client) {
 			klog.Infof("meta not ready yet, requeue after 10s")
 			return ctrl.Result{RequeueAfter: time.Second * 10}, nil
@@ -522,18 +682,31 @@ func (r *ThreeFsClusterReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 				return ctrl.Result{}, err
 			}
 
-			if err := clientcomm.CreateDataPlacementRule(threeFsCluster.Status.NodesInfo.StorageNodes, threeFsCluster, constant.ThreeFSStorageStartNodeId); err != nil {
+			r.takeDataPlacementSnapshot(adminCliConfig, threeFsCluster, token, constant.ChainTableSnapshotStageBefore)
+
+			groups, err := storage.GroupStorageNodes(r.Client, threeFsCluster.Spec.Storage, threeFsCluster.Status.NodesInfo.StorageNodes)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			targetPath, placed, err := PlaceStorageGroups(r.Client, threeFsCluster, groups, map[int]int{})
+			if err != nil {
 				r.Recorder.Event(threeFsCluster, "Warning", "CreateDataPlacementRuleFailed", err.Error())
 				return ctrl.Result{}, err
 			}
 
-			if err := adminCliConfig.CreateTarget(token, "output/create_target_cmd.txt"); err != nil {
+			if err := adminCliConfig.CreateTarget(token, targetPath); err != nil {
 				return ctrl.Result{}, err
 			}
-			if err := adminCliConfig.UploadChains(token, "output/generated_chains.csv"); err != nil {
+			if err := UploadPlacedChainTables(adminCliConfig, token, threeFsCluster, placed); err != nil {
 				return ctrl.Result{}, err
 			}
-			if err := adminCliConfig.UploadChainTable(token, "output/generated_chain_table.csv"); err != nil {
+			if err := UpdateChainTablesStatus(r.Client, threeFsCluster, placed); err != nil {
+				klog.Errorf("update ThreeFsCluster %s chain tables status failed, err: %+v", threeFsCluster.Name, err)
+				return ctrl.Result{}, err
+			}
+			r.takeDataPlacementSnapshot(adminCliConfig, threeFsCluster, token, constant.ChainTableSnapshotStageAfter)
+			if err := r.updateDiskExpansionStatus(threeFsCluster, threefsv1.DiskExpansion{TargetPaths: threeFsCluster.Spec.Storage.TargetPaths}); err != nil {
+				klog.Errorf("update ThreeFsCluster %s placed target paths failed, err: %+v", threeFsCluster.Name, err)
 				return ctrl.Result{}, err
 			}
 
@@ -572,15 +745,65 @@ func (r *ThreeFsClusterReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 			}
 		}
 
+		// roll mgmtd/meta/storage one by one after mgmtd addresses changed by failover
+		rolling, err := r.RollMgmtdAddresses(adminCliConfig, threeFsCluster)
+		if err != nil {
+			klog.Errorf("roll mgmtd addresses failed, err: %+v", err)
+			return ctrl.Result{}, err
+		}
+
+		// move primary mgmtd by annotation
+		movingPrimary, err := r.HandleMgmtdMovePrimary(adminCliConfig, threeFsCluster)
+		if err != nil {
+			klog.Errorf("handle mgmtd move primary failed, err: %+v", err)
+			return ctrl.Result{}, err
+		}
+
+		// check storage nodes in maintenance, fault storage in maintenance is not replaced
+		inMaintenance, err := r.HandleStorageMaintenance(adminCliConfig, threeFsCluster)
+		if err != nil {
+			klog.Errorf("handle storage maintenance failed, err: %+v", err)
+			return ctrl.Result{}, err
+		}
+
 		// check storage/target status for replace
-		if threeFsCluster.Labels != nil && threeFsCluster.Labels[constant.ThreeFSAutoReplaceLabel] == "true" {
-			klog.Infof("threeFsCluster %s auto replace enabled, check fault storage", threeFsCluster.Name)
+		if IsAutoRepairEnabled(threeFsCluster) {
+			klog.Infof("threeFsCluster %s auto repair enabled, check fault storage", threeFsCluster.Name)
 			_ = r.Get(context.Background(), client.ObjectKey{Name: threeFsCluster.Name, Namespace: threeFsCluster.Namespace}, threeFsCluster)
 			if err := r.HandleFaultStorage(threeFsCluster); err != nil {
 				klog.Errorf("handle fault storage failed, err: %+v", err)
 				return ctrl.Result{}, err
 			}
 		}
+
+		// check new target paths
+		if threeFsCluster.Status.Phase == constant.ThreeFSClusterReadyStatus {
+			inProgress, err := r.HandleTargetPathsExpansion(adminCliConfig, threeFsCluster, storageConfig)
+			if err != nil {
+				klog.Errorf("handle target paths expansion failed, err: %+v", err)
+				return ctrl.Result{}, err
+			}
+			if inProgress {
+				return ctrl.Result{RequeueAfter: constant.DiskExpansionInterval}, nil
+			}
+
+			// check chain tables not placed yet
+			waiting, err := r.HandlePendingChainTables(adminCliConfig, threeFsCluster)
+			if err != nil {
+				klog.Errorf("handle pending chain tables failed, err: %+v", err)
+				return ctrl.Result{}, err
+			}
+			if waiting {
+				return ctrl.Result{RequeueAfter: constant.ChainTableQueueInterval}, nil
+			}
+		}
+
+		if inMaintenance {
+			return ctrl.Result{RequeueAfter: constant.StorageMaintenanceInterval}, nil
+		}
+		if rolling || movingPrimary {
+			return ctrl.Result{RequeueAfter: constant.StorageMaintenanceInterval}, nil
+		}
 	}
 
 	return ctrl.Result{}, nil
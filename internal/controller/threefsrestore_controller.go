@@ -0,0 +1,198 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	clientcomm "github.com/aliyun/kvc-3fs-operator/internal/client"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ThreeFsRestoreReconciler reconciles a ThreeFsRestore object
+type ThreeFsRestoreReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=threefs.aliyun.com,resources=threefsrestores,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=threefs.aliyun.com,resources=threefsrestores/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=threefs.aliyun.com,resources=threefsrestores/finalizers,verbs=update
+
+// IsRestoreDone returns true if the restore is completed or failed
+func IsRestoreDone(restore *threefsv1.ThreeFsRestore) bool {
+	return restore.Status.Phase == constant.FdbBackupCompletedStatus || restore.Status.Phase == constant.FdbBackupFailedStatus
+}
+
+// IsRestorePending returns true if a restore into the cluster is not completed, mgmtd is not initialized until then.
+// A failed restore blocks the cluster as well until the ThreeFsRestore is deleted
+func IsRestorePending(rclient client.Client, tfsc *threefsv1.ThreeFsCluster) (bool, error) {
+	restoreList := &threefsv1.ThreeFsRestoreList{}
+	if err := rclient.List(context.Background(), restoreList); err != nil {
+		klog.Errorf("list threeFsRestore failed: %v", err)
+		return false, err
+	}
+	for _, restore := range restoreList.Items {
+		if restore.Spec.ThreeFsClusterName != tfsc.Name || restore.Spec.ThreeFsClusterNamespace != tfsc.Namespace {
+			continue
+		}
+		if restore.Status.Phase != constant.FdbBackupCompletedStatus {
+			klog.Infof("threeFsRestore %s/%s into threeFsCluster %s is %s", restore.Namespace, restore.Name, tfsc.Name, restore.Status.Phase)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Reconcile restores the backup into the fdb of a new cluster before mgmtd starts
+func (r *ThreeFsRestoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	klog.Infof("Reconciling threeFsRestore %s", req.NamespacedName)
+	restore := &threefsv1.ThreeFsRestore{}
+	if err := r.Client.Get(ctx, req.NamespacedName, restore); err != nil {
+		if k8serror.IsNotFound(err) {
+			klog.Infof("threeFsRestore %s has been deleted", req.NamespacedName)
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	if restore.DeletionTimestamp != nil || IsRestoreDone(restore) {
+		return ctrl.Result{}, nil
+	}
+
+	status := restore.Status
+	threeFsCluster := &threefsv1.ThreeFsCluster{}
+	if err := r.Get(ctx, client.ObjectKey{Name: restore.Spec.ThreeFsClusterName, Namespace: restore.Spec.ThreeFsClusterNamespace}, threeFsCluster); err != nil {
+		if !k8serror.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		return r.waitRestore(restore, fmt.Sprintf("threeFsCluster %s/%s not found", restore.Spec.ThreeFsClusterNamespace, restore.Spec.ThreeFsClusterName))
+	}
+	if status.Phase != constant.FdbBackupRunningStatus && threeFsCluster.Status.ConfigStatus["mgmtd"] == constant.ThreeComponentReadyStatus {
+		status.Phase = constant.FdbBackupFailedStatus
+		status.Message = fmt.Sprintf("threeFsCluster %s is initialized, restore only into a new cluster before mgmtd starts", threeFsCluster.Name)
+		r.Recorder.Event(restore, corev1.EventTypeWarning, "RestoreFailed", status.Message)
+		return ctrl.Result{}, r.updateRestoreStatus(restore, status)
+	}
+
+	agent, err := PrepareFdbBackupAgent(r.Client, r.Scheme, restore, "restore", threeFsCluster, restore.Spec.Source, constant.DefaultFdbBackupAgentReplica)
+	if err != nil {
+		return r.waitRestore(restore, fmt.Sprintf("prepare restore agent failed: %v", err))
+	}
+
+	now := time.Now().Format(constant.TimeLayout)
+	if status.Phase != constant.FdbBackupRunningStatus {
+		output, _, _ := clientcomm.NewFdbCliConfig(agent.ClusterFile, 0, 0, nil).WithTLS(agent.TLS).CheckFdbCluster()
+		if !strings.Contains(output, "The database is available") {
+			return r.waitRestore(restore, "wait fdb available")
+		}
+		if !agent.Ready {
+			return r.waitRestore(restore, "wait restore agents ready")
+		}
+		url := GetBackupUrl(restore.Spec.Source, agent.AccessKey, restore.Spec.BackupName)
+		if err := agent.StartRestore(url, restore.Name, restore.Spec.Version); err != nil {
+			status.Phase = constant.FdbBackupFailedStatus
+			status.Message = fmt.Sprintf("start restore of backup %s failed: %v", restore.Spec.BackupName, err)
+			r.Recorder.Event(restore, corev1.EventTypeWarning, "RestoreFailed", status.Message)
+			return ctrl.Result{}, r.updateRestoreStatus(restore, status)
+		}
+		klog.Infof("restore backup %s into threeFsCluster %s started", restore.Spec.BackupName, threeFsCluster.Name)
+		r.Recorder.Event(restore, corev1.EventTypeNormal, "RestoreStarted", fmt.Sprintf("restore backup %s into threeFsCluster %s started", restore.Spec.BackupName, threeFsCluster.Name))
+		status.Phase = constant.FdbBackupRunningStatus
+		status.StartTime = now
+		status.Message = ""
+		if err := r.updateRestoreStatus(restore, status); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: constant.FdbBackupInterval}, nil
+	}
+
+	state, err := agent.GetRestoreState(restore.Name)
+	if err != nil {
+		klog.Errorf("get state of restore %s failed: %v", restore.Name, err)
+		return ctrl.Result{}, err
+	}
+	switch state {
+	case "completed":
+		status.Phase = constant.FdbBackupCompletedStatus
+		status.Message = ""
+		klog.Infof("restore backup %s into threeFsCluster %s completed", restore.Spec.BackupName, threeFsCluster.Name)
+		r.Recorder.Event(restore, corev1.EventTypeNormal, "RestoreCompleted", fmt.Sprintf("restore backup %s into threeFsCluster %s completed", restore.Spec.BackupName, threeFsCluster.Name))
+	case "aborted":
+		status.Phase = constant.FdbBackupFailedStatus
+		status.Message = fmt.Sprintf("restore of backup %s is aborted", restore.Spec.BackupName)
+		r.Recorder.Event(restore, corev1.EventTypeWarning, "RestoreFailed", status.Message)
+	default:
+		status.Message = fmt.Sprintf("restore is %s", state)
+		if err := r.updateRestoreStatus(restore, status); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: constant.FdbBackupInterval}, nil
+	}
+	status.EndTime = now
+	if err := r.updateRestoreStatus(restore, status); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, DeleteFdbBackupAgent(r.Client, restore, "restore")
+}
+
+func (r *ThreeFsRestoreReconciler) waitRestore(restore *threefsv1.ThreeFsRestore, message string) (ctrl.Result, error) {
+	klog.Infof("threeFsRestore %s: %s", restore.Name, message)
+	status := restore.Status
+	if status.Phase == "" {
+		status.Phase = constant.FdbBackupPendingStatus
+	}
+	status.Message = message
+	if err := r.updateRestoreStatus(restore, status); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: constant.FdbBackupInterval}, nil
+}
+
+func (r *ThreeFsRestoreReconciler) updateRestoreStatus(restore *threefsv1.ThreeFsRestore, status threefsv1.ThreeFsRestoreStatus) error {
+	localCache := threefsv1.ThreeFsRestore{}
+	_ = r.Get(context.Background(), client.ObjectKey{Name: restore.Name, Namespace: restore.Namespace}, &localCache)
+
+	modifiedObj := localCache.DeepCopy()
+	modifiedObj.Status = status
+	restore.Status = status
+	if err := r.Client.Status().Patch(context.Background(), modifiedObj, client.MergeFrom(&localCache)); err != nil {
+		klog.Errorf("update threeFsRestore %s status failed: %v", restore.Name, err)
+		return err
+	}
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ThreeFsRestoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&threefsv1.ThreeFsRestore{}).
+		Owns(&appsv1.Deployment{}).
+		Complete(r)
+}
@@ -0,0 +1,70 @@
+package fdb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	fdbv1beta2 "github.com/FoundationDB/fdb-kubernetes-operator/api/v1beta2"
+	v1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	clientcomm "github.com/aliyun/kvc-3fs-operator/internal/client"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// IsExternalMode returns true if an existing fdb cluster is used
+func IsExternalMode(spec v1.FdbSpec) bool {
+	return spec.Mode == constant.FdbModeExternal
+}
+
+// ValidateExternal checks the external fdb cluster is only set in external mode
+func ValidateExternal(spec v1.FdbSpec) error {
+	if !IsExternalMode(spec) {
+		if spec.External != nil {
+			return fmt.Errorf("fdb external can only be set in %s mode", constant.FdbModeExternal)
+		}
+		return nil
+	}
+	external := spec.External
+	if external == nil || (external.ClusterFileSecretRef == nil) == (external.ConnectionString == "") {
+		return fmt.Errorf("one of fdb external clusterFileSecretRef and connectionString must be set in %s mode", constant.FdbModeExternal)
+	}
+	if external.ConnectionString != "" {
+		if _, err := fdbv1beta2.ParseConnectionString(strings.TrimSpace(external.ConnectionString)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetExternalConnectionString returns the connection string of the external fdb cluster
+func (fc *FdbConfig) GetExternalConnectionString(external *v1.FdbExternalSpec) (string, error) {
+	if external.ClusterFileSecretRef == nil {
+		return strings.TrimSpace(external.ConnectionString), nil
+	}
+	secret := &corev1.Secret{}
+	if err := fc.rclient.Get(context.Background(), client.ObjectKey{Name: external.ClusterFileSecretRef.Name, Namespace: fc.Namespace}, secret); err != nil {
+		klog.Errorf("get secret %s of fdb cluster file failed: %v", external.ClusterFileSecretRef.Name, err)
+		return "", err
+	}
+	content := strings.TrimSpace(string(secret.Data[external.ClusterFileSecretRef.Key]))
+	if _, err := fdbv1beta2.ParseConnectionString(content); err != nil {
+		return "", fmt.Errorf("secret %s key %s is not an fdb cluster file: %v", secret.Name, external.ClusterFileSecretRef.Key, err)
+	}
+	return content, nil
+}
+
+// ValidateExternalStatus checks the external fdb cluster is available and tolerates as many faults as storageReplicas
+func ValidateExternalStatus(details *fdbv1beta2.FoundationDBStatus, replicas int) error {
+	if !details.Client.DatabaseStatus.Available {
+		return fmt.Errorf("external fdb cluster is not available")
+	}
+	mode := details.Cluster.DatabaseConfiguration.RedundancyMode
+	desired := fdbv1beta2.RedundancyMode(clientcomm.GetRedundancyMode(replicas))
+	if fdbv1beta2.DesiredFaultTolerance(mode) < fdbv1beta2.DesiredFaultTolerance(desired) {
+		return fmt.Errorf("external fdb cluster redundancy mode %s is lower than %s", mode, desired)
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package fdb
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	fdbv1beta2 "github.com/FoundationDB/fdb-kubernetes-operator/api/v1beta2"
+	v1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	clientcomm "github.com/aliyun/kvc-3fs-operator/internal/client"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/aliyun/kvc-3fs-operator/internal/utils"
+	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// IsExternalOperatorMode returns true if fdb is managed by the FoundationDB kubernetes operator
+func IsExternalOperatorMode(spec v1.FdbSpec) bool {
+	return spec.Mode == constant.FdbModeExternalOperator
+}
+
+// IsBuiltinMode returns true if fdb deployments are run on fdb nodes by the operator
+func IsBuiltinMode(spec v1.FdbSpec) bool {
+	return spec.Mode == "" || spec.Mode == constant.FdbModeBuiltin
+}
+
+// ValidateMode checks the fdb mode
+func ValidateMode(spec v1.FdbSpec) error {
+	modes := []string{constant.FdbModeBuiltin, constant.FdbModeExternalOperator, constant.FdbModeExternal}
+	if !IsBuiltinMode(spec) && !utils.StrListContains(modes, spec.Mode) {
+		return fmt.Errorf("fdb mode %s is not supported, must be one of %s", spec.Mode, strings.Join(modes, ","))
+	}
+	return ValidateExternal(spec)
+}
+
+// getOperatorRoleCounts splits proxies into grv proxies and commit proxies like fdbcli does for proxies=N
+func getOperatorRoleCounts(roles v1.FdbRoleCounts) fdbv1beta2.RoleCounts {
+	counts := fdbv1beta2.RoleCounts{
+		Logs:      roles.Logs,
+		Resolvers: roles.Resolvers,
+	}
+	if roles.Proxies > 1 {
+		counts.GrvProxies = max(1, min(4, roles.Proxies/4))
+		counts.CommitProxies = roles.Proxies - counts.GrvProxies
+	}
+	return counts
+}
+
+// getOperatorProcessCounts returns process counts of the whole cluster, processes on each node are multiplied by
+// cluster size, one storage process on each node by default
+func getOperatorProcessCounts(spec v1.FdbSpec) fdbv1beta2.ProcessCounts {
+	if len(spec.Processes) == 0 {
+		return fdbv1beta2.ProcessCounts{Storage: spec.ClusterSize}
+	}
+	return fdbv1beta2.ProcessCounts{
+		Unset:       spec.Processes["unset"].Count * spec.ClusterSize,
+		Storage:     spec.Processes["storage"].Count * spec.ClusterSize,
+		Log:         spec.Processes["log"].Count * spec.ClusterSize,
+		Transaction: spec.Processes["transaction"].Count * spec.ClusterSize,
+		Stateless:   spec.Processes["stateless"].Count * spec.ClusterSize,
+	}
+}
+
+func getOperatorProcessSettings(resources corev1.ResourceRequirements) fdbv1beta2.ProcessSettings {
+	return fdbv1beta2.ProcessSettings{
+		PodTemplate: &corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{
+						Name:      fdbv1beta2.MainContainerName,
+						Resources: resources,
+					},
+				},
+			},
+		},
+	}
+}
+
+// GetOperatorClusterSpec returns the FoundationDBCluster spec of fdb spec
+func GetOperatorClusterSpec(spec v1.FdbSpec) fdbv1beta2.FoundationDBClusterSpec {
+	engine := spec.StorageEngine
+	if engine == "" {
+		engine = constant.DefaultFdbStorageEngine
+	}
+	processes := map[fdbv1beta2.ProcessClass]fdbv1beta2.ProcessSettings{
+		fdbv1beta2.ProcessClassGeneral: getOperatorProcessSettings(spec.Resources),
+	}
+	for class, process := range spec.Processes {
+		if process.Resources.Requests != nil || process.Resources.Limits != nil {
+			processes[fdbv1beta2.ProcessClass(class)] = getOperatorProcessSettings(process.Resources)
+		}
+	}
+	clusterSpec := fdbv1beta2.FoundationDBClusterSpec{
+		Version: GetFdbVersion(spec),
+		DatabaseConfiguration: fdbv1beta2.DatabaseConfiguration{
+			RedundancyMode: fdbv1beta2.RedundancyMode(clientcomm.GetRedundancyMode(spec.StorageReplicas)),
+			StorageEngine:  fdbv1beta2.StorageEngine(engine),
+			RoleCounts:     getOperatorRoleCounts(spec.RoleCounts),
+		},
+		Processes:     processes,
+		ProcessCounts: getOperatorProcessCounts(spec),
+	}
+	if spec.TopologyKey != "" {
+		clusterSpec.FaultDomain = fdbv1beta2.FoundationDBClusterFaultDomain{
+			Key: spec.TopologyKey,
+		}
+	}
+	return clusterSpec
+}
+
+// WithOperatorTLS enables tls of the FoundationDBCluster, certificates of the secret are mounted to the main
+// container and the sidecar of all process classes
+func WithOperatorTLS(clusterSpec *fdbv1beta2.FoundationDBClusterSpec, secretName, verifyPeers string) {
+	if secretName == "" {
+		return
+	}
+	clusterSpec.MainContainer.EnableTLS = true
+	clusterSpec.MainContainer.PeerVerificationRules = verifyPeers
+	clusterSpec.SidecarContainer.EnableTLS = true
+	clusterSpec.SidecarContainer.PeerVerificationRules = verifyPeers
+	for _, settings := range clusterSpec.Processes {
+		WithTLS(settings.PodTemplate, secretName, verifyPeers)
+		sidecar := corev1.Container{Name: fdbv1beta2.SidecarContainerName}
+		withTLSContainer(&sidecar, verifyPeers)
+		settings.PodTemplate.Spec.Containers = append(settings.PodTemplate.Spec.Containers, sidecar)
+	}
+}
+
+// isSameOperatorClusterSpec compares fields of FoundationDBCluster spec built from fdb spec
+func isSameOperatorClusterSpec(existing, desired *fdbv1beta2.FoundationDBClusterSpec) bool {
+	return existing.Version == desired.Version &&
+		reflect.DeepEqual(existing.DatabaseConfiguration, desired.DatabaseConfiguration) &&
+		reflect.DeepEqual(existing.Processes, desired.Processes) &&
+		existing.ProcessCounts == desired.ProcessCounts &&
+		existing.FaultDomain == desired.FaultDomain &&
+		reflect.DeepEqual(existing.MainContainer, desired.MainContainer) &&
+		reflect.DeepEqual(existing.SidecarContainer, desired.SidecarContainer)
+}
+
+// IsOperatorClusterReconciled returns true if the FoundationDBCluster is reconciled and available
+func IsOperatorClusterReconciled(cluster *fdbv1beta2.FoundationDBCluster) bool {
+	return cluster.Status.Generations.Reconciled == cluster.Generation &&
+		cluster.Status.ConnectionString != "" && cluster.Status.Health.Available
+}
+
+// CreateOrUpdateOperatorCluster creates the FoundationDBCluster owned by the ThreeFsCluster, or updates its spec
+// if fdb spec changes, returns the FoundationDBCluster and true if it is created or updated
+func (fc *FdbConfig) CreateOrUpdateOperatorCluster(owner *v1.ThreeFsCluster) (*fdbv1beta2.FoundationDBCluster, bool, error) {
+	desiredSpec := GetOperatorClusterSpec(owner.Spec.Fdb)
+	WithOperatorTLS(&desiredSpec, fc.TLSSecretName, fc.TLSVerifyPeers)
+	cluster := &fdbv1beta2.FoundationDBCluster{}
+	err := fc.rclient.Get(context.Background(), client.ObjectKey{Name: GetFdbDeployName(fc.Name), Namespace: fc.Namespace}, cluster)
+	if err == nil {
+		if isSameOperatorClusterSpec(&cluster.Spec, &desiredSpec) {
+			return cluster, false, nil
+		}
+		klog.Infof("update FoundationDBCluster %s", cluster.Name)
+		cluster.Spec.Version = desiredSpec.Version
+		cluster.Spec.DatabaseConfiguration = desiredSpec.DatabaseConfiguration
+		cluster.Spec.Processes = desiredSpec.Processes
+		cluster.Spec.ProcessCounts = desiredSpec.ProcessCounts
+		cluster.Spec.FaultDomain = desiredSpec.FaultDomain
+		cluster.Spec.MainContainer = desiredSpec.MainContainer
+		cluster.Spec.SidecarContainer = desiredSpec.SidecarContainer
+		if err := fc.rclient.Update(context.Background(), cluster); err != nil {
+			klog.Errorf("update FoundationDBCluster %s failed: %v", cluster.Name, err)
+			return nil, false, err
+		}
+		return cluster, true, nil
+	} else if !k8serror.IsNotFound(err) {
+		klog.Errorf("get FoundationDBCluster %s failed: %v", GetFdbDeployName(fc.Name), err)
+		return nil, false, err
+	}
+
+	cluster = &fdbv1beta2.FoundationDBCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetFdbDeployName(fc.Name),
+			Namespace: fc.Namespace,
+			Labels: map[string]string{
+				constant.ThreeFSFdbDeployKey: fc.Name,
+			},
+		},
+		Spec: desiredSpec,
+	}
+	if err := controllerutil.SetControllerReference(owner, cluster, fc.schema); err != nil {
+		klog.Errorf("set owner of FoundationDBCluster %s failed: %v", cluster.Name, err)
+		return nil, false, err
+	}
+	klog.Infof("create FoundationDBCluster %s", cluster.Name)
+	if err := fc.rclient.Create(context.Background(), cluster); err != nil {
+		klog.Errorf("create FoundationDBCluster %s failed: %v", cluster.Name, err)
+		return nil, false, err
+	}
+	return cluster, true, nil
+}
+
+func (fc *FdbConfig) DeleteOperatorClusterIfExist() error {
+	cluster := &fdbv1beta2.FoundationDBCluster{}
+	if err := fc.rclient.Get(context.Background(), client.ObjectKey{Name: GetFdbDeployName(fc.Name), Namespace: fc.Namespace}, cluster); err != nil {
+		if k8serror.IsNotFound(err) {
+			return nil
+		}
+		klog.Errorf("get FoundationDBCluster %s failed: %v", GetFdbDeployName(fc.Name), err)
+		return err
+	}
+	if err := fc.rclient.Delete(context.Background(), cluster); err != nil {
+		klog.Errorf("delete FoundationDBCluster %s failed: %v", cluster.Name, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package fdb
+
+import (
+	"context"
+	"testing"
+
+	fdbv1beta2 "github.com/FoundationDB/fdb-kubernetes-operator/api/v1beta2"
+	v1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestGetOperatorClusterSpec(t *testing.T) {
+	spec := GetOperatorClusterSpec(v1.FdbSpec{ClusterSize: 3, StorageReplicas: 3})
+	assert.Equal(t, constant.DefaultFdbVersion, spec.Version)
+	assert.Equal(t, fdbv1beta2.RedundancyMode("triple"), spec.DatabaseConfiguration.RedundancyMode)
+	assert.Equal(t, fdbv1beta2.StorageEngine(constant.DefaultFdbStorageEngine), spec.DatabaseConfiguration.StorageEngine)
+	assert.Equal(t, fdbv1beta2.RoleCounts{}, spec.DatabaseConfiguration.RoleCounts)
+	assert.Equal(t, fdbv1beta2.ProcessCounts{Storage: 3}, spec.ProcessCounts)
+	assert.Len(t, spec.Processes, 1)
+	assert.Equal(t, fdbv1beta2.FoundationDBClusterFaultDomain{}, spec.FaultDomain)
+
+	logResources := corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("8Gi")}}
+	spec = GetOperatorClusterSpec(v1.FdbSpec{
+		ClusterSize:     5,
+		StorageReplicas: 2,
+		StorageEngine:   "ssd-redwood-1",
+		Version:         "7.1.61",
+		TopologyKey:     "topology.kubernetes.io/zone",
+		RoleCounts:      v1.FdbRoleCounts{Logs: 3, Proxies: 8, Resolvers: 1},
+		Processes: map[string]v1.FdbProcessSpec{
+			"storage": {Count: 2},
+			"log":     {Count: 1, Resources: logResources},
+		},
+	})
+	assert.Equal(t, "7.1.61", spec.Version)
+	assert.Equal(t, fdbv1beta2.RedundancyMode("double"), spec.DatabaseConfiguration.RedundancyMode)
+	assert.Equal(t, fdbv1beta2.StorageEngine("ssd-redwood-1"), spec.DatabaseConfiguration.StorageEngine)
+	assert.Equal(t, fdbv1beta2.RoleCounts{Logs: 3, Resolvers: 1, GrvProxies: 2, CommitProxies: 6}, spec.DatabaseConfiguration.RoleCounts)
+	assert.Equal(t, fdbv1beta2.ProcessCounts{Storage: 10, Log: 5}, spec.ProcessCounts)
+	assert.Equal(t, "topology.kubernetes.io/zone", spec.FaultDomain.Key)
+	assert.Len(t, spec.Processes, 2)
+	assert.Equal(t, logResources, spec.Processes[fdbv1beta2.ProcessClassLog].PodTemplate.Spec.Containers[0].Resources)
+}
+
+func TestGetOperatorRoleCounts(t *testing.T) {
+	cases := []struct {
+		proxies       int
+		grvProxies    int
+		commitProxies int
+	}{
+		{proxies: 0, grvProxies: 0, commitProxies: 0},
+		{proxies: 1, grvProxies: 0, commitProxies: 0},
+		{proxies: 3, grvProxies: 1, commitProxies: 2},
+		{proxies: 8, grvProxies: 2, commitProxies: 6},
+		{proxies: 32, grvProxies: 4, commitProxies: 28},
+	}
+	for _, c := range cases {
+		counts := getOperatorRoleCounts(v1.FdbRoleCounts{Proxies: c.proxies})
+		assert.Equal(t, c.grvProxies, counts.GrvProxies, "proxies %d", c.proxies)
+		assert.Equal(t, c.commitProxies, counts.CommitProxies, "proxies %d", c.proxies)
+	}
+}
+
+func TestIsOperatorClusterReconciled(t *testing.T) {
+	newCluster := func(generation, reconciled int64, connectionString string, available bool) *fdbv1beta2.FoundationDBCluster {
+		cluster := &fdbv1beta2.FoundationDBCluster{}
+		cluster.Generation = generation
+		cluster.Status.Generations.Reconciled = reconciled
+		cluster.Status.ConnectionString = connectionString
+		cluster.Status.Health.Available = available
+		return cluster
+	}
+	assert.True(t, IsOperatorClusterReconciled(newCluster(2, 2, "test:abc@10.0.0.1:4500", true)))
+	assert.False(t, IsOperatorClusterReconciled(newCluster(2, 1, "test:abc@10.0.0.1:4500", true)))
+	assert.False(t, IsOperatorClusterReconciled(newCluster(2, 2, "", true)))
+	assert.False(t, IsOperatorClusterReconciled(newCluster(2, 2, "test:abc@10.0.0.1:4500", false)))
+}
+
+func TestCreateOrUpdateOperatorCluster(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = v1.AddToScheme(scheme)
+	_ = fdbv1beta2.AddToScheme(scheme)
+	rclient := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	owner := &v1.ThreeFsCluster{}
+	owner.Name = "test"
+	owner.Namespace = "default"
+	owner.UID = "uid"
+	owner.Spec.Fdb = v1.FdbSpec{Mode: constant.FdbModeExternalOperator, ClusterSize: 3, StorageReplicas: 3}
+	fc := NewFdbConfig("test", "default", 3, 3, nil, 4500, corev1.ResourceRequirements{}, rclient, nil, nil, scheme)
+
+	cluster, changed, err := fc.CreateOrUpdateOperatorCluster(owner)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, GetFdbDeployName("test"), cluster.Name)
+	assert.Equal(t, "test", cluster.Labels[constant.ThreeFSFdbDeployKey])
+	assert.Equal(t, "test", cluster.OwnerReferences[0].Name)
+
+	_, changed, err = fc.CreateOrUpdateOperatorCluster(owner)
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	owner.Spec.Fdb.ClusterSize = 5
+	_, changed, err = fc.CreateOrUpdateOperatorCluster(owner)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	latest := &fdbv1beta2.FoundationDBCluster{}
+	assert.NoError(t, rclient.Get(context.Background(), client.ObjectKey{Name: GetFdbDeployName("test"), Namespace: "default"}, latest))
+	assert.Equal(t, 5, latest.Spec.ProcessCounts.Storage)
+
+	assert.NoError(t, fc.DeleteOperatorClusterIfExist())
+	assert.NoError(t, fc.DeleteOperatorClusterIfExist())
+}
@@ -0,0 +1,267 @@
+package fdb
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	v1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/aliyun/kvc-3fs-operator/internal/native_resources"
+	"github.com/aliyun/kvc-3fs-operator/internal/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// FdbProcessClasses are supported process classes, ports of a class start from port + index*FdbProcessPortStep
+var FdbProcessClasses = []string{"unset", "storage", "log", "transaction", "stateless"}
+
+var FdbStorageEngines = []string{"ssd", "ssd-2", "ssd-redwood-1", "ssd-rocksdb-v1", "ssd-sharded-rocksdb", "memory"}
+
+const fdbDataMountPath = "/var/lib/foundationdb/data"
+
+type FdbProcess struct {
+	Class     string
+	Port      int
+	MountPath string
+	Memory    string
+}
+
+func ValidateStorageEngine(engine string) error {
+	if engine != "" && !utils.StrListContains(FdbStorageEngines, engine) {
+		return fmt.Errorf("fdb storage engine %s is not supported, must be one of %s", engine, strings.Join(FdbStorageEngines, ","))
+	}
+	return nil
+}
+
+func ValidateProcesses(processes map[string]v1.FdbProcessSpec) error {
+	if len(processes) == 0 {
+		return nil
+	}
+	storageNum := 0
+	for class, process := range processes {
+		if !utils.StrListContains(FdbProcessClasses, class) {
+			return fmt.Errorf("fdb process class %s is not supported, must be one of %s", class, strings.Join(FdbProcessClasses, ","))
+		}
+		if process.Count < 0 || process.Count > constant.FdbProcessPortStep {
+			return fmt.Errorf("fdb %s process count must be between 0 and %d", class, constant.FdbProcessPortStep)
+		}
+		for _, path := range process.DataPaths {
+			if !filepath.IsAbs(path) {
+				return fmt.Errorf("fdb %s process data path %s must be absolute", class, path)
+			}
+		}
+		if class == "storage" || class == "unset" {
+			storageNum += process.Count
+		}
+	}
+	if storageNum == 0 {
+		return fmt.Errorf("fdb processes must contain storage or unset processes")
+	}
+	return nil
+}
+
+// GetDataPaths returns host paths of fdb data, the default path is always the first one
+func GetDataPaths(processes map[string]v1.FdbProcessSpec) []string {
+	paths := []string{constant.DefaultFdbDataPath}
+	for _, class := range FdbProcessClasses {
+		for _, path := range processes[class].DataPaths {
+			if !utils.StrListContains(paths, path) {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+func getDataMountPath(idx int) string {
+	if idx == 0 {
+		return fdbDataMountPath
+	}
+	return fmt.Sprintf("%s-%d", fdbDataMountPath, idx)
+}
+
+func getDataVolumeName(idx int) string {
+	if idx == 0 {
+		return "data"
+	}
+	return fmt.Sprintf("data-%d", idx)
+}
+
+// GetProcesses returns fdb processes on each node ordered by class and port
+func GetProcesses(processes map[string]v1.FdbProcessSpec, port int) []FdbProcess {
+	dataPaths := GetDataPaths(processes)
+	result := make([]FdbProcess, 0)
+	for classIdx, class := range FdbProcessClasses {
+		spec, ok := processes[class]
+		if !ok {
+			continue
+		}
+		memory := ""
+		if limit, ok := spec.Resources.Limits[corev1.ResourceMemory]; ok {
+			memory = fmt.Sprintf("%dMiB", limit.Value()>>20)
+		}
+		for i := 0; i < spec.Count; i++ {
+			mountPath := getDataMountPath(0)
+			if len(spec.DataPaths) > 0 {
+				path := spec.DataPaths[i%len(spec.DataPaths)]
+				for idx, dataPath := range dataPaths {
+					if dataPath == path {
+						mountPath = getDataMountPath(idx)
+					}
+				}
+			}
+			result = append(result, FdbProcess{
+				Class:     class,
+				Port:      port + classIdx*constant.FdbProcessPortStep + i,
+				MountPath: mountPath,
+				Memory:    memory,
+			})
+		}
+	}
+	return result
+}
+
+// GetCoordinatorPort returns the port of initial coordinators in the cluster file, the first storage or unset process
+// on each node, port if no processes are configured. port itself is only listened on by unset processes
+func GetCoordinatorPort(processes map[string]v1.FdbProcessSpec, port int) int {
+	for _, process := range GetProcesses(processes, port) {
+		if process.Class == "unset" || process.Class == "storage" {
+			return process.Port
+		}
+	}
+	return port
+}
+
+// GetMonitorConf renders foundationdb.conf for fdbmonitor, ${PUBLIC_IP} and ${MACHINE_ID} are replaced by entrypoint
+func GetMonitorConf(processes []FdbProcess) string {
+	var builder strings.Builder
+	builder.WriteString("[fdbmonitor]\nuser = root\ngroup = root\n\n")
+	builder.WriteString(fmt.Sprintf("[general]\nrestart-delay = 60\ncluster-file = %s\n\n", constant.DefaultThreeFSFdbConfigPath))
+	builder.WriteString("[fdbserver]\ncommand = /usr/sbin/fdbserver\npublic-address = ${PUBLIC_IP}:$ID\nlisten-address = 0.0.0.0:$ID\n")
+	builder.WriteString("logdir = /var/log/foundationdb\nlocality-zoneid = ${MACHINE_ID}\nlocality-machineid = ${MACHINE_ID}\n")
+	for _, process := range processes {
+		builder.WriteString(fmt.Sprintf("\n[fdbserver.%d]\nclass = %s\ndatadir = %s/$ID\n", process.Port, process.Class, process.MountPath))
+		if process.Memory != "" {
+			builder.WriteString(fmt.Sprintf("memory = %s\n", process.Memory))
+		}
+	}
+	return builder.String()
+}
+
+// GetProcessResources returns resources of the fdb container, the sum of all processes
+func GetProcessResources(processes map[string]v1.FdbProcessSpec, defaultResources corev1.ResourceRequirements) corev1.ResourceRequirements {
+	resources := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{},
+		Limits:   corev1.ResourceList{},
+	}
+	add := func(list, to corev1.ResourceList, count int) {
+		for name, quantity := range list {
+			total := to[name]
+			for i := 0; i < count; i++ {
+				total.Add(quantity)
+			}
+			to[name] = total
+		}
+	}
+	for _, class := range FdbProcessClasses {
+		spec, ok := processes[class]
+		if !ok {
+			continue
+		}
+		classResources := spec.Resources
+		if classResources.Requests == nil && classResources.Limits == nil {
+			classResources = defaultResources
+		}
+		add(classResources.Requests, resources.Requests, spec.Count)
+		add(classResources.Limits, resources.Limits, spec.Count)
+	}
+	return resources
+}
+
+func (fc *FdbConfig) WithProcesses(processes map[string]v1.FdbProcessSpec) *FdbConfig {
+	fc.Processes = processes
+	return fc
+}
+
+func isSameResources(a, b corev1.ResourceList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, quantity := range a {
+		if other, ok := b[name]; !ok || quantity.Cmp(other) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func isSameProcesses(existing, desired *appsv1.Deployment) bool {
+	if len(existing.Spec.Template.Spec.Containers) == 0 || len(desired.Spec.Template.Spec.Containers) == 0 {
+		return false
+	}
+	getConf := func(container corev1.Container) string {
+		for _, env := range container.Env {
+			if env.Name == constant.ENVFdbMonitorConf {
+				return env.Value
+			}
+		}
+		return ""
+	}
+	existingContainer := existing.Spec.Template.Spec.Containers[0]
+	desiredContainer := desired.Spec.Template.Spec.Containers[0]
+	if getConf(existingContainer) != getConf(desiredContainer) {
+		return false
+	}
+	if !isSameResources(existingContainer.Resources.Requests, desiredContainer.Resources.Requests) ||
+		!isSameResources(existingContainer.Resources.Limits, desiredContainer.Resources.Limits) {
+		return false
+	}
+	return reflect.DeepEqual(existingContainer.VolumeMounts, desiredContainer.VolumeMounts)
+}
+
+// UpdateDeployProcesses updates fdb deployments with changed processes one by one,
+// returns the updated deployment and true until all deployments are updated and ready
+func (fc *FdbConfig) UpdateDeployProcesses(content string) (string, bool, error) {
+	deployList := &appsv1.DeploymentList{}
+	if err := fc.rclient.List(context.Background(), deployList, client.InNamespace(fc.Namespace), client.MatchingLabels{constant.ThreeFSFdbDeployKey: fc.Name}); err != nil {
+		klog.Errorf("list deployment %s failed: %v", GetFdbDeployName(fc.Name), err)
+		return "", false, err
+	}
+	for _, deploy := range deployList.Items {
+		if deploy.Status.ObservedGeneration < deploy.Generation || deploy.Status.UpdatedReplicas < 1 || deploy.Status.ReadyReplicas < 1 {
+			klog.Infof("fdb deployment %s is not ready, wait", deploy.Name)
+			return "", true, nil
+		}
+	}
+
+	for _, deploy := range deployList.Items {
+		nodeName := deploy.Spec.Template.Spec.NodeSelector[constant.KubernetesHostnameKey]
+		if nodeName == "" {
+			continue
+		}
+		fc.Deploys[nodeName] = native_resources.NewDeployConfig()
+		desired := fc.WithDeployMeta(nodeName).
+			WithDeploySpec(nodeName).
+			WithDeployVolumes(nodeName).
+			WithDeployContainers(nodeName, content).Deploys[nodeName].Deployment
+		if isSameProcesses(&deploy, desired) {
+			continue
+		}
+
+		klog.Infof("update processes of fdb deployment %s", deploy.Name)
+		newDeploy := deploy.DeepCopy()
+		newDeploy.Spec.Template.Spec.Volumes = desired.Spec.Template.Spec.Volumes
+		newDeploy.Spec.Template.Spec.Containers = desired.Spec.Template.Spec.Containers
+		if err := fc.rclient.Update(context.Background(), newDeploy); err != nil {
+			klog.Errorf("update deployment %s failed: %v", deploy.Name, err)
+			return "", false, err
+		}
+		return deploy.Name, true, nil
+	}
+	return "", false, nil
+}
@@ -0,0 +1,81 @@
+package fdb
+
+import (
+	"testing"
+
+	v1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetProcessesPorts(t *testing.T) {
+	cases := []struct {
+		name      string
+		processes map[string]v1.FdbProcessSpec
+		ports     []int
+	}{
+		{
+			name:      "default",
+			processes: nil,
+			ports:     []int{},
+		},
+		{
+			name:      "unset",
+			processes: map[string]v1.FdbProcessSpec{"unset": {Count: 2}},
+			ports:     []int{4500, 4501},
+		},
+		{
+			name: "classes",
+			processes: map[string]v1.FdbProcessSpec{
+				"stateless":   {Count: 1},
+				"storage":     {Count: 2},
+				"log":         {Count: 1},
+				"transaction": {Count: 0},
+			},
+			ports: []int{4510, 4511, 4520, 4540},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ports := make([]int, 0)
+			for _, process := range GetProcesses(c.processes, 4500) {
+				ports = append(ports, process.Port)
+			}
+			assert.Equal(t, c.ports, ports)
+		})
+	}
+}
+
+func TestGetCoordinatorPort(t *testing.T) {
+	// processes of docs/examples/threefscluster.yaml, no unset process listens on spec.fdb.port
+	example := map[string]v1.FdbProcessSpec{
+		"storage":   {Count: 2, DataPaths: []string{"/nvme0/fdb", "/nvme1/fdb"}},
+		"log":       {Count: 1},
+		"stateless": {Count: 1},
+	}
+	cases := []struct {
+		name      string
+		processes map[string]v1.FdbProcessSpec
+		port      int
+	}{
+		{name: "default", processes: nil, port: 4500},
+		{name: "unset", processes: map[string]v1.FdbProcessSpec{"unset": {Count: 1}, "storage": {Count: 1}}, port: 4500},
+		{name: "example", processes: example, port: 4510},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			port := GetCoordinatorPort(c.processes, 4500)
+			assert.Equal(t, c.port, port)
+			if len(c.processes) == 0 {
+				return
+			}
+			listened := false
+			for _, process := range GetProcesses(c.processes, 4500) {
+				if process.Port == port {
+					listened = true
+					assert.Contains(t, []string{"unset", "storage"}, process.Class)
+				}
+			}
+			assert.True(t, listened, "no process listens on coordinator port %d", port)
+		})
+	}
+}
@@ -32,6 +32,12 @@ type FdbConfig struct {
 	ClusterSize     int
 	ConfigContent   string // disabled
 	Resources       corev1.ResourceRequirements
+	Processes       map[string]v1.FdbProcessSpec
+	CreatedNodes    []string // nodes with fdb deployments created in this reconcile
+	Version         string   // fdb version of images of fdb deployments
+	ClientVersions  []string // fdb versions of client libraries staged for mgmtd and meta
+	TLSSecretName   string   // secret of fdb tls certificates, empty if tls is disabled
+	TLSVerifyPeers  string
 	DsConfig        *native_resources.DsConfig
 	Deploys         map[string]*native_resources.DelpoyConfig
 	rclient         client.Client
@@ -211,19 +217,27 @@ func (fc *FdbConfig) CreateFdbConfigIfNotExist(existingContent string) error {
 		klog.Infof("configmap %s exist: %v", GetFdbDeployName(fc.Name), err)
 	}
 
-	nodeIps := make([]string, fc.ClusterSize)
-	for idx, node := range fc.Nodes[:fc.ClusterSize] {
-		pc := native_resources.NewNodeConfig(fc.rclient)
-		nodeIp, err := pc.ParseNodeIp(node)
-		if err != nil {
-			klog.Errorf("get node %s ip failed: %v", node, err)
-			return err
+	// use the existing content, e.g. the connection string of a FoundationDBCluster
+	content := existingContent
+	if content == "" {
+		nodeIps := make([]string, fc.ClusterSize)
+		port := GetCoordinatorPort(fc.Processes, fc.Port)
+		for idx, node := range fc.Nodes[:fc.ClusterSize] {
+			pc := native_resources.NewNodeConfig(fc.rclient)
+			nodeIp, err := pc.ParseNodeIp(node)
+			if err != nil {
+				klog.Errorf("get node %s ip failed: %v", node, err)
+				return err
+			}
+			nodeIps[idx] = fmt.Sprintf("%s:%d", nodeIp, port)
 		}
-		nodeIps[idx] = fmt.Sprintf("%s:%d", nodeIp, fc.Port)
-	}
 
-	content := fmt.Sprintf("%s:%s@%s", utils.GenerateUuidWithLen(10),
-		utils.GenerateUuidWithLen(10), strings.Join(nodeIps, ","))
+		content = fmt.Sprintf("%s:%s@%s", utils.GenerateUuidWithLen(10),
+			utils.GenerateUuidWithLen(10), strings.Join(nodeIps, ","))
+		if fc.TLSSecretName != "" {
+			content = WithTLSAddresses(content)
+		}
+	}
 
 	fdbConfig := native_resources.NewConfigmapConfig(fc.rclient).
 		WithMeta(GetFdbDeployName(fc.Name), fc.Namespace).
@@ -256,20 +270,14 @@ func (fc *FdbConfig) DeleteFdbConfigIfExist() error {
 }
 
 func (fc *FdbConfig) DeleteDeployIfExist() error {
-	for _, node := range fc.Nodes {
-		deploy := appsv1.Deployment{}
-		deployName := fmt.Sprintf("%s-%s", GetFdbDeployName(fc.Name), utils.TranslatePlainNodeNameValid(node))
-		if err := fc.rclient.Get(context.Background(), client.ObjectKey{Name: deployName, Namespace: fc.Namespace}, &deploy); err != nil {
-			if k8serror.IsNotFound(err) {
-				klog.Infof("deployment %s already deleted", deployName)
-				continue
-			}
-			klog.Errorf("get deployment %s err: %+v", deployName, err)
-			return err
-		}
-
-		if err := fc.rclient.Delete(context.Background(), &deploy); err != nil {
-			klog.Errorf("delete deployment %s failed: %v", deployName, err)
+	deployList := &appsv1.DeploymentList{}
+	if err := fc.rclient.List(context.Background(), deployList, client.InNamespace(fc.Namespace), client.MatchingLabels{constant.ThreeFSFdbDeployKey: fc.Name}); err != nil {
+		klog.Errorf("list deployment %s failed: %v", GetFdbDeployName(fc.Name), err)
+		return err
+	}
+	for _, deploy := range deployList.Items {
+		if err := fc.rclient.Delete(context.Background(), &deploy); err != nil && !k8serror.IsNotFound(err) {
+			klog.Errorf("delete deployment %s failed: %v", deploy.Name, err)
 			return err
 		}
 	}
@@ -308,12 +316,12 @@ func (fc *FdbConfig) CreateDeployIfNotExist() error {
 		}
 	}
 
-	if existingDeploy == deployNum {
+	if existingDeploy >= deployNum {
 		klog.Infof("deployment num is satisfied, skip create")
 		return nil
 	}
 	for _, node := range fc.Nodes {
-		if existingDeploy == deployNum {
+		if existingDeploy >= deployNum {
 			klog.Infof("deployment num is satisfied, skip create")
 			break
 		}
@@ -335,42 +343,54 @@ func (fc *FdbConfig) CreateDeployIfNotExist() error {
 			klog.Errorf("create deployment %s failed: %v", deployName, err)
 			return err
 		}
+		fc.CreatedNodes = append(fc.CreatedNodes, node)
 		existingDeploy++
 	}
 
-	// delete deploy outside nodes
+	// deployments outside nodes or beyond cluster size are removed by exclusion in controller
+	return nil
+}
+
+// GetSurplusNodes returns nodes of fdb deployments to remove, deployments outside fdb nodes
+// and deployments on last nodes beyond cluster size
+func (fc *FdbConfig) GetSurplusNodes() ([]string, error) {
+	deployList := &appsv1.DeploymentList{}
 	if err := fc.rclient.List(context.Background(), deployList, client.InNamespace(fc.Namespace), client.MatchingLabels{constant.ThreeFSFdbDeployKey: fc.Name}); err != nil {
 		klog.Errorf("list deployment %s failed: %v", GetFdbDeployName(fc.Name), err)
+		return nil, err
 	}
-	count := len(deployList.Items)
+	surplusNodes := make([]string, 0)
+	inNodes := make([]string, 0)
 	for _, deploy := range deployList.Items {
 		deployNodeName := deploy.Spec.Template.Spec.NodeSelector[constant.KubernetesHostnameKey]
-		if !utils.StrListContains(fc.Nodes, deployNodeName) {
-			if err := fc.rclient.Delete(context.Background(), &deploy); err != nil {
-				klog.Errorf("delete deployment %s failed: %v", deploy.Name, err)
-				return err
-			}
-			count--
+		if utils.StrListContains(fc.Nodes, deployNodeName) {
+			inNodes = append(inNodes, deployNodeName)
+		} else {
+			surplusNodes = append(surplusNodes, deployNodeName)
 		}
 	}
+	if len(inNodes) > fc.ClusterSize {
+		sort.Sort(sort.Reverse(sort.StringSlice(inNodes)))
+		surplusNodes = append(surplusNodes, inNodes[:len(inNodes)-fc.ClusterSize]...)
+	}
+	sort.Strings(surplusNodes)
+	return surplusNodes, nil
+}
 
-	// delete deploy in nodes
-	if count > fc.ClusterSize {
-		for _, deploy := range deployList.Items {
-			deployNodeName := deploy.Spec.Template.Spec.NodeSelector[constant.KubernetesHostnameKey]
-			if utils.StrListContains(fc.Nodes, deployNodeName) {
-				if err := fc.rclient.Delete(context.Background(), &deploy); err != nil {
-					klog.Errorf("delete deployment %s failed: %v", deploy.Name, err)
-					return err
-				}
-				count--
-				if count == fc.ClusterSize {
-					break
-				}
-			}
+func (fc *FdbConfig) DeleteNodeDeployIfExist(nodeName string) error {
+	deploy := appsv1.Deployment{}
+	deployName := fmt.Sprintf("%s-%s", GetFdbDeployName(fc.Name), utils.TranslatePlainNodeNameValid(nodeName))
+	if err := fc.rclient.Get(context.Background(), client.ObjectKey{Name: deployName, Namespace: fc.Namespace}, &deploy); err != nil {
+		if k8serror.IsNotFound(err) {
+			return nil
 		}
+		klog.Errorf("get deployment %s err: %+v", deployName, err)
+		return err
+	}
+	if err := fc.rclient.Delete(context.Background(), &deploy); err != nil && !k8serror.IsNotFound(err) {
+		klog.Errorf("delete deployment %s failed: %v", deployName, err)
+		return err
 	}
-
 	return nil
 }
 
@@ -414,15 +434,17 @@ func (fc *FdbConfig) WithDeployVolumes(nodeName string) *FdbConfig {
 				},
 			},
 		},
-		{
-			Name: "data",
+	}
+	for idx, path := range GetDataPaths(fc.Processes) {
+		volumes = append(volumes, corev1.Volume{
+			Name: getDataVolumeName(idx),
 			VolumeSource: corev1.VolumeSource{
 				HostPath: &corev1.HostPathVolumeSource{
-					Path: "/opt/3fs/fdb/data",
+					Path: path,
 					Type: &HostPathDirectoryOrCreate,
 				},
 			},
-		},
+		})
 	}
 	fc.Deploys[nodeName] = fc.Deploys[nodeName].WithVolumes(volumes)
 	return fc
@@ -445,7 +467,7 @@ func (fc *FdbConfig) CheckResources() corev1.ResourceRequirements {
 }
 
 func (fc *FdbConfig) WithDeployContainers(nodeName, content string) *FdbConfig {
-	monitorImage := os.Getenv("FDB_IMAGE")
+	monitorImage := GetFdbImage(fc.Version)
 
 	ports := []corev1.ContainerPort{
 		{
@@ -492,12 +514,36 @@ func (fc *FdbConfig) WithDeployContainers(nodeName, content string) *FdbConfig {
 		},
 	}
 
+	resources := fc.CheckResources()
+	if len(fc.Processes) > 0 {
+		processes := GetProcesses(fc.Processes, fc.Port)
+		ports = make([]corev1.ContainerPort, 0, len(processes))
+		for _, process := range processes {
+			ports = append(ports, corev1.ContainerPort{
+				Name:          fmt.Sprintf("fdb-%d", process.Port),
+				ContainerPort: int32(process.Port),
+			})
+		}
+		envs = append(envs, corev1.EnvVar{
+			Name:  constant.ENVFdbMonitorConf,
+			Value: GetMonitorConf(processes),
+		})
+		for idx := range GetDataPaths(fc.Processes)[1:] {
+			volumeMount = append(volumeMount, corev1.VolumeMount{
+				Name:      getDataVolumeName(idx + 1),
+				MountPath: getDataMountPath(idx + 1),
+			})
+		}
+		resources = GetProcessResources(fc.Processes, resources)
+	}
+
 	command := []string{
 		"/tini",
 		"-g", "--", "/entrypoint.sh",
 	}
 	fc.Deploys[nodeName] = fc.Deploys[nodeName].
-		WithContainer("fdb", monitorImage, envs, nil, ports, fc.CheckResources(), volumeMount, command)
+		WithContainer("fdb", monitorImage, envs, nil, ports, resources, volumeMount, command)
+	WithTLS(&fc.Deploys[nodeName].Deployment.Spec.Template, fc.TLSSecretName, fc.TLSVerifyPeers)
 
 	return fc
 }
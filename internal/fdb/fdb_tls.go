@@ -0,0 +1,390 @@
+package fdb
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	v1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	clientcomm "github.com/aliyun/kvc-3fs-operator/internal/client"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	tlsVolumeName = "fdb-tls"
+	tlsCertKey    = "tls.crt"
+	tlsKeyKey     = "tls.key"
+	tlsCAKey      = "ca.crt"
+	tlsCAKeyKey   = "ca.key"
+)
+
+// CertificateGVK is the cert-manager Certificate referenced by fdb tls
+var CertificateGVK = schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}
+
+// IsTLSEnabled returns true if fdb runs with tls
+func IsTLSEnabled(spec v1.FdbSpec) bool {
+	return spec.TLS != nil && spec.TLS.Enabled
+}
+
+// GetTLSVerifyPeers returns the fdb tls peer verification, DefaultFdbTLSVerifyPeers if not set
+func GetTLSVerifyPeers(spec v1.FdbSpec) string {
+	if spec.TLS == nil || spec.TLS.VerifyPeers == "" {
+		return constant.DefaultFdbTLSVerifyPeers
+	}
+	return spec.TLS.VerifyPeers
+}
+
+// GetTLSSecretName returns the secret of certificates generated by the operator
+func GetTLSSecretName(name string) string {
+	return fmt.Sprintf("%s-%s", GetFdbDeployName(name), "tls")
+}
+
+// ValidateTLS checks fdb tls, certificates of an external fdb cluster can not be generated by the operator
+func ValidateTLS(spec v1.FdbSpec) error {
+	if spec.TLS == nil {
+		return nil
+	}
+	if !spec.TLS.Enabled && (spec.TLS.CertificateRef != "" || spec.TLS.VerifyPeers != "") {
+		return fmt.Errorf("fdb tls certificateRef and verifyPeers can only be set if tls is enabled")
+	}
+	if spec.TLS.Enabled && IsExternalMode(spec) && spec.TLS.CertificateRef == "" {
+		return fmt.Errorf("fdb tls certificateRef must be set in %s mode", constant.FdbModeExternal)
+	}
+	return nil
+}
+
+// WithTLSAddresses marks coordinators of the connection string as tls addresses
+func WithTLSAddresses(content string) string {
+	idx := strings.Index(content, "@")
+	if idx < 0 {
+		return content
+	}
+	addresses := strings.Split(content[idx+1:], ",")
+	for i, address := range addresses {
+		if !strings.HasSuffix(address, ":tls") {
+			addresses[i] = address + ":tls"
+		}
+	}
+	return content[:idx+1] + strings.Join(addresses, ",")
+}
+
+// GetTLSDir returns the directory of certificates used by fdbcli of the operator
+func GetTLSDir(namespace, name string) string {
+	return filepath.Join(constant.DefaultThreeFSFdbTLSPath, namespace, name)
+}
+
+// GetTLSOptions returns tls options of fdb tools reading certificates in dir
+func GetTLSOptions(dir, verifyPeers string) *clientcomm.FdbTLSOptions {
+	return &clientcomm.FdbTLSOptions{
+		CertificateFile: filepath.Join(dir, tlsCertKey),
+		KeyFile:         filepath.Join(dir, tlsKeyKey),
+		CAFile:          filepath.Join(dir, tlsCAKey),
+		VerifyPeers:     verifyPeers,
+	}
+}
+
+// WriteTLSFiles writes certificates of the secret data to dir, files are replaced by rename so fdbcli never
+// reads a partially written certificate
+func WriteTLSFiles(dir string, data map[string][]byte) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		klog.Errorf("create directory %s failed: %v", dir, err)
+		return err
+	}
+	for _, key := range []string{tlsCertKey, tlsKeyKey, tlsCAKey} {
+		path := filepath.Join(dir, key)
+		if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, data[key]) {
+			continue
+		}
+		if err := os.WriteFile(path+".tmp", data[key], 0600); err != nil {
+			klog.Errorf("write file %s failed: %v", path, err)
+			return err
+		}
+		if err := os.Rename(path+".tmp", path); err != nil {
+			klog.Errorf("rename file %s failed: %v", path, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// WithTLS mounts certificates of the secret to the pod and sets fdb tls env of the first container, fdb processes
+// and clients reload certificates when the mounted files change
+func WithTLS(template *corev1.PodTemplateSpec, secretName, verifyPeers string) {
+	if secretName == "" || len(template.Spec.Containers) == 0 {
+		return
+	}
+	template.Spec.Volumes = append(template.Spec.Volumes, GetTLSVolume(secretName))
+	withTLSContainer(&template.Spec.Containers[0], verifyPeers)
+}
+
+// GetTLSVolume returns the volume of tls.crt, tls.key and ca.crt of the secret, the key of the ca is not mounted
+func GetTLSVolume(secretName string) corev1.Volume {
+	return corev1.Volume{
+		Name: tlsVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: secretName,
+				Items: []corev1.KeyToPath{
+					{Key: tlsCertKey, Path: tlsCertKey},
+					{Key: tlsKeyKey, Path: tlsKeyKey},
+					{Key: tlsCAKey, Path: tlsCAKey},
+				},
+			},
+		},
+	}
+}
+
+func withTLSContainer(container *corev1.Container, verifyPeers string) {
+	container.Env = append(container.Env, GetTLSEnvs(constant.DefaultFdbTLSPath, verifyPeers)...)
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      tlsVolumeName,
+		MountPath: constant.DefaultFdbTLSPath,
+		ReadOnly:  true,
+	})
+}
+
+// GetTLSEnvs returns fdb tls env of certificates in dir, read by both fdbserver and fdb clients
+func GetTLSEnvs(dir, verifyPeers string) []corev1.EnvVar {
+	return []corev1.EnvVar{
+		{
+			Name:  constant.ENVFdbTLSCertificateFile,
+			Value: filepath.Join(dir, tlsCertKey),
+		},
+		{
+			Name:  constant.ENVFdbTLSKeyFile,
+			Value: filepath.Join(dir, tlsKeyKey),
+		},
+		{
+			Name:  constant.ENVFdbTLSCAFile,
+			Value: filepath.Join(dir, tlsCAKey),
+		},
+		{
+			Name:  constant.ENVFdbTLSVerifyPeers,
+			Value: verifyPeers,
+		},
+	}
+}
+
+// GetTLSData returns certificates of the secret data used by fdb
+func GetTLSData(data map[string][]byte) map[string][]byte {
+	return map[string][]byte{
+		tlsCertKey: data[tlsCertKey],
+		tlsKeyKey:  data[tlsKeyKey],
+		tlsCAKey:   data[tlsCAKey],
+	}
+}
+
+// GetCertificateNotAfter returns the expiration time of the pem encoded certificate
+func GetCertificateNotAfter(certPEM []byte) (time.Time, error) {
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return cert.NotAfter, nil
+}
+
+// NeedsRenewal returns true if the certificate is invalid or expires within FdbTLSRenewBefore
+func NeedsRenewal(certPEM []byte, now time.Time) bool {
+	notAfter, err := GetCertificateNotAfter(certPEM)
+	if err != nil {
+		return true
+	}
+	return now.Add(constant.FdbTLSRenewBefore).After(notAfter)
+}
+
+func parseCertificate(certPEM []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no certificate found")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func parsePrivateKey(keyPEM []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("no private key found")
+	}
+	return x509.ParseECPrivateKey(block.Bytes)
+}
+
+func newCertificate(template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	template.SerialNumber = serial
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), nil
+}
+
+// GenerateTLSData returns a certificate of fdb processes and clients signed by the ca of existing data, a new ca is
+// generated if existing data has no valid ca. Keeping the ca lets pods with old and new certificates verify each
+// other while the secret is rotated
+func GenerateTLSData(name string, existing map[string][]byte, now time.Time) (map[string][]byte, error) {
+	caPEM, caKeyPEM := existing[tlsCAKey], existing[tlsCAKeyKey]
+	ca, caErr := parseCertificate(caPEM)
+	caKey, keyErr := parsePrivateKey(caKeyPEM)
+	if caErr != nil || keyErr != nil || now.Add(constant.FdbTLSCertDuration).After(ca.NotAfter) {
+		var err error
+		caPEM, caKeyPEM, err = newCertificate(&x509.Certificate{
+			Subject:               pkix.Name{CommonName: fmt.Sprintf("%s-ca", GetFdbDeployName(name))},
+			NotBefore:             now.Add(-time.Hour),
+			NotAfter:              now.Add(constant.FdbTLSCADuration),
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		ca, _ = parseCertificate(caPEM)
+		caKey, _ = parsePrivateKey(caKeyPEM)
+	}
+
+	certPEM, keyPEM, err := newCertificate(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: GetFdbDeployName(name)},
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    now.Add(constant.FdbTLSCertDuration),
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{
+		tlsCertKey:  certPEM,
+		tlsKeyKey:   keyPEM,
+		tlsCAKey:    caPEM,
+		tlsCAKeyKey: caKeyPEM,
+	}, nil
+}
+
+// CreateOrRotateTLSSecret creates the secret of certificates generated by the operator, and renews the certificate
+// in place before it expires, returns the secret and true if the certificate is renewed
+func (fc *FdbConfig) CreateOrRotateTLSSecret(owner *v1.ThreeFsCluster) (*corev1.Secret, bool, error) {
+	name := GetTLSSecretName(fc.Name)
+	secret := &corev1.Secret{}
+	err := fc.rclient.Get(context.Background(), client.ObjectKey{Name: name, Namespace: fc.Namespace}, secret)
+	if err == nil {
+		if !NeedsRenewal(secret.Data[tlsCertKey], time.Now()) {
+			return secret, false, nil
+		}
+		data, err := GenerateTLSData(fc.Name, secret.Data, time.Now())
+		if err != nil {
+			klog.Errorf("generate fdb tls certificate of secret %s failed: %v", name, err)
+			return nil, false, err
+		}
+		secret.Data = data
+		klog.Infof("renew fdb tls certificate of secret %s", name)
+		if err := fc.rclient.Update(context.Background(), secret); err != nil {
+			klog.Errorf("update secret %s failed: %v", name, err)
+			return nil, false, err
+		}
+		return secret, true, nil
+	} else if !k8serror.IsNotFound(err) {
+		klog.Errorf("get secret %s failed: %v", name, err)
+		return nil, false, err
+	}
+
+	data, err := GenerateTLSData(fc.Name, nil, time.Now())
+	if err != nil {
+		klog.Errorf("generate fdb tls certificate of secret %s failed: %v", name, err)
+		return nil, false, err
+	}
+	secret = &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: fc.Namespace,
+			Labels: map[string]string{
+				constant.ThreeFSFdbDeployKey: fc.Name,
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}
+	if err := controllerutil.SetControllerReference(owner, secret, fc.schema); err != nil {
+		klog.Errorf("set owner of secret %s failed: %v", name, err)
+		return nil, false, err
+	}
+	klog.Infof("create fdb tls secret %s", name)
+	if err := fc.rclient.Create(context.Background(), secret); err != nil {
+		klog.Errorf("create secret %s failed: %v", name, err)
+		return nil, false, err
+	}
+	return secret, false, nil
+}
+
+// GetCertificateSecret returns the secret of the cert-manager Certificate and true if the certificate is ready
+func (fc *FdbConfig) GetCertificateSecret(certificateRef string) (*corev1.Secret, bool, error) {
+	certificate := &unstructured.Unstructured{}
+	certificate.SetGroupVersionKind(CertificateGVK)
+	if err := fc.rclient.Get(context.Background(), client.ObjectKey{Name: certificateRef, Namespace: fc.Namespace}, certificate); err != nil {
+		klog.Errorf("get certificate %s failed: %v", certificateRef, err)
+		return nil, false, err
+	}
+	if !isCertificateReady(certificate) {
+		return nil, false, nil
+	}
+	secretName, _, _ := unstructured.NestedString(certificate.Object, "spec", "secretName")
+	secret := &corev1.Secret{}
+	if err := fc.rclient.Get(context.Background(), client.ObjectKey{Name: secretName, Namespace: fc.Namespace}, secret); err != nil {
+		klog.Errorf("get secret %s of certificate %s failed: %v", secretName, certificateRef, err)
+		return nil, false, err
+	}
+	if len(secret.Data[tlsCertKey]) == 0 || len(secret.Data[tlsKeyKey]) == 0 || len(secret.Data[tlsCAKey]) == 0 {
+		return nil, false, fmt.Errorf("secret %s of certificate %s must have %s, %s and %s", secretName, certificateRef, tlsCertKey, tlsKeyKey, tlsCAKey)
+	}
+	return secret, true, nil
+}
+
+func isCertificateReady(certificate *unstructured.Unstructured) bool {
+	conditions, _, _ := unstructured.NestedSlice(certificate.Object, "status", "conditions")
+	for _, condition := range conditions {
+		c, ok := condition.(map[string]interface{})
+		if ok && c["type"] == "Ready" {
+			return c["status"] == string(metav1.ConditionTrue)
+		}
+	}
+	return false
+}
+
+func (fc *FdbConfig) WithTLS(secretName, verifyPeers string) *FdbConfig {
+	fc.TLSSecretName = secretName
+	fc.TLSVerifyPeers = verifyPeers
+	return fc
+}
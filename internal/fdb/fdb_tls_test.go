@@ -0,0 +1,180 @@
+package fdb
+
+import (
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	clientcomm "github.com/aliyun/kvc-3fs-operator/internal/client"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestGetTLSOptionsArgs(t *testing.T) {
+	cases := []struct {
+		name    string
+		options *clientcomm.FdbTLSOptions
+		args    []string
+	}{
+		{
+			name:    "tls disabled",
+			options: nil,
+			args:    nil,
+		},
+		{
+			name:    "default verify peers",
+			options: GetTLSOptions("/opt/3fs/fdb-tls/default/test", constant.DefaultFdbTLSVerifyPeers),
+			args: []string{
+				"--tls_certificate_file", "/opt/3fs/fdb-tls/default/test/tls.crt",
+				"--tls_key_file", "/opt/3fs/fdb-tls/default/test/tls.key",
+				"--tls_ca_file", "/opt/3fs/fdb-tls/default/test/ca.crt",
+				"--tls_verify_peers", "Check.Valid=1",
+			},
+		},
+		{
+			name:    "custom verify peers",
+			options: GetTLSOptions("/tls", "S.CN=test-fdb"),
+			args: []string{
+				"--tls_certificate_file", "/tls/tls.crt",
+				"--tls_key_file", "/tls/tls.key",
+				"--tls_ca_file", "/tls/ca.crt",
+				"--tls_verify_peers", "S.CN=test-fdb",
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.args, c.options.Args())
+		})
+	}
+}
+
+func TestValidateTLS(t *testing.T) {
+	cases := []struct {
+		name  string
+		spec  v1.FdbSpec
+		valid bool
+	}{
+		{name: "no tls", spec: v1.FdbSpec{}, valid: true},
+		{name: "generated certificates", spec: v1.FdbSpec{TLS: &v1.FdbTLSSpec{Enabled: true}}, valid: true},
+		{name: "verify peers of disabled tls", spec: v1.FdbSpec{TLS: &v1.FdbTLSSpec{VerifyPeers: "Check.Valid=1"}}, valid: false},
+		{name: "certificate of disabled tls", spec: v1.FdbSpec{TLS: &v1.FdbTLSSpec{CertificateRef: "cert"}}, valid: false},
+		{name: "external without certificate", spec: v1.FdbSpec{Mode: constant.FdbModeExternal, TLS: &v1.FdbTLSSpec{Enabled: true}}, valid: false},
+		{name: "external with certificate", spec: v1.FdbSpec{Mode: constant.FdbModeExternal, TLS: &v1.FdbTLSSpec{Enabled: true, CertificateRef: "cert"}}, valid: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := ValidateTLS(c.spec)
+			if c.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+	assert.Equal(t, constant.DefaultFdbTLSVerifyPeers, GetTLSVerifyPeers(v1.FdbSpec{TLS: &v1.FdbTLSSpec{Enabled: true}}))
+	assert.Equal(t, "S.CN=test-fdb", GetTLSVerifyPeers(v1.FdbSpec{TLS: &v1.FdbTLSSpec{Enabled: true, VerifyPeers: "S.CN=test-fdb"}}))
+}
+
+func TestWithTLSAddresses(t *testing.T) {
+	cases := []struct {
+		content string
+		expect  string
+	}{
+		{content: "test:abc@10.0.0.1:4500", expect: "test:abc@10.0.0.1:4500:tls"},
+		{content: "test:abc@10.0.0.1:4500,10.0.0.2:4500:tls", expect: "test:abc@10.0.0.1:4500:tls,10.0.0.2:4500:tls"},
+		{content: "invalid", expect: "invalid"},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expect, WithTLSAddresses(c.content))
+	}
+}
+
+func TestWithTLS(t *testing.T) {
+	template := &corev1.PodTemplateSpec{}
+	WithTLS(template, "test-fdb-tls", constant.DefaultFdbTLSVerifyPeers)
+	assert.Empty(t, template.Spec.Volumes)
+
+	template.Spec.Containers = []corev1.Container{{Name: "main"}, {Name: "sidecar"}}
+	WithTLS(template, "", constant.DefaultFdbTLSVerifyPeers)
+	assert.Empty(t, template.Spec.Volumes)
+
+	WithTLS(template, "test-fdb-tls", constant.DefaultFdbTLSVerifyPeers)
+	assert.Equal(t, "test-fdb-tls", template.Spec.Volumes[0].Secret.SecretName)
+	assert.Len(t, template.Spec.Containers[0].Env, 4)
+	assert.Equal(t, constant.DefaultFdbTLSPath, template.Spec.Containers[0].VolumeMounts[0].MountPath)
+	assert.Empty(t, template.Spec.Containers[1].Env)
+}
+
+func TestGenerateTLSData(t *testing.T) {
+	now := time.Now()
+	data, err := GenerateTLSData("test", nil, now)
+	assert.NoError(t, err)
+	assert.False(t, NeedsRenewal(data[tlsCertKey], now))
+	assert.True(t, NeedsRenewal(data[tlsCertKey], now.Add(constant.FdbTLSCertDuration-constant.FdbTLSRenewBefore/2)))
+	assert.True(t, NeedsRenewal(nil, now))
+	assert.NotContains(t, GetTLSData(data), tlsCAKeyKey)
+
+	// the ca is kept when the certificate is renewed
+	renewed, err := GenerateTLSData("test", data, now.Add(constant.FdbTLSCertDuration))
+	assert.NoError(t, err)
+	assert.Equal(t, data[tlsCAKey], renewed[tlsCAKey])
+	assert.NotEqual(t, data[tlsCertKey], renewed[tlsCertKey])
+
+	ca, err := parseCertificate(renewed[tlsCAKey])
+	assert.NoError(t, err)
+	cert, err := parseCertificate(renewed[tlsCertKey])
+	assert.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	_, err = cert.Verify(x509.VerifyOptions{
+		Roots:       pool,
+		CurrentTime: now.Add(constant.FdbTLSCertDuration),
+		KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	assert.NoError(t, err)
+
+	// a new ca is generated if the ca expires before the certificate
+	rotated, err := GenerateTLSData("test", data, now.Add(constant.FdbTLSCADuration))
+	assert.NoError(t, err)
+	assert.NotEqual(t, data[tlsCAKey], rotated[tlsCAKey])
+}
+
+func TestWriteTLSFiles(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "default", "test")
+	data := map[string][]byte{tlsCertKey: []byte("cert"), tlsKeyKey: []byte("key"), tlsCAKey: []byte("ca")}
+	assert.NoError(t, WriteTLSFiles(dir, data))
+	data[tlsCertKey] = []byte("renewed")
+	assert.NoError(t, WriteTLSFiles(dir, data))
+
+	for key, value := range data {
+		content, err := os.ReadFile(filepath.Join(dir, key))
+		assert.NoError(t, err)
+		assert.Equal(t, value, content)
+	}
+	_, err := os.Stat(filepath.Join(dir, tlsCertKey+".tmp"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCreateOrRotateTLSSecret(t *testing.T) {
+	fc := newVersionFdbConfig()
+	owner := &v1.ThreeFsCluster{}
+	owner.Name = "test"
+	owner.Namespace = "default"
+	owner.UID = "uid"
+
+	secret, renewed, err := fc.CreateOrRotateTLSSecret(owner)
+	assert.NoError(t, err)
+	assert.False(t, renewed)
+	assert.Equal(t, GetTLSSecretName("test"), secret.Name)
+	cert := secret.Data[tlsCertKey]
+
+	secret, renewed, err = fc.CreateOrRotateTLSSecret(owner)
+	assert.NoError(t, err)
+	assert.False(t, renewed)
+	assert.Equal(t, cert, secret.Data[tlsCertKey])
+}
@@ -0,0 +1,290 @@
+package fdb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	fdbv1beta2 "github.com/FoundationDB/fdb-kubernetes-operator/api/v1beta2"
+	v1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	clientLibraryVolumeName = "fdb-client-libs"
+	clientLibraryPath       = "/var/fdb/client-libs"
+	clientLibraryPrefix     = "fdb-client-"
+)
+
+// GetFdbVersion returns fdb version of fdb spec, DefaultFdbVersion if not set
+func GetFdbVersion(spec v1.FdbSpec) string {
+	if spec.Version == "" {
+		return constant.DefaultFdbVersion
+	}
+	return spec.Version
+}
+
+// ValidateVersion checks fdb version is a release version usable by 3fs clients
+func ValidateVersion(version string) error {
+	if version == "" {
+		return nil
+	}
+	parsed, err := fdbv1beta2.ParseFdbVersion(version)
+	if err != nil {
+		return fmt.Errorf("fdb version %s is invalid: %v", version, err)
+	}
+	minimum, _ := fdbv1beta2.ParseFdbVersion(constant.MinimumFdbVersion)
+	if !parsed.IsAtLeast(minimum) {
+		return fmt.Errorf("fdb version %s is older than %s", version, constant.MinimumFdbVersion)
+	}
+	return nil
+}
+
+// ValidateVersionChange checks fdb can be changed from version to target, fdb can not be downgraded
+// to an older protocol version
+func ValidateVersionChange(version, target string) error {
+	if err := ValidateVersion(target); err != nil {
+		return err
+	}
+	from, err := fdbv1beta2.ParseFdbVersion(version)
+	if err != nil {
+		return fmt.Errorf("running fdb version %s is invalid: %v", version, err)
+	}
+	to, _ := fdbv1beta2.ParseFdbVersion(target)
+	if !from.SupportsVersionChange(to) {
+		return fmt.Errorf("fdb can not be downgraded from %s to %s", version, target)
+	}
+	return nil
+}
+
+// IsProtocolCompatible returns true if fdb of the two versions talk the same protocol
+func IsProtocolCompatible(version, other string) bool {
+	a, err := fdbv1beta2.ParseFdbVersion(version)
+	if err != nil {
+		return false
+	}
+	b, err := fdbv1beta2.ParseFdbVersion(other)
+	if err != nil {
+		return false
+	}
+	return a.IsProtocolCompatible(b)
+}
+
+// GetFdbImage returns fdb image of version, FDB_IMAGE is the image of DefaultFdbVersion and
+// images of other versions are tagged by version in the same repository
+func GetFdbImage(version string) string {
+	image := os.Getenv("FDB_IMAGE")
+	if version == "" || version == constant.DefaultFdbVersion {
+		return image
+	}
+	repository := strings.Split(image, "@")[0]
+	if idx := strings.LastIndex(repository, ":"); idx > strings.LastIndex(repository, "/") {
+		repository = repository[:idx]
+	}
+	return fmt.Sprintf("%s:%s", repository, version)
+}
+
+// GetStagedClientVersions returns sorted fdb versions of which client libraries are staged for 3fs clients,
+// versions with the same protocol as the bundled libfdb_c are not staged
+func GetStagedClientVersions(versions ...string) []string {
+	staged := make([]string, 0)
+	for _, version := range versions {
+		if version == "" || IsProtocolCompatible(version, constant.FdbClientVersion) {
+			continue
+		}
+		found := false
+		for _, existing := range staged {
+			if existing == version {
+				found = true
+				break
+			}
+		}
+		if !found {
+			staged = append(staged, version)
+		}
+	}
+	sort.Strings(staged)
+	return staged
+}
+
+func getClientContainerName(version string) string {
+	return clientLibraryPrefix + strings.ReplaceAll(version, ".", "-")
+}
+
+// WithClientLibraries stages client libraries of versions to the pod by init containers copying libfdb_c from
+// fdb images, the first container loads them by the multi-version client
+func WithClientLibraries(template *corev1.PodTemplateSpec, versions []string) {
+	if template.Annotations == nil {
+		template.Annotations = make(map[string]string)
+	}
+	template.Annotations[constant.ThreeFSFdbClientVersionsKey] = strings.Join(versions, ",")
+	if len(versions) == 0 || len(template.Spec.Containers) == 0 {
+		return
+	}
+
+	template.Spec.Volumes = append(template.Spec.Volumes, corev1.Volume{
+		Name: clientLibraryVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{},
+		},
+	})
+	mount := corev1.VolumeMount{
+		Name:      clientLibraryVolumeName,
+		MountPath: clientLibraryPath,
+	}
+	for _, version := range versions {
+		template.Spec.InitContainers = append(template.Spec.InitContainers, corev1.Container{
+			Name:            getClientContainerName(version),
+			Image:           GetFdbImage(version),
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			Command:         []string{"cp", "/usr/lib/libfdb_c.so", fmt.Sprintf("%s/libfdb_c_%s.so", clientLibraryPath, version)},
+			VolumeMounts:    []corev1.VolumeMount{mount},
+		})
+	}
+	container := &template.Spec.Containers[0]
+	container.Env = append(container.Env, corev1.EnvVar{
+		Name:  constant.ENVFdbExternalClientDirectory,
+		Value: clientLibraryPath,
+	})
+	container.VolumeMounts = append(container.VolumeMounts, mount)
+}
+
+// withoutClientLibraries removes client libraries staged by WithClientLibraries
+func withoutClientLibraries(template *corev1.PodTemplateSpec) {
+	delete(template.Annotations, constant.ThreeFSFdbClientVersionsKey)
+	initContainers := make([]corev1.Container, 0)
+	for _, container := range template.Spec.InitContainers {
+		if !strings.HasPrefix(container.Name, clientLibraryPrefix) {
+			initContainers = append(initContainers, container)
+		}
+	}
+	template.Spec.InitContainers = initContainers
+	volumes := make([]corev1.Volume, 0)
+	for _, volume := range template.Spec.Volumes {
+		if volume.Name != clientLibraryVolumeName {
+			volumes = append(volumes, volume)
+		}
+	}
+	template.Spec.Volumes = volumes
+	if len(template.Spec.Containers) == 0 {
+		return
+	}
+	container := &template.Spec.Containers[0]
+	envs := make([]corev1.EnvVar, 0)
+	for _, env := range container.Env {
+		if env.Name != constant.ENVFdbExternalClientDirectory {
+			envs = append(envs, env)
+		}
+	}
+	container.Env = envs
+	mounts := make([]corev1.VolumeMount, 0)
+	for _, mount := range container.VolumeMounts {
+		if mount.Name != clientLibraryVolumeName {
+			mounts = append(mounts, mount)
+		}
+	}
+	container.VolumeMounts = mounts
+}
+
+// IsSameClientLibraries returns true if client libraries of versions are staged to the pod
+func IsSameClientLibraries(template *corev1.PodTemplateSpec, versions []string) bool {
+	return template.Annotations[constant.ThreeFSFdbClientVersionsKey] == strings.Join(versions, ",")
+}
+
+func (fc *FdbConfig) WithVersion(version string) *FdbConfig {
+	fc.Version = version
+	return fc
+}
+
+func (fc *FdbConfig) WithClientVersions(versions []string) *FdbConfig {
+	fc.ClientVersions = versions
+	return fc
+}
+
+func isDeployReady(deploy *appsv1.Deployment) bool {
+	return deploy.Status.ObservedGeneration >= deploy.Generation && deploy.Status.UpdatedReplicas >= 1 && deploy.Status.ReadyReplicas >= 1
+}
+
+// UpdateClientLibraries stages client libraries of ClientVersions to deployments labeled by labelKey one by one,
+// returns the updated deployment and true until all deployments are updated and ready
+func (fc *FdbConfig) UpdateClientLibraries(labelKey string) (string, bool, error) {
+	deployList := &appsv1.DeploymentList{}
+	if err := fc.rclient.List(context.Background(), deployList, client.InNamespace(fc.Namespace), client.MatchingLabels{labelKey: fc.Name}); err != nil {
+		klog.Errorf("list deployment with label %s failed: %v", labelKey, err)
+		return "", false, err
+	}
+	staged := true
+	for _, deploy := range deployList.Items {
+		if !IsSameClientLibraries(&deploy.Spec.Template, fc.ClientVersions) {
+			staged = false
+		}
+	}
+	for _, deploy := range deployList.Items {
+		if !isDeployReady(&deploy) {
+			klog.Infof("deployment %s is not ready, wait", deploy.Name)
+			return "", !staged, nil
+		}
+	}
+	for _, deploy := range deployList.Items {
+		if IsSameClientLibraries(&deploy.Spec.Template, fc.ClientVersions) {
+			continue
+		}
+		klog.Infof("stage fdb client libraries %v to deployment %s", fc.ClientVersions, deploy.Name)
+		newDeploy := deploy.DeepCopy()
+		withoutClientLibraries(&newDeploy.Spec.Template)
+		WithClientLibraries(&newDeploy.Spec.Template, fc.ClientVersions)
+		if err := fc.rclient.Update(context.Background(), newDeploy); err != nil {
+			klog.Errorf("update deployment %s failed: %v", deploy.Name, err)
+			return "", false, err
+		}
+		return deploy.Name, true, nil
+	}
+	return "", false, nil
+}
+
+// UpdateDeployVersion updates images of all fdb deployments to Version at once, fdb processes of different
+// protocol versions can not talk to each other so they are bounced together
+func (fc *FdbConfig) UpdateDeployVersion() ([]string, error) {
+	deployList := &appsv1.DeploymentList{}
+	if err := fc.rclient.List(context.Background(), deployList, client.InNamespace(fc.Namespace), client.MatchingLabels{constant.ThreeFSFdbDeployKey: fc.Name}); err != nil {
+		klog.Errorf("list deployment %s failed: %v", GetFdbDeployName(fc.Name), err)
+		return nil, err
+	}
+	image := GetFdbImage(fc.Version)
+	updated := make([]string, 0)
+	for _, deploy := range deployList.Items {
+		if len(deploy.Spec.Template.Spec.Containers) == 0 || deploy.Spec.Template.Spec.Containers[0].Image == image {
+			continue
+		}
+		newDeploy := deploy.DeepCopy()
+		newDeploy.Spec.Template.Spec.Containers[0].Image = image
+		if err := fc.rclient.Update(context.Background(), newDeploy); err != nil {
+			klog.Errorf("update image of deployment %s failed: %v", deploy.Name, err)
+			return updated, err
+		}
+		updated = append(updated, deploy.Name)
+	}
+	return updated, nil
+}
+
+// IsDeployVersionReady returns true if all fdb deployments run image of Version and are ready
+func (fc *FdbConfig) IsDeployVersionReady() (bool, error) {
+	deployList := &appsv1.DeploymentList{}
+	if err := fc.rclient.List(context.Background(), deployList, client.InNamespace(fc.Namespace), client.MatchingLabels{constant.ThreeFSFdbDeployKey: fc.Name}); err != nil {
+		klog.Errorf("list deployment %s failed: %v", GetFdbDeployName(fc.Name), err)
+		return false, err
+	}
+	image := GetFdbImage(fc.Version)
+	for _, deploy := range deployList.Items {
+		if len(deploy.Spec.Template.Spec.Containers) == 0 || deploy.Spec.Template.Spec.Containers[0].Image != image || !isDeployReady(&deploy) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
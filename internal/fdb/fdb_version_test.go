@@ -0,0 +1,175 @@
+package fdb
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newVersionDeploy(name, labelKey, image string, ready bool) *appsv1.Deployment {
+	deploy := &appsv1.Deployment{}
+	deploy.Name = name
+	deploy.Namespace = "default"
+	deploy.Labels = map[string]string{labelKey: "test"}
+	deploy.Spec.Template.Spec.Containers = []corev1.Container{{Name: "main", Image: image}}
+	if ready {
+		deploy.Status.UpdatedReplicas = 1
+		deploy.Status.ReadyReplicas = 1
+	}
+	return deploy
+}
+
+func newVersionFdbConfig(objs ...client.Object) *FdbConfig {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = v1.AddToScheme(scheme)
+	rclient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+	return NewFdbConfig("test", "default", 3, 3, nil, 4500, corev1.ResourceRequirements{}, rclient, nil, nil, scheme)
+}
+
+func TestValidateVersionChange(t *testing.T) {
+	cases := []struct {
+		name    string
+		version string
+		target  string
+		valid   bool
+	}{
+		{name: "upgrade", version: "7.1.61", target: "7.3.63", valid: true},
+		{name: "same version", version: "7.3.63", target: "7.3.63", valid: true},
+		{name: "patch downgrade", version: "7.3.63", target: "7.3.57", valid: true},
+		{name: "protocol downgrade", version: "7.3.63", target: "7.1.61", valid: false},
+		{name: "older than minimum", version: "6.3.25", target: "6.3.26", valid: false},
+		{name: "invalid target", version: "7.3.63", target: "latest", valid: false},
+		{name: "invalid running version", version: "latest", target: "7.3.63", valid: false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := ValidateVersionChange(c.version, c.target)
+			if c.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestGetFdbImage(t *testing.T) {
+	cases := []struct {
+		name    string
+		image   string
+		version string
+		expect  string
+	}{
+		{name: "default version", image: "registry.local/fdb:7.3.63", version: "", expect: "registry.local/fdb:7.3.63"},
+		{name: "bundled version", image: "registry.local/fdb:7.3.63", version: constant.DefaultFdbVersion, expect: "registry.local/fdb:7.3.63"},
+		{name: "other version", image: "registry.local/fdb:7.3.63", version: "7.1.61", expect: "registry.local/fdb:7.1.61"},
+		{name: "registry with port", image: "registry.local:5000/fdb:7.3.63", version: "7.1.61", expect: "registry.local:5000/fdb:7.1.61"},
+		{name: "image digest", image: "registry.local:5000/fdb:7.3.63@sha256:abc", version: "7.1.61", expect: "registry.local:5000/fdb:7.1.61"},
+		{name: "image without tag", image: "registry.local:5000/fdb", version: "7.1.61", expect: "registry.local:5000/fdb:7.1.61"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Setenv("FDB_IMAGE", c.image)
+			assert.Equal(t, c.expect, GetFdbImage(c.version))
+		})
+	}
+}
+
+func TestGetStagedClientVersions(t *testing.T) {
+	assert.Equal(t, []string{}, GetStagedClientVersions())
+	assert.Equal(t, []string{}, GetStagedClientVersions("", constant.FdbClientVersion, "7.3.57"))
+	assert.Equal(t, []string{"7.1.61", "7.2.5"}, GetStagedClientVersions("7.2.5", constant.FdbClientVersion, "7.1.61", "", "7.1.61"))
+}
+
+func TestWithClientLibraries(t *testing.T) {
+	t.Setenv("FDB_IMAGE", "registry.local/fdb:7.3.63")
+	template := &corev1.PodTemplateSpec{}
+	template.Spec.Containers = []corev1.Container{{Name: "main"}}
+
+	WithClientLibraries(template, []string{"7.1.61"})
+	assert.True(t, IsSameClientLibraries(template, []string{"7.1.61"}))
+	assert.Len(t, template.Spec.InitContainers, 1)
+	assert.Equal(t, "fdb-client-7-1-61", template.Spec.InitContainers[0].Name)
+	assert.Equal(t, "registry.local/fdb:7.1.61", template.Spec.InitContainers[0].Image)
+	assert.Len(t, template.Spec.Containers[0].VolumeMounts, 1)
+
+	withoutClientLibraries(template)
+	WithClientLibraries(template, nil)
+	assert.True(t, IsSameClientLibraries(template, nil))
+	assert.False(t, IsSameClientLibraries(template, []string{"7.1.61"}))
+	assert.Empty(t, template.Spec.InitContainers)
+	assert.Empty(t, template.Spec.Volumes)
+	assert.Empty(t, template.Spec.Containers[0].Env)
+	assert.Empty(t, template.Spec.Containers[0].VolumeMounts)
+}
+
+func TestUpdateClientLibraries(t *testing.T) {
+	t.Setenv("FDB_IMAGE", "registry.local/fdb:7.3.63")
+	fc := newVersionFdbConfig(
+		newVersionDeploy("meta-a", constant.ThreeFSMetaDeployKey, "meta", true),
+		newVersionDeploy("meta-b", constant.ThreeFSMetaDeployKey, "meta", true),
+	).WithClientVersions([]string{"7.1.61"})
+
+	// deployments are updated one by one
+	updated, rolling, err := fc.UpdateClientLibraries(constant.ThreeFSMetaDeployKey)
+	assert.NoError(t, err)
+	assert.True(t, rolling)
+	assert.Equal(t, "meta-a", updated)
+	updated, rolling, err = fc.UpdateClientLibraries(constant.ThreeFSMetaDeployKey)
+	assert.NoError(t, err)
+	assert.True(t, rolling)
+	assert.Equal(t, "meta-b", updated)
+	updated, rolling, err = fc.UpdateClientLibraries(constant.ThreeFSMetaDeployKey)
+	assert.NoError(t, err)
+	assert.False(t, rolling)
+	assert.Equal(t, "", updated)
+
+	deploy := &appsv1.Deployment{}
+	assert.NoError(t, fc.rclient.Get(context.Background(), client.ObjectKey{Name: "meta-b", Namespace: "default"}, deploy))
+	assert.True(t, IsSameClientLibraries(&deploy.Spec.Template, []string{"7.1.61"}))
+
+	// wait for deployments not ready
+	fc = newVersionFdbConfig(
+		newVersionDeploy("meta-a", constant.ThreeFSMetaDeployKey, "meta", true),
+		newVersionDeploy("meta-b", constant.ThreeFSMetaDeployKey, "meta", false),
+	).WithClientVersions([]string{"7.1.61"})
+	updated, rolling, err = fc.UpdateClientLibraries(constant.ThreeFSMetaDeployKey)
+	assert.NoError(t, err)
+	assert.True(t, rolling)
+	assert.Equal(t, "", updated)
+}
+
+func TestUpdateDeployVersion(t *testing.T) {
+	t.Setenv("FDB_IMAGE", "registry.local/fdb:7.3.63")
+	fc := newVersionFdbConfig(
+		newVersionDeploy("test-fdb-a", constant.ThreeFSFdbDeployKey, "registry.local/fdb:7.1.61", true),
+		newVersionDeploy("test-fdb-b", constant.ThreeFSFdbDeployKey, "registry.local/fdb:7.1.61", true),
+		newVersionDeploy("other-fdb-a", constant.ThreeFSMetaDeployKey, "registry.local/fdb:7.1.61", true),
+	).WithVersion("7.3.63")
+
+	ready, err := fc.IsDeployVersionReady()
+	assert.NoError(t, err)
+	assert.False(t, ready)
+
+	// all fdb deployments are bounced together
+	updated, err := fc.UpdateDeployVersion()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"test-fdb-a", "test-fdb-b"}, updated)
+	ready, err = fc.IsDeployVersionReady()
+	assert.NoError(t, err)
+	assert.True(t, ready)
+
+	updated, err = fc.UpdateDeployVersion()
+	assert.NoError(t, err)
+	assert.Empty(t, updated)
+}
@@ -60,58 +60,47 @@ func GetMetaDeployName(name string) string {
 	return fmt.Sprintf("%s-%s", name, "meta")
 }
 
-func (mc *MetaConfig) TagNodeLabel() error {
+// TagNodeLabel tags meta nodes with meta label and removes the label from other nodes, deploys on untagged
+// nodes are returned by ListRemovedDeploys
+func (mc *MetaConfig) TagNodeLabel(nodes []string) error {
+	mc.Nodes = nodes
 	tagNodeList := &corev1.NodeList{}
 	if err := mc.rclient.List(context.Background(), tagNodeList, client.MatchingLabels{constant.ThreeFSMetaNodeKey: "true"}); err != nil && !k8serror.IsNotFound(err) {
 		klog.Errorf("list node with meta label failed: %v", err)
 		return err
 	}
-	if len(tagNodeList.Items) == mc.Replica {
-		klog.Infof("node num with meta label already satisfy in k8s cluster")
-		return nil
-	} else if len(tagNodeList.Items) > mc.Replica {
-		// delete one each time
-		times := len(tagNodeList.Items) - mc.Replica
-		klog.Infof("node num with meta label exceed replica(%d) in k8s cluster", mc.Replica)
-		node := tagNodeList.Items[0]
-		delete(node.Labels, constant.ThreeFSMetaNodeKey)
-		if err := mc.rclient.Update(context.Background(), &node); err != nil {
+	for _, node := range tagNodeList.Items {
+		if utils.StrListContains(nodes, node.Name) {
+			continue
+		}
+		newNode := node.DeepCopy()
+		delete(newNode.Labels, constant.ThreeFSMetaNodeKey)
+		if err := mc.rclient.Patch(context.Background(), newNode, client.MergeFrom(&node)); err != nil {
 			klog.Errorf("delete node %s meta label failed: %v", node.Name, err)
 			return err
-		} else {
-			klog.Infof("delete node %s meta lebel success", node.Name)
-			times--
-			if times == 0 {
-				return nil
-			}
 		}
+		klog.Infof("delete node %s meta label success", node.Name)
 	}
 
-	allNodeList := &corev1.NodeList{}
-	if err := mc.rclient.List(context.Background(), allNodeList); err != nil {
-		klog.Errorf("list node failed: %v", err)
-		return err
-	}
-
-	times := len(tagNodeList.Items)
-	for _, node := range allNodeList.Items {
-		if !utils.StrListContains(mc.Nodes, node.Name) {
-			continue
+	for _, nodeName := range nodes {
+		node := &corev1.Node{}
+		if err := mc.rclient.Get(context.Background(), client.ObjectKey{Name: nodeName}, node); err != nil {
+			klog.Errorf("get node %s failed: %v", nodeName, err)
+			return err
 		}
 		if _, ok := node.Labels[constant.ThreeFSMetaNodeKey]; ok {
 			continue
 		}
-		node.Labels[constant.ThreeFSMetaNodeKey] = "true"
-		if err := mc.rclient.Update(context.Background(), &node); err != nil {
-			klog.Errorf("update node %s failed: %v", node.Name, err)
+		newNode := node.DeepCopy()
+		if newNode.Labels == nil {
+			newNode.Labels = make(map[string]string)
+		}
+		newNode.Labels[constant.ThreeFSMetaNodeKey] = "true"
+		if err := mc.rclient.Patch(context.Background(), newNode, client.MergeFrom(node)); err != nil {
+			klog.Errorf("update node %s failed: %v", nodeName, err)
 			return err
-		} else {
-			klog.Infof("tag node %s with meta lebel success", node.Name)
-			times += 1
-			if times == mc.Replica {
-				break
-			}
 		}
+		klog.Infof("tag node %s with meta lebel success", nodeName)
 	}
 
 	if !mc.CheckMetaTagNode() {
@@ -259,27 +248,30 @@ func (mc *MetaConfig) CreateDeployIfNotExist() error {
 		}
 	}
 
-	// delete deploy
+	return nil
+}
+
+// ListRemovedDeploys returns meta deployments on nodes without meta label, which are deleted by the controller
+// after other meta services are healthy
+func (mc *MetaConfig) ListRemovedDeploys() ([]appsv1.Deployment, error) {
 	deployList := &appsv1.DeploymentList{}
 	if err := mc.rclient.List(context.Background(), deployList, client.InNamespace(mc.Namespace), client.MatchingLabels{constant.ThreeFSMetaDeployKey: mc.Name}); err != nil {
 		klog.Errorf("list deployment %s failed: %v", GetMetaDeployName(mc.Name), err)
+		return nil, err
 	}
+	deploys := make([]appsv1.Deployment, 0)
 	for _, deploy := range deployList.Items {
 		deployNodeName := deploy.Spec.Template.Spec.NodeSelector[constant.KubernetesHostnameKey]
 		nodeObj := &corev1.Node{}
-		if err := mc.rclient.Get(context.Background(), client.ObjectKey{Name: deployNodeName, Namespace: mc.Namespace}, nodeObj); err != nil {
+		if err := mc.rclient.Get(context.Background(), client.ObjectKey{Name: deployNodeName}, nodeObj); err != nil && !k8serror.IsNotFound(err) {
 			klog.Errorf("get node %s failed: %v", deployNodeName, err)
-			return err
+			return nil, err
 		}
 		if _, ok := nodeObj.Labels[constant.ThreeFSMetaNodeKey]; !ok {
-			if err := mc.rclient.Delete(context.Background(), &deploy); err != nil {
-				klog.Errorf("delete deployment %s failed: %v", deploy.Name, err)
-				return err
-			}
+			deploys = append(deploys, deploy)
 		}
 	}
-
-	return nil
+	return deploys, nil
 }
 
 func (mc *MetaConfig) WithDeployMeta(nodeName string) *MetaConfig {
@@ -414,6 +406,8 @@ func (mc *MetaConfig) WithDeployContainers(nodeName string) *MetaConfig {
 	}
 	mc.Deploys[nodeName] = mc.Deploys[nodeName].
 		WithContainer("meta", metaImage, envs, envFrom, nil, mc.CheckResources(), volumeMount, command)
+	fdb.WithClientLibraries(&mc.Deploys[nodeName].Deployment.Spec.Template, mc.FdbConfig.ClientVersions)
+	fdb.WithTLS(&mc.Deploys[nodeName].Deployment.Spec.Template, mc.FdbConfig.TLSSecretName, mc.FdbConfig.TLSVerifyPeers)
 
 	return mc
 }
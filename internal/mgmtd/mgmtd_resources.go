@@ -417,6 +417,8 @@ func (mc *MgmtdConfig) WithDeployContainers(nodeName string) *MgmtdConfig {
 	}
 	mc.Deploys[nodeName] = mc.Deploys[nodeName].
 		WithContainer("mgmtd", mgmtdImage, envs, envFrom, nil, mc.CheckResources(), volumeMount, command)
+	fdb.WithClientLibraries(&mc.Deploys[nodeName].Deployment.Spec.Template, mc.FdbConfig.ClientVersions)
+	fdb.WithTLS(&mc.Deploys[nodeName].Deployment.Spec.Template, mc.FdbConfig.TLSSecretName, mc.FdbConfig.TLSVerifyPeers)
 
 	return mc
 }
@@ -28,3 +28,18 @@ func (cc *ConfigmapConfig) WithData(data map[string]string) *ConfigmapConfig {
 	cc.ConfigMap.Data = data
 	return cc
 }
+
+func (cc *ConfigmapConfig) WithBinaryData(data map[string][]byte) *ConfigmapConfig {
+	cc.ConfigMap.BinaryData = data
+	return cc
+}
+
+func (cc *ConfigmapConfig) WithLabels(labels map[string]string) *ConfigmapConfig {
+	cc.ConfigMap.Labels = labels
+	return cc
+}
+
+func (cc *ConfigmapConfig) WithAnnotations(annotations map[string]string) *ConfigmapConfig {
+	cc.ConfigMap.Annotations = annotations
+	return cc
+}
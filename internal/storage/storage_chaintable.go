@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"strings"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ChainTable is a chain table of the cluster resolved from spec
+type ChainTable struct {
+	Id string
+	// NodeGroup is the storage node group placed into the table, ignored if AllGroups is true
+	NodeGroup string
+	// AllGroups is true if spec has no chainTables, all storage nodes are placed into the table
+	AllGroups bool
+	// Storage is storage spec with redundancy of the table
+	Storage    threefsv1.StorageSpec
+	StripeSize int
+	ChunkSize  int
+	Path       string
+}
+
+// GetChainTables returns all chain tables of the cluster, a single table ChainTableId with all storage nodes
+// is returned if spec has no chainTables
+func GetChainTables(spec threefsv1.ThreeFsClusterSpec) []ChainTable {
+	if len(spec.ChainTables) == 0 {
+		return []ChainTable{{
+			Id:         spec.ChainTableId,
+			AllGroups:  true,
+			Storage:    spec.Storage,
+			StripeSize: spec.StripeSize,
+			ChunkSize:  spec.ChunkSize,
+		}}
+	}
+
+	tables := make([]ChainTable, 0, len(spec.ChainTables))
+	for _, tableSpec := range spec.ChainTables {
+		table := ChainTable{
+			Id:         tableSpec.Id,
+			NodeGroup:  tableSpec.NodeGroup,
+			Storage:    spec.Storage,
+			StripeSize: tableSpec.StripeSize,
+			ChunkSize:  tableSpec.ChunkSize,
+			Path:       tableSpec.Path,
+		}
+		// redundancy of storage is used if the table does not set it
+		if tableSpec.Redundancy.Type != "" {
+			table.Storage.Redundancy = tableSpec.Redundancy
+		}
+		if table.StripeSize == 0 {
+			table.StripeSize = spec.StripeSize
+		}
+		if table.ChunkSize == 0 {
+			table.ChunkSize = spec.ChunkSize
+		}
+		tables = append(tables, table)
+	}
+	return tables
+}
+
+// GetChainTable returns the chain table with the id
+func GetChainTable(spec threefsv1.ThreeFsClusterSpec, id string) (ChainTable, bool) {
+	for _, table := range GetChainTables(spec) {
+		if table.Id == id {
+			return table, true
+		}
+	}
+	return ChainTable{}, false
+}
+
+// GetDefaultChainTable returns chain table ChainTableId which is the layout of the root directory
+func GetDefaultChainTable(spec threefsv1.ThreeFsClusterSpec) ChainTable {
+	if table, ok := GetChainTable(spec, spec.ChainTableId); ok {
+		return table
+	}
+	return GetChainTables(spec)[0]
+}
+
+// GetNodeGroupChainTable returns the chain table which the node group is placed into, false if the targets of the
+// node group are not placed into any table
+func GetNodeGroupChainTable(spec threefsv1.ThreeFsClusterSpec, group string) (ChainTable, bool) {
+	for _, table := range GetChainTables(spec) {
+		if table.AllGroups || table.NodeGroup == group {
+			return table, true
+		}
+	}
+	return ChainTable{}, false
+}
+
+// GetNodeChainTable returns the chain table of the storage node
+func GetNodeChainTable(rclient client.Client, spec threefsv1.ThreeFsClusterSpec, nodeName string) (ChainTable, bool, error) {
+	layout, err := GetNodeLayout(rclient, spec.Storage, nodeName)
+	if err != nil {
+		return ChainTable{}, false, err
+	}
+	table, ok := GetNodeGroupChainTable(spec, layout.Group)
+	return table, ok, nil
+}
+
+// ParseChainTableSnapshotFiles returns chain table files of the snapshot keyed by chain table id, snapshots taken
+// before multiple chain tables are supported only have chain table 1
+func ParseChainTableSnapshotFiles(cm *corev1.ConfigMap) map[string]string {
+	files := make(map[string]string)
+	for key := range cm.BinaryData {
+		fileName := strings.TrimSuffix(key, ".gz")
+		if fileName == constant.DefaultChainTableSnapshotTable {
+			files["1"] = fileName
+		} else if strings.HasPrefix(fileName, constant.DefaultChainTableSnapshotTable+".") {
+			files[strings.TrimPrefix(fileName, constant.DefaultChainTableSnapshotTable+".")] = fileName
+		}
+	}
+	return files
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"testing"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestGetChainTables(t *testing.T) {
+	spec := threefsv1.ThreeFsClusterSpec{
+		StripeSize:   16,
+		ChunkSize:    1048576,
+		ChainTableId: "1",
+		Storage:      threefsv1.StorageSpec{Replica: 3},
+	}
+
+	// all storage nodes are placed into chain table ChainTableId
+	tables := GetChainTables(spec)
+	assert.Len(t, tables, 1)
+	assert.Equal(t, ChainTable{Id: "1", AllGroups: true, Storage: spec.Storage, StripeSize: 16, ChunkSize: 1048576}, tables[0])
+	assert.Equal(t, "1", GetDefaultChainTable(spec).Id)
+	table, ok := GetNodeGroupChainTable(spec, "any")
+	assert.True(t, ok)
+	assert.Equal(t, "1", table.Id)
+
+	ec := threefsv1.StorageRedundancy{Type: constant.ThreeFSRedundancyEC, DataChunks: 4, ParityChunks: 2}
+	spec.ChainTableId = "2"
+	spec.ChainTables = []threefsv1.ChainTableSpec{
+		{Id: "1", NodeGroup: "ssd"},
+		{Id: "2", NodeGroup: "hdd", Redundancy: ec, StripeSize: 12, Path: "/cold"},
+	}
+	tables = GetChainTables(spec)
+	assert.Len(t, tables, 2)
+	assert.Equal(t, ChainTable{Id: "1", NodeGroup: "ssd", Storage: spec.Storage, StripeSize: 16, ChunkSize: 1048576}, tables[0])
+	assert.Equal(t, ec, tables[1].Storage.Redundancy)
+	assert.Equal(t, 3, tables[1].Storage.Replica)
+	assert.Equal(t, 12, tables[1].StripeSize)
+	assert.Equal(t, 1048576, tables[1].ChunkSize)
+	assert.Equal(t, "/cold", tables[1].Path)
+
+	assert.Equal(t, "2", GetDefaultChainTable(spec).Id)
+	table, ok = GetChainTable(spec, "1")
+	assert.True(t, ok)
+	assert.Equal(t, "ssd", table.NodeGroup)
+	_, ok = GetChainTable(spec, "3")
+	assert.False(t, ok)
+
+	table, ok = GetNodeGroupChainTable(spec, "hdd")
+	assert.True(t, ok)
+	assert.Equal(t, "2", table.Id)
+	_, ok = GetNodeGroupChainTable(spec, "")
+	assert.False(t, ok)
+
+	// falls back to the first table if ChainTableId is not listed
+	spec.ChainTableId = "3"
+	assert.Equal(t, "1", GetDefaultChainTable(spec).Id)
+}
+
+func TestGetNodeChainTable(t *testing.T) {
+	node := &corev1.Node{}
+	node.Name = "node-a"
+	node.Labels = map[string]string{"disk": "hdd"}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	rclient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(node).Build()
+
+	spec := threefsv1.ThreeFsClusterSpec{
+		ChainTableId: "1",
+		Storage: threefsv1.StorageSpec{
+			Replica:     3,
+			TargetPaths: []string{"/data0"},
+			NodeGroups: []threefsv1.StorageNodeGroup{
+				{Name: "ssd", NodeSelector: map[string]string{"disk": "ssd"}, TargetPaths: []string{"/data0"}},
+				{Name: "hdd", NodeSelector: map[string]string{"disk": "hdd"}, TargetPaths: []string{"/data0"}},
+			},
+		},
+		ChainTables: []threefsv1.ChainTableSpec{{Id: "1", NodeGroup: "ssd"}, {Id: "2", NodeGroup: "hdd"}},
+	}
+	table, ok, err := GetNodeChainTable(rclient, spec, "node-a")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "2", table.Id)
+
+	_, _, err = GetNodeChainTable(rclient, spec, "node-b")
+	assert.Error(t, err)
+}
+
+func TestParseChainTableSnapshotFiles(t *testing.T) {
+	testCases := []struct {
+		name   string
+		keys   []string
+		expect map[string]string
+	}{
+		{
+			name:   "legacy snapshot",
+			keys:   []string{"chains.csv.gz", "chain_table.csv.gz"},
+			expect: map[string]string{"1": "chain_table.csv"},
+		},
+		{
+			name:   "multiple chain tables",
+			keys:   []string{"chains.csv.gz", "chain_table.csv.1.gz", "chain_table.csv.2.gz"},
+			expect: map[string]string{"1": "chain_table.csv.1", "2": "chain_table.csv.2"},
+		},
+		{
+			name:   "no chain table",
+			keys:   []string{"chains.csv.gz"},
+			expect: map[string]string{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cm := &corev1.ConfigMap{BinaryData: map[string][]byte{}}
+			for _, key := range tc.keys {
+				cm.BinaryData[key] = []byte{}
+			}
+			assert.Equal(t, tc.expect, ParseChainTableSnapshotFiles(cm))
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NodeLayout is the disk layout of a storage node
+type NodeLayout struct {
+	// Group is empty for nodes matching no node group
+	Group         string
+	TargetPaths   []string
+	TargetPerDisk int
+}
+
+// MatchNodeGroups returns names of all node groups whose selector matches the node labels
+func MatchNodeGroups(groups []threefsv1.StorageNodeGroup, nodeLabels map[string]string) []string {
+	matched := make([]string, 0)
+	for _, group := range groups {
+		if len(group.NodeSelector) == 0 {
+			continue
+		}
+		if labels.SelectorFromSet(group.NodeSelector).Matches(labels.Set(nodeLabels)) {
+			matched = append(matched, group.Name)
+		}
+	}
+	return matched
+}
+
+// GetGroupWeight returns the weight of disks in the node group, DefaultStorageNodeGroupWeight if not set
+func GetGroupWeight(nodeGroup threefsv1.StorageNodeGroup) int {
+	if nodeGroup.Weight <= 0 {
+		return constant.DefaultStorageNodeGroupWeight
+	}
+	return nodeGroup.Weight
+}
+
+// GetGroupLayout returns the layout of the node group, or the default layout if group is empty.
+// Targets per disk of a group is weighted by disk capacity if not set, rounded to the nearest
+func GetGroupLayout(spec threefsv1.StorageSpec, group string) NodeLayout {
+	for _, nodeGroup := range spec.NodeGroups {
+		if nodeGroup.Name != group {
+			continue
+		}
+		targetPerDisk := nodeGroup.TargetPerDisk
+		if targetPerDisk <= 0 {
+			weight := GetGroupWeight(nodeGroup)
+			targetPerDisk = (spec.TargetPerDisk*weight + constant.DefaultStorageNodeGroupWeight/2) / constant.DefaultStorageNodeGroupWeight
+			if targetPerDisk < 1 {
+				targetPerDisk = 1
+			}
+		}
+		return NodeLayout{
+			Group:         nodeGroup.Name,
+			TargetPaths:   nodeGroup.TargetPaths,
+			TargetPerDisk: targetPerDisk,
+		}
+	}
+	return NodeLayout{
+		TargetPaths:   spec.TargetPaths,
+		TargetPerDisk: spec.TargetPerDisk,
+	}
+}
+
+// GetNodeLayout returns the disk layout of the storage node according to its labels
+func GetNodeLayout(rclient client.Client, spec threefsv1.StorageSpec, nodeName string) (NodeLayout, error) {
+	if len(spec.NodeGroups) == 0 {
+		return GetGroupLayout(spec, ""), nil
+	}
+	node := &corev1.Node{}
+	if err := rclient.Get(context.Background(), client.ObjectKey{Name: nodeName}, node); err != nil {
+		klog.Errorf("get node %s failed: %v", nodeName, err)
+		return NodeLayout{}, err
+	}
+	group := ""
+	if matched := MatchNodeGroups(spec.NodeGroups, node.Labels); len(matched) > 0 {
+		group = matched[0]
+	}
+	return GetGroupLayout(spec, group), nil
+}
+
+// PlacementGroup is a set of storage nodes with the same disk layout, data placement runs for each group
+// separately so that chains never cross nodes with different disks
+type PlacementGroup struct {
+	Group         string
+	Nodes         []string
+	NumDisks      int
+	DiskOffset    int
+	TargetPerDisk int
+}
+
+// GroupStorageNodes groups nodes by disk layout, the default group comes first and others are ordered by name
+func GroupStorageNodes(rclient client.Client, spec threefsv1.StorageSpec, nodes []string) ([]PlacementGroup, error) {
+	groupMaps := make(map[string]*PlacementGroup)
+	for _, node := range nodes {
+		layout, err := GetNodeLayout(rclient, spec, node)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := groupMaps[layout.Group]; !ok {
+			groupMaps[layout.Group] = &PlacementGroup{
+				Group:         layout.Group,
+				NumDisks:      len(layout.TargetPaths),
+				TargetPerDisk: layout.TargetPerDisk,
+			}
+		}
+		groupMaps[layout.Group].Nodes = append(groupMaps[layout.Group].Nodes, node)
+	}
+
+	groups := make([]PlacementGroup, 0, len(groupMaps))
+	for _, group := range groupMaps {
+		groups = append(groups, *group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Group < groups[j].Group
+	})
+	return groups, nil
+}
+
+// ValidateGroupWeights checks weights of node groups without targetPerDisk are kept by the weighted targets per
+// disk. Data of a group is proportional to its targets, so a weight lost by rounding, e.g. 150 with targetPerDisk 1,
+// puts the same data on disks of different capacities across groups
+func ValidateGroupWeights(spec threefsv1.StorageSpec) error {
+	for _, nodeGroup := range spec.NodeGroups {
+		if nodeGroup.TargetPerDisk > 0 {
+			continue
+		}
+		weight := GetGroupWeight(nodeGroup)
+		targetPerDisk := GetGroupLayout(spec, nodeGroup.Name).TargetPerDisk
+		// weight of the targets differs from the weight of disks by more than 10 percent
+		actual := targetPerDisk * constant.DefaultStorageNodeGroupWeight
+		expected := spec.TargetPerDisk * weight
+		if diff := actual - expected; diff*10 > expected || -diff*10 > expected {
+			return fmt.Errorf("storage node group %s weight %d can not be kept by %d targets per disk, increase targetPerDisk of storage or set targetPerDisk of the group",
+				nodeGroup.Name, weight, targetPerDisk)
+		}
+	}
+	return nil
+}
+
+// GetContainerTargetPaths returns target paths in storage pods, which are the target_paths of the storage main config.
+// The i-th disk of a node is always mounted at the i-th path, nodes without node group mount host paths at the
+// same paths, disks beyond storage.targetPaths are mounted under ThreeFSStorageDiskSlotPath
+func GetContainerTargetPaths(spec threefsv1.StorageSpec) []string {
+	paths := append([]string{}, spec.TargetPaths...)
+	maxDisks := len(spec.TargetPaths)
+	for _, group := range spec.NodeGroups {
+		if len(group.TargetPaths) > maxDisks {
+			maxDisks = len(group.TargetPaths)
+		}
+	}
+	for idx := len(spec.TargetPaths); idx < maxDisks; idx++ {
+		paths = append(paths, fmt.Sprintf("%s-%d", constant.ThreeFSStorageDiskSlotPath, idx))
+	}
+	return paths
+}
+
+// GetNodeDomain returns the failure domain of the storage node, which is the value of the topology label. Empty
+// is returned if storage has no topology key
+func GetNodeDomain(rclient client.Client, spec threefsv1.StorageSpec, nodeName string) (string, error) {
+	if spec.TopologyKey == "" {
+		return "", nil
+	}
+	node := &corev1.Node{}
+	if err := rclient.Get(context.Background(), client.ObjectKey{Name: nodeName}, node); err != nil {
+		klog.Errorf("get node %s failed: %v", nodeName, err)
+		return "", err
+	}
+	domain, ok := node.Labels[spec.TopologyKey]
+	if !ok || domain == "" {
+		return "", fmt.Errorf("storage node %s has no topology label %s", nodeName, spec.TopologyKey)
+	}
+	return domain, nil
+}
+
+// ValidateDomainSpread checks whether groups of groupSize targets can be spread across failure domains. Each group
+// takes at most one target of a domain, so a domain can hold at most 1/groupSize of the nodes
+func ValidateDomainSpread(nodeDomains map[string]string, groupSize int) error {
+	domainNodes := make(map[string]int)
+	for _, domain := range nodeDomains {
+		domainNodes[domain]++
+	}
+	if len(domainNodes) < groupSize {
+		return fmt.Errorf("%d failure domains are less than group size %d", len(domainNodes), groupSize)
+	}
+	for domain, num := range domainNodes {
+		if num*groupSize > len(nodeDomains) {
+			return fmt.Errorf("failure domain %s has %d of %d nodes, more than 1/%d of the nodes", domain, num, len(nodeDomains), groupSize)
+		}
+	}
+	return nil
+}
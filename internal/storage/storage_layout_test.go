@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"testing"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestGetGroupLayout(t *testing.T) {
+	spec := threefsv1.StorageSpec{
+		TargetPaths:   []string{"/data0"},
+		TargetPerDisk: 16,
+		NodeGroups: []threefsv1.StorageNodeGroup{
+			{Name: "large", TargetPaths: []string{"/data0", "/data1"}, Weight: 200},
+			{Name: "small", TargetPaths: []string{"/data0"}, Weight: 50},
+			{Name: "fixed", TargetPaths: []string{"/data0"}, TargetPerDisk: 3, Weight: 200},
+			{Name: "default", TargetPaths: []string{"/data0"}},
+		},
+	}
+	cases := []struct {
+		group         string
+		targetPaths   []string
+		targetPerDisk int
+	}{
+		{group: "", targetPaths: []string{"/data0"}, targetPerDisk: 16},
+		{group: "large", targetPaths: []string{"/data0", "/data1"}, targetPerDisk: 32},
+		{group: "small", targetPaths: []string{"/data0"}, targetPerDisk: 8},
+		{group: "fixed", targetPaths: []string{"/data0"}, targetPerDisk: 3},
+		{group: "default", targetPaths: []string{"/data0"}, targetPerDisk: 16},
+	}
+	for _, c := range cases {
+		t.Run(c.group, func(t *testing.T) {
+			layout := GetGroupLayout(spec, c.group)
+			assert.Equal(t, c.group, layout.Group)
+			assert.Equal(t, c.targetPaths, layout.TargetPaths)
+			assert.Equal(t, c.targetPerDisk, layout.TargetPerDisk)
+		})
+	}
+}
+
+func TestValidateGroupWeights(t *testing.T) {
+	cases := []struct {
+		name          string
+		targetPerDisk int
+		group         threefsv1.StorageNodeGroup
+		valid         bool
+	}{
+		{name: "default weight", targetPerDisk: 1, group: threefsv1.StorageNodeGroup{Name: "g"}, valid: true},
+		{name: "double", targetPerDisk: 1, group: threefsv1.StorageNodeGroup{Name: "g", Weight: 200}, valid: true},
+		// 1.5 targets per disk is rounded to 2
+		{name: "rounded", targetPerDisk: 1, group: threefsv1.StorageNodeGroup{Name: "g", Weight: 150}, valid: false},
+		{name: "half", targetPerDisk: 1, group: threefsv1.StorageNodeGroup{Name: "g", Weight: 50}, valid: false},
+		{name: "enough targets", targetPerDisk: 16, group: threefsv1.StorageNodeGroup{Name: "g", Weight: 150}, valid: true},
+		{name: "slightly rounded", targetPerDisk: 16, group: threefsv1.StorageNodeGroup{Name: "g", Weight: 133}, valid: true},
+		{name: "fixed targets", targetPerDisk: 1, group: threefsv1.StorageNodeGroup{Name: "g", Weight: 150, TargetPerDisk: 2}, valid: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			spec := threefsv1.StorageSpec{TargetPerDisk: c.targetPerDisk, NodeGroups: []threefsv1.StorageNodeGroup{c.group}}
+			assert.Equal(t, c.valid, ValidateGroupWeights(spec) == nil)
+		})
+	}
+}
+
+func TestValidateDomainSpread(t *testing.T) {
+	cases := []struct {
+		name        string
+		nodeDomains map[string]string
+		groupSize   int
+		valid       bool
+	}{
+		{name: "spread", nodeDomains: map[string]string{"a": "z1", "b": "z2", "c": "z3"}, groupSize: 3, valid: true},
+		{name: "too few domains", nodeDomains: map[string]string{"a": "z1", "b": "z1", "c": "z2"}, groupSize: 3, valid: false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.valid, ValidateDomainSpread(c.nodeDomains, c.groupSize) == nil)
+		})
+	}
+}
+
+func TestGroupStorageNodesCapacity(t *testing.T) {
+	newNode := func(name, disk string) *corev1.Node {
+		node := &corev1.Node{}
+		node.Name, node.Labels = name, map[string]string{"disk": disk}
+		return node
+	}
+	spec := threefsv1.StorageSpec{
+		TargetPaths:   []string{"/data0"},
+		TargetPerDisk: 4,
+		NodeGroups: []threefsv1.StorageNodeGroup{
+			// two disks of double capacity
+			{Name: "large", NodeSelector: map[string]string{"disk": "large"}, TargetPaths: []string{"/data0", "/data1"}, Weight: 200},
+			// one disk of 1.5 times capacity
+			{Name: "medium", NodeSelector: map[string]string{"disk": "medium"}, TargetPaths: []string{"/data0"}, Weight: 150},
+		},
+	}
+	assert.NoError(t, ValidateGroupWeights(spec))
+	nodes := []client.Object{newNode("node-a", "default"), newNode("node-b", "large"), newNode("node-c", "large"), newNode("node-d", "medium")}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	rclient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(nodes...).Build()
+	groups, err := GroupStorageNodes(rclient, spec, []string{"node-a", "node-b", "node-c", "node-d"})
+	assert.NoError(t, err)
+	assert.Len(t, groups, 3)
+
+	// targets of a node are proportional to its capacity across groups
+	capacities := map[string]int{"": 100, "large": 400, "medium": 150}
+	for _, group := range groups {
+		targets := group.NumDisks * group.TargetPerDisk
+		assert.Equal(t, capacities[group.Group]*spec.TargetPerDisk, targets*100, "group %q", group.Group)
+	}
+	assert.Equal(t, []string{"node-b", "node-c"}, groups[1].Nodes)
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"fmt"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+)
+
+// GetRedundancyType returns the chain table type passed to data placement, CR if not set
+func GetRedundancyType(spec threefsv1.StorageSpec) string {
+	if spec.Redundancy.Type == constant.ThreeFSRedundancyEC {
+		return constant.ThreeFSRedundancyEC
+	}
+	return constant.ThreeFSRedundancyCR
+}
+
+// IsErasureCoding returns true if chains of the storage are erasure coded
+func IsErasureCoding(spec threefsv1.StorageSpec) bool {
+	return GetRedundancyType(spec) == constant.ThreeFSRedundancyEC
+}
+
+// GetGroupSize returns the number of targets on different nodes placed together, which is the replica of
+// chain replication or data plus parity chunks of erasure coding
+func GetGroupSize(spec threefsv1.StorageSpec) int {
+	if IsErasureCoding(spec) {
+		return spec.Redundancy.DataChunks + spec.Redundancy.ParityChunks
+	}
+	return spec.Replica
+}
+
+// GetChainTargetNum returns the number of targets in a chain, chains of erasure coding have only one target
+func GetChainTargetNum(spec threefsv1.StorageSpec) int {
+	if IsErasureCoding(spec) {
+		return 1
+	}
+	return spec.Replica
+}
+
+// GetChainNum returns the number of chains created on targetNum targets
+func GetChainNum(spec threefsv1.StorageSpec, targetNum int) int {
+	chainTargetNum := GetChainTargetNum(spec)
+	if chainTargetNum <= 0 {
+		return 0
+	}
+	return targetNum / chainTargetNum
+}
+
+// GetChainTableDesc returns the description used when uploading chain table
+func GetChainTableDesc(spec threefsv1.StorageSpec) string {
+	if IsErasureCoding(spec) {
+		return fmt.Sprintf("ec-%d+%d", spec.Redundancy.DataChunks, spec.Redundancy.ParityChunks)
+	}
+	return constant.DefaultChainTableDesc
+}
+
+// ValidateRedundancy checks redundancy of storage, stripe size of erasure coding must be a multiple of EC group size
+// so that a stripe consists of whole EC groups
+func ValidateRedundancy(spec threefsv1.StorageSpec, stripeSize int) error {
+	redundancy := spec.Redundancy
+	switch redundancy.Type {
+	case "", constant.ThreeFSRedundancyCR:
+		if redundancy.DataChunks != 0 || redundancy.ParityChunks != 0 {
+			return fmt.Errorf("dataChunks and parityChunks are only supported by EC redundancy")
+		}
+		if spec.Replica <= 0 {
+			return fmt.Errorf("storage replica must be greater than 0")
+		}
+	case constant.ThreeFSRedundancyEC:
+		if redundancy.DataChunks <= 0 || redundancy.ParityChunks <= 0 {
+			return fmt.Errorf("dataChunks and parityChunks of EC redundancy must be greater than 0")
+		}
+		if stripeSize%GetGroupSize(spec) != 0 {
+			return fmt.Errorf("stripe size %d must be a multiple of EC group size %d", stripeSize, GetGroupSize(spec))
+		}
+	default:
+		return fmt.Errorf("storage redundancy type %s is not supported, only %s and %s", redundancy.Type, constant.ThreeFSRedundancyCR, constant.ThreeFSRedundancyEC)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"testing"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRedundancy(t *testing.T) {
+	cases := []struct {
+		name           string
+		spec           threefsv1.StorageSpec
+		redundancyType string
+		groupSize      int
+		chainTargetNum int
+		chainNum       int
+		desc           string
+	}{
+		{
+			name:           "default",
+			spec:           threefsv1.StorageSpec{Replica: 3},
+			redundancyType: constant.ThreeFSRedundancyCR,
+			groupSize:      3,
+			chainTargetNum: 3,
+			chainNum:       4,
+			desc:           constant.DefaultChainTableDesc,
+		},
+		{
+			name:           "chain replication",
+			spec:           threefsv1.StorageSpec{Replica: 2, Redundancy: threefsv1.StorageRedundancy{Type: constant.ThreeFSRedundancyCR}},
+			redundancyType: constant.ThreeFSRedundancyCR,
+			groupSize:      2,
+			chainTargetNum: 2,
+			chainNum:       6,
+			desc:           constant.DefaultChainTableDesc,
+		},
+		{
+			name:           "erasure coding",
+			spec:           threefsv1.StorageSpec{Replica: 3, Redundancy: threefsv1.StorageRedundancy{Type: constant.ThreeFSRedundancyEC, DataChunks: 4, ParityChunks: 2}},
+			redundancyType: constant.ThreeFSRedundancyEC,
+			groupSize:      6,
+			chainTargetNum: 1,
+			chainNum:       12,
+			desc:           "ec-4+2",
+		},
+		{
+			name:           "no replica",
+			spec:           threefsv1.StorageSpec{},
+			redundancyType: constant.ThreeFSRedundancyCR,
+			groupSize:      0,
+			chainTargetNum: 0,
+			chainNum:       0,
+			desc:           constant.DefaultChainTableDesc,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.redundancyType, GetRedundancyType(c.spec))
+			assert.Equal(t, c.redundancyType == constant.ThreeFSRedundancyEC, IsErasureCoding(c.spec))
+			assert.Equal(t, c.groupSize, GetGroupSize(c.spec))
+			assert.Equal(t, c.chainTargetNum, GetChainTargetNum(c.spec))
+			assert.Equal(t, c.chainNum, GetChainNum(c.spec, 12))
+			assert.Equal(t, c.desc, GetChainTableDesc(c.spec))
+		})
+	}
+}
+
+func TestValidateRedundancy(t *testing.T) {
+	cases := []struct {
+		name       string
+		spec       threefsv1.StorageSpec
+		stripeSize int
+		valid      bool
+	}{
+		{name: "default", spec: threefsv1.StorageSpec{Replica: 3}, stripeSize: 16, valid: true},
+		{name: "no replica", spec: threefsv1.StorageSpec{}, stripeSize: 16, valid: false},
+		{name: "chunks of chain replication", spec: threefsv1.StorageSpec{Replica: 3, Redundancy: threefsv1.StorageRedundancy{Type: constant.ThreeFSRedundancyCR, DataChunks: 4}}, stripeSize: 16, valid: false},
+		{name: "erasure coding", spec: threefsv1.StorageSpec{Redundancy: threefsv1.StorageRedundancy{Type: constant.ThreeFSRedundancyEC, DataChunks: 4, ParityChunks: 2}}, stripeSize: 12, valid: true},
+		{name: "erasure coding without parity", spec: threefsv1.StorageSpec{Redundancy: threefsv1.StorageRedundancy{Type: constant.ThreeFSRedundancyEC, DataChunks: 4}}, stripeSize: 12, valid: false},
+		{name: "stripe not multiple of group", spec: threefsv1.StorageSpec{Redundancy: threefsv1.StorageRedundancy{Type: constant.ThreeFSRedundancyEC, DataChunks: 4, ParityChunks: 2}}, stripeSize: 16, valid: false},
+		{name: "unknown type", spec: threefsv1.StorageSpec{Replica: 3, Redundancy: threefsv1.StorageRedundancy{Type: "RAID"}}, stripeSize: 16, valid: false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := ValidateRedundancy(c.spec, c.stripeSize)
+			if c.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
@@ -25,6 +25,8 @@ type StorageConfig struct {
 	Nodes          []string
 	MgmtdAddresses string
 	TargetPaths    []string
+	TargetPerDisk  int
+	NodeGroups     []threefsv1.StorageNodeGroup
 	Resources      corev1.ResourceRequirements
 	DsConfig       *native_resources.DsConfig
 	Deploys        map[string]*native_resources.DelpoyConfig
@@ -53,6 +55,26 @@ func NewStorageConfig(name, namespace string, nodes []string, mgmtdAddress strin
 	}
 }
 
+// WithNodeGroups sets disk layout overrides of storage nodes
+func (mc *StorageConfig) WithNodeGroups(targetPerDisk int, nodeGroups []threefsv1.StorageNodeGroup) *StorageConfig {
+	mc.TargetPerDisk = targetPerDisk
+	mc.NodeGroups = nodeGroups
+	return mc
+}
+
+func (mc *StorageConfig) storageSpec() threefsv1.StorageSpec {
+	return threefsv1.StorageSpec{
+		TargetPaths:   mc.TargetPaths,
+		TargetPerDisk: mc.TargetPerDisk,
+		NodeGroups:    mc.NodeGroups,
+	}
+}
+
+// GetContainerTargetPaths returns target paths of the storage main config
+func (mc *StorageConfig) GetContainerTargetPaths() []string {
+	return GetContainerTargetPaths(mc.storageSpec())
+}
+
 func GetStorageDeployName(name string) string {
 	return fmt.Sprintf("%s-%s", name, "storage")
 }
@@ -268,6 +290,44 @@ func (mc *StorageConfig) CreateDeployIfNotExist() error {
 	return nil
 }
 
+// IsDeployTargetPathsUpToDate checks whether all target paths are mounted into the storage deployment
+func (mc *StorageConfig) IsDeployTargetPathsUpToDate(deploy *appsv1.Deployment) bool {
+	if len(deploy.Spec.Template.Spec.Containers) == 0 {
+		return false
+	}
+	mountPaths := make([]string, 0)
+	for _, volumeMount := range deploy.Spec.Template.Spec.Containers[0].VolumeMounts {
+		mountPaths = append(mountPaths, volumeMount.MountPath)
+	}
+	for _, targetPath := range mc.GetContainerTargetPaths() {
+		if !utils.StrListContains(mountPaths, targetPath) {
+			return false
+		}
+	}
+	return true
+}
+
+// UpdateDeployTargetPaths replaces volumes and volume mounts of the storage deployment with current target paths,
+// other fields of the deployment are kept as is
+func (mc *StorageConfig) UpdateDeployTargetPaths(deploy *appsv1.Deployment) error {
+	nodeName := deploy.Spec.Template.Spec.NodeSelector[constant.KubernetesHostnameKey]
+	// containers are appended by builder, always build from an empty deploy config
+	mc.Deploys[nodeName] = native_resources.NewDeployConfig()
+	expected := mc.WithDeployMeta(nodeName).
+		WithDeploySpec(nodeName).
+		WithDeployVolumes(nodeName).
+		WithDeployContainers(nodeName).Deploys[nodeName].Deployment
+
+	deploy.Spec.Template.Spec.Volumes = expected.Spec.Template.Spec.Volumes
+	deploy.Spec.Template.Spec.Containers[0].VolumeMounts = expected.Spec.Template.Spec.Containers[0].VolumeMounts
+	if err := mc.rclient.Update(context.Background(), deploy); err != nil {
+		klog.Errorf("update deployment %s target paths failed: %v", deploy.Name, err)
+		return err
+	}
+	klog.Infof("update deployment %s target paths to %v", deploy.Name, mc.GetContainerTargetPaths())
+	return nil
+}
+
 func (mc *StorageConfig) WithDeployMeta(nodeName string) *StorageConfig {
 	if mc.Deploys[nodeName] == nil {
 		mc.Deploys[nodeName] = native_resources.NewDeployConfig()
@@ -321,12 +381,28 @@ func (mc *StorageConfig) WithDeployVolumes(nodeName string) *StorageConfig {
 		},
 	}
 
-	for idx, targetPath := range mc.TargetPaths {
+	layout, err := GetNodeLayout(mc.rclient, mc.storageSpec(), nodeName)
+	if err != nil {
+		klog.Errorf("get disk layout of node %s failed, use default layout: %v", nodeName, err)
+		layout = GetGroupLayout(mc.storageSpec(), "")
+	}
+	for idx := range mc.GetContainerTargetPaths() {
+		if idx >= len(layout.TargetPaths) {
+			// node has less disks, mount an empty dir so that all target paths of storage main config exist,
+			// no target is created on it
+			volumes = append(volumes, corev1.Volume{
+				Name: fmt.Sprintf("data-%d", idx),
+				VolumeSource: corev1.VolumeSource{
+					EmptyDir: &corev1.EmptyDirVolumeSource{},
+				},
+			})
+			continue
+		}
 		volumes = append(volumes, corev1.Volume{
 			Name: fmt.Sprintf("data-%d", idx),
 			VolumeSource: corev1.VolumeSource{
 				HostPath: &corev1.HostPathVolumeSource{
-					Path: targetPath,
+					Path: layout.TargetPaths[idx],
 					Type: &HostPathDirectory,
 				},
 			},
@@ -399,7 +475,7 @@ func (mc *StorageConfig) WithDeployContainers(nodeName string) *StorageConfig {
 		},
 	}
 
-	for idx, targetPath := range mc.TargetPaths {
+	for idx, targetPath := range mc.GetContainerTargetPaths() {
 		volumeMount = append(volumeMount, corev1.VolumeMount{
 			Name:      fmt.Sprintf("data-%d", idx),
 			MountPath: targetPath,
@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestUpdateDeployTargetPaths(t *testing.T) {
+	deploy := &appsv1.Deployment{}
+	deploy.Name = "test-storage-node-a"
+	deploy.Namespace = "default"
+	deploy.Spec.Template.Spec.NodeSelector = map[string]string{constant.KubernetesHostnameKey: "node-a"}
+	deploy.Spec.Template.Spec.Containers = []corev1.Container{{
+		Name:         "storage",
+		VolumeMounts: []corev1.VolumeMount{{Name: "data-0", MountPath: "/data0"}},
+	}}
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	rclient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(deploy).Build()
+	mc := NewStorageConfig("test", "default", []string{"node-a"}, "", 8000, 9000, []string{"/data0"}, corev1.ResourceRequirements{}, rclient)
+	assert.True(t, mc.IsDeployTargetPathsUpToDate(deploy))
+
+	mc.TargetPaths = []string{"/data0", "/data1"}
+	assert.False(t, mc.IsDeployTargetPathsUpToDate(deploy))
+	assert.NoError(t, mc.UpdateDeployTargetPaths(deploy))
+
+	latest := &appsv1.Deployment{}
+	assert.NoError(t, rclient.Get(context.Background(), client.ObjectKeyFromObject(deploy), latest))
+	assert.True(t, mc.IsDeployTargetPathsUpToDate(latest))
+	assert.Equal(t, "storage", latest.Spec.Template.Spec.Containers[0].Name)
+
+	assert.False(t, mc.IsDeployTargetPathsUpToDate(&appsv1.Deployment{}))
+}
@@ -1,10 +1,13 @@
 package utils
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/csv"
 	"fmt"
 	"github.com/aliyun/kvc-3fs-operator/internal/constant"
 	uuid "github.com/satori/go.uuid"
+	"io"
 	"net"
 	"os"
 	"path/filepath"
@@ -212,3 +215,24 @@ func GetEnableTraceEnv() bool {
 func ResolveDNS(domain string) (ips []net.IP, err error) {
 	return net.LookupIP(domain)
 }
+
+func GzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func GunzipBytes(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
@@ -0,0 +1,51 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+)
+
+// ParseClock returns minutes of the day of clock in HH:MM format
+func ParseClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// ValidateMaintenanceWindow checks start and end are in HH:MM format and days are weekdays like Mon
+func ValidateMaintenanceWindow(window threefsv1.MaintenanceWindow) error {
+	if _, err := ParseClock(window.Start); err != nil {
+		return err
+	}
+	if _, err := ParseClock(window.End); err != nil {
+		return err
+	}
+	for _, day := range window.Days {
+		if _, err := time.Parse("Mon", day); err != nil {
+			return fmt.Errorf("invalid day %q, expected Mon, Tue, Wed, Thu, Fri, Sat or Sun", day)
+		}
+	}
+	return nil
+}
+
+// ValidateAutoRepair checks thresholds and limits are not negative and maintenance windows are valid
+func ValidateAutoRepair(spec threefsv1.AutoRepairSpec) error {
+	if spec.NodeHeartbeatTimeoutMinutes < 0 || spec.TargetOfflineMinutes < 0 || spec.FailoverTimeoutMinutes < 0 ||
+		spec.MaxConcurrentRepairs < 0 || spec.RateLimit.MaxRepairs < 0 || spec.RateLimit.WindowMinutes < 0 {
+		return fmt.Errorf("autoRepair thresholds and limits can not be negative")
+	}
+	if (spec.RateLimit.MaxRepairs > 0) != (spec.RateLimit.WindowMinutes > 0) {
+		return fmt.Errorf("autoRepair rateLimit maxRepairs and windowMinutes must be set together")
+	}
+	for _, window := range spec.MaintenanceWindows {
+		if err := ValidateMaintenanceWindow(window); err != nil {
+			return fmt.Errorf("autoRepair maintenance window %s-%s %s is invalid: %v", window.Start, window.End, strings.Join(window.Days, ","), err)
+		}
+	}
+	return nil
+}
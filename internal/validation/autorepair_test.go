@@ -0,0 +1,27 @@
+package validation
+
+import (
+	"testing"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateAutoRepair(t *testing.T) {
+	spec := threefsv1.AutoRepairSpec{
+		Enabled:            true,
+		RateLimit:          threefsv1.AutoRepairRateLimit{MaxRepairs: 2, WindowMinutes: 60},
+		MaintenanceWindows: []threefsv1.MaintenanceWindow{{Start: "22:00", End: "04:00", Days: []string{"Sat", "Sun"}}},
+	}
+	assert.NoError(t, ValidateAutoRepair(spec))
+
+	spec.MaintenanceWindows[0].Days = []string{"Saturday"}
+	assert.Error(t, ValidateAutoRepair(spec))
+
+	spec.MaintenanceWindows[0] = threefsv1.MaintenanceWindow{Start: "25:00", End: "04:00"}
+	assert.Error(t, ValidateAutoRepair(spec))
+
+	spec.MaintenanceWindows = nil
+	spec.RateLimit.WindowMinutes = 0
+	assert.Error(t, ValidateAutoRepair(spec))
+}
@@ -0,0 +1,101 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/aliyun/kvc-3fs-operator/internal/utils"
+	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// evictionDeployKeys maps components gated on eviction to the pod label holding the cluster name
+var evictionDeployKeys = map[string]string{
+	"storage": constant.ThreeFSStorageDeployKey,
+	"meta":    constant.ThreeFSMetaDeployKey,
+	"mgmtd":   constant.ThreeFSMgmtdDeployKey,
+	"fdb":     constant.ThreeFSFdbDeployKey,
+}
+
+// IsPodReady returns true if the pod is running and ready
+func IsPodReady(pod *corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodRunning || pod.DeletionTimestamp != nil {
+		return false
+	}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// CheckPeerPodsReady allows one disruption per component at a time, the evicted pod is not checked
+func CheckPeerPodsReady(pods []corev1.Pod, evicted string) error {
+	for idx := range pods {
+		if pods[idx].Name == evicted {
+			continue
+		}
+		if !IsPodReady(&pods[idx]) {
+			return fmt.Errorf("pod %s of the same component is not ready", pods[idx].Name)
+		}
+	}
+	return nil
+}
+
+// CheckStorageTargetsHealthy requires targets of all other storage nodes to be UPTODATE in the target states
+// collected by the reconciler, so every chain with a target on the node keeps another serving target
+func CheckStorageTargetsHealthy(tfsc *threefsv1.ThreeFsCluster, nodeName string) error {
+	if tfsc.Status.UnhealthyTargetStatus == nil {
+		return fmt.Errorf("target states of threeFsCluster %s are not collected yet", tfsc.Name)
+	}
+	evicted := utils.TranslatePlainNodeName3fs(nodeName)
+	for node, targets := range tfsc.Status.UnhealthyTargetStatus {
+		if node == evicted || len(targets) == 0 {
+			continue
+		}
+		return fmt.Errorf("target %s of storage %s is %s", targets[0].TargetId, node, targets[0].Status)
+	}
+	return nil
+}
+
+// CheckPodEvictionSafe returns an error if evicting the 3fs component pod breaks the cluster. Evicting a storage pod
+// requires targets of other storage nodes to be healthy, other components require all peer pods to be ready. Pods
+// not ready or of a deleting cluster are always evictable. It only reads cached objects, so admission never waits
+// for 3fs
+func CheckPodEvictionSafe(rclient client.Client, pod *corev1.Pod) error {
+	component := pod.Labels[constant.ThreeFSComponentLabel]
+	deployKey, ok := evictionDeployKeys[component]
+	if !ok || pod.Labels[deployKey] == "" || !IsPodReady(pod) {
+		return nil
+	}
+
+	tfsc := &threefsv1.ThreeFsCluster{}
+	if err := rclient.Get(context.Background(), client.ObjectKey{Name: pod.Labels[deployKey], Namespace: pod.Namespace}, tfsc); err != nil {
+		if k8serror.IsNotFound(err) {
+			return nil
+		}
+		klog.Errorf("get threeFsCluster %s failed: %v", pod.Labels[deployKey], err)
+		return err
+	}
+	if tfsc.DeletionTimestamp != nil {
+		return nil
+	}
+
+	podList := &corev1.PodList{}
+	if err := rclient.List(context.Background(), podList, client.InNamespace(pod.Namespace), client.MatchingLabels{deployKey: tfsc.Name}); err != nil {
+		klog.Errorf("list %s pods failed: %v", component, err)
+		return err
+	}
+	if err := CheckPeerPodsReady(podList.Items, pod.Name); err != nil {
+		return err
+	}
+	if component != "storage" {
+		return nil
+	}
+	return CheckStorageTargetsHealthy(tfsc, pod.Spec.NodeName)
+}
@@ -0,0 +1,51 @@
+package validation
+
+import (
+	"testing"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func readyPod(name string, ready bool) corev1.Pod {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+		},
+	}
+}
+
+func TestCheckPeerPodsReady(t *testing.T) {
+	pods := []corev1.Pod{readyPod("meta-a", true), readyPod("meta-b", true), readyPod("meta-c", false)}
+	assert.True(t, IsPodReady(&pods[0]))
+	assert.False(t, IsPodReady(&pods[2]))
+
+	assert.NoError(t, CheckPeerPodsReady(pods, "meta-c"))
+	assert.Error(t, CheckPeerPodsReady(pods, "meta-a"))
+
+	pods[2].Status.Phase = corev1.PodPending
+	pods[2].Status.Conditions[0].Status = corev1.ConditionTrue
+	assert.Error(t, CheckPeerPodsReady(pods, "meta-a"))
+}
+
+func TestCheckStorageTargetsHealthy(t *testing.T) {
+	tfsc := &threefsv1.ThreeFsCluster{}
+	tfsc.Name = "test"
+	assert.Error(t, CheckStorageTargetsHealthy(tfsc, "node-a"))
+
+	tfsc.Status.UnhealthyTargetStatus = map[string][]threefsv1.TargetStatus{
+		"node_a": {{TargetId: "101000100101", Status: "OFFLINE"}},
+		"node_b": {},
+	}
+	// unhealthy targets of the evicted node itself do not block
+	assert.NoError(t, CheckStorageTargetsHealthy(tfsc, "node-a"))
+	assert.Error(t, CheckStorageTargetsHealthy(tfsc, "node-b"))
+}
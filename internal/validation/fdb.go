@@ -0,0 +1,59 @@
+package validation
+
+import (
+	"fmt"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	clientcomm "github.com/aliyun/kvc-3fs-operator/internal/client"
+	"github.com/aliyun/kvc-3fs-operator/internal/fdb"
+)
+
+// ValidateFdbReconfiguration checks fdb storage replicas and cluster size against the fdb node pool
+func ValidateFdbReconfiguration(nodeNum, replicas, clusterSize int) error {
+	if clientcomm.GetRedundancyMode(replicas) == "" {
+		return fmt.Errorf("fdb storage replicas must be 1, 2 or 3")
+	}
+	if replicas > (nodeNum+1)/2 {
+		return fmt.Errorf("n replica whith at least 2n-1 node is recommended, fdb node pool has %d nodes", nodeNum)
+	}
+	if clusterSize > nodeNum {
+		return fmt.Errorf("fdb clusterSize %d is larger then node pool %d", clusterSize, nodeNum)
+	}
+	if clusterSize < 2*replicas-1 {
+		return fmt.Errorf("fdb clusterSize %d is less than 2*storageReplicas-1", clusterSize)
+	}
+	return nil
+}
+
+// ValidateFdbSpec checks mode, storage engine, processes, version and role counts of fdb
+func ValidateFdbSpec(spec threefsv1.FdbSpec) error {
+	if err := fdb.ValidateMode(spec); err != nil {
+		return err
+	}
+	if err := fdb.ValidateStorageEngine(spec.StorageEngine); err != nil {
+		return err
+	}
+	if err := fdb.ValidateProcesses(spec.Processes); err != nil {
+		return err
+	}
+	if err := fdb.ValidateVersion(spec.Version); err != nil {
+		return err
+	}
+	if fdb.IsExternalMode(spec) && spec.Version != "" {
+		return fmt.Errorf("fdb version of external mode is managed outside of the operator")
+	}
+	if err := fdb.ValidateTLS(spec); err != nil {
+		return err
+	}
+	roles := spec.RoleCounts
+	if roles.Logs < 0 || roles.Proxies < 0 || roles.Resolvers < 0 {
+		return fmt.Errorf("fdb role counts can not be negative")
+	}
+	if spec.CoordinatorNum < 0 {
+		return fmt.Errorf("fdb coordinatorNum can not be negative")
+	}
+	if roles.Proxies == 1 {
+		return fmt.Errorf("fdb proxies must be at least 2 for one grv proxy and one commit proxy")
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateFdbReconfiguration(t *testing.T) {
+	assert.NoError(t, ValidateFdbReconfiguration(5, 2, 3))
+	assert.NoError(t, ValidateFdbReconfiguration(5, 3, 5))
+	// triple needs at least 5 processes
+	assert.Error(t, ValidateFdbReconfiguration(5, 3, 3))
+	assert.Error(t, ValidateFdbReconfiguration(4, 3, 4))
+	assert.Error(t, ValidateFdbReconfiguration(3, 2, 4))
+	assert.Error(t, ValidateFdbReconfiguration(9, 4, 7))
+}
@@ -0,0 +1,36 @@
+package validation
+
+import (
+	"context"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// IsTfsctDone returns true if the job is finished or aborted
+func IsTfsctDone(tfsct *threefsv1.ThreeFsChainTable) bool {
+	return tfsct.Status.Phase == constant.ThreeFSChainTableFinishedStatus ||
+		tfsct.Status.Phase == constant.ThreeFSChainTableAbortedStatus
+}
+
+// IsTfsctPending returns true if the job is waiting in the queue
+func IsTfsctPending(tfsct *threefsv1.ThreeFsChainTable) bool {
+	return tfsct.Status.Phase == "" || tfsct.Status.Phase == constant.ThreeFSChainTablePendingStatus
+}
+
+func IsProcessingTfsctExisted(rclient client.Client) bool {
+	tfsctList := &threefsv1.ThreeFsChainTableList{}
+	if err := rclient.List(context.Background(), tfsctList); err != nil {
+		klog.Errorf("list tfsct failed: %v", err)
+		return false
+	}
+	for _, tfsct := range tfsctList.Items {
+		if IsTfsctDone(&tfsct) {
+			continue
+		}
+		return true
+	}
+	return false
+}
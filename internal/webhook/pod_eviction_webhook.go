@@ -0,0 +1,72 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/aliyun/kvc-3fs-operator/internal/validation"
+	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// PodEvictionValidator denies evictions of 3fs component pods which the cluster can not tolerate
+type PodEvictionValidator struct {
+	Client client.Client
+}
+
+// SetupPodEvictionWebhookWithManager registers the pods/eviction validating webhook
+func SetupPodEvictionWebhookWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(constant.DefaultEvictionValidatePath, &webhook.Admission{
+		Handler: &PodEvictionValidator{Client: mgr.GetClient()},
+	})
+	return nil
+}
+
+var _ admission.Handler = &PodEvictionValidator{}
+
+// Handle gates evictions by name of the evicted pod, so both policy/v1 and policy/v1beta1 evictions are handled
+func (v *PodEvictionValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.SubResource != "eviction" {
+		return admission.Allowed("")
+	}
+	pod := &corev1.Pod{}
+	if err := v.Client.Get(ctx, client.ObjectKey{Name: req.Name, Namespace: req.Namespace}, pod); err != nil {
+		if k8serror.IsNotFound(err) {
+			return admission.Allowed("")
+		}
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if _, ok := pod.Labels[constant.ThreeFSComponentLabel]; !ok {
+		return admission.Allowed("")
+	}
+
+	if err := validation.CheckPodEvictionSafe(v.Client, pod); err != nil {
+		klog.Infof("deny eviction of pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		return admission.Denied(fmt.Sprintf("evicting %s pod %s is not safe: %v", pod.Labels[constant.ThreeFSComponentLabel], pod.Name, err))
+	}
+	klog.Infof("allow eviction of pod %s/%s", pod.Namespace, pod.Name)
+	return admission.Allowed("")
+}
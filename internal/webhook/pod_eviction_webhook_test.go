@@ -0,0 +1,151 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func newComponentPod(name, component, deployKey string, ready bool) *corev1.Pod {
+	pod := &corev1.Pod{}
+	pod.Name = name
+	pod.Namespace = "default"
+	pod.Labels = map[string]string{constant.ThreeFSComponentLabel: component, deployKey: "test"}
+	pod.Spec.NodeName = "node-a"
+	pod.Status.Phase = corev1.PodRunning
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: status}}
+	return pod
+}
+
+func newEvictionRequest(name, subResource string) admission.Request {
+	return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Name:        name,
+		Namespace:   "default",
+		SubResource: subResource,
+	}}
+}
+
+func TestPodEvictionValidatorHandle(t *testing.T) {
+	tfsc := &threefsv1.ThreeFsCluster{}
+	tfsc.Name = "test"
+	tfsc.Namespace = "default"
+	healthyTfsc := tfsc.DeepCopy()
+	healthyTfsc.Status.UnhealthyTargetStatus = map[string][]threefsv1.TargetStatus{"node_a": {}, "node_b": {}}
+	unhealthyTfsc := tfsc.DeepCopy()
+	unhealthyTfsc.Status.UnhealthyTargetStatus = map[string][]threefsv1.TargetStatus{"node_a": {}, "node_b": {{TargetId: "101000200101", Status: "OFFLINE"}}}
+	plainPod := &corev1.Pod{}
+	plainPod.Name = "plain"
+	plainPod.Namespace = "default"
+
+	testCases := []struct {
+		name    string
+		objs    []client.Object
+		req     admission.Request
+		allowed bool
+	}{
+		{
+			name:    "not an eviction",
+			objs:    []client.Object{newComponentPod("meta-a", "meta", constant.ThreeFSMetaDeployKey, true)},
+			req:     newEvictionRequest("meta-a", ""),
+			allowed: true,
+		},
+		{
+			name:    "pod not found",
+			req:     newEvictionRequest("meta-a", "eviction"),
+			allowed: true,
+		},
+		{
+			name:    "not a 3fs pod",
+			objs:    []client.Object{plainPod},
+			req:     newEvictionRequest("plain", "eviction"),
+			allowed: true,
+		},
+		{
+			name: "peer meta ready",
+			objs: []client.Object{tfsc,
+				newComponentPod("meta-a", "meta", constant.ThreeFSMetaDeployKey, true),
+				newComponentPod("meta-b", "meta", constant.ThreeFSMetaDeployKey, true)},
+			req:     newEvictionRequest("meta-a", "eviction"),
+			allowed: true,
+		},
+		{
+			name: "peer meta not ready",
+			objs: []client.Object{tfsc,
+				newComponentPod("meta-a", "meta", constant.ThreeFSMetaDeployKey, true),
+				newComponentPod("meta-b", "meta", constant.ThreeFSMetaDeployKey, false)},
+			req:     newEvictionRequest("meta-a", "eviction"),
+			allowed: false,
+		},
+		{
+			name: "evicted meta not ready",
+			objs: []client.Object{tfsc,
+				newComponentPod("meta-a", "meta", constant.ThreeFSMetaDeployKey, false),
+				newComponentPod("meta-b", "meta", constant.ThreeFSMetaDeployKey, false)},
+			req:     newEvictionRequest("meta-a", "eviction"),
+			allowed: true,
+		},
+		{
+			name:    "cluster not found",
+			objs:    []client.Object{newComponentPod("meta-a", "meta", constant.ThreeFSMetaDeployKey, true), newComponentPod("meta-b", "meta", constant.ThreeFSMetaDeployKey, false)},
+			req:     newEvictionRequest("meta-a", "eviction"),
+			allowed: true,
+		},
+		{
+			name:    "storage without target states",
+			objs:    []client.Object{tfsc, newComponentPod("storage-a", "storage", constant.ThreeFSStorageDeployKey, true)},
+			req:     newEvictionRequest("storage-a", "eviction"),
+			allowed: false,
+		},
+		{
+			name:    "storage with healthy targets",
+			objs:    []client.Object{healthyTfsc, newComponentPod("storage-a", "storage", constant.ThreeFSStorageDeployKey, true)},
+			req:     newEvictionRequest("storage-a", "eviction"),
+			allowed: true,
+		},
+		{
+			name:    "storage with unhealthy targets on another node",
+			objs:    []client.Object{unhealthyTfsc, newComponentPod("storage-a", "storage", constant.ThreeFSStorageDeployKey, true)},
+			req:     newEvictionRequest("storage-a", "eviction"),
+			allowed: false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			_ = clientgoscheme.AddToScheme(scheme)
+			_ = threefsv1.AddToScheme(scheme)
+			v := &PodEvictionValidator{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(tc.objs...).Build()}
+			assert.Equal(t, tc.allowed, v.Handle(context.Background(), tc.req).Allowed)
+		})
+	}
+}
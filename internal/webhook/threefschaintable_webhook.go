@@ -21,7 +21,9 @@ import (
 	"fmt"
 	"github.com/aliyun/kvc-3fs-operator/api/v1"
 	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/aliyun/kvc-3fs-operator/internal/storage"
 	"github.com/aliyun/kvc-3fs-operator/internal/utils"
+	"github.com/aliyun/kvc-3fs-operator/internal/validation"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -64,7 +66,16 @@ func (r *VcnsFsChaintableValidator) ValidateCreate(ctx context.Context, obj runt
 	if err := r.Client.Get(ctx, client.ObjectKey{Name: vfsct.Spec.ThreeFsClusterName, Namespace: vfsct.Spec.ThreeFsClusterNamespace}, vfsc); err != nil {
 		return nil, fmt.Errorf("get threefsChanintable %s failed: %v", vfsct.Spec.ThreeFsClusterName, err)
 	}
+	if vfsc.Status.DiskExpansion.Phase != "" {
+		return nil, fmt.Errorf("threefsCluster %s is adding target paths (%s), retry later", vfsc.Name, vfsc.Status.DiskExpansion.Phase)
+	}
+	if vfsct.Spec.ChainTableId != "" {
+		if _, ok := storage.GetChainTable(vfsc.Spec, vfsct.Spec.ChainTableId); !ok {
+			return nil, fmt.Errorf("threefsChanintable job %s chain table %s is not found in threefsCluster %s", vfsct.Name, vfsct.Spec.ChainTableId, vfsc.Name)
+		}
+	}
 
+	warnings := admission.Warnings{}
 	if vfsc.Status.NodesInfo.StorageBackupNodes != nil && len(vfsc.Status.NodesInfo.StorageBackupNodes) > 0 {
 		for _, node := range vfsct.Spec.NewNode {
 			if !utils.StrListContains(vfsc.Status.NodesInfo.StorageBackupNodes, node) {
@@ -78,17 +89,51 @@ func (r *VcnsFsChaintableValidator) ValidateCreate(ctx context.Context, obj runt
 		if vfsct.Labels != nil && vfsct.Labels[constant.ThreeDebugMode] == "true" {
 			addSize = 2
 		}
-		if vfsct.Spec.NewNode == nil || len(vfsct.Spec.NewNode) < addSize || len(vfsct.Spec.NewNode) < vfsc.Spec.Storage.Replica {
+		if vfsct.Spec.NewNode == nil || len(vfsct.Spec.NewNode) < addSize {
 			klog.Errorf("threefsChanintable job %s newNode is less then %d", vfsct.Name, addSize)
 			return nil, fmt.Errorf("threefsChanintable job %s newNode is less then 3", vfsct.Name)
 		}
-		targetNum := len(vfsct.Spec.NewNode) * len(vfsc.Spec.Storage.TargetPaths) * vfsc.Spec.Storage.TargetPerDisk
-		if targetNum%vfsc.Spec.Storage.Replica != 0 {
-			return nil, fmt.Errorf("threefsChanintable job %s newNode number is not valid", vfsct.Name)
+		// data placement runs for each group of new nodes with the same disk layout, with redundancy of the chain
+		// table which the group is placed into
+		groups, err := storage.GroupStorageNodes(r.Client, vfsc.Spec.Storage, vfsct.Spec.NewNode)
+		if err != nil {
+			return nil, err
+		}
+		chainNums := make(map[string]int)
+		for _, group := range groups {
+			table, ok := storage.GetNodeGroupChainTable(vfsc.Spec, group.Group)
+			if !ok {
+				return nil, fmt.Errorf("threefsChanintable job %s node group %q of newNode is not placed into any chain table", vfsct.Name, group.Group)
+			}
+			if vfsct.Spec.ChainTableId != "" && table.Id != vfsct.Spec.ChainTableId {
+				return nil, fmt.Errorf("threefsChanintable job %s node group %q of newNode is placed into chain table %s, not %s", vfsct.Name, group.Group, table.Id, vfsct.Spec.ChainTableId)
+			}
+			groupSize := storage.GetGroupSize(table.Storage)
+			targetNum := len(group.Nodes) * group.NumDisks * group.TargetPerDisk
+			if len(group.Nodes) < groupSize || targetNum%groupSize != 0 {
+				return nil, fmt.Errorf("threefsChanintable job %s newNode number %d of node group %q is not valid for %s group size %d",
+					vfsct.Name, len(group.Nodes), group.Group, storage.GetRedundancyType(table.Storage), groupSize)
+			}
+			if vfsc.Spec.Storage.TopologyKey != "" {
+				nodeDomains := make(map[string]string)
+				for _, node := range group.Nodes {
+					domain, err := storage.GetNodeDomain(r.Client, vfsc.Spec.Storage, node)
+					if err != nil {
+						return nil, err
+					}
+					nodeDomains[node] = domain
+				}
+				if err := storage.ValidateDomainSpread(nodeDomains, groupSize); err != nil {
+					return nil, fmt.Errorf("threefsChanintable job %s newNode of node group %q can not be spread across failure domains %s: %v",
+						vfsct.Name, group.Group, vfsc.Spec.Storage.TopologyKey, err)
+				}
+			}
+			chainNums[table.Id] += storage.GetChainNum(table.Storage, targetNum)
 		}
-		chainNum := targetNum / vfsc.Spec.Storage.Replica
-		if chainNum < vfsc.Spec.StripeSize {
-			return nil, fmt.Errorf("stripe size must be less or eqaul than chain num")
+		for id, chainNum := range chainNums {
+			if table, _ := storage.GetChainTable(vfsc.Spec, id); chainNum < table.StripeSize {
+				return nil, fmt.Errorf("stripe size of chain table %s must be less or eqaul than chain num", id)
+			}
 		}
 	} else if vfsct.Spec.Type == constant.ThreeFSChainTableTypeDelete {
 		if vfsct.Spec.OldNode == nil {
@@ -101,6 +146,46 @@ func (r *VcnsFsChaintableValidator) ValidateCreate(ctx context.Context, obj runt
 			klog.Errorf("threefsChanintable job %s oldNode or newNode is empty or more then 1", vfsct.Name)
 			return nil, fmt.Errorf("threefsChanintable job %s oldNode or newNode is empty or more then 1", vfsct.Name)
 		}
+		// old node may be removed from the cluster already, check disk layout only when it exists
+		if oldLayout, err := storage.GetNodeLayout(r.Client, vfsc.Spec.Storage, vfsct.Spec.OldNode[0]); err == nil {
+			newLayout, err := storage.GetNodeLayout(r.Client, vfsc.Spec.Storage, vfsct.Spec.NewNode[0])
+			if err != nil {
+				return nil, err
+			}
+			if oldLayout.Group != newLayout.Group {
+				return nil, fmt.Errorf("threefsChanintable job %s newNode %s is in node group %q, but oldNode %s is in node group %q",
+					vfsct.Name, vfsct.Spec.NewNode[0], newLayout.Group, vfsct.Spec.OldNode[0], oldLayout.Group)
+			}
+			if table, ok := storage.GetNodeGroupChainTable(vfsc.Spec, oldLayout.Group); vfsct.Spec.ChainTableId != "" && (!ok || table.Id != vfsct.Spec.ChainTableId) {
+				return nil, fmt.Errorf("threefsChanintable job %s oldNode %s is not in chain table %s", vfsct.Name, vfsct.Spec.OldNode[0], vfsct.Spec.ChainTableId)
+			}
+			// targets of the new node join chains of the old node, which are spread across failure domains
+			if oldDomain, err := storage.GetNodeDomain(r.Client, vfsc.Spec.Storage, vfsct.Spec.OldNode[0]); err == nil {
+				newDomain, err := storage.GetNodeDomain(r.Client, vfsc.Spec.Storage, vfsct.Spec.NewNode[0])
+				if err != nil {
+					return nil, err
+				}
+				if oldDomain != newDomain {
+					warnings = append(warnings, fmt.Sprintf("newNode %s is in failure domain %q, but oldNode %s is in %q, chains of oldNode may have targets in the same failure domain",
+						vfsct.Spec.NewNode[0], newDomain, vfsct.Spec.OldNode[0], oldDomain))
+				}
+			}
+		}
+	} else if vfsct.Spec.Type == constant.ThreeFSChainTableTypeRestore {
+		if len(vfsct.Spec.NewNode) != 0 || len(vfsct.Spec.OldNode) != 0 {
+			return nil, fmt.Errorf("threefsChanintable job %s oldNode and newNode must be empty for %s", vfsct.Name, vfsct.Spec.Type)
+		}
+		snapshot := &corev1.ConfigMap{}
+		if err := r.Client.Get(ctx, client.ObjectKey{Name: vfsct.Spec.Snapshot, Namespace: vfsc.Namespace}, snapshot); err != nil {
+			klog.Errorf("threefsChanintable job %s get snapshot %s failed: %v", vfsct.Name, vfsct.Spec.Snapshot, err)
+			return nil, fmt.Errorf("threefsChanintable job %s get snapshot %s failed: %v", vfsct.Name, vfsct.Spec.Snapshot, err)
+		}
+		if snapshot.Labels[constant.ThreeFSChainTableSnapshotKey] != vfsc.Name {
+			return nil, fmt.Errorf("threefsChanintable job %s snapshot %s is not a chain table snapshot of %s", vfsct.Name, vfsct.Spec.Snapshot, vfsc.Name)
+		}
+		if _, ok := storage.ParseChainTableSnapshotFiles(snapshot)[vfsct.Spec.ChainTableId]; vfsct.Spec.ChainTableId != "" && !ok {
+			return nil, fmt.Errorf("threefsChanintable job %s snapshot %s has no chain table %s", vfsct.Name, vfsct.Spec.Snapshot, vfsct.Spec.ChainTableId)
+		}
 	} else {
 		klog.Errorf("threefsChanintable job %s type %s is invalid", vfsct.Name, vfsct.Spec.Type)
 	}
@@ -146,17 +231,7 @@ func (r *VcnsFsChaintableValidator) ValidateCreate(ctx context.Context, obj runt
 		}
 	}
 
-	vfsctList := &v1.ThreeFsChainTableList{}
-	if err := r.Client.List(ctx, vfsctList); err != nil {
-		return nil, err
-	}
-	for _, item := range vfsctList.Items {
-		if item.Status.Phase != constant.ThreeFSChainTableFinishedStatus {
-			return nil, fmt.Errorf("ThreeFsChainTable %s is processing now, not allowed to be created new ThreeFsChainTable", item.Name)
-		}
-	}
-
-	return nil, nil
+	return warnings, nil
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
@@ -172,7 +247,16 @@ func (r *VcnsFsChaintableValidator) ValidateUpdate(ctx context.Context, oldObj r
 		return nil, fmt.Errorf("expected a ThreeFsChainTable object but got %T", newObj)
 	}
 
-	if !reflect.DeepEqual(oldvfsct.Spec, newvfsct.Spec) {
+	if oldvfsct.Spec.Abort && !newvfsct.Spec.Abort {
+		return nil, fmt.Errorf("threefsChanintable abort is not allowed to be reverted")
+	}
+
+	// only abort is allowed to be updated
+	oldSpec := oldvfsct.Spec.DeepCopy()
+	newSpec := newvfsct.Spec.DeepCopy()
+	oldSpec.Abort = false
+	newSpec.Abort = false
+	if !reflect.DeepEqual(oldSpec, newSpec) {
 		return nil, fmt.Errorf("threefsChanintable spec is not allowed to be updated")
 	}
 
@@ -192,8 +276,9 @@ func (r *VcnsFsChaintableValidator) ValidateDelete(ctx context.Context, obj runt
 		_, ok = vfsct.Labels[constant.ThreeDebugMode]
 	}
 
-	if !ok && vfsct.Status.Phase != constant.ThreeFSChainTableFinishedStatus {
-		return nil, fmt.Errorf("threefsChanintable status before finished is not allowed to be deleted")
+	// pending job has not touched the chain table yet, it can be removed from the queue
+	if !ok && !validation.IsTfsctPending(vfsct) && !validation.IsTfsctDone(vfsct) {
+		return nil, fmt.Errorf("threefsChanintable status before finished is not allowed to be deleted, set spec.abort to abort it first")
 	}
 
 	return nil, nil
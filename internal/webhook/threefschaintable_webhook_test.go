@@ -0,0 +1,76 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	threefsv1 "github.com/aliyun/kvc-3fs-operator/api/v1"
+	"github.com/aliyun/kvc-3fs-operator/internal/constant"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newStorageDeploy(name, nodeName string) *appsv1.Deployment {
+	deploy := &appsv1.Deployment{}
+	deploy.Name = name
+	deploy.Namespace = "default"
+	deploy.Labels = map[string]string{constant.ThreeFSStorageDeployKey: "test"}
+	deploy.Spec.Template.Spec.NodeSelector = map[string]string{constant.KubernetesHostnameKey: nodeName}
+	return deploy
+}
+
+func newReplaceJob(name, phase, newNode, oldNode string) *threefsv1.ThreeFsChainTable {
+	job := threefsv1.NewThreeFsChainTable(name, "default").
+		WithThreeFsCluster("test", "default").
+		WithType(constant.ThreeFSChainTableTypeReplace).
+		WithNewNode([]string{newNode}).
+		WithOldNode([]string{oldNode})
+	job.Status.Phase = phase
+	return job
+}
+
+func TestChaintableValidateCreate(t *testing.T) {
+	tfsc := &threefsv1.ThreeFsCluster{}
+	tfsc.Name = "test"
+	tfsc.Namespace = "default"
+	objs := []client.Object{
+		tfsc,
+		newStorageDeploy("test-storage-1", "node-1"),
+		newStorageDeploy("test-storage-2", "node-2"),
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "backup-1"}},
+		newReplaceJob("running", constant.ThreeFSChainTableProcessingStatus, "backup-1", "node-1"),
+	}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = threefsv1.AddToScheme(scheme)
+	validator := &VcnsFsChaintableValidator{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()}
+
+	// job sharing nodes with an unfinished job is queued instead of rejected
+	_, err := validator.ValidateCreate(context.Background(), newReplaceJob("overlap", "", "backup-1", "node-2"))
+	assert.NoError(t, err)
+
+	_, err = validator.ValidateCreate(context.Background(), newReplaceJob("invalid", "", "backup-1", "node-3"))
+	assert.Error(t, err)
+}
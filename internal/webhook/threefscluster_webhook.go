@@ -21,11 +21,11 @@ import (
 	"fmt"
 	"github.com/aliyun/kvc-3fs-operator/api/v1"
 	"github.com/aliyun/kvc-3fs-operator/internal/constant"
-	"github.com/aliyun/kvc-3fs-operator/internal/controller"
 	"github.com/aliyun/kvc-3fs-operator/internal/fdb"
 	"github.com/aliyun/kvc-3fs-operator/internal/native_resources"
 	"github.com/aliyun/kvc-3fs-operator/internal/storage"
 	"github.com/aliyun/kvc-3fs-operator/internal/utils"
+	"github.com/aliyun/kvc-3fs-operator/internal/validation"
 	corev1 "k8s.io/api/core/v1"
 	k8serror "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -38,6 +38,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"strconv"
 	"strings"
 )
 
@@ -276,34 +277,50 @@ func (r *ThreeFsClusterValidator) ValidateCreate(ctx context.Context, obj runtim
 	}
 
 	// check tableid
-	if threefsCluster.Spec.ChainTableId != "1" {
-		return nil, fmt.Errorf("please set chaintableid to 1")
-	}
 	if threefsCluster.Spec.StripeSize <= 0 {
 		return nil, fmt.Errorf("stripe size must be greater than 0")
 	}
+	if err := validateChainTables(threefsCluster.Spec); err != nil {
+		return nil, err
+	}
+	if err := validateBackupNodePolicy(threefsCluster.Spec.Storage.BackupNodePolicy); err != nil {
+		return nil, err
+	}
+	if err := validation.ValidateAutoRepair(threefsCluster.Spec.AutoRepair); err != nil {
+		return nil, err
+	}
 
 	// check storage node
 	storageNodes, err := storage.FilterStorageNode(r.Client)
 	if err != nil {
 		return nil, err
 	}
-	targetNum := len(storageNodes) * len(threefsCluster.Spec.Storage.TargetPaths) * threefsCluster.Spec.Storage.TargetPerDisk
-	if targetNum%threefsCluster.Spec.Storage.Replica != 0 {
-		return nil, fmt.Errorf("threefsCluster %s args is not valid", threefsCluster.Name)
-	}
-	chainNum := targetNum / threefsCluster.Spec.Storage.Replica
-	if chainNum < threefsCluster.Spec.StripeSize {
-		return nil, fmt.Errorf("stripe size must be less or equal than chain num")
-	}
-
-	// check fdb
-	fdbNodes, err := fdb.FilterFdbNodes(r.Client)
+	chainNums, err := r.validateStorageLayout(threefsCluster, storageNodes)
 	if err != nil {
 		return nil, err
 	}
-	if fdbNodes == nil || len(fdbNodes) == 0 {
-		return nil, fmt.Errorf("fdb nodes pool is empty")
+	// chain tables without nodes are placed when nodes of their group are added, except the default table
+	defaultTable := storage.GetDefaultChainTable(threefsCluster.Spec)
+	for _, table := range storage.GetChainTables(threefsCluster.Spec) {
+		if chainNums[table.Id] == 0 && table.Id != defaultTable.Id {
+			continue
+		}
+		if chainNums[table.Id] < table.StripeSize {
+			return nil, fmt.Errorf("stripe size of chain table %s must be less or equal than chain num", table.Id)
+		}
+	}
+
+	// check fdb, pods of external-operator mode are scheduled by the FoundationDB kubernetes operator and
+	// external mode uses an existing fdb cluster
+	var fdbNodes []string
+	if fdb.IsBuiltinMode(threefsCluster.Spec.Fdb) {
+		fdbNodes, err = fdb.FilterFdbNodes(r.Client)
+		if err != nil {
+			return nil, err
+		}
+		if fdbNodes == nil || len(fdbNodes) == 0 {
+			return nil, fmt.Errorf("fdb nodes pool is empty")
+		}
 	}
 
 	fdbThresReplica := 2
@@ -313,11 +330,18 @@ func (r *ThreeFsClusterValidator) ValidateCreate(ctx context.Context, obj runtim
 	if threefsCluster.Spec.Fdb.StorageReplicas < fdbThresReplica {
 		return nil, fmt.Errorf("storage replicas must be greater than 1 for fault tolerance")
 	}
-	if threefsCluster.Spec.Fdb.StorageReplicas > (len(fdbNodes)+1)/2 {
+	if fdb.IsBuiltinMode(threefsCluster.Spec.Fdb) {
+		if threefsCluster.Spec.Fdb.StorageReplicas > (len(fdbNodes)+1)/2 {
+			return nil, fmt.Errorf("n replica whith at least 2n-1 node is recommended")
+		}
+		if threefsCluster.Spec.Fdb.ClusterSize > len(fdbNodes) {
+			return nil, fmt.Errorf("clustersize is larger then nodes pool")
+		}
+	} else if fdb.IsExternalOperatorMode(threefsCluster.Spec.Fdb) && threefsCluster.Spec.Fdb.ClusterSize < 2*threefsCluster.Spec.Fdb.StorageReplicas-1 {
 		return nil, fmt.Errorf("n replica whith at least 2n-1 node is recommended")
 	}
-	if threefsCluster.Spec.Fdb.ClusterSize > len(fdbNodes) {
-		return nil, fmt.Errorf("clustersize is larger then nodes pool")
+	if err := validation.ValidateFdbSpec(threefsCluster.Spec.Fdb); err != nil {
+		return nil, err
 	}
 	nodeList := &corev1.NodeList{}
 	if err := r.Client.List(context.Background(), nodeList); err != nil {
@@ -329,16 +353,16 @@ func (r *ThreeFsClusterValidator) ValidateCreate(ctx context.Context, obj runtim
 	}
 
 	// check meta
-	if threefsCluster.Spec.Meta.Replica > len(storageNodes) {
-		return nil, fmt.Errorf("meta replica must be equal or less than storage nodes pool")
+	if err := r.validateServiceReplica("meta", threefsCluster.Spec.Meta.Replica, threefsCluster.Spec.Meta.NodeSelector, storageNodes); err != nil {
+		return nil, err
 	}
 	if threefsCluster.Spec.Meta.RdmaPort == threefsCluster.Spec.Storage.RdmaPort || threefsCluster.Spec.Meta.TcpPort == threefsCluster.Spec.Storage.TcpPort {
 		return nil, fmt.Errorf("mgmtd port must be different from storage port")
 	}
 
 	// check mgmtd
-	if threefsCluster.Spec.Mgmtd.Replica > len(storageNodes) {
-		return nil, fmt.Errorf("mgmtd replica must be equal or less than storage nodes pool")
+	if err := r.validateServiceReplica("mgmtd", threefsCluster.Spec.Mgmtd.Replica, threefsCluster.Spec.Mgmtd.NodeSelector, storageNodes); err != nil {
+		return nil, err
 	}
 	if threefsCluster.Spec.Mgmtd.RdmaPort == threefsCluster.Spec.Storage.RdmaPort || threefsCluster.Spec.Mgmtd.TcpPort == threefsCluster.Spec.Storage.TcpPort {
 		return nil, fmt.Errorf("mgmtd port must be different from storage port")
@@ -348,8 +372,8 @@ func (r *ThreeFsClusterValidator) ValidateCreate(ctx context.Context, obj runtim
 	if storageNodes == nil || len(storageNodes) == 0 {
 		return nil, fmt.Errorf("storage nodes pool is empty")
 	}
-	if threefsCluster.Spec.Storage.Replica > len(storageNodes) {
-		return nil, fmt.Errorf("storage replica must be equal or less than storage nodes pool")
+	if storage.GetGroupSize(defaultTable.Storage) > len(storageNodes) {
+		return nil, fmt.Errorf("storage replica or EC group size must be equal or less than storage nodes pool")
 	}
 
 	// check fdb nodes are different from storage nodes
@@ -366,6 +390,169 @@ func (r *ThreeFsClusterValidator) ValidateCreate(ctx context.Context, obj runtim
 	return nil, nil
 }
 
+// validateChainTables checks chain tables of the cluster, each node group can only be placed into one table and
+// the default table ChainTableId must be one of the tables
+func validateChainTables(spec v1.ThreeFsClusterSpec) error {
+	if id, err := strconv.Atoi(spec.ChainTableId); err != nil || id <= 0 {
+		return fmt.Errorf("chain table id %s must be a positive integer", spec.ChainTableId)
+	}
+	groupNames := []string{""}
+	for _, group := range spec.Storage.NodeGroups {
+		groupNames = append(groupNames, group.Name)
+	}
+
+	ids := make([]string, 0)
+	groups := make([]string, 0)
+	paths := make([]string, 0)
+	for _, tableSpec := range spec.ChainTables {
+		if id, err := strconv.Atoi(tableSpec.Id); err != nil || id <= 0 || utils.StrListContains(ids, tableSpec.Id) {
+			return fmt.Errorf("chain table id %s is not a positive integer or duplicated", tableSpec.Id)
+		}
+		ids = append(ids, tableSpec.Id)
+		if !utils.StrListContains(groupNames, tableSpec.NodeGroup) {
+			return fmt.Errorf("chain table %s node group %s is not found in storage nodeGroups", tableSpec.Id, tableSpec.NodeGroup)
+		}
+		if utils.StrListContains(groups, tableSpec.NodeGroup) {
+			return fmt.Errorf("chain table %s node group %q is already placed into another chain table", tableSpec.Id, tableSpec.NodeGroup)
+		}
+		groups = append(groups, tableSpec.NodeGroup)
+		if tableSpec.StripeSize < 0 || tableSpec.ChunkSize < 0 {
+			return fmt.Errorf("chain table %s stripeSize and chunkSize can not be negative", tableSpec.Id)
+		}
+		if tableSpec.Path != "" {
+			if !filepath.IsAbs(tableSpec.Path) || filepath.Clean(tableSpec.Path) == "/" || utils.StrListContains(paths, filepath.Clean(tableSpec.Path)) {
+				return fmt.Errorf("chain table %s path %s must be a unique absolute path other than /", tableSpec.Id, tableSpec.Path)
+			}
+			paths = append(paths, filepath.Clean(tableSpec.Path))
+		}
+	}
+	if len(spec.ChainTables) > 0 && !utils.StrListContains(ids, spec.ChainTableId) {
+		return fmt.Errorf("chain table id %s is not found in chainTables", spec.ChainTableId)
+	}
+
+	for _, table := range storage.GetChainTables(spec) {
+		if err := storage.ValidateRedundancy(table.Storage, table.StripeSize); err != nil {
+			return fmt.Errorf("chain table %s is not valid: %v", table.Id, err)
+		}
+	}
+	return nil
+}
+
+// validateServiceReplica checks replica of meta or mgmtd is not more than nodes matching its node selector, or
+// storage nodes if the selector is empty
+func (r *ThreeFsClusterValidator) validateServiceReplica(component string, replica int, selector map[string]string, storageNodes []string) error {
+	if replica < 1 {
+		return fmt.Errorf("%s replica must be greater than 0", component)
+	}
+	if len(selector) == 0 {
+		if replica > len(storageNodes) {
+			return fmt.Errorf("%s replica must be equal or less than storage nodes pool", component)
+		}
+		return nil
+	}
+	nodeList := &corev1.NodeList{}
+	if err := r.Client.List(context.Background(), nodeList, client.MatchingLabels(selector)); err != nil {
+		return err
+	}
+	if replica > len(nodeList.Items) {
+		return fmt.Errorf("%s replica must be equal or less than %d nodes matching nodeSelector", component, len(nodeList.Items))
+	}
+	return nil
+}
+
+// validateBackupNodePolicy checks strategies of the backup node policy are known and not duplicated
+func validateBackupNodePolicy(policy v1.BackupNodePolicy) error {
+	strategies := []string{constant.BackupNodeStrategySameZone, constant.BackupNodeStrategyMostFreeDisk, constant.BackupNodeStrategyLeastRecentlyFailed}
+	for idx, strategy := range policy.Strategies {
+		if !utils.StrListContains(strategies, strategy) {
+			return fmt.Errorf("storage backupNodePolicy strategy %q is not one of %v", strategy, strategies)
+		}
+		if utils.StrListContains(policy.Strategies[:idx], strategy) {
+			return fmt.Errorf("storage backupNodePolicy strategy %q is duplicated", strategy)
+		}
+	}
+	if policy.FailureCooldownMinutes < 0 {
+		return fmt.Errorf("storage backupNodePolicy failureCooldownMinutes can not be negative")
+	}
+	return nil
+}
+
+// validateStorageLayout checks node groups and disk layout of storage nodes, data placement runs for each group
+// of nodes with the same layout, so each group needs enough nodes for replica or EC group of its chain table.
+// It returns the number of chains of each chain table
+func (r *ThreeFsClusterValidator) validateStorageLayout(threefsCluster *v1.ThreeFsCluster, storageNodes []string) (map[string]int, error) {
+	spec := threefsCluster.Spec.Storage
+	groupNames := make([]string, 0)
+	for _, group := range spec.NodeGroups {
+		if group.Name == "" || utils.StrListContains(groupNames, group.Name) {
+			return nil, fmt.Errorf("storage node group name %q is empty or duplicated", group.Name)
+		}
+		groupNames = append(groupNames, group.Name)
+		if len(group.NodeSelector) == 0 || len(group.TargetPaths) == 0 {
+			return nil, fmt.Errorf("storage node group %s nodeSelector and targetPaths can not be empty", group.Name)
+		}
+		if group.TargetPerDisk < 0 || group.Weight < 0 {
+			return nil, fmt.Errorf("storage node group %s targetPerDisk and weight can not be negative", group.Name)
+		}
+	}
+	if err := storage.ValidateGroupWeights(spec); err != nil {
+		return nil, err
+	}
+
+	groupNodes := make(map[string]int)
+	groupDomains := make(map[string]map[string]string)
+	for _, nodeName := range storageNodes {
+		node := &corev1.Node{}
+		if err := r.Client.Get(context.Background(), client.ObjectKey{Name: nodeName}, node); err != nil {
+			return nil, err
+		}
+		matched := storage.MatchNodeGroups(spec.NodeGroups, node.Labels)
+		if len(matched) > 1 {
+			return nil, fmt.Errorf("storage node %s matches more than one node group: %v", nodeName, matched)
+		}
+		group := ""
+		if len(matched) == 1 {
+			group = matched[0]
+		}
+		groupNodes[group]++
+		if spec.TopologyKey != "" {
+			domain := node.Labels[spec.TopologyKey]
+			if domain == "" {
+				return nil, fmt.Errorf("storage node %s has no topology label %s", nodeName, spec.TopologyKey)
+			}
+			if groupDomains[group] == nil {
+				groupDomains[group] = make(map[string]string)
+			}
+			groupDomains[group][nodeName] = domain
+		}
+	}
+
+	chainNums := make(map[string]int)
+	for group, nodeNum := range groupNodes {
+		table, ok := storage.GetNodeGroupChainTable(threefsCluster.Spec, group)
+		if !ok {
+			// targets are not created on nodes of the group
+			continue
+		}
+		layout := storage.GetGroupLayout(spec, group)
+		groupSize := storage.GetGroupSize(table.Storage)
+		if nodeNum < groupSize {
+			return nil, fmt.Errorf("storage node group %q has %d nodes, less than %s group size %d", group, nodeNum, storage.GetRedundancyType(table.Storage), groupSize)
+		}
+		targetNum := nodeNum * len(layout.TargetPaths) * layout.TargetPerDisk
+		if targetNum%groupSize != 0 {
+			return nil, fmt.Errorf("threefsCluster %s args is not valid, target number %d of node group %q is not a multiple of %s group size", threefsCluster.Name, targetNum, group, storage.GetRedundancyType(table.Storage))
+		}
+		if spec.TopologyKey != "" {
+			if err := storage.ValidateDomainSpread(groupDomains[group], groupSize); err != nil {
+				return nil, fmt.Errorf("storage node group %q can not be spread across failure domains %s: %v", group, spec.TopologyKey, err)
+			}
+		}
+		chainNums[table.Id] += storage.GetChainNum(table.Storage, targetNum)
+	}
+	return chainNums, nil
+}
+
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
 func (r *ThreeFsClusterValidator) ValidateUpdate(ctx context.Context, oldObj runtime.Object, newObj runtime.Object) (admission.Warnings, error) {
 
@@ -385,7 +572,130 @@ func (r *ThreeFsClusterValidator) ValidateUpdate(ctx context.Context, oldObj run
 		klog.Infof("threefsCluster %s spec is changed, check", oldVfsc.Name)
 	}
 
-	// check in operator, no check here
+	// check target paths, only appending new paths is supported
+	oldPaths := oldVfsc.Spec.Storage.TargetPaths
+	newPaths := newVfsc.Spec.Storage.TargetPaths
+	if len(newPaths) < len(oldPaths) || !reflect.DeepEqual(oldPaths, newPaths[:len(oldPaths)]) {
+		return nil, fmt.Errorf("threefsCluster %s targetPaths can only be appended, existing paths can not be removed or reordered", newVfsc.Name)
+	}
+	for idx, path := range newPaths {
+		if utils.StrListContains(newPaths[:idx], path) {
+			return nil, fmt.Errorf("threefsCluster %s targetPath %s is duplicated", newVfsc.Name, path)
+		}
+	}
+	if !reflect.DeepEqual(oldVfsc.Spec.Storage.Redundancy, newVfsc.Spec.Storage.Redundancy) || oldVfsc.Spec.Storage.Replica != newVfsc.Spec.Storage.Replica {
+		return nil, fmt.Errorf("threefsCluster %s storage replica and redundancy can not be changed", newVfsc.Name)
+	}
+	if oldVfsc.Spec.Storage.TopologyKey != newVfsc.Spec.Storage.TopologyKey {
+		return nil, fmt.Errorf("threefsCluster %s storage topologyKey can not be changed", newVfsc.Name)
+	}
+	if !reflect.DeepEqual(oldVfsc.Spec.Mgmtd.NodeSelector, newVfsc.Spec.Mgmtd.NodeSelector) || oldVfsc.Spec.Mgmtd.Replica != newVfsc.Spec.Mgmtd.Replica {
+		return nil, fmt.Errorf("threefsCluster %s mgmtd replica and nodeSelector can not be changed", newVfsc.Name)
+	}
+	if !reflect.DeepEqual(oldVfsc.Spec.Meta.NodeSelector, newVfsc.Spec.Meta.NodeSelector) {
+		return nil, fmt.Errorf("threefsCluster %s meta nodeSelector can not be changed", newVfsc.Name)
+	}
+	if oldVfsc.Spec.Meta.Replica != newVfsc.Spec.Meta.Replica {
+		if err := r.validateServiceReplica("meta", newVfsc.Spec.Meta.Replica, newVfsc.Spec.Meta.NodeSelector, oldVfsc.Status.NodesInfo.StorageNodes); err != nil {
+			return nil, err
+		}
+	}
+	// redundancy of external mode is checked by the controller against the external fdb cluster
+	if !fdb.IsExternalMode(newVfsc.Spec.Fdb) &&
+		(oldVfsc.Spec.Fdb.StorageReplicas != newVfsc.Spec.Fdb.StorageReplicas || oldVfsc.Spec.Fdb.ClusterSize != newVfsc.Spec.Fdb.ClusterSize) {
+		if newVfsc.Spec.Fdb.StorageReplicas < 2 && (newVfsc.Labels == nil || newVfsc.Labels[constant.ThreeDebugMode] != "true") {
+			return nil, fmt.Errorf("storage replicas must be greater than 1 for fault tolerance")
+		}
+		fdbNodeNum := newVfsc.Spec.Fdb.ClusterSize
+		if fdb.IsBuiltinMode(newVfsc.Spec.Fdb) {
+			fdbNodes, err := fdb.FilterFdbNodes(r.Client)
+			if err != nil {
+				return nil, err
+			}
+			fdbNodeNum = len(fdbNodes)
+		}
+		if err := validation.ValidateFdbReconfiguration(fdbNodeNum, newVfsc.Spec.Fdb.StorageReplicas, newVfsc.Spec.Fdb.ClusterSize); err != nil {
+			return nil, err
+		}
+		if phase := oldVfsc.Status.FdbReconfiguration.Phase; phase != "" && phase != constant.FdbReconfigureCompletedStatus {
+			return nil, fmt.Errorf("threefsCluster %s fdb is reconfiguring now, retry later", newVfsc.Name)
+		}
+	}
+	if fdb.IsBuiltinMode(oldVfsc.Spec.Fdb) != fdb.IsBuiltinMode(newVfsc.Spec.Fdb) ||
+		(!fdb.IsBuiltinMode(oldVfsc.Spec.Fdb) && oldVfsc.Spec.Fdb.Mode != newVfsc.Spec.Fdb.Mode) {
+		return nil, fmt.Errorf("threefsCluster %s fdb mode can not be changed", newVfsc.Name)
+	}
+	if oldVfsc.Spec.Fdb.StorageEngine != newVfsc.Spec.Fdb.StorageEngine {
+		return nil, fmt.Errorf("threefsCluster %s fdb storageEngine can not be changed", newVfsc.Name)
+	}
+	if oldVersion, newVersion := fdb.GetFdbVersion(oldVfsc.Spec.Fdb), fdb.GetFdbVersion(newVfsc.Spec.Fdb); oldVersion != newVersion {
+		if err := fdb.ValidateVersionChange(oldVersion, newVersion); err != nil {
+			return nil, err
+		}
+		if phase := oldVfsc.Status.FdbUpgrade.Phase; phase != "" && phase != constant.FdbUpgradeCompletedStatus {
+			return nil, fmt.Errorf("threefsCluster %s fdb is upgrading now, retry later", newVfsc.Name)
+		}
+	}
+	if fdb.IsTLSEnabled(oldVfsc.Spec.Fdb) != fdb.IsTLSEnabled(newVfsc.Spec.Fdb) {
+		return nil, fmt.Errorf("threefsCluster %s fdb tls enabled can not be changed", newVfsc.Name)
+	}
+	if fdb.IsTLSEnabled(newVfsc.Spec.Fdb) && oldVfsc.Spec.Fdb.TLS.CertificateRef != newVfsc.Spec.Fdb.TLS.CertificateRef {
+		return nil, fmt.Errorf("threefsCluster %s fdb tls certificateRef can not be changed", newVfsc.Name)
+	}
+	if err := validation.ValidateFdbSpec(newVfsc.Spec.Fdb); err != nil {
+		return nil, err
+	}
+	if oldVfsc.Spec.ChainTableId != newVfsc.Spec.ChainTableId {
+		return nil, fmt.Errorf("threefsCluster %s chainTableId can not be changed", newVfsc.Name)
+	}
+	// only appending new chain tables is supported
+	oldTables := oldVfsc.Spec.ChainTables
+	newTables := newVfsc.Spec.ChainTables
+	if (len(oldTables) == 0) != (len(newTables) == 0) || len(newTables) < len(oldTables) || !reflect.DeepEqual(oldTables, newTables[:len(oldTables)]) {
+		return nil, fmt.Errorf("threefsCluster %s chainTables can only be appended, existing chain tables can not be changed", newVfsc.Name)
+	}
+	if err := validateChainTables(newVfsc.Spec); err != nil {
+		return nil, err
+	}
+	if err := validateBackupNodePolicy(newVfsc.Spec.Storage.BackupNodePolicy); err != nil {
+		return nil, err
+	}
+	if err := validation.ValidateAutoRepair(newVfsc.Spec.AutoRepair); err != nil {
+		return nil, err
+	}
+	if !reflect.DeepEqual(oldVfsc.Spec.Storage.NodeGroups, newVfsc.Spec.Storage.NodeGroups) {
+		return nil, fmt.Errorf("threefsCluster %s storage nodeGroups can not be changed", newVfsc.Name)
+	}
+	if len(newPaths) > len(oldPaths) {
+		for _, group := range newVfsc.Spec.Storage.NodeGroups {
+			if len(group.TargetPaths) > len(oldPaths) {
+				return nil, fmt.Errorf("threefsCluster %s targetPaths can not be appended, node group %s has more disks", newVfsc.Name, group.Name)
+			}
+		}
+		if oldVfsc.Status.DiskExpansion.Phase != "" {
+			return nil, fmt.Errorf("threefsCluster %s is adding target paths now, retry later", newVfsc.Name)
+		}
+		// only nodes without node group get the new paths
+		storageNodes := make([]string, 0)
+		for _, nodeName := range oldVfsc.Status.NodesInfo.StorageNodes {
+			layout, err := storage.GetNodeLayout(r.Client, oldVfsc.Spec.Storage, nodeName)
+			if err != nil {
+				return nil, err
+			}
+			if layout.Group == "" {
+				storageNodes = append(storageNodes, nodeName)
+			}
+		}
+		targetNum := len(storageNodes) * (len(newPaths) - len(oldPaths)) * newVfsc.Spec.Storage.TargetPerDisk
+		if table, ok := storage.GetNodeGroupChainTable(newVfsc.Spec, ""); ok {
+			groupSize := storage.GetGroupSize(table.Storage)
+			if len(storageNodes) > 0 && targetNum%groupSize != 0 {
+				return nil, fmt.Errorf("threefsCluster %s new target number %d is not a multiple of %s group size %d", newVfsc.Name, targetNum, storage.GetRedundancyType(table.Storage), groupSize)
+			}
+		}
+	}
+
+	// check others in operator
 	return nil, nil
 }
 
@@ -409,7 +719,7 @@ func (r *ThreeFsClusterValidator) ValidateDelete(ctx context.Context, obj runtim
 	}
 
 	// check if some vfsct are using threefsCluster
-	if controller.IsProcessingTfsctExisted(r.Client) {
+	if validation.IsProcessingTfsctExisted(r.Client) {
 		return nil, fmt.Errorf("threefsCluster %s is still in use by threefs chaintable, delete processing vfsct first", threefsCluster.Name)
 	}
 